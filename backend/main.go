@@ -18,12 +18,13 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"react-and-go/pkd/app"
 	"react-and-go/pkd/config"
-	"react-and-go/pkd/controller"
-	"react-and-go/pkd/cron"
 	"react-and-go/pkd/database"
 	"react-and-go/pkd/database/dbmigrate"
-	"react-and-go/pkd/messaging"
+	gsfile "react-and-go/pkd/gasstation/file"
+	"react-and-go/pkd/poller"
+	"react-and-go/pkd/token"
 	"runtime"
 	"syscall"
 	"time"
@@ -32,27 +33,39 @@ import (
 //go:embed public
 var embeddedFiles embed.FS
 
-func init() {
+func main() {
 	config.LoadEnvVariables()
 	database.ConnectToDB()
+	//'migrate up|down|status' runs the database migrations without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(dbmigrate.RunCommand(os.Args[2:]))
+	}
 	dbmigrate.MigrateDB()
-	messaging.Start()
-	cron.Start()
-}
-
-func main() {
+	myApp := app.NewGormApp(database.DB)
+	myApp.Wire()
+	//'importprices <directory>' imports the Tankerkoenig prices csv files of the directory
+	if len(os.Args) > 1 && os.Args[1] == "importprices" {
+		os.Exit(gsfile.RunImportCommand(os.Args[2:]))
+	}
+	//'fakelist [address] [tooManyRequestsEvery]' serves a fake of the Tankerkoenig list.php for tests of the price poller
+	if len(os.Args) > 1 && os.Args[1] == "fakelist" {
+		os.Exit(poller.RunFakeList(os.Args[2:], myApp.GasStationRepository.FindAllLocations()))
+	}
+	if err := token.CheckKeySecret(); err != nil {
+		log.Fatalf("Startup failed: %v\n", err)
+	}
 	updateThreadPoolSize()
 	quit := make(chan os.Signal, 1)
 	// kill (no param) default send syscall.SIGTERM
 	// kill -2 is syscall.SIGINT
 	// kill -9 is syscall.SIGKILL but can't be catch, so don't need add it
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	go controller.Start(getPublicFolder(embeddedFiles))
+	myApp.Start(getPublicFolder(embeddedFiles))
 
 	<-quit
 	log.Println("Shutting down server...")
 
-	messaging.Stop()
+	myApp.Stop()
 	time.Sleep(2 * time.Second)
 
 	log.Println("Server exiting")
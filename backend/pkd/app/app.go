@@ -0,0 +1,100 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package app
+
+import (
+	"io/fs"
+	"react-and-go/pkd/appuser"
+	"react-and-go/pkd/controller"
+	"react-and-go/pkd/cron"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/mail"
+	"react-and-go/pkd/messaging"
+	"react-and-go/pkd/notification"
+	unmodel "react-and-go/pkd/notification/model"
+	"react-and-go/pkd/poller"
+	"react-and-go/pkd/repository"
+	"react-and-go/pkd/repository/gormrepo"
+	"react-and-go/pkd/repository/memrepo"
+	"react-and-go/pkd/token"
+
+	"gorm.io/gorm"
+)
+
+type App struct {
+	GasStationRepository   repository.GasStationRepository
+	PriceRepository        repository.PriceRepository
+	AppUserRepository      repository.AppUserRepository
+	NotificationRepository repository.NotificationRepository
+	PollStateRepository    repository.PollStateRepository
+	QuarantineRepository   repository.QuarantineRepository
+	OutboxRepository       repository.OutboxRepository
+	AuditLogRepository     repository.AuditLogRepository
+	SessionRepository      repository.SessionRepository
+	SigningKeyRepository   repository.SigningKeyRepository
+	UserTokenRepository    repository.UserTokenRepository
+}
+
+func NewGormApp(db *gorm.DB) *App {
+	return &App{GasStationRepository: gormrepo.NewGasStationRepository(db), PriceRepository: gormrepo.NewPriceRepository(db),
+		AppUserRepository: gormrepo.NewAppUserRepository(db), NotificationRepository: gormrepo.NewNotificationRepository(db),
+		PollStateRepository: gormrepo.NewPollStateRepository(db), QuarantineRepository: gormrepo.NewQuarantineRepository(db),
+		OutboxRepository: gormrepo.NewOutboxRepository(db), AuditLogRepository: gormrepo.NewAuditLogRepository(db),
+		SessionRepository: gormrepo.NewSessionRepository(db), SigningKeyRepository: gormrepo.NewSigningKeyRepository(db),
+		UserTokenRepository: gormrepo.NewUserTokenRepository(db)}
+}
+
+// for tests without a database
+func NewInMemoryApp() *App {
+	outboxRepository := memrepo.NewOutboxRepository()
+	priceRepository := memrepo.NewPriceRepository(outboxRepository)
+	sessionRepository := memrepo.NewSessionRepository()
+	userTokenRepository := memrepo.NewUserTokenRepository()
+	notificationRepository := memrepo.NewNotificationRepository(outboxRepository)
+	return &App{GasStationRepository: memrepo.NewGasStationRepository(priceRepository), PriceRepository: priceRepository,
+		AppUserRepository: memrepo.NewAppUserRepository(sessionRepository, userTokenRepository, notificationRepository), NotificationRepository: notificationRepository,
+		PollStateRepository: memrepo.NewPollStateRepository(), QuarantineRepository: memrepo.NewQuarantineRepository(priceRepository),
+		OutboxRepository: outboxRepository, AuditLogRepository: memrepo.NewAuditLogRepository(),
+		SessionRepository: sessionRepository, SigningKeyRepository: memrepo.NewSigningKeyRepository(),
+		UserTokenRepository: userTokenRepository}
+}
+
+// hands the repositories to the packages that use them
+func (app *App) Wire() {
+	gasstation.SetRepositories(app.GasStationRepository, app.PriceRepository, app.QuarantineRepository)
+	appuser.SetRepositories(app.AppUserRepository, app.AuditLogRepository, app.SessionRepository, app.UserTokenRepository)
+	appuser.SetMailSender(mail.NewMailSender())
+	appuser.AddUserDataHandler(appuser.UserDataHandler{Name: "notifications", Export: notification.ExportUserData})
+	token.SetSessionCheck(appuser.SessionActive)
+	token.SetRepository(app.SigningKeyRepository)
+	notification.SetRepositories(app.NotificationRepository, app.OutboxRepository)
+	notification.SetOutboxHandler(unmodel.EvaluateAlertsKind, gasstation.EvaluateAlerts)
+	notification.SetOutboxHandler(unmodel.DeliverNotificationKind, notification.DeliverNotifications)
+	poller.SetRepository(app.PollStateRepository)
+}
+
+// loads the signing keys, starts the outbox dispatcher, connects to the message broker, starts the cron jobs and the http server
+func (app *App) Start(publicFolder fs.FS) {
+	token.RotateKeys()
+	notification.StartOutboxDispatcher()
+	messaging.Start()
+	cron.Start()
+	go controller.Start(publicFolder)
+}
+
+// the outbox is drained after the price sources are stopped
+func (app *App) Stop() {
+	poller.Stop()
+	messaging.Stop()
+	notification.StopOutboxDispatcher()
+}
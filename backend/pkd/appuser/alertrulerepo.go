@@ -0,0 +1,196 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package appuser
+
+import (
+	"log"
+	"math"
+	"react-and-go/pkd/appuser/aumodel"
+	"react-and-go/pkd/config"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type AlertRuleIn struct {
+	ID              uint
+	Username        string
+	Name            string
+	Latitude        float64
+	Longitude       float64
+	SearchRadius    float64
+	FuelType        string
+	TargetPrice     string
+	ActiveFrom      string
+	ActiveTo        string
+	Brands          []string
+	Active          bool
+	ReferenceType   string
+	ReferenceRadius float64
+	MinDifference   string
+}
+
+const maxAlertRulesPerUser = 20
+
+func FindAlertRules(username string) []aumodel.AlertRule {
+	appUser, err := FindByUsername(username)
+	if err != nil {
+		return []aumodel.AlertRule{}
+	}
+	return appUserRepository.FindAlertRules(appUser.Uuid)
+}
+
+func FindAllActiveAlertRules() map[string][]aumodel.AlertRule {
+	alertRules := appUserRepository.FindActiveAlertRules()
+	result := make(map[string][]aumodel.AlertRule)
+	for _, alertRule := range alertRules {
+		result[alertRule.UserUuid] = append(result[alertRule.UserUuid], alertRule)
+	}
+	return result
+}
+
+// the users with alert rules do not get the alerts of their target prices, even if their rules are paused
+func FindAlertRuleUsers() map[string]bool {
+	result := make(map[string]bool)
+	for _, userUuid := range appUserRepository.FindAlertRuleUserUuids() {
+		result[userUuid] = true
+	}
+	return result
+}
+
+func StoreAlertRule(alertRuleIn AlertRuleIn) (aumodel.AlertRule, DbResult) {
+	var alertRule aumodel.AlertRule
+	if !validAlertRuleIn(alertRuleIn) {
+		return alertRule, Invalid
+	}
+	var err error
+	targetPrice := 0
+	if len(strings.TrimSpace(alertRuleIn.TargetPrice)) > 0 {
+		if targetPrice, err = parseMilliPrice(alertRuleIn.TargetPrice); err != nil {
+			log.Printf("TargetPrice: %v\n", alertRuleIn.TargetPrice)
+			return alertRule, Invalid
+		}
+	}
+	referenceType := aumodel.AlertRule{ReferenceType: alertRuleIn.ReferenceType}.Reference()
+	if referenceType == aumodel.AbsoluteReference && targetPrice <= 0 {
+		return alertRule, Invalid
+	}
+	minDifference := 0
+	if len(strings.TrimSpace(alertRuleIn.MinDifference)) > 0 {
+		if minDifference, err = parseMilliPrice(alertRuleIn.MinDifference); err != nil {
+			log.Printf("MinDifference: %v\n", alertRuleIn.MinDifference)
+			return alertRule, Invalid
+		}
+	}
+	appUser, err := appUserRepository.FindByUsername(alertRuleIn.Username)
+	if err != nil {
+		return alertRule, Invalid
+	}
+	if alertRuleIn.ID > 0 {
+		if alertRule, err = appUserRepository.FindAlertRule(alertRuleIn.ID, appUser.Uuid); err != nil {
+			return alertRule, Invalid
+		}
+	} else {
+		if appUserRepository.CountAlertRules(appUser.Uuid) >= maxAlertRulesPerUser {
+			return alertRule, Invalid
+		}
+		alertRule.UserUuid = appUser.Uuid
+	}
+	alertRule.Name = strings.TrimSpace(alertRuleIn.Name)
+	alertRule.Latitude = alertRuleIn.Latitude
+	alertRule.Longitude = alertRuleIn.Longitude
+	alertRule.SearchRadius = alertRuleIn.SearchRadius
+	alertRule.FuelType = strings.ToLower(strings.TrimSpace(alertRuleIn.FuelType))
+	alertRule.TargetPrice = targetPrice
+	alertRule.ActiveFrom = strings.TrimSpace(alertRuleIn.ActiveFrom)
+	alertRule.ActiveTo = strings.TrimSpace(alertRuleIn.ActiveTo)
+	alertRule.Brands = joinBrands(alertRuleIn.Brands)
+	alertRule.Active = alertRuleIn.Active
+	alertRule.ReferenceType = string(referenceType)
+	alertRule.ReferenceRadius = alertRuleIn.ReferenceRadius
+	alertRule.MinDifference = minDifference
+	if err := appUserRepository.SaveAlertRule(&alertRule); err != nil {
+		log.Printf("Store alert rule failed: %v\n", err)
+		return alertRule, Failed
+	}
+	return alertRule, Ok
+}
+
+func FindLastSeenPrices(stids []string) []aumodel.AlertRuleLastSeen {
+	return appUserRepository.FindLastSeenPrices(stids)
+}
+
+func StoreLastSeenPrices(lastSeenPrices []aumodel.AlertRuleLastSeen) {
+	if len(lastSeenPrices) == 0 {
+		return
+	}
+	if err := appUserRepository.SaveLastSeenPrices(lastSeenPrices); err != nil {
+		log.Printf("Store last seen prices failed: %v\n", err)
+	}
+}
+
+func DeleteAlertRule(username string, id uint) DbResult {
+	appUser, err := appUserRepository.FindByUsername(username)
+	if err != nil || !appUserRepository.DeleteAlertRule(id, appUser.Uuid) {
+		return Invalid
+	}
+	return Ok
+}
+
+func validAlertRuleIn(alertRuleIn AlertRuleIn) bool {
+	if len(strings.TrimSpace(alertRuleIn.Name)) < 1 || len(strings.TrimSpace(alertRuleIn.Name)) > 64 {
+		return false
+	}
+	if alertRuleIn.SearchRadius <= 0.0 || alertRuleIn.SearchRadius > config.MaxSearchRadius() || math.Abs(alertRuleIn.Latitude) > 90.0 || math.Abs(alertRuleIn.Longitude) > 180.0 {
+		return false
+	}
+	if _, err := gsmodel.ParseFuelType(alertRuleIn.FuelType); err != nil {
+		return false
+	}
+	if len(strings.TrimSpace(alertRuleIn.ReferenceType)) > 0 && string(aumodel.AlertRule{ReferenceType: alertRuleIn.ReferenceType}.Reference()) != strings.ToUpper(strings.TrimSpace(alertRuleIn.ReferenceType)) {
+		return false
+	}
+	if alertRuleIn.ReferenceRadius < 0.0 || alertRuleIn.ReferenceRadius > config.MaxSearchRadius() {
+		return false
+	}
+	//both or none of the active hours
+	if (len(strings.TrimSpace(alertRuleIn.ActiveFrom)) > 0) != (len(strings.TrimSpace(alertRuleIn.ActiveTo)) > 0) {
+		return false
+	}
+	for _, activeHour := range []string{alertRuleIn.ActiveFrom, alertRuleIn.ActiveTo} {
+		if _, err := time.Parse("15:04", strings.TrimSpace(activeHour)); len(strings.TrimSpace(activeHour)) > 0 && err != nil {
+			return false
+		}
+	}
+	return len(joinBrands(alertRuleIn.Brands)) <= 512
+}
+
+func joinBrands(brands []string) string {
+	myBrands := []string{}
+	for _, brand := range brands {
+		if len(strings.TrimSpace(brand)) > 0 {
+			myBrands = append(myBrands, strings.TrimSpace(brand))
+		}
+	}
+	return strings.Join(myBrands, ",")
+}
+
+// '1.789' -> 1789
+func parseMilliPrice(priceStr string) (int, error) {
+	price, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(priceStr), ",", "."), 64)
+	if err != nil {
+		return 0, err
+	}
+	return int(math.Round(price * 1000)), nil
+}
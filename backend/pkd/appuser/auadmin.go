@@ -0,0 +1,120 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package appuser
+
+import (
+	"log"
+	"os"
+	"react-and-go/pkd/appuser/aumodel"
+	"react-and-go/pkd/token"
+	"strings"
+	"time"
+)
+
+var validRoles = []token.Role{token.UserRole, token.AdminRole}
+
+// the users in ADMIN_USERNAMES are admins without a stored role to bootstrap the first admin
+func UserRoles(appUser aumodel.AppUser) []string {
+	result := appUser.RoleList()
+	for _, username := range strings.Split(os.Getenv("ADMIN_USERNAMES"), ",") {
+		if strings.TrimSpace(username) == appUser.Username && !containsRole(result, token.AdminRole) {
+			result = append(result, string(token.AdminRole))
+		}
+	}
+	return result
+}
+
+func containsRole(roles []string, role token.Role) bool {
+	for _, myRole := range roles {
+		if token.Role(myRole) == role {
+			return true
+		}
+	}
+	return false
+}
+
+// admins can not lock themselves out, the sessions of locked users are revoked
+func LockUser(adminUsername string, username string, locked bool) DbResult {
+	if adminUsername == username && locked {
+		return Invalid
+	}
+	appUser, err := appUserRepository.FindByUsername(username)
+	if err != nil {
+		return Invalid
+	}
+	appUser.Locked = locked
+	if err := appUserRepository.Save(&appUser); err != nil {
+		log.Printf("Store user lock failed: %v\n", err)
+		return Failed
+	}
+	if locked {
+		RevokeAllSessions(username, LockedReason)
+	}
+	return Ok
+}
+
+// the previous stored roles are returned for the audit log
+func StoreUserRoles(adminUsername string, username string, roles []string) ([]string, DbResult) {
+	myRoles := []string{}
+	for _, roleStr := range roles {
+		myRole := token.Role(strings.ToUpper(strings.TrimSpace(roleStr)))
+		if !validRole(myRole) {
+			log.Printf("Invalid role: %v\n", roleStr)
+			return nil, Invalid
+		}
+		if !containsRole(myRoles, myRole) {
+			myRoles = append(myRoles, string(myRole))
+		}
+	}
+	if len(myRoles) == 0 || (adminUsername == username && !containsRole(myRoles, token.AdminRole)) {
+		return nil, Invalid
+	}
+	appUser, err := appUserRepository.FindByUsername(username)
+	if err != nil {
+		return nil, Invalid
+	}
+	previousRoles := appUser.RoleList()
+	appUser.Roles = strings.Join(myRoles, ",")
+	if err := appUserRepository.Save(&appUser); err != nil {
+		log.Printf("Store user roles failed: %v\n", err)
+		return nil, Failed
+	}
+	return previousRoles, Ok
+}
+
+func validRole(role token.Role) bool {
+	for _, myRole := range validRoles {
+		if myRole == role {
+			return true
+		}
+	}
+	return false
+}
+
+func StoreAuditLogEntry(username string, method string, path string, target string, details string, status int) {
+	if len(target) > 256 {
+		target = target[:256]
+	}
+	if len(details) > 1024 {
+		details = details[:1024]
+	}
+	auditLogEntry := aumodel.AuditLogEntry{Timestamp: time.Now(), Username: username, Method: method, Path: path, Target: target,
+		Details: details, Status: status}
+	if err := auditLogRepository.Save(&auditLogEntry); err != nil {
+		log.Printf("Store audit log entry failed: %v\n", err)
+	}
+}
+
+func FindAuditLog(limit int) []aumodel.AuditLogEntry {
+	return auditLogRepository.FindLatest(limit)
+}
@@ -0,0 +1,292 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package appuser
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"react-and-go/pkd/appuser/aumodel"
+	"react-and-go/pkd/config"
+	"react-and-go/pkd/mail"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	PasswordChangedReason = "password-changed"
+	PasswordResetReason   = "password-reset"
+)
+
+// exports the data of a user that other packages store, registered in app.Wire, the repository erases it with the user
+type UserDataHandler struct {
+	Name   string
+	Export func(userUuid string) (interface{}, error)
+}
+
+type UserProfile struct {
+	Username             string
+	Uuid                 string
+	LangKey              string
+	CreatedAt            time.Time
+	Latitude             float64
+	Longitude            float64
+	SearchRadius         float64
+	TargetDiesel         int
+	TargetE5             int
+	TargetE10            int
+	NotificationChannels string
+	Email                string
+	EmailVerified        bool
+	WebhookUrl           string
+	WebPushSubscription  string
+	NotificationCooldown int
+	RepeatUnchanged      bool
+	QuietHoursStart      string
+	QuietHoursEnd        string
+	DigestMode           string
+	Roles                []string
+}
+
+type UserDataExport struct {
+	ExportedAt time.Time
+	Profile    UserProfile
+	AlertRules []aumodel.AlertRule
+	Sessions   []aumodel.UserSession
+	Data       map[string]interface{} // by the name of the UserDataHandler
+}
+
+var mailSender mail.MailSender
+var userDataHandlers = []UserDataHandler{}
+
+func SetMailSender(myMailSender mail.MailSender) {
+	mailSender = myMailSender
+}
+
+func AddUserDataHandler(userDataHandler UserDataHandler) {
+	userDataHandlers = append(userDataHandlers, userDataHandler)
+}
+
+// the other sessions of the user are revoked, the current one stays logged in
+func ChangePassword(username string, sessionId string, oldPassword string, newPassword string) int {
+	if len(newPassword) < 8 {
+		return http.StatusBadRequest
+	}
+	appUser, err := appUserRepository.FindByUsername(username)
+	if err != nil {
+		return http.StatusUnauthorized
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(appUser.Password), []byte(oldPassword)); err != nil {
+		log.Printf("Password wrong. Username: %v\n", appUser.Username)
+		return http.StatusUnauthorized
+	}
+	appUser.Password = string(generatePasswordHash(newPassword))
+	if err := appUserRepository.Save(&appUser); err != nil {
+		log.Printf("Change password failed: %v\n", err)
+		return http.StatusInternalServerError
+	}
+	sessionIds := []string{}
+	for _, userSession := range sessionRepository.FindSessions(username) {
+		if !userSession.Revoked && userSession.ID != sessionId {
+			sessionIds = append(sessionIds, userSession.ID)
+		}
+	}
+	revokeSessions(sessionIds, PasswordChangedReason, time.Now())
+	return http.StatusOK
+}
+
+// the reset token is only sent to a verified address. The result does not show if the user exists.
+func RequestPasswordReset(username string) {
+	appUser, err := appUserRepository.FindByUsername(strings.TrimSpace(username))
+	if err != nil || appUser.Locked || !appUser.EmailVerified || len(appUser.Email) == 0 {
+		log.Printf("Password reset not possible. Username: %v\n", username)
+		return
+	}
+	myTtl := time.Duration(config.ReadIntEnv("PASSWORD_RESET_MINUTES", 30)) * time.Minute
+	myToken, err := createUserToken(appUser, aumodel.PasswordResetPurpose, appUser.Email, myTtl)
+	if err != nil {
+		log.Printf("Password reset token creation failed: %v\n", err)
+		return
+	}
+	sendUserMail(appUser.Email, "Gas price alerts password reset.", fmt.Sprintf("Hello %v,\n\nuse this token to set a new password: %v\n\n"+
+		"The token is valid for %v minutes. Ignore this mail if you did not request a password reset.\n", appUser.Username, myToken,
+		int(myTtl.Minutes())))
+}
+
+// all sessions are revoked, the user has to login with the new password
+func ResetPassword(tokenStr string, newPassword string) int {
+	if len(newPassword) < 8 {
+		return http.StatusBadRequest
+	}
+	userToken, err := userTokenRepository.Use(hashToken(strings.TrimSpace(tokenStr)), aumodel.PasswordResetPurpose, time.Now())
+	if err != nil {
+		return http.StatusUnauthorized
+	}
+	appUser, err := appUserRepository.FindByUsername(userToken.Username)
+	if err != nil || appUser.Locked {
+		return http.StatusUnauthorized
+	}
+	appUser.Password = string(generatePasswordHash(newPassword))
+	if err := appUserRepository.Save(&appUser); err != nil {
+		log.Printf("Reset password failed: %v\n", err)
+		return http.StatusInternalServerError
+	}
+	RevokeAllSessions(appUser.Username, PasswordResetReason)
+	return http.StatusOK
+}
+
+// the address is stored unverified and a verification token is mailed to it
+func StoreEmail(username string, email string) DbResult {
+	myEmail := strings.TrimSpace(email)
+	if _, err := netmail.ParseAddress(myEmail); err != nil {
+		log.Printf("Invalid email: %v\n", myEmail)
+		return Invalid
+	}
+	appUser, err := appUserRepository.FindByUsername(username)
+	if err != nil {
+		return Invalid
+	}
+	if appUser.Email == myEmail && appUser.EmailVerified {
+		return Ok
+	}
+	appUser.Email = myEmail
+	appUser.EmailVerified = false
+	if err := appUserRepository.Save(&appUser); err != nil {
+		log.Printf("Store email failed: %v\n", err)
+		return Failed
+	}
+	myTtl := time.Duration(config.ReadIntEnv("EMAIL_VERIFY_HOURS", 24)) * time.Hour
+	myToken, err := createUserToken(appUser, aumodel.EmailVerifyPurpose, myEmail, myTtl)
+	if err != nil {
+		log.Printf("Email verification token creation failed: %v\n", err)
+		return Failed
+	}
+	sendUserMail(myEmail, "Gas price alerts email verification.", fmt.Sprintf("Hello %v,\n\nuse this token to verify your email address: %v\n\n"+
+		"The token is valid for %v hours.\n", appUser.Username, myToken, int(myTtl.Hours())))
+	return Ok
+}
+
+// the token is only valid for the address it was sent to
+func VerifyEmail(tokenStr string) int {
+	userToken, err := userTokenRepository.Use(hashToken(strings.TrimSpace(tokenStr)), aumodel.EmailVerifyPurpose, time.Now())
+	if err != nil {
+		return http.StatusUnauthorized
+	}
+	appUser, err := appUserRepository.FindByUsername(userToken.Username)
+	if err != nil {
+		return http.StatusUnauthorized
+	}
+	if appUser.Email != userToken.Email {
+		log.Printf("Email changed after the verification token was sent. Username: %v\n", appUser.Username)
+		return http.StatusBadRequest
+	}
+	appUser.EmailVerified = true
+	if err := appUserRepository.Save(&appUser); err != nil {
+		log.Printf("Verify email failed: %v\n", err)
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
+
+func createUserToken(appUser aumodel.AppUser, purpose aumodel.TokenPurpose, email string, ttl time.Duration) (string, error) {
+	myToken, myTokenHash, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	userToken := aumodel.UserToken{TokenHash: myTokenHash, Username: appUser.Username, Purpose: purpose, Email: email, CreatedAt: now,
+		ExpiresAt: now.Add(ttl)}
+	if err := userTokenRepository.Save(&userToken); err != nil {
+		return "", err
+	}
+	return myToken, nil
+}
+
+func sendUserMail(to string, subject string, body string) {
+	if mailSender == nil {
+		log.Printf("No mail sender, mail not sent: %v\n", subject)
+		return
+	}
+	if err := mailSender.Send(mail.Mail{To: to, Subject: subject, Body: body}); err != nil {
+		log.Printf("Send mail failed: %v\n", err)
+	}
+}
+
+func ExportUserData(username string) (UserDataExport, error) {
+	appUser, err := appUserRepository.FindByUsername(username)
+	if err != nil {
+		return UserDataExport{}, err
+	}
+	return exportUserData(appUser)
+}
+
+func exportUserData(appUser aumodel.AppUser) (UserDataExport, error) {
+	result := UserDataExport{ExportedAt: time.Now(), Profile: UserProfile{Username: appUser.Username, Uuid: appUser.Uuid, LangKey: appUser.LangKey,
+		CreatedAt: appUser.CreatedAt, Latitude: appUser.Latitude, Longitude: appUser.Longitude, SearchRadius: appUser.SearchRadius,
+		TargetDiesel: appUser.TargetDiesel, TargetE5: appUser.TargetE5, TargetE10: appUser.TargetE10,
+		NotificationChannels: appUser.NotificationChannels, Email: appUser.Email, EmailVerified: appUser.EmailVerified,
+		WebhookUrl: appUser.WebhookUrl, WebPushSubscription: appUser.WebPushSubscription, NotificationCooldown: appUser.NotificationCooldown,
+		RepeatUnchanged: appUser.RepeatUnchanged, QuietHoursStart: appUser.QuietHoursStart, QuietHoursEnd: appUser.QuietHoursEnd,
+		DigestMode: appUser.DigestMode, Roles: appUser.RoleList()},
+		AlertRules: appUserRepository.FindAlertRules(appUser.Uuid), Sessions: sessionRepository.FindSessions(appUser.Username),
+		Data: make(map[string]interface{})}
+	for _, userDataHandler := range userDataHandlers {
+		myData, err := userDataHandler.Export(appUser.Uuid)
+		if err != nil {
+			return UserDataExport{}, fmt.Errorf("export %v: %w", userDataHandler.Name, err)
+		}
+		result.Data[userDataHandler.Name] = myData
+	}
+	return result, nil
+}
+
+// the data is exported before it is erased, a failed export keeps the account
+func DeleteAccount(username string, password string) (UserDataExport, int) {
+	appUser, err := appUserRepository.FindByUsername(username)
+	if err != nil {
+		return UserDataExport{}, http.StatusUnauthorized
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(appUser.Password), []byte(password)); err != nil {
+		log.Printf("Password wrong. Username: %v\n", appUser.Username)
+		return UserDataExport{}, http.StatusUnauthorized
+	}
+	result, err := exportUserData(appUser)
+	if err != nil {
+		log.Printf("Export user data failed: %v\n", err)
+		return UserDataExport{}, http.StatusInternalServerError
+	}
+	// one transaction, a failed delete keeps all the data of the account
+	if err := appUserRepository.Delete(appUser); err != nil {
+		log.Printf("Delete account failed: %v\n", err)
+		return UserDataExport{}, http.StatusInternalServerError
+	}
+	// the access tokens of the deleted sessions stop working before they expire
+	sessionIds := []string{}
+	for _, userSession := range result.Sessions {
+		sessionIds = append(sessionIds, userSession.ID)
+	}
+	clearSessionChecks(sessionIds)
+	log.Printf("Account deleted. Username: %v\n", appUser.Username)
+	return result, http.StatusOK
+}
+
+func DeleteExpiredUserTokens() {
+	if deleted, err := userTokenRepository.DeleteExpiredBefore(time.Now()); err != nil {
+		log.Printf("Delete user tokens failed: %v\n", err)
+	} else {
+		log.Printf("User tokens deleted: %v\n", deleted)
+	}
+}
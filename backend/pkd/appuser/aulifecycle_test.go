@@ -0,0 +1,124 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package appuser_test
+
+import (
+	"net/http"
+	"react-and-go/pkd/appuser"
+	"react-and-go/pkd/mail"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testMailSender struct {
+	mutex sync.Mutex
+	mails []mail.Mail
+}
+
+func (mailSender *testMailSender) Send(mail mail.Mail) error {
+	mailSender.mutex.Lock()
+	defer mailSender.mutex.Unlock()
+	mailSender.mails = append(mailSender.mails, mail)
+	return nil
+}
+
+// the token follows the first colon of the last mail
+func (mailSender *testMailSender) lastToken(t *testing.T) string {
+	mailSender.mutex.Lock()
+	defer mailSender.mutex.Unlock()
+	if len(mailSender.mails) == 0 {
+		t.Fatalf("No mail sent")
+	}
+	body := mailSender.mails[len(mailSender.mails)-1].Body
+	index := strings.Index(body, ": ")
+	if index < 0 {
+		t.Fatalf("No token in mail: %v", body)
+	}
+	return strings.Fields(body[index+2:])[0]
+}
+
+func (mailSender *testMailSender) count() int {
+	mailSender.mutex.Lock()
+	defer mailSender.mutex.Unlock()
+	return len(mailSender.mails)
+}
+
+func newTestMailSender() *testMailSender {
+	mailSender := &testMailSender{}
+	appuser.SetMailSender(mailSender)
+	return mailSender
+}
+
+func TestPasswordReset(t *testing.T) {
+	newTestApp(t)
+	mailSender := newTestMailSender()
+	// no mail without a verified address
+	appuser.RequestPasswordReset(testUsername)
+	if mailSender.count() != 0 {
+		t.Fatalf("Reset mail sent to an unverified address")
+	}
+	if result := appuser.StoreEmail(testUsername, "test@example.com"); result != appuser.Ok {
+		t.Fatalf("Store email failed: %v", result)
+	}
+	if status := appuser.VerifyEmail(mailSender.lastToken(t)); status != http.StatusOK {
+		t.Fatalf("Verify email failed: %v", status)
+	}
+	_, refreshToken := login(t, testPassword)
+	appuser.RequestPasswordReset(testUsername)
+	resetToken := mailSender.lastToken(t)
+	if status := appuser.ResetPassword(resetToken, "short"); status != http.StatusBadRequest {
+		t.Errorf("Short password accepted: %v", status)
+	}
+	if status := appuser.ResetPassword(resetToken, "newpassword"); status != http.StatusOK {
+		t.Fatalf("Reset password failed: %v", status)
+	}
+	// the token can be used once
+	if status := appuser.ResetPassword(resetToken, "otherpassword"); status != http.StatusUnauthorized {
+		t.Errorf("Reset token used twice: %v", status)
+	}
+	if _, _, status, _, _, _, _, _, _, _ := appuser.Login(appuser.AppUserIn{Username: testUsername, Password: testPassword}); status != http.StatusUnauthorized {
+		t.Errorf("Login with the old password: %v", status)
+	}
+	login(t, "newpassword")
+	// the reset revokes the sessions
+	if _, _, status := appuser.RefreshSession(refreshToken); status != http.StatusUnauthorized {
+		t.Errorf("Refresh in the session before the reset: %v", status)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	myApp := newTestApp(t)
+	newTestMailSender()
+	_, refreshToken := login(t, testPassword)
+	sessionId := appuser.FindSessions(testUsername)[0].ID
+	if _, status := appuser.DeleteAccount(testUsername, "wrongpassword"); status != http.StatusUnauthorized {
+		t.Errorf("Delete with a wrong password: %v", status)
+	}
+	result, status := appuser.DeleteAccount(testUsername, testPassword)
+	if status != http.StatusOK || result.Profile.Username != testUsername || len(result.Sessions) != 1 {
+		t.Fatalf("Delete account failed: %v export: %+v", status, result)
+	}
+	if _, err := appuser.FindByUsername(testUsername); err == nil {
+		t.Errorf("User not deleted")
+	}
+	if sessions := myApp.SessionRepository.FindSessions(testUsername); len(sessions) != 0 {
+		t.Errorf("Sessions not deleted: %+v", sessions)
+	}
+	if appuser.SessionActive(testUsername, sessionId) {
+		t.Errorf("Session of the deleted account active")
+	}
+	if _, _, status := appuser.RefreshSession(refreshToken); status != http.StatusUnauthorized {
+		t.Errorf("Refresh of the deleted account: %v", status)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package aumodel
+
+import (
+	"react-and-go/pkd/gasstation/gsmodel"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AlertRule struct {
+	gorm.Model
+	UserUuid        string `gorm:"size:64;not null;index:idx_ar_user_uuid"`
+	Name            string `gorm:"size:64;not null"`
+	Latitude        float64
+	Longitude       float64
+	SearchRadius    float64
+	FuelType        string `gorm:"size:16;not null"`
+	TargetPrice     int
+	ActiveFrom      string `gorm:"size:5"`   // 'HH:MM', empty means always active
+	ActiveTo        string `gorm:"size:5"`   // 'HH:MM', can be before ActiveFrom for rules over midnight
+	Brands          string `gorm:"size:512"` // comma separated, empty means all brands
+	Active          bool
+	ReferenceType   string `gorm:"size:16"` // empty means ABSOLUTE, for relative rules TargetPrice > 0 is an upper limit
+	ReferenceRadius float64
+	MinDifference   int // below the reference price
+}
+
+// the last evaluated price of a station for a LAST_SEEN rule
+type AlertRuleLastSeen struct {
+	AlertRuleID  uint   `gorm:"primaryKey"`
+	GasStationID string `gorm:"primaryKey;size:64;column:stid;index:idx_arls_stid"`
+	Price        int
+	SeenAt       time.Time
+}
+
+func (AlertRuleLastSeen) TableName() string {
+	return "alert_rule_last_seen"
+}
+
+type ReferenceType string
+
+const (
+	AbsoluteReference        ReferenceType = "ABSOLUTE"
+	AreaMedianReference      ReferenceType = "AREA_MEDIAN"
+	TrailingAverageReference ReferenceType = "TRAILING_AVERAGE"
+	LastSeenReference        ReferenceType = "LAST_SEEN"
+)
+
+var ReferenceTypes = []ReferenceType{AbsoluteReference, AreaMedianReference, TrailingAverageReference, LastSeenReference}
+
+func (alertRule AlertRule) Reference() ReferenceType {
+	for _, referenceType := range ReferenceTypes {
+		if string(referenceType) == strings.ToUpper(strings.TrimSpace(alertRule.ReferenceType)) {
+			return referenceType
+		}
+	}
+	return AbsoluteReference
+}
+
+func (alertRule AlertRule) IsActiveAt(at time.Time) bool {
+	if !alertRule.Active {
+		return false
+	}
+	from, fromErr := time.Parse("15:04", strings.TrimSpace(alertRule.ActiveFrom))
+	to, toErr := time.Parse("15:04", strings.TrimSpace(alertRule.ActiveTo))
+	if fromErr != nil || toErr != nil {
+		return true
+	}
+	localAt := at.In(gsmodel.StationLocation)
+	minuteOfDay := localAt.Hour()*60 + localAt.Minute()
+	fromMinute := from.Hour()*60 + from.Minute()
+	toMinute := to.Hour()*60 + to.Minute()
+	if fromMinute <= toMinute {
+		return minuteOfDay >= fromMinute && minuteOfDay < toMinute
+	}
+	return minuteOfDay >= fromMinute || minuteOfDay < toMinute
+}
+
+func (alertRule AlertRule) MatchesBrand(brand string) bool {
+	if len(strings.TrimSpace(alertRule.Brands)) == 0 {
+		return true
+	}
+	for _, myBrand := range strings.Split(alertRule.Brands, ",") {
+		if strings.EqualFold(strings.TrimSpace(myBrand), strings.TrimSpace(brand)) {
+			return true
+		}
+	}
+	return false
+}
+
+// the target prices of users without alert rules work like rules for their location
+func (appUser AppUser) LegacyAlertRules() []AlertRule {
+	result := []AlertRule{}
+	targetPrices := map[gsmodel.FuelType]int{gsmodel.FuelDiesel: appUser.TargetDiesel, gsmodel.FuelE5: appUser.TargetE5, gsmodel.FuelE10: appUser.TargetE10}
+	for _, fuelType := range gsmodel.FuelTypes {
+		if targetPrices[fuelType] <= 0 {
+			continue
+		}
+		result = append(result, AlertRule{UserUuid: appUser.Uuid, Name: string(fuelType), Latitude: appUser.Latitude, Longitude: appUser.Longitude,
+			SearchRadius: appUser.SearchRadius, FuelType: string(fuelType), TargetPrice: targetPrices[fuelType], Active: true})
+	}
+	return result
+}
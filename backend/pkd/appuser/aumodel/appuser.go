@@ -13,19 +13,65 @@
 package aumodel
 
 import (
+	"strings"
+
 	"gorm.io/gorm"
 )
 
 type AppUser struct {
 	gorm.Model
-	Username     string `gorm:"size:64;not null;index:idx_au_user_name,unique"`
-	Password     string `gorm:"size:128;not null"`
-	Uuid         string `gorm:"size:64;not null"`
-	LangKey      string `gorm:"size:8;not null"`
-	Latitude     float64
-	Longitude    float64
-	SearchRadius float64
-	TargetDiesel int
-	TargetE5     int
-	TargetE10    int
+	Username             string `gorm:"size:64;not null;index:idx_au_user_name,unique"`
+	Password             string `gorm:"size:128;not null"`
+	Uuid                 string `gorm:"size:64;not null"`
+	LangKey              string `gorm:"size:8;not null"`
+	Latitude             float64
+	Longitude            float64
+	SearchRadius         float64
+	TargetDiesel         int
+	TargetE5             int
+	TargetE10            int
+	NotificationChannels string `gorm:"size:128"` // comma separated like 'email,webhook'
+	Email                string `gorm:"size:256"`
+	EmailVerified        bool   // the user confirmed the address with a mailed token
+	WebhookUrl           string `gorm:"size:1024"`
+	WebPushSubscription  string `gorm:"size:2048"`
+	NotificationCooldown int    // minutes between the notifications of a station, 0 for NOTIFICATION_COOLDOWN_MINUTES
+	RepeatUnchanged      bool   // notify after the cooldown without a further price drop
+	QuietHoursStart      string `gorm:"size:5"` // 'HH:MM', empty without quiet hours
+	QuietHoursEnd        string `gorm:"size:5"`
+	DigestMode           string `gorm:"size:8"`  // 'hourly' or 'daily', empty for single notifications
+	Roles                string `gorm:"size:64"` // comma separated like 'USER,ADMIN', empty means 'USER'
+	Locked               bool
+}
+
+type NotificationChannel string
+
+const (
+	EmailChannel   NotificationChannel = "email"
+	WebhookChannel NotificationChannel = "webhook"
+	WebPushChannel NotificationChannel = "webpush"
+)
+
+var NotificationChannels = []NotificationChannel{EmailChannel, WebhookChannel, WebPushChannel}
+
+func (appUser AppUser) RoleList() []string {
+	result := []string{}
+	for _, roleStr := range strings.Split(appUser.Roles, ",") {
+		if len(strings.TrimSpace(roleStr)) > 0 {
+			result = append(result, strings.ToUpper(strings.TrimSpace(roleStr)))
+		}
+	}
+	if len(result) == 0 {
+		result = append(result, "USER")
+	}
+	return result
+}
+
+func (appUser AppUser) ChannelEnabled(channel NotificationChannel) bool {
+	for _, channelStr := range strings.Split(appUser.NotificationChannels, ",") {
+		if NotificationChannel(strings.TrimSpace(channelStr)) == channel {
+			return true
+		}
+	}
+	return false
 }
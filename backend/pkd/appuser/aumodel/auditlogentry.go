@@ -0,0 +1,31 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package aumodel
+
+import "time"
+
+// an admin request with the acting user and the response status
+type AuditLogEntry struct {
+	ID        int64     `gorm:"primaryKey"`
+	Timestamp time.Time `gorm:"not null;index:idx_al_timestamp"`
+	Username  string    `gorm:"size:64;not null"`
+	Method    string    `gorm:"size:8;not null"`
+	Path      string    `gorm:"size:256;not null"` // the route like '/admin/users/:username/lock'
+	Target    string    `gorm:"size:256"`          // the route parameters and the query
+	Details   string    `gorm:"size:1024"`         // the json request body and the changed values
+	Status    int
+}
+
+func (AuditLogEntry) TableName() string {
+	return "admin_audit_log"
+}
@@ -0,0 +1,65 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package aumodel
+
+import (
+	"fmt"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"strings"
+	"time"
+)
+
+type DigestMode string
+
+const (
+	NoDigest     DigestMode = ""
+	HourlyDigest DigestMode = "hourly"
+	DailyDigest  DigestMode = "daily"
+)
+
+func ParseDigestMode(digestModeStr string) (DigestMode, error) {
+	myDigestMode := DigestMode(strings.ToLower(strings.TrimSpace(digestModeStr)))
+	if myDigestMode != NoDigest && myDigestMode != HourlyDigest && myDigestMode != DailyDigest {
+		return NoDigest, fmt.Errorf("unknown digest mode: %v", digestModeStr)
+	}
+	return myDigestMode, nil
+}
+
+// minutes of the day for 'HH:MM'
+func ParseClock(clockStr string) (int, error) {
+	myTime, err := time.Parse("15:04", strings.TrimSpace(clockStr))
+	if err != nil {
+		return 0, err
+	}
+	return myTime.Hour()*60 + myTime.Minute(), nil
+}
+
+func (appUser AppUser) Digest() DigestMode {
+	myDigestMode, _ := ParseDigestMode(appUser.DigestMode)
+	return myDigestMode
+}
+
+// the quiet hours are in the time zone of the stations and can span midnight like 22:00 - 07:00
+func (appUser AppUser) InQuietHours(now time.Time) bool {
+	start, startErr := ParseClock(appUser.QuietHoursStart)
+	end, endErr := ParseClock(appUser.QuietHoursEnd)
+	if startErr != nil || endErr != nil || start == end {
+		return false
+	}
+	localNow := now.In(gsmodel.StationLocation)
+	minuteOfDay := localNow.Hour()*60 + localNow.Minute()
+	if start < end {
+		return minuteOfDay >= start && minuteOfDay < end
+	}
+	return minuteOfDay >= start || minuteOfDay < end
+}
@@ -0,0 +1,50 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package aumodel
+
+import "time"
+
+// a login of a user, the access tokens carry the session id and the session is checked on every request
+type UserSession struct {
+	ID           string    `gorm:"primaryKey;size:64"`
+	Username     string    `gorm:"size:64;not null;index:idx_us_user_name"`
+	UserAgent    string    `gorm:"size:256"`
+	IpAddress    string    `gorm:"size:64"`
+	CreatedAt    time.Time `gorm:"not null"`
+	LastUsedAt   time.Time `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null;index:idx_us_expires_at"` // the expiry of the current refresh token
+	Revoked      bool
+	RevokedAt    time.Time
+	RevokeReason string `gorm:"size:32"`
+}
+
+func (UserSession) TableName() string {
+	return "user_session"
+}
+
+func (userSession UserSession) ActiveAt(at time.Time) bool {
+	return !userSession.Revoked && at.Before(userSession.ExpiresAt)
+}
+
+// only the sha256 hash of the opaque refresh token is stored, a used token stays to detect its reuse
+type RefreshToken struct {
+	TokenHash string    `gorm:"primaryKey;size:64"`
+	SessionID string    `gorm:"size:64;not null;index:idx_rt_session_id"`
+	CreatedAt time.Time `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	Used      bool
+}
+
+func (RefreshToken) TableName() string {
+	return "user_refresh_token"
+}
@@ -0,0 +1,37 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package aumodel
+
+import "time"
+
+type TokenPurpose string
+
+const (
+	PasswordResetPurpose TokenPurpose = "PASSWORD_RESET"
+	EmailVerifyPurpose   TokenPurpose = "EMAIL_VERIFY"
+)
+
+// a time limited single use token that is sent by mail, only the sha256 hash is stored
+type UserToken struct {
+	TokenHash string       `gorm:"primaryKey;size:64"`
+	Username  string       `gorm:"size:64;not null;index:idx_ut_user_name"`
+	Purpose   TokenPurpose `gorm:"size:16;not null"`
+	Email     string       `gorm:"size:256"` // the verified address for EMAIL_VERIFY
+	CreatedAt time.Time    `gorm:"not null"`
+	ExpiresAt time.Time    `gorm:"not null"`
+	Used      bool
+}
+
+func (UserToken) TableName() string {
+	return "user_action_token"
+}
@@ -13,19 +13,19 @@
 package appuser
 
 import (
-	"fmt"
+	"encoding/json"
 	"log"
 	"net/http"
+	"net/mail"
 	"react-and-go/pkd/appuser/aumodel"
-	"react-and-go/pkd/database"
+	"react-and-go/pkd/netguard"
+	"react-and-go/pkd/repository"
 	token "react-and-go/pkd/token"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 )
 
 type AppUserIn struct {
@@ -36,6 +36,8 @@ type AppUserIn struct {
 	Latitude     float64
 	Longitude    float64
 	SearchRadius float64
+	UserAgent    string
+	IpAddress    string
 }
 
 type AppTargetIn struct {
@@ -45,6 +47,28 @@ type AppTargetIn struct {
 	TargetE5     string
 }
 
+type AppChannelsIn struct {
+	Username             string
+	NotificationChannels []string
+	Email                string
+	WebhookUrl           string
+	WebPushSubscription  string
+}
+
+// the endpoint of the json of the browser PushSubscription
+type webPushSubscription struct {
+	Endpoint string `json:"endpoint"`
+}
+
+type AppNotificationSettingsIn struct {
+	Username             string
+	NotificationCooldown int
+	RepeatUnchanged      bool
+	QuietHoursStart      string
+	QuietHoursEnd        string
+	DigestMode           string
+}
+
 type DbResult int
 
 type PostCodeData struct {
@@ -70,55 +94,67 @@ const (
 	Failed
 )
 
+var appUserRepository repository.AppUserRepository
+var auditLogRepository repository.AuditLogRepository
+var sessionRepository repository.SessionRepository
+var userTokenRepository repository.UserTokenRepository
+
+func SetRepositories(myAppUserRepository repository.AppUserRepository, myAuditLogRepository repository.AuditLogRepository,
+	mySessionRepository repository.SessionRepository, myUserTokenRepository repository.UserTokenRepository) {
+	appUserRepository = myAppUserRepository
+	auditLogRepository = myAuditLogRepository
+	sessionRepository = mySessionRepository
+	userTokenRepository = myUserTokenRepository
+}
+
 func FindAllUsers() []aumodel.AppUser {
-	var result []aumodel.AppUser
-	database.DB.Find(&result)
-	return result
+	return appUserRepository.FindAll()
+}
+
+func FindByUsername(username string) (aumodel.AppUser, error) {
+	return appUserRepository.FindByUsername(username)
 }
 
-func StoreUserLogout(username string, uuid string) []token.LoggedOutUserOut {
-	database.DB.Delete("last_logout < ?", time.Now().Add(-4*time.Minute))
-	loggedOutUser := aumodel.LoggedOutUser{Username: username, Uuid: uuid, LastLogout: time.Now()}
-	database.DB.Save(loggedOutUser)
-	var loggedOutUsers []aumodel.LoggedOutUser
-	database.DB.Find(&loggedOutUsers)
-	var results []token.LoggedOutUserOut
-	for _, myLoggedOutUser := range loggedOutUsers {
-		loggedOutUserOut := token.LoggedOutUserOut{Username: myLoggedOutUser.Username, Uuid: myLoggedOutUser.Uuid, LastLogout: myLoggedOutUser.LastLogout}
-		results = append(results, loggedOutUserOut)
-	}
-	return results
+func FindByUuid(userUuid string) (aumodel.AppUser, error) {
+	return appUserRepository.FindByUuid(userUuid)
 }
 
 func FindLocation(locationStr string) []aumodel.PostCodeLocation {
-	result := []aumodel.PostCodeLocation{}
-	database.DB.Where("lower(label) like ?", fmt.Sprintf("%%%v%%", strings.ToLower(strings.TrimSpace(locationStr)))).Limit(20).Find(&result)
-	//log.Printf("Select: %v failed. %v", fmt.Sprintf("%%%v%%", strings.ToLower(strings.TrimSpace(locationStr))), err)
-	return result
+	return appUserRepository.FindPostCodeLocations(strings.TrimSpace(locationStr), 20)
 }
 
-func Login(appUserIn AppUserIn) (string, int, string, float64, float64, float64, int, int, int) {
+// returns the access token and the refresh token of a new session
+func Login(appUserIn AppUserIn) (string, string, int, string, float64, float64, float64, int, int, int) {
 	result := ""
 	status := http.StatusUnauthorized
 	//log.Printf("%v", appUserIn.Username)
-	var appUser aumodel.AppUser
-	if err := database.DB.Where("username = ?", appUserIn.Username).First(&appUser); err.Error != nil {
-		log.Printf("User not found: %v error: %v\n", appUserIn.Username, err.Error)
-		return result, status, "", 0.0, 0.0, 0.0, 0, 0, 0
+	appUser, err := appUserRepository.FindByUsername(appUserIn.Username)
+	if err != nil {
+		log.Printf("User not found: %v error: %v\n", appUserIn.Username, err)
+		return result, "", status, "", 0.0, 0.0, 0.0, 0, 0, 0
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(appUser.Password), []byte(appUserIn.Password)); err != nil {
 		log.Printf("Password wrong. Username: %v\n", appUser.Username)
-		return result, status, "", 0.0, 0.0, 0.0, 0, 0, 0
+		return result, "", status, "", 0.0, 0.0, 0.0, 0, 0, 0
+	}
+	if appUser.Locked {
+		log.Printf("User locked. Username: %v\n", appUser.Username)
+		return result, "", http.StatusForbidden, "", 0.0, 0.0, 0.0, 0, 0, 0
+	}
+	sessionId, refreshToken, err := createSession(appUser.Username, appUserIn.UserAgent, appUserIn.IpAddress)
+	if err != nil {
+		log.Printf("Failed to create session: %v\n", err)
+		return result, "", status, "", 0.0, 0.0, 0.0, 0, 0, 0
 	}
 	//jwt token creation
-	result, err := token.CreateToken(token.TokenUser{Username: appUser.Username, Roles: []string{"USERS"}})
+	result, err = token.CreateToken(token.TokenUser{Username: appUser.Username, Roles: UserRoles(appUser), SessionId: sessionId})
 	if err != nil {
 		log.Printf("Failed to create jwt token: %v\n", err)
-		return result, status, "", 0.0, 0.0, 0.0, 0, 0, 0
+		return result, "", status, "", 0.0, 0.0, 0.0, 0, 0, 0
 	} else {
 		status = http.StatusOK
 	}
-	return result, status, appUser.Uuid, appUser.Longitude, appUser.Latitude, appUser.SearchRadius, appUser.TargetE5, appUser.TargetE10, appUser.TargetDiesel
+	return result, refreshToken, status, appUser.Uuid, appUser.Longitude, appUser.Latitude, appUser.SearchRadius, appUser.TargetE5, appUser.TargetE10, appUser.TargetDiesel
 }
 
 func Signin(appUserIn AppUserIn) DbResult {
@@ -126,109 +162,200 @@ func Signin(appUserIn AppUserIn) DbResult {
 	if len(appUserIn.Username) < 4 || len(appUserIn.Password) < 8 {
 		return result
 	}
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
-		var appUser aumodel.AppUser
-		//check usernames
-		if err := tx.Where("username = ?", appUserIn.Username).First(&appUser); err.Error == nil {
-			result = UsernameTaken
-			return nil
-		}
-		//generate uuid
-		myUuid, err := uuid.NewRandom()
-		if err != nil {
-			result = Failed
-			return nil
-		}
-		appUser.Username = appUserIn.Username
-		appUser.Password = string(generatePasswordHash(appUserIn.Password))
-		appUser.Uuid = myUuid.String()
-		appUser.LangKey = string(appUserIn.Language)
-		tx.Save(&appUser)
-		return nil
-	})
+	//check usernames
+	if _, err := appUserRepository.FindByUsername(appUserIn.Username); err == nil {
+		return UsernameTaken
+	}
+	//generate uuid
+	myUuid, err := uuid.NewRandom()
 	if err != nil {
-		result = Failed
-	} else if result == Invalid {
-		result = Ok
+		return Failed
 	}
-	return result
+	var appUser aumodel.AppUser
+	appUser.Username = appUserIn.Username
+	appUser.Password = string(generatePasswordHash(appUserIn.Password))
+	appUser.Uuid = myUuid.String()
+	appUser.LangKey = string(appUserIn.Language)
+	if err := appUserRepository.Save(&appUser); err != nil {
+		log.Printf("Signin failed: %v\n", err)
+		return Failed
+	}
+	return Ok
 }
 
 func StoreLocationAndRadius(appUserIn AppUserIn) DbResult {
-	result := Invalid
-	database.DB.Transaction(func(tx *gorm.DB) error {
-		var appUser aumodel.AppUser
-		if err := tx.Where("username = ?", appUserIn.Username).Find(&appUser); err.Error == nil {
-			appUser.Longitude = appUserIn.Longitude
-			appUser.Latitude = appUserIn.Latitude
-			appUser.SearchRadius = appUserIn.SearchRadius
-			tx.Save(&appUser)
-			result = Ok
-		}
-		return nil
-	})
-	return result
+	appUser, err := appUserRepository.FindByUsername(appUserIn.Username)
+	if err != nil {
+		return Invalid
+	}
+	appUser.Longitude = appUserIn.Longitude
+	appUser.Latitude = appUserIn.Latitude
+	appUser.SearchRadius = appUserIn.SearchRadius
+	if err := appUserRepository.Save(&appUser); err != nil {
+		log.Printf("Store location failed: %v\n", err)
+		return Failed
+	}
+	return Ok
 }
 
 func StoreTargetPrices(appTargetIn AppTargetIn) DbResult {
-	result := Invalid
-	database.DB.Transaction(func(tx *gorm.DB) error {
-		var appUser aumodel.AppUser
-		var txError error = nil
-		if err := tx.Where("username = ?", appTargetIn.Username).Find(&appUser); err.Error == nil {
-			if targetPrice, err := strconv.ParseInt(strings.ReplaceAll(appTargetIn.TargetDiesel, ".", ""), 10, 32); err == nil {
-				appUser.TargetDiesel = int(targetPrice)
-			} else {
-				log.Printf("TargetDiesel: %v\n", appTargetIn.TargetDiesel)
-				txError = err
-			}
-			if targetPrice, err := strconv.ParseInt(strings.ReplaceAll(appTargetIn.TargetE10, ".", ""), 10, 32); err == nil {
-				appUser.TargetE10 = int(targetPrice)
-			} else {
-				log.Printf("TargetE10: %v\n", appTargetIn.TargetE10)
-				txError = err
-			}
-			if targetPrice, err := strconv.ParseInt(strings.ReplaceAll(appTargetIn.TargetE5, ".", ""), 10, 32); err == nil {
-				appUser.TargetE5 = int(targetPrice)
-			} else {
-				log.Printf("TargetE5: %v\n", appTargetIn.TargetE5)
-				txError = err
-			}
-			if txError == nil {
-				tx.Save(&appUser)
-				result = Ok
-			}
+	appUser, err := appUserRepository.FindByUsername(appTargetIn.Username)
+	if err != nil {
+		return Invalid
+	}
+	result := Ok
+	if targetPrice, err := strconv.ParseInt(strings.ReplaceAll(appTargetIn.TargetDiesel, ".", ""), 10, 32); err == nil {
+		appUser.TargetDiesel = int(targetPrice)
+	} else {
+		log.Printf("TargetDiesel: %v\n", appTargetIn.TargetDiesel)
+		result = Invalid
+	}
+	if targetPrice, err := strconv.ParseInt(strings.ReplaceAll(appTargetIn.TargetE10, ".", ""), 10, 32); err == nil {
+		appUser.TargetE10 = int(targetPrice)
+	} else {
+		log.Printf("TargetE10: %v\n", appTargetIn.TargetE10)
+		result = Invalid
+	}
+	if targetPrice, err := strconv.ParseInt(strings.ReplaceAll(appTargetIn.TargetE5, ".", ""), 10, 32); err == nil {
+		appUser.TargetE5 = int(targetPrice)
+	} else {
+		log.Printf("TargetE5: %v\n", appTargetIn.TargetE5)
+		result = Invalid
+	}
+	if result == Ok {
+		if err := appUserRepository.Save(&appUser); err != nil {
+			log.Printf("Store target prices failed: %v\n", err)
+			result = Failed
 		}
-		return txError
-	})
+	}
 	return result
 }
 
+func StoreNotificationChannels(appChannelsIn AppChannelsIn) DbResult {
+	myChannels := []string{}
+	for _, channelStr := range appChannelsIn.NotificationChannels {
+		if !validNotificationChannel(aumodel.NotificationChannel(strings.TrimSpace(channelStr))) {
+			log.Printf("Invalid notification channel: %v\n", channelStr)
+			return Invalid
+		}
+		myChannels = append(myChannels, strings.TrimSpace(channelStr))
+	}
+	myEmail := strings.TrimSpace(appChannelsIn.Email)
+	if len(myEmail) > 0 {
+		if _, err := mail.ParseAddress(myEmail); err != nil {
+			log.Printf("Invalid email: %v\n", myEmail)
+			return Invalid
+		}
+	}
+	myWebhookUrl := strings.TrimSpace(appChannelsIn.WebhookUrl)
+	if len(myWebhookUrl) > 0 {
+		if err := netguard.CheckPublicUrl(myWebhookUrl); err != nil {
+			log.Printf("Invalid webhook url: %v error: %v\n", myWebhookUrl, err)
+			return Invalid
+		}
+	}
+	myWebPushSubscription := strings.TrimSpace(appChannelsIn.WebPushSubscription)
+	if len(myWebPushSubscription) > 0 {
+		var subscription webPushSubscription
+		if err := json.Unmarshal([]byte(myWebPushSubscription), &subscription); err != nil {
+			log.Printf("Invalid web push subscription: %v\n", err)
+			return Invalid
+		}
+		if err := netguard.CheckPublicUrl(strings.TrimSpace(subscription.Endpoint)); err != nil {
+			log.Printf("Invalid web push endpoint: %v error: %v\n", subscription.Endpoint, err)
+			return Invalid
+		}
+	}
+	appUser, err := appUserRepository.FindByUsername(appChannelsIn.Username)
+	if err != nil {
+		return Invalid
+	}
+	appUser.NotificationChannels = strings.Join(myChannels, ",")
+	if appUser.Email != myEmail {
+		// a changed address has to be verified again with POST /appuser/email
+		appUser.EmailVerified = false
+	}
+	appUser.Email = myEmail
+	appUser.WebhookUrl = myWebhookUrl
+	appUser.WebPushSubscription = myWebPushSubscription
+	if err := appUserRepository.Save(&appUser); err != nil {
+		log.Printf("Store notification channels failed: %v\n", err)
+		return Failed
+	}
+	return Ok
+}
+
+func StoreNotificationSettings(appSettingsIn AppNotificationSettingsIn) DbResult {
+	if appSettingsIn.NotificationCooldown < 0 {
+		log.Printf("Invalid notification cooldown: %v\n", appSettingsIn.NotificationCooldown)
+		return Invalid
+	}
+	myDigestMode, err := aumodel.ParseDigestMode(appSettingsIn.DigestMode)
+	if err != nil {
+		log.Printf("Invalid digest mode: %v\n", appSettingsIn.DigestMode)
+		return Invalid
+	}
+	//both or none of the quiet hours
+	myQuietHoursStart := strings.TrimSpace(appSettingsIn.QuietHoursStart)
+	myQuietHoursEnd := strings.TrimSpace(appSettingsIn.QuietHoursEnd)
+	if len(myQuietHoursStart) > 0 || len(myQuietHoursEnd) > 0 {
+		_, startErr := aumodel.ParseClock(myQuietHoursStart)
+		_, endErr := aumodel.ParseClock(myQuietHoursEnd)
+		if startErr != nil || endErr != nil {
+			log.Printf("Invalid quiet hours: %v - %v\n", myQuietHoursStart, myQuietHoursEnd)
+			return Invalid
+		}
+	}
+	appUser, err := appUserRepository.FindByUsername(appSettingsIn.Username)
+	if err != nil {
+		return Invalid
+	}
+	appUser.NotificationCooldown = appSettingsIn.NotificationCooldown
+	appUser.RepeatUnchanged = appSettingsIn.RepeatUnchanged
+	appUser.QuietHoursStart = myQuietHoursStart
+	appUser.QuietHoursEnd = myQuietHoursEnd
+	appUser.DigestMode = string(myDigestMode)
+	if err := appUserRepository.Save(&appUser); err != nil {
+		log.Printf("Store notification settings failed: %v\n", err)
+		return Failed
+	}
+	return Ok
+}
+
+func validNotificationChannel(channel aumodel.NotificationChannel) bool {
+	for _, myChannel := range aumodel.NotificationChannels {
+		if myChannel == channel {
+			return true
+		}
+	}
+	return false
+}
+
 func ImportPostCodeData(postCodeData []PostCodeData) {
 	postCodeLocations := mapToPostCodeLocation(postCodeData)
-	var oriPostCodeLocations []aumodel.PostCodeLocation
-	database.DB.Find(&oriPostCodeLocations)
+	oriPostCodeLocations := appUserRepository.FindAllPostCodeLocations()
 	postCodeLocationsMap := make(map[int32]aumodel.PostCodeLocation)
 	for _, oriPostCodeLocation := range oriPostCodeLocations {
 		postCodeLocationsMap[oriPostCodeLocation.PostCode] = oriPostCodeLocation
 	}
-	database.DB.Transaction(func(tx *gorm.DB) error {
-		for _, postCodeLocation := range postCodeLocations {
-			oriPostCodeLocation, exists := postCodeLocationsMap[postCodeLocation.PostCode]
-			if exists {
-				oriPostCodeLocation.Label = postCodeLocation.Label
-				oriPostCodeLocation.PostCode = postCodeLocation.PostCode
-				oriPostCodeLocation.Population = postCodeLocation.Population
-				oriPostCodeLocation.SquareKM = postCodeLocation.SquareKM
-				oriPostCodeLocation.CenterLongitude = postCodeLocation.CenterLongitude
-				oriPostCodeLocation.CenterLatitude = postCodeLocation.CenterLatitude
-				tx.Save(&oriPostCodeLocation)
-			} else {
-				tx.Save(&postCodeLocation)
-			}
+	myPostCodeLocations := []aumodel.PostCodeLocation{}
+	for _, postCodeLocation := range postCodeLocations {
+		oriPostCodeLocation, exists := postCodeLocationsMap[postCodeLocation.PostCode]
+		if exists {
+			oriPostCodeLocation.Label = postCodeLocation.Label
+			oriPostCodeLocation.PostCode = postCodeLocation.PostCode
+			oriPostCodeLocation.Population = postCodeLocation.Population
+			oriPostCodeLocation.SquareKM = postCodeLocation.SquareKM
+			oriPostCodeLocation.CenterLongitude = postCodeLocation.CenterLongitude
+			oriPostCodeLocation.CenterLatitude = postCodeLocation.CenterLatitude
+			myPostCodeLocations = append(myPostCodeLocations, oriPostCodeLocation)
+		} else {
+			myPostCodeLocations = append(myPostCodeLocations, postCodeLocation)
 		}
-		return nil
-	})
+	}
+	if err := appUserRepository.SavePostCodeLocations(myPostCodeLocations); err != nil {
+		log.Printf("PostCodeLocations save failed: %v\n", err)
+	}
 	log.Printf("PostCodeLocations saved: %v\n", len(postCodeLocations))
 }
 
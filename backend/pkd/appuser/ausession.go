@@ -0,0 +1,211 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package appuser
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"react-and-go/pkd/appuser/aumodel"
+	"react-and-go/pkd/config"
+	"react-and-go/pkd/token"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	LogoutReason    = "logout"
+	RevokedReason   = "revoked"
+	RevokeAllReason = "revoke-all"
+	ReuseReason     = "reuse"
+	LockedReason    = "locked"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type sessionCheckEntry struct {
+	username  string
+	active    bool
+	checkedAt time.Time
+}
+
+// the session checks are cached for SESSION_CHECK_SECONDS, revocations on other instances are seen after that time
+var sessionCheckCache = make(map[string]sessionCheckEntry)
+var sessionCheckMutex sync.Mutex
+
+// returns the session id and the refresh token
+func createSession(username string, userAgent string, ipAddress string) (string, string, error) {
+	mySessionId, err := uuid.NewRandom()
+	if err != nil {
+		return "", "", err
+	}
+	myRefreshToken, myTokenHash, err := newOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	if len(userAgent) > 256 {
+		userAgent = userAgent[:256]
+	}
+	userSession := aumodel.UserSession{ID: mySessionId.String(), Username: username, UserAgent: userAgent, IpAddress: ipAddress, CreatedAt: now,
+		LastUsedAt: now, ExpiresAt: refreshTokenExpiry(now)}
+	refreshToken := aumodel.RefreshToken{TokenHash: myTokenHash, SessionID: userSession.ID, CreatedAt: now, ExpiresAt: userSession.ExpiresAt}
+	if err := sessionRepository.CreateSession(&userSession, refreshToken); err != nil {
+		return "", "", err
+	}
+	return userSession.ID, myRefreshToken, nil
+}
+
+// the opaque token for the client and its hash for the database
+func newOpaqueToken() (string, string, error) {
+	myBytes := make([]byte, 32)
+	if _, err := rand.Read(myBytes); err != nil {
+		return "", "", err
+	}
+	myToken := base64.RawURLEncoding.EncodeToString(myBytes)
+	return myToken, hashToken(myToken), nil
+}
+
+func hashToken(tokenStr string) string {
+	myHash := sha256.Sum256([]byte(tokenStr))
+	return hex.EncodeToString(myHash[:])
+}
+
+func refreshTokenExpiry(now time.Time) time.Time {
+	return now.AddDate(0, 0, config.ReadIntEnv("REFRESH_TOKEN_DAYS", 30))
+}
+
+// returns a new access token and the next refresh token, a reused refresh token revokes its session
+func RefreshSession(refreshTokenStr string) (string, string, int) {
+	refreshToken, err := sessionRepository.FindRefreshToken(hashToken(strings.TrimSpace(refreshTokenStr)))
+	if err != nil {
+		return "", "", http.StatusUnauthorized
+	}
+	now := time.Now()
+	if refreshToken.Used {
+		revokeReusedSession(refreshToken.SessionID, now)
+		return "", "", http.StatusUnauthorized
+	}
+	userSession, err := sessionRepository.FindSession(refreshToken.SessionID)
+	if err != nil || !userSession.ActiveAt(now) || !now.Before(refreshToken.ExpiresAt) {
+		return "", "", http.StatusUnauthorized
+	}
+	appUser, err := appUserRepository.FindByUsername(userSession.Username)
+	if err != nil || appUser.Locked {
+		return "", "", http.StatusUnauthorized
+	}
+	myRefreshToken, myTokenHash, err := newOpaqueToken()
+	if err != nil {
+		log.Printf("Refresh token creation failed: %v\n", err)
+		return "", "", http.StatusInternalServerError
+	}
+	newRefreshToken := aumodel.RefreshToken{TokenHash: myTokenHash, SessionID: userSession.ID, CreatedAt: now, ExpiresAt: refreshTokenExpiry(now)}
+	rotated, err := sessionRepository.RotateRefreshToken(refreshToken.TokenHash, newRefreshToken, now)
+	if err != nil {
+		log.Printf("Refresh token rotation failed: %v\n", err)
+		return "", "", http.StatusInternalServerError
+	}
+	if !rotated {
+		// a concurrent request used the token first
+		revokeReusedSession(refreshToken.SessionID, now)
+		return "", "", http.StatusUnauthorized
+	}
+	result, err := token.CreateToken(token.TokenUser{Username: appUser.Username, Roles: UserRoles(appUser), SessionId: userSession.ID})
+	if err != nil {
+		log.Printf("Failed to create jwt token: %v\n", err)
+		return "", "", http.StatusInternalServerError
+	}
+	return result, myRefreshToken, http.StatusOK
+}
+
+func revokeReusedSession(sessionId string, now time.Time) {
+	log.Printf("Refresh token reused, session revoked: %v\n", sessionId)
+	revokeSessions([]string{sessionId}, ReuseReason, now)
+}
+
+func revokeSessions(sessionIds []string, reason string, now time.Time) int64 {
+	revoked, err := sessionRepository.RevokeSessions(sessionIds, reason, now)
+	if err != nil {
+		log.Printf("Revoke sessions failed: %v\n", err)
+	}
+	clearSessionChecks(sessionIds)
+	return revoked
+}
+
+func clearSessionChecks(sessionIds []string) {
+	sessionCheckMutex.Lock()
+	defer sessionCheckMutex.Unlock()
+	for _, sessionId := range sessionIds {
+		delete(sessionCheckCache, sessionId)
+	}
+}
+
+// used by token.CheckToken for every request
+func SessionActive(username string, sessionId string) bool {
+	now := time.Now()
+	sessionCheckMutex.Lock()
+	entry, found := sessionCheckCache[sessionId]
+	sessionCheckMutex.Unlock()
+	if !found || now.Sub(entry.checkedAt) > time.Duration(config.ReadIntEnv("SESSION_CHECK_SECONDS", 10))*time.Second {
+		userSession, err := sessionRepository.FindSession(sessionId)
+		entry = sessionCheckEntry{username: userSession.Username, active: err == nil && userSession.ActiveAt(now), checkedAt: now}
+		sessionCheckMutex.Lock()
+		if len(sessionCheckCache) > 10000 {
+			sessionCheckCache = make(map[string]sessionCheckEntry)
+		}
+		sessionCheckCache[sessionId] = entry
+		sessionCheckMutex.Unlock()
+	}
+	return entry.active && entry.username == username
+}
+
+func FindSessions(username string) []aumodel.UserSession {
+	return sessionRepository.FindSessions(username)
+}
+
+// users can only revoke their own sessions
+func RevokeSession(username string, sessionId string, reason string) error {
+	userSession, err := sessionRepository.FindSession(sessionId)
+	if err != nil || userSession.Username != username {
+		return ErrSessionNotFound
+	}
+	revokeSessions([]string{sessionId}, reason, time.Now())
+	return nil
+}
+
+func RevokeAllSessions(username string, reason string) int64 {
+	sessionIds := []string{}
+	for _, userSession := range sessionRepository.FindSessions(username) {
+		if !userSession.Revoked {
+			sessionIds = append(sessionIds, userSession.ID)
+		}
+	}
+	return revokeSessions(sessionIds, reason, time.Now())
+}
+
+// the expired and revoked sessions are kept for SESSION_KEEP_DAYS
+func DeleteOldSessions() {
+	before := time.Now().AddDate(0, 0, -config.ReadIntEnv("SESSION_KEEP_DAYS", 7))
+	if deleted, err := sessionRepository.DeleteSessionsBefore(before); err != nil {
+		log.Printf("Delete sessions failed: %v\n", err)
+	} else {
+		log.Printf("Sessions deleted: %v\n", deleted)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package appuser_test
+
+import (
+	"net/http"
+	"react-and-go/pkd/app"
+	"react-and-go/pkd/appuser"
+	"react-and-go/pkd/token"
+	"testing"
+)
+
+const (
+	testUsername = "testuser"
+	testPassword = "testpassword"
+)
+
+func newTestApp(t *testing.T) *app.App {
+	t.Setenv("JWT_KEY_SECRET", "test-key-secret-of-32-characters")
+	myApp := app.NewInMemoryApp()
+	myApp.Wire()
+	token.RotateKeys()
+	if result := appuser.Signin(appuser.AppUserIn{Username: testUsername, Password: testPassword}); result != appuser.Ok {
+		t.Fatalf("Signin failed: %v", result)
+	}
+	return myApp
+}
+
+// returns the access token and the refresh token
+func login(t *testing.T, password string) (string, string) {
+	accessToken, refreshToken, status, _, _, _, _, _, _, _ := appuser.Login(appuser.AppUserIn{Username: testUsername, Password: password})
+	if status != http.StatusOK || len(accessToken) == 0 || len(refreshToken) == 0 {
+		t.Fatalf("Login failed: %v", status)
+	}
+	return accessToken, refreshToken
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	newTestApp(t)
+	_, refreshToken := login(t, testPassword)
+	accessToken, nextRefreshToken, status := appuser.RefreshSession(refreshToken)
+	if status != http.StatusOK || len(accessToken) == 0 || len(nextRefreshToken) == 0 || nextRefreshToken == refreshToken {
+		t.Fatalf("Refresh failed: %v", status)
+	}
+	sessions := appuser.FindSessions(testUsername)
+	if len(sessions) != 1 || !appuser.SessionActive(testUsername, sessions[0].ID) {
+		t.Fatalf("Session not active: %+v", sessions)
+	}
+	if _, _, status := appuser.RefreshSession(nextRefreshToken); status != http.StatusOK {
+		t.Errorf("Refresh with the rotated token failed: %v", status)
+	}
+	if _, _, status := appuser.RefreshSession("unknown"); status != http.StatusUnauthorized {
+		t.Errorf("Refresh with an unknown token: %v", status)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	newTestApp(t)
+	_, refreshToken := login(t, testPassword)
+	_, nextRefreshToken, status := appuser.RefreshSession(refreshToken)
+	if status != http.StatusOK {
+		t.Fatalf("Refresh failed: %v", status)
+	}
+	if _, _, status := appuser.RefreshSession(refreshToken); status != http.StatusUnauthorized {
+		t.Errorf("Reused refresh token accepted: %v", status)
+	}
+	sessions := appuser.FindSessions(testUsername)
+	if len(sessions) != 1 || !sessions[0].Revoked || sessions[0].RevokeReason != appuser.ReuseReason {
+		t.Errorf("Session not revoked: %+v", sessions)
+	}
+	if appuser.SessionActive(testUsername, sessions[0].ID) {
+		t.Errorf("Revoked session active")
+	}
+	// the token of the legitimate client stops working too
+	if _, _, status := appuser.RefreshSession(nextRefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("Refresh in revoked session: %v", status)
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	newTestApp(t)
+	login(t, testPassword)
+	login(t, testPassword)
+	sessions := appuser.FindSessions(testUsername)
+	if len(sessions) != 2 {
+		t.Fatalf("Sessions: %+v", sessions)
+	}
+	for _, userSession := range sessions {
+		// fills the session check cache
+		if !appuser.SessionActive(testUsername, userSession.ID) {
+			t.Fatalf("Session not active: %+v", userSession)
+		}
+	}
+	if err := appuser.RevokeSession("otheruser", sessions[0].ID, appuser.RevokedReason); err != appuser.ErrSessionNotFound {
+		t.Errorf("Session of another user revoked: %v", err)
+	}
+	if err := appuser.RevokeSession(testUsername, sessions[0].ID, appuser.RevokedReason); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	// the revocation applies at once on this instance
+	if appuser.SessionActive(testUsername, sessions[0].ID) || !appuser.SessionActive(testUsername, sessions[1].ID) {
+		t.Errorf("Wrong session revoked")
+	}
+	if revoked := appuser.RevokeAllSessions(testUsername, appuser.RevokeAllReason); revoked != 1 {
+		t.Errorf("Revoked sessions: %v", revoked)
+	}
+	if appuser.SessionActive(testUsername, sessions[1].ID) {
+		t.Errorf("Session active after revoke all")
+	}
+}
@@ -14,6 +14,9 @@ package config
 
 import (
 	"log"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -24,3 +27,17 @@ func LoadEnvVariables() {
 		log.Fatal("Error loading .env file")
 	}
 }
+
+// the positive int value of the environment variable, the default value if it is missing or invalid
+func ReadIntEnv(name string, defaultValue int) int {
+	result, err := strconv.Atoi(strings.TrimSpace(os.Getenv(name)))
+	if err != nil || result <= 0 {
+		return defaultValue
+	}
+	return result
+}
+
+// the largest radius in km of the searches around a location
+func MaxSearchRadius() float64 {
+	return float64(ReadIntEnv("SEARCH_MAX_RADIUS_KM", 20))
+}
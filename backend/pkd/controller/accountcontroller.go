@@ -0,0 +1,123 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package controller
+
+import (
+	"log"
+	"net/http"
+	"react-and-go/pkd/appuser"
+	aubody "react-and-go/pkd/controller/aumodel"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the other sessions of the user are revoked
+func postPassword(c *gin.Context) {
+	username, exists1 := c.Get("user")
+	uuid, exists2 := c.Get("uuid")
+	if !exists1 || !exists2 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	var passwordChangeRequest aubody.PasswordChangeRequest
+	if err := c.Bind(&passwordChangeRequest); err != nil {
+		log.Printf("postPassword: %v", err.Error())
+		return
+	}
+	c.Status(appuser.ChangePassword(username.(string), uuid.(string), passwordChangeRequest.OldPassword, passwordChangeRequest.NewPassword))
+}
+
+// always accepted, the response does not show if the user exists
+func postPasswordForgot(c *gin.Context) {
+	var passwordForgotRequest aubody.PasswordForgotRequest
+	if err := c.Bind(&passwordForgotRequest); err != nil {
+		log.Printf("postPasswordForgot: %v", err.Error())
+		return
+	}
+	appuser.RequestPasswordReset(passwordForgotRequest.Username)
+	c.Status(http.StatusAccepted)
+}
+
+func postPasswordReset(c *gin.Context) {
+	var passwordResetRequest aubody.PasswordResetRequest
+	if err := c.Bind(&passwordResetRequest); err != nil {
+		log.Printf("postPasswordReset: %v", err.Error())
+		return
+	}
+	c.Status(appuser.ResetPassword(passwordResetRequest.Token, passwordResetRequest.NewPassword))
+}
+
+func postEmail(c *gin.Context) {
+	username, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	var emailRequest aubody.EmailRequest
+	if err := c.Bind(&emailRequest); err != nil {
+		log.Printf("postEmail: %v", err.Error())
+		return
+	}
+	switch appuser.StoreEmail(username.(string), emailRequest.Email) {
+	case appuser.Ok:
+		c.Status(http.StatusAccepted)
+	case appuser.Invalid:
+		c.AbortWithStatus(http.StatusBadRequest)
+	default:
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}
+}
+
+func postEmailVerify(c *gin.Context) {
+	var emailVerifyRequest aubody.EmailVerifyRequest
+	if err := c.Bind(&emailVerifyRequest); err != nil {
+		log.Printf("postEmailVerify: %v", err.Error())
+		return
+	}
+	c.Status(appuser.VerifyEmail(emailVerifyRequest.Token))
+}
+
+func getUserDataExport(c *gin.Context) {
+	username, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	result, err := appuser.ExportUserData(username.(string))
+	if err != nil {
+		log.Printf("getUserDataExport: %v", err.Error())
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// erases the account with its data and returns the export of the data
+func deleteAccount(c *gin.Context) {
+	username, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	var accountDeleteRequest aubody.AccountDeleteRequest
+	if err := c.Bind(&accountDeleteRequest); err != nil {
+		log.Printf("deleteAccount: %v", err.Error())
+		return
+	}
+	result, status := appuser.DeleteAccount(username.(string), accountDeleteRequest.Password)
+	if status != http.StatusOK {
+		c.AbortWithStatus(status)
+		return
+	}
+	c.JSON(status, result)
+}
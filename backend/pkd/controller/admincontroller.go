@@ -0,0 +1,157 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"react-and-go/pkd/appuser"
+	aubody "react-and-go/pkd/controller/aumodel"
+	"react-and-go/pkd/messaging"
+	"react-and-go/pkd/notification"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const auditChangesKey = "auditChanges"
+const maxAuditBodySize = 1024
+
+// every request of the admin routes, reads included, is logged after the response
+// the changing requests are logged with their json body and the changed values the handler sets
+func auditAdminRequest(c *gin.Context) {
+	requestBody := readAuditBody(c)
+	c.Next()
+	username, exists := c.Get("user")
+	if !exists {
+		return
+	}
+	targets := []string{}
+	for _, param := range c.Params {
+		targets = append(targets, fmt.Sprintf("%v=%v", param.Key, param.Value))
+	}
+	if len(c.Request.URL.RawQuery) > 0 {
+		targets = append(targets, c.Request.URL.RawQuery)
+	}
+	details := []string{}
+	if len(requestBody) > 0 {
+		details = append(details, "body: "+requestBody)
+	}
+	if changes, exists := c.Get(auditChangesKey); exists {
+		details = append(details, changes.(string))
+	}
+	appuser.StoreAuditLogEntry(username.(string), c.Request.Method, c.FullPath(), strings.Join(targets, "&"), strings.Join(details, "; "),
+		c.Writer.Status())
+}
+
+// the read start of the body is put back in front of the rest for the handler
+func readAuditBody(c *gin.Context) string {
+	if c.Request.Method == http.MethodGet || c.Request.Body == nil || c.ContentType() != gin.MIMEJSON {
+		return ""
+	}
+	myBody, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAuditBodySize))
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(myBody), c.Request.Body))
+	if err != nil {
+		log.Printf("readAuditBody: %v", err.Error())
+		return ""
+	}
+	var myCompactBody bytes.Buffer
+	if err := json.Compact(&myCompactBody, myBody); err != nil {
+		return string(myBody)
+	}
+	return myCompactBody.String()
+}
+
+func getAdminUsers(c *gin.Context) {
+	result := []aubody.AdminUserResponse{}
+	for _, appUser := range appuser.FindAllUsers() {
+		result = append(result, aubody.AdminUserResponse{Username: appUser.Username, Uuid: appUser.Uuid, Roles: appuser.UserRoles(appUser),
+			Locked: appUser.Locked, CreatedAt: appUser.CreatedAt})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func postLockUser(c *gin.Context) {
+	storeUserLock(c, true)
+}
+
+func postUnlockUser(c *gin.Context) {
+	storeUserLock(c, false)
+}
+
+func storeUserLock(c *gin.Context, locked bool) {
+	adminUsername, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	if result := appuser.LockUser(adminUsername.(string), c.Param("username"), locked); result != appuser.Ok {
+		c.AbortWithStatus(mapDbResultToStatus(result))
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func putUserRoles(c *gin.Context) {
+	adminUsername, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	var rolesRequest aubody.UserRolesRequest
+	if err := c.Bind(&rolesRequest); err != nil {
+		log.Printf("putUserRoles: %v", err.Error())
+		return
+	}
+	previousRoles, result := appuser.StoreUserRoles(adminUsername.(string), c.Param("username"), rolesRequest.Roles)
+	if result != appuser.Ok {
+		c.AbortWithStatus(mapDbResultToStatus(result))
+		return
+	}
+	c.Set(auditChangesKey, fmt.Sprintf("roles: %v -> %v", strings.Join(previousRoles, ","), strings.Join(rolesRequest.Roles, ",")))
+	c.JSON(http.StatusOK, rolesRequest)
+}
+
+func mapDbResultToStatus(result appuser.DbResult) int {
+	if result == appuser.Failed {
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+func getSystemStatus(c *gin.Context) {
+	appUsers := appuser.FindAllUsers()
+	lockedUsers := 0
+	for _, appUser := range appUsers {
+		if appUser.Locked {
+			lockedUsers++
+		}
+	}
+	c.JSON(http.StatusOK, aubody.SystemStatusResponse{Users: len(appUsers), LockedUsers: lockedUsers, Pipeline: messaging.Metrics(),
+		Outbox: notification.OutboxStatusCounts()})
+}
+
+// the newest entries, 'limit' defaults to 100
+func getAuditLog(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	c.JSON(http.StatusOK, appuser.FindAuditLog(limit))
+}
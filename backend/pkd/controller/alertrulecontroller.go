@@ -0,0 +1,105 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package controller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"react-and-go/pkd/appuser"
+	"react-and-go/pkd/appuser/aumodel"
+	aubody "react-and-go/pkd/controller/aumodel"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getAlertRules(c *gin.Context) {
+	username, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	alertRules := appuser.FindAlertRules(username.(string))
+	result := []aubody.AlertRuleResponse{}
+	for _, alertRule := range alertRules {
+		result = append(result, mapToAlertRuleResponse(alertRule, ""))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func postAlertRule(c *gin.Context) {
+	storeAlertRule(c, 0)
+}
+
+func putAlertRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		log.Printf("putAlertRule: %v", err.Error())
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	storeAlertRule(c, uint(id))
+}
+
+func deleteAlertRule(c *gin.Context) {
+	username, exists := c.Get("user")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if !exists || err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	result := appuser.DeleteAlertRule(username.(string), uint(id))
+	httpResult := http.StatusOK
+	message := "Ok"
+	if result != appuser.Ok {
+		httpResult = http.StatusNotFound
+		message = "Invalid"
+	}
+	c.JSON(httpResult, aubody.AlertRuleResponse{Message: message, ID: uint(id)})
+}
+
+func storeAlertRule(c *gin.Context, id uint) {
+	username, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	var alertRuleRequest aubody.AlertRuleRequest
+	if err := c.Bind(&alertRuleRequest); err != nil {
+		log.Printf("storeAlertRule: %v", err.Error())
+	}
+	myAlertRule := appuser.AlertRuleIn{ID: id, Username: username.(string), Name: alertRuleRequest.Name, Latitude: alertRuleRequest.Latitude,
+		Longitude: alertRuleRequest.Longitude, SearchRadius: alertRuleRequest.SearchRadius, FuelType: alertRuleRequest.FuelType,
+		TargetPrice: alertRuleRequest.TargetPrice, ActiveFrom: alertRuleRequest.ActiveFrom, ActiveTo: alertRuleRequest.ActiveTo,
+		Brands: alertRuleRequest.Brands, Active: alertRuleRequest.Active, ReferenceType: alertRuleRequest.ReferenceType,
+		ReferenceRadius: alertRuleRequest.ReferenceRadius, MinDifference: alertRuleRequest.MinDifference}
+	alertRule, result := appuser.StoreAlertRule(myAlertRule)
+	if result != appuser.Ok {
+		c.JSON(http.StatusBadRequest, aubody.AlertRuleResponse{Message: "Invalid", ID: id})
+		return
+	}
+	c.JSON(http.StatusOK, mapToAlertRuleResponse(alertRule, "Ok"))
+}
+
+func mapToAlertRuleResponse(alertRule aumodel.AlertRule, message string) aubody.AlertRuleResponse {
+	brands := []string{}
+	if len(alertRule.Brands) > 0 {
+		brands = strings.Split(alertRule.Brands, ",")
+	}
+	return aubody.AlertRuleResponse{Message: message, ID: alertRule.ID, Name: alertRule.Name, Latitude: alertRule.Latitude, Longitude: alertRule.Longitude,
+		SearchRadius: alertRule.SearchRadius, FuelType: alertRule.FuelType, TargetPrice: fmt.Sprintf("%v", float64(alertRule.TargetPrice)/1000),
+		ActiveFrom: alertRule.ActiveFrom, ActiveTo: alertRule.ActiveTo, Brands: brands, Active: alertRule.Active, ReferenceType: string(alertRule.Reference()),
+		ReferenceRadius: alertRule.ReferenceRadius, MinDifference: fmt.Sprintf("%v", float64(alertRule.MinDifference)/1000)}
+}
@@ -17,11 +17,12 @@ import (
 	"log"
 	"math"
 	"net/http"
+	"os"
 	"react-and-go/pkd/appuser"
 	"react-and-go/pkd/appuser/aumodel"
 	aufile "react-and-go/pkd/appuser/file"
 	aubody "react-and-go/pkd/controller/aumodel"
-	token "react-and-go/pkd/token"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -32,8 +33,7 @@ func getLogout(c *gin.Context) {
 	username, exists1 := c.Get("user")
 	uuid, exists2 := c.Get("uuid")
 	if exists1 && exists2 {
-		token.LoggedOutUsers = appuser.StoreUserLogout(username.(string), uuid.(string))
-		if len(token.LoggedOutUsers) > 0 {
+		if err := appuser.RevokeSession(username.(string), uuid.(string), appuser.LogoutReason); err == nil {
 			message = ""
 			status = http.StatusOK
 		}
@@ -41,26 +41,6 @@ func getLogout(c *gin.Context) {
 	c.JSON(status, aubody.AppUserResponse{Token: "", Message: message})
 }
 
-func getRefreshToken(c *gin.Context) {
-	status := http.StatusUnauthorized
-	message := "Invalid"
-	result := ""
-	userName, exits := c.Get("user")
-	roles, exists2 := c.Get("roles")
-	if exits && exists2 {
-		//jwt token creation
-		var err error
-		result, err = token.CreateToken(token.TokenUser{Username: userName.(string), Roles: []string{roles.(string)}})
-		if err != nil {
-			log.Printf("Failed to create jwt token: %v\n", err)
-		} else {
-			status = http.StatusOK
-			message = ""
-		}
-	}
-	c.JSON(status, aubody.AppUserResponse{Token: result, Message: message})
-}
-
 func getLocation(c *gin.Context) {
 	locationStr := c.Query("location")
 	postCodeLocations := appuser.FindLocation(locationStr)
@@ -87,7 +67,7 @@ func mapToPostCodeLocation(postCodeLocations []aumodel.PostCodeLocation) []aubod
 	return result
 }
 
-func getPostCodeCoordinates(c *gin.Context) {
+func postImportPostCodes(c *gin.Context) {
 	filePath := c.Query("filename")
 	aufile.UpdatePostCodeCoordinates(filePath)
 }
@@ -116,13 +96,14 @@ func postLogin(c *gin.Context) {
 	if err := c.Bind(&appUserRequest); err != nil {
 		log.Printf("postLogin: %v", err.Error())
 	}
-	myAppUser := appuser.AppUserIn{Username: appUserRequest.Username, Password: appUserRequest.Password, Uuid: ""}
-	result, status, userUuid, userLongitude, userLatitude, searchRadius, targetE5, targetE10, targetDiesel := appuser.Login(myAppUser)
+	myAppUser := appuser.AppUserIn{Username: appUserRequest.Username, Password: appUserRequest.Password, Uuid: "", UserAgent: c.Request.UserAgent(),
+		IpAddress: c.ClientIP()}
+	result, refreshToken, status, userUuid, userLongitude, userLatitude, searchRadius, targetE5, targetE10, targetDiesel := appuser.Login(myAppUser)
 	var message = ""
 	if status != http.StatusOK {
 		message = "Login failed."
 	}
-	appAuResponse := aubody.AppUserResponse{Token: result, Message: message, Uuid: userUuid, Longitude: userLongitude, Latitude: userLatitude,
+	appAuResponse := aubody.AppUserResponse{Token: result, RefreshToken: refreshToken, Message: message, Uuid: userUuid, Longitude: userLongitude, Latitude: userLatitude,
 		SearchRadius: searchRadius, TargetE5: fmt.Sprintf("%v", (float64(targetE5) / 1000)), TargetE10: fmt.Sprintf("%v", (float64(targetE10) / 1000)), TargetDiesel: fmt.Sprintf("%v", (float64(targetDiesel) / 1000))}
 	c.JSON(status, appAuResponse)
 }
@@ -158,3 +139,73 @@ func postTargetPrices(c *gin.Context) {
 	}
 	c.JSON(httpResult, aubody.TargetPricesResponse{Message: message, TargetDiesel: appUserRequest.TargetDiesel, TargetE10: appUserRequest.TargetE10, TargetE5: appUserRequest.TargetE5})
 }
+
+// the channels of the user of the token are stored
+func postNotificationChannels(c *gin.Context) {
+	username, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	var channelsRequest aubody.NotificationChannelsRequest
+	if err := c.Bind(&channelsRequest); err != nil {
+		log.Printf("postNotificationChannels: %v", err.Error())
+		return
+	}
+	myChannels := appuser.AppChannelsIn{Username: username.(string), NotificationChannels: channelsRequest.NotificationChannels, Email: channelsRequest.Email,
+		WebhookUrl: channelsRequest.WebhookUrl, WebPushSubscription: channelsRequest.WebPushSubscription}
+	result := appuser.StoreNotificationChannels(myChannels)
+	httpResult := http.StatusOK
+	message := "Ok"
+	if result != appuser.Ok {
+		httpResult = http.StatusBadRequest
+		message = "Invalid"
+	}
+	c.JSON(httpResult, aubody.NotificationChannelsResponse{Message: message, NotificationChannels: channelsRequest.NotificationChannels, Email: channelsRequest.Email,
+		WebhookUrl: channelsRequest.WebhookUrl, VapidPublicKey: strings.TrimSpace(os.Getenv("VAPID_PUBLIC_KEY"))})
+}
+
+func getNotificationSettings(c *gin.Context) {
+	username, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	appUser, err := appuser.FindByUsername(username.(string))
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, aubody.NotificationSettingsResponse{Message: "Ok", NotificationCooldown: appUser.NotificationCooldown, RepeatUnchanged: appUser.RepeatUnchanged,
+		QuietHoursStart: appUser.QuietHoursStart, QuietHoursEnd: appUser.QuietHoursEnd, DigestMode: appUser.DigestMode})
+}
+
+func postNotificationSettings(c *gin.Context) {
+	username, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	var settingsRequest aubody.NotificationSettingsRequest
+	if err := c.Bind(&settingsRequest); err != nil {
+		log.Printf("postNotificationSettings: %v", err.Error())
+		return
+	}
+	mySettings := appuser.AppNotificationSettingsIn{Username: username.(string), NotificationCooldown: settingsRequest.NotificationCooldown,
+		RepeatUnchanged: settingsRequest.RepeatUnchanged, QuietHoursStart: settingsRequest.QuietHoursStart, QuietHoursEnd: settingsRequest.QuietHoursEnd,
+		DigestMode: settingsRequest.DigestMode}
+	result := appuser.StoreNotificationSettings(mySettings)
+	httpResult := http.StatusOK
+	message := "Ok"
+	if result != appuser.Ok {
+		httpResult = http.StatusBadRequest
+		message = "Invalid"
+	}
+	c.JSON(httpResult, aubody.NotificationSettingsResponse{Message: message, NotificationCooldown: settingsRequest.NotificationCooldown,
+		RepeatUnchanged: settingsRequest.RepeatUnchanged, QuietHoursStart: settingsRequest.QuietHoursStart, QuietHoursEnd: settingsRequest.QuietHoursEnd,
+		DigestMode: settingsRequest.DigestMode})
+}
+
+func getWebPushKey(c *gin.Context) {
+	c.JSON(http.StatusOK, aubody.NotificationChannelsResponse{Message: "Ok", VapidPublicKey: strings.TrimSpace(os.Getenv("VAPID_PUBLIC_KEY"))})
+}
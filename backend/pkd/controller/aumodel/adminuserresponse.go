@@ -10,13 +10,14 @@
     See the License for the specific language governing permissions and
     limitations under the License.
 */
-package aumodel
+package aubody
 
 import "time"
 
-type LoggedOutUser struct {
-	ID         int64  `gorm:"primaryKey"`
-	Username   string `gorm:"size:64;not null;index:idx_lou_user_name"`
-	Uuid       string `gorm:"size:64;not null;index:idx_lou_uuid,unique"`
-	LastLogout time.Time
+type AdminUserResponse struct {
+	Username  string
+	Uuid      string
+	Roles     []string
+	Locked    bool
+	CreatedAt time.Time
 }
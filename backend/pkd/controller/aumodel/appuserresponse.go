@@ -14,6 +14,7 @@ package aubody
 
 type AppUserResponse struct {
 	Token        string
+	RefreshToken string
 	Message      string
 	Uuid         string
 	Longitude    float64
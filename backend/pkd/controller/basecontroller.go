@@ -28,21 +28,60 @@ import (
 
 func Start(embeddedFiles fs.FS) {
 	router := gin.Default()
+	router.GET("/.well-known/jwks.json", getJwks)
 	router.POST("/appuser/signin", postSignin)
 	router.POST("/appuser/login", postLogin)
 	router.GET("/appuser/logout", token.CheckToken, getLogout)
 	router.GET("/appuser/location", token.CheckToken, getLocation)
-	router.GET("/appuser/refreshtoken", token.CheckToken, getRefreshToken)
+	router.POST("/appuser/token/refresh", postRefreshToken)
+	router.GET("/appuser/sessions", token.CheckToken, getSessions)
+	router.DELETE("/appuser/sessions", token.CheckToken, deleteSessions)
+	router.DELETE("/appuser/sessions/:id", token.CheckToken, deleteSession)
+	router.POST("/appuser/password", token.CheckToken, postPassword)
+	router.POST("/appuser/password/forgot", postPasswordForgot)
+	router.POST("/appuser/password/reset", postPasswordReset)
+	router.POST("/appuser/email", token.CheckToken, postEmail)
+	router.POST("/appuser/email/verify", postEmailVerify)
+	router.GET("/appuser/export", token.CheckToken, getUserDataExport)
+	router.DELETE("/appuser", token.CheckToken, deleteAccount)
 	router.POST("/appuser/locationradius", token.CheckToken, postUserLocationRadius)
 	router.POST("/appuser/targetprices", token.CheckToken, postTargetPrices)
-	router.GET("/config/updategs", token.CheckToken, gsclient.UpdateGasStations)
-	router.GET("/config/updatepc", token.CheckToken, getPostCodeCoordinates)
+	router.POST("/appuser/notificationchannels", token.CheckToken, postNotificationChannels)
+	router.GET("/appuser/notificationsettings", token.CheckToken, getNotificationSettings)
+	router.POST("/appuser/notificationsettings", token.CheckToken, postNotificationSettings)
+	router.GET("/appuser/notificationsuppressions", token.CheckToken, getNotificationSuppressions)
+	router.GET("/appuser/webpushkey", token.CheckToken, getWebPushKey)
+	router.GET("/appuser/alertrules", token.CheckToken, getAlertRules)
+	router.POST("/appuser/alertrules", token.CheckToken, postAlertRule)
+	router.PUT("/appuser/alertrules/:id", token.CheckToken, putAlertRule)
+	router.DELETE("/appuser/alertrules/:id", token.CheckToken, deleteAlertRule)
 	router.GET("/gasprice/:id", token.CheckToken, getGasPriceByGasStationId)
+	router.GET("/gasprice/statistics/:id", token.CheckToken, getPriceStatisticsByGasStationId)
+	router.POST("/gasprice/statistics/location", token.CheckToken, searchPriceStatisticsLocation)
+	router.GET("/gasprice/prediction/:id", token.CheckToken, getPricePredictionByGasStationId)
+	router.POST("/gasprice/prediction/location", token.CheckToken, searchPricePredictionLocation)
 	router.GET("/gasstation/:id", token.CheckToken, getGasStationById)
 	router.POST("/gasstation/search/place", token.CheckToken, searchGasStationPlace)
 	router.POST("/gasstation/search/location", token.CheckToken, searchGasStationLocation)
+	router.POST("/gasstation/search/nearest", token.CheckToken, searchGasStationNearest)
+	router.POST("/gasstation/search/route", token.CheckToken, searchGasStationRoute)
 	router.GET("/usernotification/new/:useruuid", token.CheckToken, getNewUserNotifications)
 	router.GET("/usernotification/current/:useruuid", token.CheckToken, getCurrentUserNotifications)
+	router.GET("/stream/updates", token.CheckToken, getUpdateStream)
+	adminGroup := router.Group("/admin", token.CheckToken, auditAdminRequest, token.RequireRole(token.AdminRole))
+	adminGroup.GET("/users", getAdminUsers)
+	adminGroup.POST("/users/:username/lock", postLockUser)
+	adminGroup.POST("/users/:username/unlock", postUnlockUser)
+	adminGroup.PUT("/users/:username/roles", putUserRoles)
+	adminGroup.POST("/import/gasstations", gsclient.UpdateGasStations)
+	adminGroup.POST("/import/postcodes", postImportPostCodes)
+	adminGroup.GET("/quarantine", getQuarantinedPrices)
+	adminGroup.POST("/quarantine/:id/confirm", postConfirmQuarantinedPrice)
+	adminGroup.POST("/quarantine/:id/reject", postRejectQuarantinedPrice)
+	adminGroup.GET("/status", getSystemStatus)
+	adminGroup.GET("/pipeline", getPipelineMetrics)
+	adminGroup.GET("/outbox", getOutboxStatus)
+	adminGroup.GET("/auditlog", getAuditLog)
 
 	myPort := strings.TrimSpace(os.Getenv("PORT"))
 	portNum, err := strconv.ParseInt(myPort, 10, 0)
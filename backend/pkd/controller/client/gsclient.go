@@ -13,12 +13,15 @@
 package gsclient
 
 import (
+	"compress/gzip"
 	"encoding/csv"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"react-and-go/pkd/gasstation"
 	"strconv"
 	"strings"
@@ -27,68 +30,129 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-type gsResponse struct {
-	Ok       bool         `json:"ok"`
-	License  string       `json:"license"`
-	Data     string       `json:"data"`
-	Status   string       `json:"status"`
-	Stations []gsStations `json:"stations"`
+const stationsUrl = "https://dev.azure.com/tankerkoenig/362e70d1-bafa-4cf7-a346-1f3613304973/_apis/git/repositories/0d6e7286-91e4-402c-af56-fa75be1f223d/Items?path=/stations/%04d/%02d/%04d-%02d-%02d-stations.csv" +
+	"&recursionLevel=0&includeContentMetadata=true&versionDescriptor.version=master&versionDescriptor.versionOptions=0&versionDescriptor.versionType=0&includeContent=true&resolveLfs=true"
+
+// days that are searched back for the latest stations csv
+const latestSearchDays = 14
+
+var errStationsNotFound = errors.New("stations csv not found")
+
+// imports the stations csv of the 'date' (YYYY-MM-DD), of the 'file' in STATION_IMPORT_PATH or of the latest available date
+func UpdateGasStations(c *gin.Context) {
+	dateStr := strings.TrimSpace(c.Query("date"))
+	fileName := strings.TrimSpace(c.Query("file"))
+	var report gasstation.StationImportReport
+	var err error
+	if len(fileName) > 0 {
+		report, err = ImportGasStationsFile(fileName)
+	} else if len(dateStr) > 0 {
+		date, parseErr := time.Parse("2006-01-02", dateStr)
+		if parseErr != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		report, err = ImportGasStationsOfDate(date)
+	} else {
+		report, err = ImportLatestGasStations()
+	}
+	if errors.Is(err, errStationsNotFound) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Station import failed: %v\n", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, report)
 }
 
-type gsStations struct {
-	Id          string  `json:"id"`
-	Name        string  `json:"name"`
-	Brand       string  `json:"brand"`
-	Street      string  `json:"street"`
-	Place       string  `json:"place"`
-	Lat         float64 `json:"lat"`
-	Lng         float64 `json:"lng"`
-	Dist        float64 `json:"dist"`
-	Diesel      float64 `json:"diesel"`
-	E5          float64 `json:"e5"`
-	E10         float64 `json:"e10"`
-	IsOpen      bool    `json:"isOpen"`
-	HouseNumber string  `json:"houseNumber"`
-	PostCode    int     `json:"postCode"`
+func ImportLatestGasStations() (gasstation.StationImportReport, error) {
+	for day := 0; day < latestSearchDays; day++ {
+		report, err := ImportGasStationsOfDate(time.Now().AddDate(0, 0, -day))
+		if !errors.Is(err, errStationsNotFound) {
+			return report, err
+		}
+	}
+	return gasstation.StationImportReport{}, errStationsNotFound
 }
 
-func UpdateGasStations(c *gin.Context) {
-	year := 2023
-	month := 1
-	day := 7
-	url := fmt.Sprintf("https://dev.azure.com/tankerkoenig/362e70d1-bafa-4cf7-a346-1f3613304973/_apis/git/repositories/0d6e7286-91e4-402c-af56-fa75be1f223d/Items?path=/stations/%04d/%02d/%04d-%02d-%02d-stations.csv"+
-		"&recursionLevel=0&includeContentMetadata=true&versionDescriptor.version=master&versionDescriptor.versionOptions=0&versionDescriptor.versionType=0&includeContent=true&resolveLfs=true", year, month, year, month, day)
-	//fmt.Printf("Url: %v\n", url)
-	response, err := http.Get(url)
+func ImportGasStationsOfDate(date time.Time) (gasstation.StationImportReport, error) {
+	url := fmt.Sprintf(stationsUrl, date.Year(), date.Month(), date.Year(), date.Month(), date.Day())
+	client := http.Client{
+		Timeout: 120 * time.Second,
+	}
+	response, err := client.Get(url)
 	if err != nil {
-		log.Printf("Request failed: %v\n", url)
+		return gasstation.StationImportReport{}, fmt.Errorf("request failed: %v", err)
 	}
 	defer response.Body.Close()
-	reader := csv.NewReader(response.Body)
-	rows, err := reader.ReadAll()
+	if response.StatusCode == http.StatusNotFound {
+		return gasstation.StationImportReport{}, errStationsNotFound
+	}
+	if response.StatusCode >= 300 {
+		return gasstation.StationImportReport{}, fmt.Errorf("response status: %v", response.Status)
+	}
+	return importGasStations(response.Body, date.Format("2006-01-02"))
+}
+
+// the file name is relative to STATION_IMPORT_PATH, files with the suffix '.gz' are unzipped
+func ImportGasStationsFile(fileName string) (gasstation.StationImportReport, error) {
+	filePath := filepath.Join(strings.TrimSpace(os.Getenv("STATION_IMPORT_PATH")), filepath.Base(fileName))
+	file, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return gasstation.StationImportReport{}, errStationsNotFound
+	}
+	if err != nil {
+		return gasstation.StationImportReport{}, err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if strings.HasSuffix(strings.ToLower(filePath), ".gz") {
+		gzReader, err := gzip.NewReader(file)
+		if err != nil {
+			return gasstation.StationImportReport{}, err
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	}
+	return importGasStations(reader, filepath.Base(filePath))
+}
+
+func importGasStations(reader io.Reader, source string) (gasstation.StationImportReport, error) {
+	rows, err := csv.NewReader(reader).ReadAll()
 	if err != nil {
-		log.Println("Cannot read request body:", err)
+		return gasstation.StationImportReport{}, fmt.Errorf("cannot read csv: %v", err)
+	}
+	//an error page instead of the csv must not report all stations as removed
+	if len(rows) == 0 || strings.ToLower(strings.TrimSpace(rows[0][0])) != "uuid" {
+		return gasstation.StationImportReport{}, fmt.Errorf("%w: no csv header", errStationsNotFound)
 	}
 	gasStationImports := convertCsvToGasStationImports(&rows)
-	log.Default().Printf("Result: %v\n", len(gasStationImports))
-	//log.Default().Fatalf("Result: GasStationImport {\nUuid: %v\nStationName: %v\nBrand: %v\nStreet: %v\nHouseNumber: %v\nPostCode: %v\nCity: %v\nLatitude: %v\nLongitude: %v\nFirstActive: %v\nOpeningTimesJson: %v\n",
-	//	gasStationImports[0].Uuid, gasStationImports[0].StationName, gasStationImports[0].Brand, gasStationImports[0].Street, gasStationImports[0].HouseNumber, gasStationImports[0].PostCode, gasStationImports[0].City,
-	//	gasStationImports[0].Latitude, gasStationImports[0].Longitude, gasStationImports[0].FirstActive, gasStationImports[0].OpeningTimesJson)
-	gasstation.UpdateGasStations(&gasStationImports)
+	log.Printf("Stations csv: %v rows: %v stations: %v\n", source, len(rows)-1, len(gasStationImports))
+	if len(gasStationImports) == 0 {
+		return gasstation.StationImportReport{}, errors.New("no stations in csv")
+	}
+	report, err := gasstation.UpdateGasStations(&gasStationImports)
+	report.Source = source
+	return report, err
 }
 
 func convertCsvToGasStationImports(rows *[][]string) []gasstation.GasStationImport {
 	var result []gasstation.GasStationImport
 	for _, row := range *rows {
-		//ignore header
-		if strings.ToLower(row[0]) == "uuid" {
+		//ignore header and incomplete rows
+		if len(row) < 11 || strings.ToLower(row[0]) == "uuid" {
+			continue
+		}
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(row[7]), 64)
+		lng, lngErr := strconv.ParseFloat(strings.TrimSpace(row[8]), 64)
+		if latErr != nil || lngErr != nil {
+			log.Printf("Invalid coordinates of station: %v\n", row[0])
 			continue
 		}
-		lat, _ := strconv.ParseFloat(row[7], 64)
-		lng, _ := strconv.ParseFloat(row[8], 64)
-		//log.Default().Printf("Date: %v", strings.TrimSpace(row[9]))
-		firstActive, _ := time.Parse("2006-02-01 15:04:05-07", strings.TrimSpace(row[9]))
-		//log.Default().Printf("GoDate: %v", firstActive.UTC())
+		firstActive, _ := time.Parse("2006-01-02 15:04:05-07", strings.TrimSpace(row[9]))
 		gsImport := gasstation.GasStationImport{Uuid: row[0],
 			StationName:      row[1],
 			Brand:            row[2],
@@ -105,62 +169,3 @@ func convertCsvToGasStationImports(rows *[][]string) []gasstation.GasStationImpo
 	}
 	return result
 }
-
-func UpdateGsPrices1(c *gin.Context) {
-	var latitude = 52.521
-	var longitude = 13.438
-	var radiusKM = 10.0
-	apikey := os.Getenv("APIKEY1")
-	UpdateGsPrices(latitude, longitude, radiusKM, apikey)
-}
-
-func UpdateGsPrices(latitude float64, longitude float64, radiusKM float64, apikey string) error {
-	fmt.Printf("Price requested Latitude: %f Longitude: %f radiusKM:: %f\n", latitude, longitude, radiusKM)
-	var queryUrl = fmt.Sprintf("https://creativecommons.tankerkoenig.de/json/list.php?lat=%f&lng=%f&rad=%f&sort=dist&type=all&apikey=%v", latitude, longitude, radiusKM, strings.TrimSpace(apikey))
-	//log.Printf("Url: %v", queryUrl)
-	client := http.Client{
-		Timeout: 5 * time.Second,
-	}
-	response, err := client.Get(queryUrl)
-	if err != nil {
-		log.Printf("Request failed: %v\n", err.Error())
-		return err
-	}
-	defer response.Body.Close()
-	if response.StatusCode >= 300 {
-		log.Printf("Response status: %v\n", response.Status)
-		err := fmt.Errorf("response status: %v", response.Status)
-		return err
-	}
-	var myGsResponse gsResponse
-	if err := json.NewDecoder(response.Body).Decode(&myGsResponse); err != nil {
-		log.Printf("Json decode failed: %v\n", err.Error())
-		return err
-	}
-	stationPricesMap := make(map[string]gasstation.GasStationPrices)
-	for _, value := range myGsResponse.Stations {
-		stationPricesMap[value.Id] = gasstation.GasStationPrices{GasStationID: value.Id, E5: int(value.E5 * 1000), E10: int(value.E10 * 1000), Diesel: int(value.Diesel * 1000), Timestamp: time.Now()}
-	}
-	var gasPriceUpdates []gasstation.GasStationPrices
-	for _, value := range stationPricesMap {
-		gasPriceUpdates = append(gasPriceUpdates, value)
-	}
-	fmt.Printf("Number of Price updates: %v\n", len(gasPriceUpdates))
-	gasstation.UpdatePrice(&gasPriceUpdates)
-	/*
-		body, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			log.Fatalf("Read body failed: %v\n", body)
-		}
-		//fmt.Printf("Body received: %v", string(body))
-		err1 := json.Unmarshal(body, &myGsResponse)
-		if err1 != nil {
-			log.Fatalf("Error: %v\n", err1.Error())
-		}
-	*/
-	/*
-		result, _ := json.Marshal(myGsResponse)
-		fmt.Printf("Json: %v\n", string(result))
-	*/
-	return nil
-}
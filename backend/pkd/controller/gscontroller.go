@@ -17,16 +17,88 @@ import (
 	"net/http"
 	gsbody "react-and-go/pkd/controller/gsmodel"
 	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/messaging"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 func getGasPriceByGasStationId(c *gin.Context) {
 	gasstationId := c.Params.ByName("id")
-	gsEntity := gasstation.FindPricesByStid(gasstationId)
+	days, err := strconv.Atoi(c.DefaultQuery("days", "0"))
+	if err != nil {
+		log.Printf("getGasPriceByGasStationId: %v", err.Error())
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	gsEntity := gasstation.FindPricesByStid(gasstationId, days)
 	c.JSON(http.StatusOK, gsEntity)
 }
 
+func getPriceStatisticsByGasStationId(c *gin.Context) {
+	gasstationId := c.Params.ByName("id")
+	bucketType, err := gasstation.ParseBucketType(c.Query("bucket"))
+	if err != nil {
+		log.Printf("getPriceStatisticsByGasStationId: %v", err.Error())
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "0"))
+	if err != nil {
+		log.Printf("getPriceStatisticsByGasStationId: %v", err.Error())
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	priceStatistics := gasstation.CalcPriceStatisticsByStid(gasstationId, bucketType, days)
+	c.JSON(http.StatusOK, priceStatistics)
+}
+
+func searchPriceStatisticsLocation(c *gin.Context) {
+	var searchStatisticsBody gsbody.SearchStatistics
+	if err := c.Bind(&searchStatisticsBody); err != nil {
+		log.Printf("searchPriceStatisticsLocation: %v", err.Error())
+	}
+	priceStatistics, err := gasstation.CalcPriceStatisticsByLocation(searchStatisticsBody)
+	if err != nil {
+		log.Printf("searchPriceStatisticsLocation: %v", err.Error())
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	c.JSON(http.StatusOK, priceStatistics)
+}
+
+func getPricePredictionByGasStationId(c *gin.Context) {
+	gasstationId := c.Params.ByName("id")
+	fuelType, err := gsmodel.ParseFuelType(c.DefaultQuery("fueltype", string(gsmodel.FuelE5)))
+	if err != nil {
+		log.Printf("getPricePredictionByGasStationId: %v", err.Error())
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	pricePrediction, err := gasstation.PredictBestHour(gasstationId, fuelType)
+	if err != nil {
+		log.Printf("getPricePredictionByGasStationId: %v", err.Error())
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, pricePrediction)
+}
+
+func searchPricePredictionLocation(c *gin.Context) {
+	var searchPredictionBody gsbody.SearchPrediction
+	if err := c.Bind(&searchPredictionBody); err != nil {
+		log.Printf("searchPricePredictionLocation: %v", err.Error())
+	}
+	areaPricePrediction, err := gasstation.PredictBestHourByLocation(searchPredictionBody)
+	if err != nil {
+		log.Printf("searchPricePredictionLocation: %v", err.Error())
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	c.JSON(http.StatusOK, areaPricePrediction)
+}
+
 func getGasStationById(c *gin.Context) {
 	gasstationId := c.Params.ByName("id")
 	gsEntity := gasstation.FindById(gasstationId)
@@ -50,7 +122,40 @@ func searchGasStationLocation(c *gin.Context) {
 		log.Printf("searchGasStationLocation: %v", err.Error())
 	}
 	//fmt.Printf("Lat: %v, Lng: %v\n", searchLocationBody.Latitude, searchLocationBody.Longitude)
-	gsEntity := gasstation.FindBySearchLocation(searchLocationBody)
+	gsEntity, err := gasstation.FindBySearchLocation(searchLocationBody)
+	if err != nil {
+		log.Printf("searchGasStationLocation: %v", err.Error())
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	c.JSON(http.StatusOK, gsEntity)
+}
+
+func searchGasStationNearest(c *gin.Context) {
+	var searchNearestBody gsbody.SearchNearest
+	if err := c.Bind(&searchNearestBody); err != nil {
+		log.Printf("searchGasStationNearest: %v", err.Error())
+	}
+	gsEntity, err := gasstation.FindNearest(searchNearestBody)
+	if err != nil {
+		log.Printf("searchGasStationNearest: %v", err.Error())
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	c.JSON(http.StatusOK, gsEntity)
+}
+
+func searchGasStationRoute(c *gin.Context) {
+	var searchRouteBody gsbody.SearchRoute
+	if err := c.Bind(&searchRouteBody); err != nil {
+		log.Printf("searchGasStationRoute: %v", err.Error())
+	}
+	gsEntity, err := gasstation.FindAlongRoute(searchRouteBody)
+	if err != nil {
+		log.Printf("searchGasStationRoute: %v", err.Error())
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
 	c.JSON(http.StatusOK, gsEntity)
 }
 
@@ -61,3 +166,7 @@ func postsUpdate(c *gin.Context) {
 func postsDelete(c *gin.Context) {
 
 }
+
+func getPipelineMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, messaging.Metrics())
+}
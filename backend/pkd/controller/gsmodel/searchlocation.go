@@ -12,8 +12,15 @@
 */
 package gsbody
 
+import "time"
+
 type SearchLocation struct {
 	Longitude float64
 	Latitude  float64
 	Radius    float64
+	OpenAt    time.Time
+	OnlyOpen  bool
+	// adds the cheapest hour in the next 24h per station, all fuel types if FuelType is empty
+	WithPrediction bool
+	FuelType       string
 }
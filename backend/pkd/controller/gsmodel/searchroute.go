@@ -0,0 +1,30 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsbody
+
+import "time"
+
+type RoutePoint struct {
+	Longitude float64
+	Latitude  float64
+}
+
+type SearchRoute struct {
+	Route    []RoutePoint
+	Polyline string // encoded polyline, used if Route is empty
+	Distance float64
+	FuelType string // only stations with a current price for the fuel type, all stations if empty
+	SortBy   string // 'route', 'distance' or 'price'
+	OpenAt   time.Time
+	OnlyOpen bool
+}
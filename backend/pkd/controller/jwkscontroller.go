@@ -0,0 +1,26 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package controller
+
+import (
+	"net/http"
+	"react-and-go/pkd/token"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the public keys to verify the access tokens, the verifiers should cache them for less than JWT_KEY_PUBLISH_MINUTES
+func getJwks(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, token.Jwks())
+}
@@ -0,0 +1,65 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getQuarantinedPrices(c *gin.Context) {
+	quarantinedPrices, err := gasstation.FindQuarantinedPrices(c.Query("status"))
+	if err != nil {
+		log.Printf("getQuarantinedPrices: %v", err.Error())
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	c.JSON(http.StatusOK, quarantinedPrices)
+}
+
+func postConfirmQuarantinedPrice(c *gin.Context) {
+	resolveQuarantinedPrice(c, gasstation.ConfirmQuarantinedPrice)
+}
+
+func postRejectQuarantinedPrice(c *gin.Context) {
+	resolveQuarantinedPrice(c, gasstation.RejectQuarantinedPrice)
+}
+
+func resolveQuarantinedPrice(c *gin.Context, resolve func(id int64, username string) (gsmodel.QuarantinedPrice, error)) {
+	username, exists := c.Get("user")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if !exists || err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	quarantinedPrice, err := resolve(id, username.(string))
+	if err != nil {
+		log.Printf("resolveQuarantinedPrice: %v", err.Error())
+		switch {
+		case errors.Is(err, gasstation.ErrQuarantineNotFound):
+			c.AbortWithStatus(http.StatusNotFound)
+		case errors.Is(err, gasstation.ErrQuarantineResolved):
+			c.AbortWithStatus(http.StatusConflict)
+		default:
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, quarantinedPrice)
+}
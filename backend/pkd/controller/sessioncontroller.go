@@ -0,0 +1,77 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package controller
+
+import (
+	"log"
+	"net/http"
+	"react-and-go/pkd/appuser"
+	aubody "react-and-go/pkd/controller/aumodel"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exchanges a refresh token for a new access token and the next refresh token, the access token can be expired
+func postRefreshToken(c *gin.Context) {
+	var refreshTokenRequest aubody.RefreshTokenRequest
+	if err := c.Bind(&refreshTokenRequest); err != nil {
+		log.Printf("postRefreshToken: %v", err.Error())
+		return
+	}
+	result, refreshToken, status := appuser.RefreshSession(refreshTokenRequest.RefreshToken)
+	message := ""
+	if status != http.StatusOK {
+		message = "Invalid"
+	}
+	c.JSON(status, aubody.AppUserResponse{Token: result, RefreshToken: refreshToken, Message: message})
+}
+
+func getSessions(c *gin.Context) {
+	username, exists1 := c.Get("user")
+	uuid, exists2 := c.Get("uuid")
+	if !exists1 || !exists2 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	result := []aubody.SessionResponse{}
+	for _, userSession := range appuser.FindSessions(username.(string)) {
+		result = append(result, aubody.SessionResponse{ID: userSession.ID, UserAgent: userSession.UserAgent, IpAddress: userSession.IpAddress,
+			CreatedAt: userSession.CreatedAt, LastUsedAt: userSession.LastUsedAt, ExpiresAt: userSession.ExpiresAt, Revoked: userSession.Revoked,
+			RevokeReason: userSession.RevokeReason, Current: userSession.ID == uuid.(string)})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func deleteSession(c *gin.Context) {
+	username, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	if err := appuser.RevokeSession(username.(string), c.Param("id"), appuser.RevokedReason); err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// revokes the current session too
+func deleteSessions(c *gin.Context) {
+	username, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	appuser.RevokeAllSessions(username.(string), appuser.RevokeAllReason)
+	c.Status(http.StatusOK)
+}
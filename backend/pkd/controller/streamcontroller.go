@@ -0,0 +1,90 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package controller
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"react-and-go/pkd/appuser"
+	"react-and-go/pkd/gasstation/gsmodel"
+	unmodel "react-and-go/pkd/notification/model"
+	"react-and-go/pkd/pubsub"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	streamBufferSize        = 100
+	streamHeartbeatInterval = 30 * time.Second
+)
+
+// server sent events with the price changes in the circle of the query parameters latitude, longitude, radius
+// and the new notifications of the logged in user, the stream ends at the first heartbeat after the session is revoked
+func getUpdateStream(c *gin.Context) {
+	username, exists1 := c.Get("user")
+	sessionId, exists2 := c.Get("uuid")
+	if !exists1 || !exists2 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	appUser, err := appuser.FindByUsername(username.(string))
+	if err != nil {
+		log.Printf("getUpdateStream: %v", err.Error())
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	latitude, _ := strconv.ParseFloat(c.Query("latitude"), 64)
+	longitude, _ := strconv.ParseFloat(c.Query("longitude"), 64)
+	radius, _ := strconv.ParseFloat(c.Query("radius"), 64)
+	subscriber := pubsub.Subscribe(streamBufferSize, func(event pubsub.Event) bool {
+		if event.Type == pubsub.NotificationEventType {
+			return event.Notification != nil && event.Notification.UserUuid == appUser.Uuid
+		}
+		if event.Type == pubsub.PriceEventType && event.Price != nil && radius > 0.0 {
+			myGasStation := gsmodel.GasStation{Latitude: event.Price.Latitude, Longitude: event.Price.Longitude}
+			distance, _ := myGasStation.CalcDistanceBearing(latitude, longitude)
+			return distance <= radius
+		}
+		return false
+	})
+	defer pubsub.Unsubscribe(subscriber)
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			if !appuser.SessionActive(username.(string), sessionId.(string)) {
+				return false
+			}
+			c.SSEvent("heartbeat", time.Now().Unix())
+			return true
+		case event, ok := <-subscriber.Events:
+			if !ok {
+				return false
+			}
+			if event.Type == pubsub.NotificationEventType {
+				c.SSEvent(string(event.Type), mapToUnResponses([]unmodel.UserNotification{*event.Notification})[0])
+			} else {
+				c.SSEvent(string(event.Type), event.Price)
+			}
+			return true
+		}
+	})
+}
@@ -14,6 +14,7 @@ package controller
 
 import (
 	"net/http"
+	"react-and-go/pkd/appuser"
 	unbody "react-and-go/pkd/controller/unmodel"
 	notification "react-and-go/pkd/notification"
 	unmodel "react-and-go/pkd/notification/model"
@@ -27,6 +28,31 @@ func getNewUserNotifications(c *gin.Context) {
 	c.JSON(http.StatusOK, mapToUnResponses(myNotifications))
 }
 
+// the outbox items per status, failed items keep their last error
+func getOutboxStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, notification.OutboxStatusCounts())
+}
+
+// why matches of the user were not notified, newest first
+func getNotificationSuppressions(c *gin.Context) {
+	username, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	appUser, err := appuser.FindByUsername(username.(string))
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	result := []unbody.SuppressionResponse{}
+	for _, suppression := range notification.FindSuppressions(appUser.Uuid) {
+		result = append(result, unbody.SuppressionResponse{Timestamp: suppression.Timestamp, GasStationID: suppression.GasStationID,
+			FuelType: suppression.FuelType, Price: suppression.Price, Reason: string(suppression.Reason)})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
 func getCurrentUserNotifications(c *gin.Context) {
 	userUuid := c.Param("useruuid")
 	myNotifications := notification.LoadNotifications(userUuid, false)
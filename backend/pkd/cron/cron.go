@@ -16,104 +16,51 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"react-and-go/pkd/appuser"
 	gsclient "react-and-go/pkd/controller/client"
+	"react-and-go/pkd/gasstation"
 	"react-and-go/pkd/messaging"
+	"react-and-go/pkd/notification"
+	"react-and-go/pkd/poller"
+	"react-and-go/pkd/token"
 	"strings"
 	"time"
 
 	"github.com/go-co-op/gocron"
 )
 
-type CircleCenter struct {
-	Latitude  float64
-	Longitude float64
-}
-
-var HamburgAndSH = [...]CircleCenter{
-	{
-		Latitude:  54.824158,
-		Longitude: 8.346131,
-	},
-	{
-		Latitude:  54.715297,
-		Longitude: 8.775641,
-	},
-	{
-		Latitude:  54.661861,
-		Longitude: 9.180214,
-	},
-	{
-		Latitude:  54.677340,
-		Longitude: 9.743868,
-	},
-	{
-		Latitude:  54.298884,
-		Longitude: 8.743990,
-	},
-	{
-		Latitude:  54.308298,
-		Longitude: 9.317139,
-	},
-	{
-		Latitude:  54.306721,
-		Longitude: 9.792173,
-	},
-	{
-		Latitude:  54.280894,
-		Longitude: 10.247840,
-	},
-	{
-		Latitude:  54.333907,
-		Longitude: 10.987011,
-	},
-	{
-		Latitude:  54.019711,
-		Longitude: 10.643870,
-	},
-	{
-		Latitude:  53.889138,
-		Longitude: 10.020025,
-	},
-	{
-		Latitude:  53.913517,
-		Longitude: 9.572239,
-	},
-	{
-		Latitude:  53.928135,
-		Longitude: 9.042212,
-	},
-	{
-		Latitude:  53.648308,
-		Longitude: 10.580193,
-	},
-	{
-		Latitude:  53.473590,
-		Longitude: 10.277897,
-	},
-	{
-		Latitude:  53.522599,
-		Longitude: 9.800100,
-	},
-}
-
-var requestCounter int64 = 0
-var apikeyIndex = 0
-
 func Start() {
-	/*
-		var apikeys [3]string
-		for index, _ := range apikeys {
-			apikeys[index] = os.Getenv(fmt.Sprintf("APIKEY%v", index+1))
-		}
-	*/
-
 	scheduler := gocron.NewScheduler(time.UTC)
 	scheduler.Every(1).Day().At("01:07").Do(func() {
-		gsclient.UpdateGasStations(nil)
+		if report, err := gsclient.ImportLatestGasStations(); err != nil {
+			log.Printf("Station import failed: %v\n", err)
+		} else {
+			log.Printf("Station import %v new: %v changed: %v removed: %v\n", report.Source, report.New, report.Changed, report.Removed)
+		}
+	})
+
+	scheduler.Every(1).Day().At("02:37").Do(func() {
+		if _, err := gasstation.ApplyPriceRetention(); err != nil {
+			log.Printf("Price retention failed: %v\n", err)
+		}
 	})
 
+	scheduler.Every(1).Day().At("03:17").Do(notification.DeleteOldSuppressions)
+
+	scheduler.Every(1).Day().At("03:47").Do(appuser.DeleteOldSessions)
+
+	scheduler.Every(1).Day().At("04:07").Do(appuser.DeleteExpiredUserTokens)
+
+	scheduler.Every(5).Minutes().Tag("signingkeys").SingletonMode().Do(token.RotateKeys)
+
+	scheduler.Every(5).Minutes().Tag("digest").SingletonMode().Do(notification.SendDueDigests)
+
 	scheduler.Every(60).Seconds().Tag("messaging").Do(messaging.ConnectionCheck)
 
+	if poller.Start() {
+		scheduler.Every(60).Seconds().Tag("poller").SingletonMode().Do(poller.Poll)
+	}
+
 	msgFileStr := os.Getenv("MSG_MESSAGES")
 	if len(strings.TrimSpace(msgFileStr)) > 3 {
 		msgFiles := strings.Split(msgFileStr, ";")
@@ -133,32 +80,3 @@ func sendTestPriceMsgs(msgFiles []string) {
 		time.Sleep(10 * time.Second)
 	}
 }
-
-func updatePriceRegion(regionCircleCenters [16]CircleCenter, apikeys [3]string) {
-	for index, value := range HamburgAndSH {
-		//time.Sleep(6 * time.Second)
-		time.Sleep(15 * time.Second)
-		log.Printf("index: %v value: %v", index, value)
-		/*
-			err := gsclient.UpdateGsPrices(value.Latitude, value.Longitude, 25.0, apikeys[apikeyIndex])
-			if err != nil {
-				log.Printf("Region Canceled index: %v\n", index)
-				updateApiKeyIndex()
-				break
-			}
-		*/
-		requestCounter += 1
-		if requestCounter%45 == 0 {
-			updateApiKeyIndex()
-		}
-		log.Printf("Request %v, ApikeyIndex: %v\n", requestCounter, apikeyIndex)
-	}
-}
-
-func updateApiKeyIndex() {
-	if apikeyIndex < 2 {
-		apikeyIndex += 1
-	} else {
-		apikeyIndex = 0
-	}
-}
@@ -15,6 +15,7 @@ package database
 import (
 	"log"
 	"os"
+	"strings"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -23,14 +24,36 @@ import (
 
 var DB *gorm.DB
 
+const (
+	PostgresDriver = "postgres"
+	SqliteDriver   = "sqlite"
+)
+
+// DB_DRIVER selects the database, postgres is the default
+func Driver() string {
+	driver := strings.ToLower(strings.TrimSpace(os.Getenv("DB_DRIVER")))
+	if len(driver) == 0 {
+		return PostgresDriver
+	}
+	return driver
+}
+
 func ConnectToDB() {
 	var err error
 	dsn := os.Getenv("DB_PARAMS")
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
+	gormConfig := &gorm.Config{
 		NamingStrategy: schema.NamingStrategy{
 			SingularTable: true,
 		},
-	})
+	}
+	switch Driver() {
+	case PostgresDriver:
+		DB, err = gorm.Open(postgres.Open(dsn), gormConfig)
+	case SqliteDriver:
+		DB, err = openSqlite(dsn, gormConfig)
+	default:
+		log.Fatalf("Unknown DB_DRIVER: %v\n", Driver())
+	}
 
 	if err != nil {
 		log.Fatal("Failed to connect to database. ")
@@ -0,0 +1,108 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package dbmigrate
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const usage = "usage: migrate up [version] | down [version] | status"
+
+// 'migrate up' migrates to the latest version, 'migrate down' rolls back one version, returns the exit code
+func RunCommand(args []string) int {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	targetVersion := -1
+	if len(args) == 2 {
+		var err error
+		if targetVersion, err = strconv.Atoi(args[1]); err != nil || targetVersion < 0 {
+			fmt.Fprintln(os.Stderr, usage)
+			return 2
+		}
+	}
+	var err error
+	switch args[0] {
+	case "up":
+		if targetVersion < 0 {
+			targetVersion = LatestVersion()
+		}
+		if err = CheckSchemaVersion(); err == nil {
+			err = MigrateUp(targetVersion)
+		}
+	case "down":
+		if targetVersion < 0 {
+			var currentVersion int
+			if currentVersion, err = CurrentVersion(); err == nil {
+				targetVersion = previousVersion(currentVersion)
+			}
+		}
+		if err == nil {
+			err = MigrateDown(targetVersion)
+		}
+	case "status":
+		err = printStatus()
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate %v failed: %v\n", args[0], err)
+		return 1
+	}
+	if args[0] != "status" {
+		return printCurrentVersion()
+	}
+	return 0
+}
+
+func printStatus() error {
+	migrationStatuses, err := Status()
+	if err != nil {
+		return err
+	}
+	for _, migrationStatus := range migrationStatuses {
+		appliedAt := "pending"
+		if migrationStatus.Applied {
+			appliedAt = migrationStatus.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%4d  %-19v  %v\n", migrationStatus.Version, appliedAt, migrationStatus.Description)
+	}
+	if printCurrentVersion() != 0 {
+		return nil
+	}
+	return CheckSchemaVersion()
+}
+
+func printCurrentVersion() int {
+	currentVersion, err := CurrentVersion()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read schema version failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Schema version: %v, latest version: %v\n", currentVersion, LatestVersion())
+	return 0
+}
+
+func previousVersion(version int) int {
+	result := 0
+	for _, myMigration := range migrations {
+		if myMigration.Version < version {
+			result = myMigration.Version
+		}
+	}
+	return result
+}
@@ -13,31 +13,225 @@
 package dbmigrate
 
 import (
+	"fmt"
 	"log"
-	"react-and-go/pkd/appuser/aumodel"
 	database "react-and-go/pkd/database"
-	"react-and-go/pkd/gasstation/gsmodel"
-	unmodel "react-and-go/pkd/notification/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+type SchemaVersion struct {
+	Version     int `gorm:"primaryKey;autoIncrement:false"`
+	Description string
+	AppliedAt   time.Time
+}
+
+type migration struct {
+	Version     int
+	Description string
+	Up          func(tx *gorm.DB) error
+	Down        func(tx *gorm.DB) error
+}
+
+type MigrationStatus struct {
+	Version     int
+	Description string
+	Applied     bool
+	AppliedAt   time.Time
+}
+
+// migrates to the latest version, stops the application if the schema is newer than the application
 func MigrateDB() {
-	if !database.DB.Migrator().HasTable(&gsmodel.GasStation{}) {
-		database.DB.AutoMigrate(&gsmodel.GasStation{})
+	if err := CheckSchemaVersion(); err != nil {
+		log.Fatalf("%v\n", err)
 	}
-	if !database.DB.Migrator().HasTable(&gsmodel.GasPrice{}) {
-		database.DB.AutoMigrate(&gsmodel.GasPrice{})
+	if err := MigrateUp(LatestVersion()); err != nil {
+		log.Fatalf("DB Migration failed: %v\n", err)
 	}
-	if !database.DB.Migrator().HasTable(&aumodel.AppUser{}) {
-		database.DB.AutoMigrate(&aumodel.AppUser{})
+	log.Printf("DB Migration Done.")
+}
+
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+func CurrentVersion() (int, error) {
+	if err := createSchemaVersionTable(); err != nil {
+		return 0, err
 	}
-	if !database.DB.Migrator().HasTable(&aumodel.LoggedOutUser{}) {
-		database.DB.AutoMigrate(&aumodel.LoggedOutUser{})
+	var currentVersion int
+	if result := database.DB.Model(&SchemaVersion{}).Select("coalesce(max(version), 0)").Scan(&currentVersion); result.Error != nil {
+		return 0, result.Error
 	}
-	if !database.DB.Migrator().HasTable(&aumodel.PostCodeLocation{}) {
-		database.DB.AutoMigrate(&aumodel.PostCodeLocation{})
+	return currentVersion, nil
+}
+
+func CheckSchemaVersion() error {
+	currentVersion, err := CurrentVersion()
+	if err != nil {
+		return err
 	}
-	if !database.DB.Migrator().HasTable(&unmodel.UserNotification{}) {
-		database.DB.AutoMigrate(&unmodel.UserNotification{})
+	if currentVersion > LatestVersion() {
+		return fmt.Errorf("schema version %v is newer than the supported version %v", currentVersion, LatestVersion())
 	}
-	log.Printf("DB Migration Done.")
+	return nil
+}
+
+// applies the pending migrations up to the target version, every step in its own transaction
+func MigrateUp(targetVersion int) error {
+	currentVersion, err := CurrentVersion()
+	if err != nil {
+		return err
+	}
+	for _, myMigration := range migrations {
+		if myMigration.Version <= currentVersion || myMigration.Version > targetVersion {
+			continue
+		}
+		if err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := myMigration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaVersion{Version: myMigration.Version, Description: myMigration.Description, AppliedAt: time.Now()}).Error
+		}); err != nil {
+			return fmt.Errorf("migration %v up failed: %v", myMigration.Version, err)
+		}
+		log.Printf("Migrated up to version: %v %v\n", myMigration.Version, myMigration.Description)
+	}
+	return nil
+}
+
+// rolls back the applied migrations above the target version, newest first
+func MigrateDown(targetVersion int) error {
+	if err := CheckSchemaVersion(); err != nil {
+		return err
+	}
+	currentVersion, err := CurrentVersion()
+	if err != nil {
+		return err
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		myMigration := migrations[i]
+		if myMigration.Version > currentVersion || myMigration.Version <= targetVersion {
+			continue
+		}
+		if err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := myMigration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Where("version = ?", myMigration.Version).Delete(&SchemaVersion{}).Error
+		}); err != nil {
+			return fmt.Errorf("migration %v down failed: %v", myMigration.Version, err)
+		}
+		log.Printf("Migrated down from version: %v %v\n", myMigration.Version, myMigration.Description)
+	}
+	return nil
+}
+
+func Status() ([]MigrationStatus, error) {
+	result := []MigrationStatus{}
+	if err := createSchemaVersionTable(); err != nil {
+		return result, err
+	}
+	var schemaVersions []SchemaVersion
+	if dbResult := database.DB.Order("version").Find(&schemaVersions); dbResult.Error != nil {
+		return result, dbResult.Error
+	}
+	versionToSchemaVersion := make(map[int]SchemaVersion)
+	for _, mySchemaVersion := range schemaVersions {
+		versionToSchemaVersion[mySchemaVersion.Version] = mySchemaVersion
+	}
+	for _, myMigration := range migrations {
+		mySchemaVersion, applied := versionToSchemaVersion[myMigration.Version]
+		result = append(result, MigrationStatus{Version: myMigration.Version, Description: myMigration.Description, Applied: applied, AppliedAt: mySchemaVersion.AppliedAt})
+		delete(versionToSchemaVersion, myMigration.Version)
+	}
+	//versions applied by a newer application
+	for _, mySchemaVersion := range schemaVersions {
+		if _, unknown := versionToSchemaVersion[mySchemaVersion.Version]; unknown {
+			result = append(result, MigrationStatus{Version: mySchemaVersion.Version, Description: mySchemaVersion.Description, Applied: true, AppliedAt: mySchemaVersion.AppliedAt})
+		}
+	}
+	return result, nil
+}
+
+func createSchemaVersionTable() error {
+	if !database.DB.Migrator().HasTable(&SchemaVersion{}) {
+		return database.DB.Migrator().CreateTable(&SchemaVersion{})
+	}
+	return nil
+}
+
+func createMissingTables(tx *gorm.DB, models ...interface{}) error {
+	for _, model := range models {
+		if !tx.Migrator().HasTable(model) {
+			if err := tx.Migrator().CreateTable(model); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func dropTables(tx *gorm.DB, models ...interface{}) error {
+	for _, model := range models {
+		if err := tx.Migrator().DropTable(model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addMissingColumns(tx *gorm.DB, model interface{}, fieldNames ...string) error {
+	for _, fieldName := range fieldNames {
+		if !tx.Migrator().HasColumn(model, fieldName) {
+			if err := tx.Migrator().AddColumn(model, fieldName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// the sqlite migrator of gorm recreates the table without its indexes to drop a column, sqlite supports 'drop column' since 3.35
+func dropColumns(tx *gorm.DB, model interface{}, fieldNames ...string) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	for _, fieldName := range fieldNames {
+		field := stmt.Schema.LookUpField(fieldName)
+		if field == nil {
+			return fmt.Errorf("unknown field %v of %v", fieldName, stmt.Table)
+		}
+		if tx.Migrator().HasColumn(model, fieldName) {
+			if err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: stmt.Table}, clause.Column{Name: field.DBName}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func createMissingIndexes(tx *gorm.DB, model interface{}, indexNames ...string) error {
+	for _, indexName := range indexNames {
+		if !tx.Migrator().HasIndex(model, indexName) {
+			if err := tx.Migrator().CreateIndex(model, indexName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func dropIndexes(tx *gorm.DB, model interface{}, indexNames ...string) error {
+	for _, indexName := range indexNames {
+		if tx.Migrator().HasIndex(model, indexName) {
+			if err := tx.Migrator().DropIndex(model, indexName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,107 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package dbmigrate
+
+import (
+	"path/filepath"
+	database "react-and-go/pkd/database"
+	"testing"
+	"time"
+)
+
+func connectTestDB(t *testing.T) {
+	t.Setenv("DB_DRIVER", database.SqliteDriver)
+	t.Setenv("DB_PARAMS", filepath.Join(t.TempDir(), "migrate.db"))
+	database.ConnectToDB()
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func TestMigrationVersionsAscending(t *testing.T) {
+	for index, myMigration := range migrations {
+		if myMigration.Version != index+1 {
+			t.Errorf("Migration %v has version: %v", index, myMigration.Version)
+		}
+		if myMigration.Up == nil || myMigration.Down == nil || len(myMigration.Description) == 0 {
+			t.Errorf("Migration %v is incomplete", myMigration.Version)
+		}
+	}
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	connectTestDB(t)
+	if err := MigrateUp(LatestVersion()); err != nil {
+		t.Fatalf("Migrate up failed: %v", err)
+	}
+	if currentVersion, err := CurrentVersion(); err != nil || currentVersion != LatestVersion() {
+		t.Errorf("Version after migrate up: %v, %v", currentVersion, err)
+	}
+	if !database.DB.Migrator().HasTable(&gasStationV1{}) || !database.DB.Migrator().HasColumn(&alertRuleV4{}, "ReferenceRadius") {
+		t.Errorf("Schema not created")
+	}
+	myStatus, err := Status()
+	if err != nil || len(myStatus) != len(migrations) {
+		t.Fatalf("Status: %v, %v", myStatus, err)
+	}
+	for _, myMigrationStatus := range myStatus {
+		if !myMigrationStatus.Applied {
+			t.Errorf("Migration not applied: %+v", myMigrationStatus)
+		}
+	}
+	//a second run has nothing to do
+	if err := MigrateUp(LatestVersion()); err != nil {
+		t.Errorf("Repeated migrate up failed: %v", err)
+	}
+
+	if err := MigrateDown(3); err != nil {
+		t.Fatalf("Migrate down failed: %v", err)
+	}
+	if currentVersion, err := CurrentVersion(); err != nil || currentVersion != 3 {
+		t.Errorf("Version after migrate down: %v, %v", currentVersion, err)
+	}
+	if !database.DB.Migrator().HasTable(&alertRuleV3{}) || database.DB.Migrator().HasColumn(&alertRuleV4{}, "ReferenceRadius") {
+		t.Errorf("Schema of version 3 not restored")
+	}
+	if err := MigrateDown(0); err != nil {
+		t.Fatalf("Migrate down to 0 failed: %v", err)
+	}
+	if database.DB.Migrator().HasTable(&gasStationV1{}) {
+		t.Errorf("Tables left after migrate down to 0")
+	}
+	if err := MigrateUp(LatestVersion()); err != nil {
+		t.Errorf("Migrate up after migrate down failed: %v", err)
+	}
+}
+
+func TestCheckSchemaVersionNewerSchema(t *testing.T) {
+	connectTestDB(t)
+	if err := CheckSchemaVersion(); err != nil {
+		t.Errorf("Empty schema rejected: %v", err)
+	}
+	if err := database.DB.Create(&SchemaVersion{Version: LatestVersion() + 1, Description: "newer", AppliedAt: time.Now()}).Error; err != nil {
+		t.Fatalf("Create version failed: %v", err)
+	}
+	if err := CheckSchemaVersion(); err == nil {
+		t.Errorf("Newer schema accepted")
+	}
+	if err := MigrateDown(0); err == nil {
+		t.Errorf("Migrate down of a newer schema accepted")
+	}
+	myStatus, err := Status()
+	if err != nil || len(myStatus) != len(migrations)+1 || !myStatus[len(myStatus)-1].Applied {
+		t.Errorf("Status of a newer schema: %v, %v", myStatus, err)
+	}
+}
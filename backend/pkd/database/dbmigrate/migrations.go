@@ -0,0 +1,187 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package dbmigrate
+
+import (
+	"gorm.io/gorm"
+)
+
+// ordered by version, new steps are appended. The steps use the snapshots of their version, not the current models,
+// they only create what is missing and can run on databases that were created before the schema_version table.
+var migrations = []migration{
+	{Version: 1, Description: "initial schema",
+		Up: func(tx *gorm.DB) error {
+			return createMissingTables(tx, &gasStationV1{}, &gasPriceV1{}, &appUserV1{}, &loggedOutUserV1{},
+				&postCodeLocationV1{}, &userNotificationV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &userNotificationV1{}, &postCodeLocationV1{}, &loggedOutUserV1{}, &appUserV1{},
+				&gasPriceV1{}, &gasStationV1{})
+		}},
+	{Version: 2, Description: "notification channels",
+		Up: func(tx *gorm.DB) error {
+			if err := addMissingColumns(tx, &appUserV2{}, "NotificationChannels", "Email", "WebhookUrl", "WebPushSubscription"); err != nil {
+				return err
+			}
+			return addMissingColumns(tx, &userNotificationV2{}, "DeliveryStatus", "DeliveryAttempts")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &appUserV2{}, "NotificationChannels", "Email", "WebhookUrl", "WebPushSubscription"); err != nil {
+				return err
+			}
+			return dropColumns(tx, &userNotificationV2{}, "DeliveryStatus", "DeliveryAttempts")
+		}},
+	{Version: 3, Description: "alert rules",
+		Up: func(tx *gorm.DB) error {
+			return createMissingTables(tx, &alertRuleV3{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &alertRuleV3{})
+		}},
+	{Version: 4, Description: "relative alert rules",
+		Up: func(tx *gorm.DB) error {
+			return addMissingColumns(tx, &alertRuleV4{}, "ReferenceType", "ReferenceRadius", "MinDifference", "LastSeenPrice")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &alertRuleV4{}, "ReferenceType", "ReferenceRadius", "MinDifference", "LastSeenPrice")
+		}},
+	{Version: 5, Description: "price poll state",
+		Up: func(tx *gorm.DB) error {
+			return createMissingTables(tx, &pollKeyStateV5{}, &pollRegionStateV5{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &pollRegionStateV5{}, &pollKeyStateV5{})
+		}},
+	{Version: 6, Description: "price import state",
+		Up: func(tx *gorm.DB) error {
+			return createMissingTables(tx, &priceImportStateV6{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &priceImportStateV6{})
+		}},
+	{Version: 7, Description: "hourly and daily price aggregates",
+		Up: func(tx *gorm.DB) error {
+			return createMissingTables(tx, &hourlyPriceV7{}, &dailyPriceV7{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &dailyPriceV7{}, &hourlyPriceV7{})
+		}},
+	{Version: 8, Description: "price quarantine",
+		Up: func(tx *gorm.DB) error {
+			return createMissingTables(tx, &quarantinedPriceV8{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &quarantinedPriceV8{})
+		}},
+	{Version: 9, Description: "unique price per station and source timestamp",
+		Up: func(tx *gorm.DB) error {
+			// redelivered messages created duplicates before, the oldest row of a station and date is kept
+			if err := tx.Exec("DELETE FROM gas_station_information_history WHERE id NOT IN " +
+				"(SELECT MIN(id) FROM gas_station_information_history GROUP BY stid, date)").Error; err != nil {
+				return err
+			}
+			return createMissingIndexes(tx, &gasPriceV9{}, "idx_stid_date")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropIndexes(tx, &gasPriceV9{}, "idx_stid_date")
+		}},
+	{Version: 10, Description: "notification outbox",
+		Up: func(tx *gorm.DB) error {
+			return createMissingTables(tx, &outboxItemV10{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &outboxItemV10{})
+		}},
+	{Version: 11, Description: "notification cooldown, quiet hours and digest",
+		Up: func(tx *gorm.DB) error {
+			if err := addMissingColumns(tx, &appUserV11{}, "NotificationCooldown", "RepeatUnchanged", "QuietHoursStart", "QuietHoursEnd",
+				"DigestMode"); err != nil {
+				return err
+			}
+			return createMissingTables(tx, &notificationStateV11{}, &notificationSuppressionV11{}, &digestEntryV11{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropTables(tx, &digestEntryV11{}, &notificationSuppressionV11{}, &notificationStateV11{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &appUserV11{}, "NotificationCooldown", "RepeatUnchanged", "QuietHoursStart", "QuietHoursEnd", "DigestMode")
+		}},
+	{Version: 12, Description: "user roles, user lock and admin audit log",
+		Up: func(tx *gorm.DB) error {
+			if err := addMissingColumns(tx, &appUserV12{}, "Roles", "Locked"); err != nil {
+				return err
+			}
+			return createMissingTables(tx, &auditLogEntryV12{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropTables(tx, &auditLogEntryV12{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &appUserV12{}, "Roles", "Locked")
+		}},
+	{Version: 13, Description: "user sessions and refresh tokens",
+		Up: func(tx *gorm.DB) error {
+			// the sessions replace the logout tracking
+			if err := dropTables(tx, &loggedOutUserV1{}); err != nil {
+				return err
+			}
+			return createMissingTables(tx, &userSessionV13{}, &refreshTokenV13{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropTables(tx, &refreshTokenV13{}, &userSessionV13{}); err != nil {
+				return err
+			}
+			return createMissingTables(tx, &loggedOutUserV1{})
+		}},
+	{Version: 14, Description: "jwt signing keys",
+		Up: func(tx *gorm.DB) error {
+			return createMissingTables(tx, &signingKeyV14{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &signingKeyV14{})
+		}},
+	{Version: 15, Description: "email verification and user action tokens",
+		Up: func(tx *gorm.DB) error {
+			if err := addMissingColumns(tx, &appUserV15{}, "EmailVerified"); err != nil {
+				return err
+			}
+			return createMissingTables(tx, &userTokenV15{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropTables(tx, &userTokenV15{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &appUserV15{}, "EmailVerified")
+		}},
+	{Version: 16, Description: "last seen prices per alert rule and station",
+		Up: func(tx *gorm.DB) error {
+			// the single price per rule can not be assigned to a station, the first evaluation sets the new references
+			if err := dropColumns(tx, &alertRuleV4{}, "LastSeenPrice"); err != nil {
+				return err
+			}
+			return createMissingTables(tx, &alertRuleLastSeenV16{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropTables(tx, &alertRuleLastSeenV16{}); err != nil {
+				return err
+			}
+			return addMissingColumns(tx, &alertRuleV4{}, "LastSeenPrice")
+		}},
+	{Version: 17, Description: "admin audit log details",
+		Up: func(tx *gorm.DB) error {
+			return addMissingColumns(tx, &auditLogEntryV17{}, "Details")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &auditLogEntryV17{}, "Details")
+		}},
+}
@@ -0,0 +1,460 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package dbmigrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// the tables as they were at the version in the name, a migration must not change with the live models.
+// The snapshots of versions that add columns to a table contain only the added columns.
+
+// version 1
+type gasStationV1 struct {
+	ID                      string `gorm:"primaryKey"`
+	Version                 string
+	VersionTime             time.Time
+	StationName             string `gorm:"column:name"`
+	Brand                   string `gorm:"index:idx_brand"`
+	Street                  string
+	Place                   string `gorm:"index:idx_gas_station_place"`
+	HouseNumber             string
+	PostCode                string  `gorm:"index:idx_gas_station_post_code"`
+	Latitude                float64 `gorm:"column:lat;index:idx_lat"`
+	Longitude               float64 `gorm:"column:lng;index:idx_lng"`
+	PublicHolidayIdentifier string
+	PriceInImport           time.Time `gorm:"index:idx_updated"`
+	PriceChanged            time.Time
+	OpenTs                  int `gorm:"index:idx_open_ts"`
+	OtJson                  string
+	StationInImport         time.Time
+	FirstActive             time.Time
+	GasPrices               []gasPriceV1 `gorm:"foreignKey:GasStationID"`
+}
+
+func (gasStationV1) TableName() string {
+	return "gas_station"
+}
+
+type gasPriceV1 struct {
+	ID           int64  `gorm:"primaryKey"`
+	GasStationID string `gorm:"column:stid;index:idx_stid"`
+	E5           int
+	E10          int
+	Diesel       int
+	Date         time.Time `gorm:"index:idx_date"`
+	Changed      int
+}
+
+func (gasPriceV1) TableName() string {
+	return "gas_station_information_history"
+}
+
+type appUserV1 struct {
+	gorm.Model
+	Username     string `gorm:"size:64;not null;index:idx_au_user_name,unique"`
+	Password     string `gorm:"size:128;not null"`
+	Uuid         string `gorm:"size:64;not null"`
+	LangKey      string `gorm:"size:8;not null"`
+	Latitude     float64
+	Longitude    float64
+	SearchRadius float64
+	TargetDiesel int
+	TargetE5     int
+	TargetE10    int
+}
+
+func (appUserV1) TableName() string {
+	return "app_user"
+}
+
+// replaced by the user sessions in version 13
+type loggedOutUserV1 struct {
+	ID         int64  `gorm:"primaryKey"`
+	Username   string `gorm:"size:64;not null;index:idx_lou_user_name"`
+	Uuid       string `gorm:"size:64;not null;index:idx_lou_uuid,unique"`
+	LastLogout time.Time
+}
+
+func (loggedOutUserV1) TableName() string {
+	return "logged_out_user"
+}
+
+type postCodeLocationV1 struct {
+	gorm.Model
+	Label           string `gorm:"size:256;not null;index:idx_post_code_location_label"`
+	PostCode        int32  `gorm:"index:idx_post_code_location_post_code"`
+	Population      int32
+	SquareKM        float32
+	CenterLongitude float64 `gorm:"index:idx_post_code_location_center_logitude"`
+	CenterLatitude  float64 `gorm:"index:idx_post_code_location_center_latitude"`
+}
+
+func (postCodeLocationV1) TableName() string {
+	return "post_code_location"
+}
+
+type userNotificationV1 struct {
+	ID               int64     `gorm:"primaryKey"`
+	Timestamp        time.Time `gorm:"index:idx_un_timestamp"`
+	UserUuid         string    `gorm:"size:64;not null;index:idx_un_user_uuid"`
+	Title            string    `gorm:"size:256"`
+	Message          string    `gorm:"size:4096"`
+	DataJson         string
+	NotificationSend bool
+}
+
+func (userNotificationV1) TableName() string {
+	return "user_notification"
+}
+
+// version 2
+type appUserV2 struct {
+	NotificationChannels string `gorm:"size:128"`
+	Email                string `gorm:"size:256"`
+	WebhookUrl           string `gorm:"size:1024"`
+	WebPushSubscription  string `gorm:"size:2048"`
+}
+
+func (appUserV2) TableName() string {
+	return "app_user"
+}
+
+type userNotificationV2 struct {
+	DeliveryStatus   string `gorm:"size:1024"`
+	DeliveryAttempts int
+}
+
+func (userNotificationV2) TableName() string {
+	return "user_notification"
+}
+
+// version 3
+type alertRuleV3 struct {
+	gorm.Model
+	UserUuid     string `gorm:"size:64;not null;index:idx_ar_user_uuid"`
+	Name         string `gorm:"size:64;not null"`
+	Latitude     float64
+	Longitude    float64
+	SearchRadius float64
+	FuelType     string `gorm:"size:16;not null"`
+	TargetPrice  int
+	ActiveFrom   string `gorm:"size:5"`
+	ActiveTo     string `gorm:"size:5"`
+	Brands       string `gorm:"size:512"`
+	Active       bool
+}
+
+func (alertRuleV3) TableName() string {
+	return "alert_rule"
+}
+
+// version 4
+type alertRuleV4 struct {
+	ReferenceType   string `gorm:"size:16"`
+	ReferenceRadius float64
+	MinDifference   int
+	LastSeenPrice   int
+}
+
+func (alertRuleV4) TableName() string {
+	return "alert_rule"
+}
+
+// version 5
+type pollKeyStateV5 struct {
+	KeyId          string `gorm:"primaryKey;size:64"`
+	Day            string `gorm:"size:10"`
+	RequestCount   int
+	LastRequest    time.Time
+	BackoffUntil   time.Time
+	BackoffSeconds int
+}
+
+func (pollKeyStateV5) TableName() string {
+	return "poll_key_state"
+}
+
+type pollRegionStateV5 struct {
+	Name           string `gorm:"primaryKey;size:64"`
+	NextCircle     int
+	RoundStarted   time.Time
+	RoundFinished  time.Time
+	FailedRequests int
+}
+
+func (pollRegionStateV5) TableName() string {
+	return "poll_region_state"
+}
+
+// version 6
+type priceImportStateV6 struct {
+	FileName  string `gorm:"primaryKey;size:255"`
+	Rows      int
+	Done      bool
+	UpdatedAt time.Time
+}
+
+func (priceImportStateV6) TableName() string {
+	return "price_import_state"
+}
+
+// version 7
+type fuelAggregateV7 struct {
+	Min   int
+	Max   int
+	Avg   int
+	First int
+	Last  int
+	Count int
+}
+
+type priceAggregateV7 struct {
+	GasStationID string          `gorm:"column:stid;primaryKey;size:64"`
+	Start        time.Time       `gorm:"primaryKey;index"`
+	E5           fuelAggregateV7 `gorm:"embedded;embeddedPrefix:e5_"`
+	E10          fuelAggregateV7 `gorm:"embedded;embeddedPrefix:e10_"`
+	Diesel       fuelAggregateV7 `gorm:"embedded;embeddedPrefix:diesel_"`
+}
+
+type hourlyPriceV7 struct {
+	PriceAggregate priceAggregateV7 `gorm:"embedded"`
+}
+
+func (hourlyPriceV7) TableName() string {
+	return "gas_price_hourly"
+}
+
+type dailyPriceV7 struct {
+	PriceAggregate priceAggregateV7 `gorm:"embedded"`
+}
+
+func (dailyPriceV7) TableName() string {
+	return "gas_price_daily"
+}
+
+// version 8
+type quarantinedPriceV8 struct {
+	ID           int64  `gorm:"primaryKey"`
+	GasStationID string `gorm:"column:stid;index"`
+	E5           int
+	E10          int
+	Diesel       int
+	Date         time.Time
+	Changed      int
+	Reason       string `gorm:"size:512"`
+	Status       string `gorm:"size:16;index"`
+	ResolvedBy   string `gorm:"size:255"`
+	ResolvedAt   time.Time
+	CreatedAt    time.Time
+}
+
+func (quarantinedPriceV8) TableName() string {
+	return "gas_price_quarantine"
+}
+
+// version 9
+type gasPriceV9 struct {
+	ID           int64  `gorm:"primaryKey"`
+	GasStationID string `gorm:"column:stid;index:idx_stid;uniqueIndex:idx_stid_date"`
+	E5           int
+	E10          int
+	Diesel       int
+	Date         time.Time `gorm:"index:idx_date;uniqueIndex:idx_stid_date"`
+	Changed      int
+}
+
+func (gasPriceV9) TableName() string {
+	return "gas_station_information_history"
+}
+
+// version 10
+type outboxItemV10 struct {
+	ID            int64  `gorm:"primaryKey"`
+	Kind          string `gorm:"size:32;not null"`
+	Payload       string
+	Status        string `gorm:"size:16;index:idx_outbox_status_next"`
+	Attempts      int
+	LastError     string    `gorm:"size:1024"`
+	NextAttemptAt time.Time `gorm:"index:idx_outbox_status_next"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (outboxItemV10) TableName() string {
+	return "notification_outbox"
+}
+
+// version 11
+type appUserV11 struct {
+	NotificationCooldown int
+	RepeatUnchanged      bool
+	QuietHoursStart      string `gorm:"size:5"`
+	QuietHoursEnd        string `gorm:"size:5"`
+	DigestMode           string `gorm:"size:8"`
+}
+
+func (appUserV11) TableName() string {
+	return "app_user"
+}
+
+type notificationStateV11 struct {
+	UserUuid     string `gorm:"primaryKey;size:64"`
+	GasStationID string `gorm:"primaryKey;size:64;column:stid;index:idx_ns_stid"`
+	FuelType     string `gorm:"primaryKey;size:8"`
+	Price        int
+	NotifiedAt   time.Time
+}
+
+func (notificationStateV11) TableName() string {
+	return "notification_state"
+}
+
+type notificationSuppressionV11 struct {
+	ID           int64     `gorm:"primaryKey"`
+	Timestamp    time.Time `gorm:"index:idx_nsu_timestamp"`
+	UserUuid     string    `gorm:"size:64;not null;index:idx_nsu_user_uuid"`
+	GasStationID string    `gorm:"size:64;column:stid"`
+	FuelType     string    `gorm:"size:8"`
+	Price        int
+	Reason       string `gorm:"size:16"`
+}
+
+func (notificationSuppressionV11) TableName() string {
+	return "notification_suppression"
+}
+
+type digestEntryV11 struct {
+	ID           int64     `gorm:"primaryKey"`
+	Timestamp    time.Time `gorm:"index:idx_de_timestamp"`
+	UserUuid     string    `gorm:"size:64;not null;index:idx_de_user_uuid"`
+	GasStationID string    `gorm:"size:64;column:stid"`
+	DataJson     string
+}
+
+func (digestEntryV11) TableName() string {
+	return "digest_entry"
+}
+
+// version 12
+type appUserV12 struct {
+	Roles  string `gorm:"size:64"`
+	Locked bool
+}
+
+func (appUserV12) TableName() string {
+	return "app_user"
+}
+
+type auditLogEntryV12 struct {
+	ID        int64     `gorm:"primaryKey"`
+	Timestamp time.Time `gorm:"not null;index:idx_al_timestamp"`
+	Username  string    `gorm:"size:64;not null"`
+	Method    string    `gorm:"size:8;not null"`
+	Path      string    `gorm:"size:256;not null"`
+	Target    string    `gorm:"size:256"`
+	Status    int
+}
+
+func (auditLogEntryV12) TableName() string {
+	return "admin_audit_log"
+}
+
+// version 13
+type userSessionV13 struct {
+	ID           string    `gorm:"primaryKey;size:64"`
+	Username     string    `gorm:"size:64;not null;index:idx_us_user_name"`
+	UserAgent    string    `gorm:"size:256"`
+	IpAddress    string    `gorm:"size:64"`
+	CreatedAt    time.Time `gorm:"not null"`
+	LastUsedAt   time.Time `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null;index:idx_us_expires_at"`
+	Revoked      bool
+	RevokedAt    time.Time
+	RevokeReason string `gorm:"size:32"`
+}
+
+func (userSessionV13) TableName() string {
+	return "user_session"
+}
+
+type refreshTokenV13 struct {
+	TokenHash string    `gorm:"primaryKey;size:64"`
+	SessionID string    `gorm:"size:64;not null;index:idx_rt_session_id"`
+	CreatedAt time.Time `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	Used      bool
+}
+
+func (refreshTokenV13) TableName() string {
+	return "user_refresh_token"
+}
+
+// version 14
+type signingKeyV14 struct {
+	Kid         string    `gorm:"primaryKey;size:64"`
+	Algorithm   string    `gorm:"size:8;not null"`
+	PrivateKey  string    `gorm:"size:4096;not null"`
+	CreatedAt   time.Time `gorm:"not null"`
+	ActivatesAt time.Time `gorm:"not null"`
+}
+
+func (signingKeyV14) TableName() string {
+	return "jwt_signing_key"
+}
+
+// version 15
+type appUserV15 struct {
+	EmailVerified bool
+}
+
+func (appUserV15) TableName() string {
+	return "app_user"
+}
+
+type userTokenV15 struct {
+	TokenHash string    `gorm:"primaryKey;size:64"`
+	Username  string    `gorm:"size:64;not null;index:idx_ut_user_name"`
+	Purpose   string    `gorm:"size:16;not null"`
+	Email     string    `gorm:"size:256"`
+	CreatedAt time.Time `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	Used      bool
+}
+
+func (userTokenV15) TableName() string {
+	return "user_action_token"
+}
+
+// version 16
+type alertRuleLastSeenV16 struct {
+	AlertRuleID  uint   `gorm:"primaryKey"`
+	GasStationID string `gorm:"primaryKey;size:64;column:stid;index:idx_arls_stid"`
+	Price        int
+	SeenAt       time.Time
+}
+
+func (alertRuleLastSeenV16) TableName() string {
+	return "alert_rule_last_seen"
+}
+
+// version 17
+type auditLogEntryV17 struct {
+	Details string `gorm:"size:1024"`
+}
+
+func (auditLogEntryV17) TableName() string {
+	return "admin_audit_log"
+}
@@ -0,0 +1,134 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	gosqlite "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqlite lower() only folds ascii characters
+const SqliteLowerFunction = "unicode_lower"
+
+// waits for locks instead of failing and starts write transactions immediately to avoid lock upgrade deadlocks
+const sqliteParams = "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate"
+
+func init() {
+	gosqlite.MustRegisterDeterministicScalarFunction(SqliteLowerFunction, 1, func(ctx *gosqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		if myStr, ok := args[0].(string); ok {
+			return strings.ToLower(myStr), nil
+		}
+		return args[0], nil
+	})
+}
+
+// DB_PARAMS is the path of the database file
+func openSqlite(dsn string, gormConfig *gorm.Config) (*gorm.DB, error) {
+	if !strings.Contains(dsn, "?") {
+		dsn = dsn + "?" + sqliteParams
+	}
+	sqlDB, err := sql.Open(sqlite.DriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return gorm.Open(sqlite.Dialector{Conn: &utcConnPool{db: sqlDB}}, gormConfig)
+}
+
+// sqlite stores times as text with the offset of the time value, the values are stored in utc to compare them as text
+type utcConnPool struct {
+	db *sql.DB
+}
+
+func (connPool *utcConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return connPool.db.PrepareContext(ctx, query)
+}
+
+func (connPool *utcConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return connPool.db.ExecContext(ctx, query, utcArgs(args)...)
+}
+
+func (connPool *utcConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return connPool.db.QueryContext(ctx, query, utcArgs(args)...)
+}
+
+func (connPool *utcConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return connPool.db.QueryRowContext(ctx, query, utcArgs(args)...)
+}
+
+func (connPool *utcConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tx, err := connPool.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &utcTx{tx: tx}, nil
+}
+
+func (connPool *utcConnPool) GetDBConn() (*sql.DB, error) {
+	return connPool.db, nil
+}
+
+type utcTx struct {
+	tx *sql.Tx
+}
+
+func (utcTx *utcTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return utcTx.tx.PrepareContext(ctx, query)
+}
+
+func (utcTx *utcTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return utcTx.tx.ExecContext(ctx, query, utcArgs(args)...)
+}
+
+func (utcTx *utcTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return utcTx.tx.QueryContext(ctx, query, utcArgs(args)...)
+}
+
+func (utcTx *utcTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return utcTx.tx.QueryRowContext(ctx, query, utcArgs(args)...)
+}
+
+func (utcTx *utcTx) Commit() error {
+	return utcTx.tx.Commit()
+}
+
+func (utcTx *utcTx) Rollback() error {
+	return utcTx.tx.Rollback()
+}
+
+func utcArgs(args []interface{}) []interface{} {
+	result := make([]interface{}, len(args))
+	for index, arg := range args {
+		//gorm.DeletedAt and sql.NullTime provide the time as driver.Valuer
+		if myValuer, ok := arg.(driver.Valuer); ok {
+			if myValue, err := myValuer.Value(); err == nil {
+				if _, isTime := myValue.(time.Time); isTime {
+					arg = myValue
+				}
+			}
+		}
+		if myTime, ok := arg.(time.Time); ok {
+			arg = myTime.UTC()
+		} else if myTime, ok := arg.(*time.Time); ok && myTime != nil {
+			arg = myTime.UTC()
+		}
+		result[index] = arg
+	}
+	return result
+}
@@ -0,0 +1,202 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsfile
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rows that are written in one transaction, the import state is saved after every batch
+const priceBatchRows = 10000
+
+const importUsage = "usage: importprices <directory>"
+
+var requiredColumns = []string{"date", "station_uuid", "diesel", "e5", "e10"}
+
+// 'importprices <directory>' imports the prices csv files of the directory, returns the exit code
+func RunImportCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, importUsage)
+		return 2
+	}
+	report, err := ImportPriceFiles(args[0])
+	fmt.Printf("Files: %v rows: %v imported: %v duplicates: %v unknown stations: %v invalid: %v\n", report.Files, report.Rows, report.Imported, report.Duplicates,
+		report.UnknownStations, report.Invalid)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "importprices failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// imports the files '*.csv' and '*.csv.gz' of the directory and its subdirectories in the order of the file names.
+// Imported files are skipped, a partly imported file continues after the last saved batch.
+func ImportPriceFiles(dirPath string) (gasstation.PriceImportReport, error) {
+	fileNames := []string{}
+	if err := filepath.WalkDir(dirPath, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		lowerName := strings.ToLower(dirEntry.Name())
+		if !dirEntry.IsDir() && (strings.HasSuffix(lowerName, ".csv") || strings.HasSuffix(lowerName, ".csv.gz")) {
+			fileName, err := filepath.Rel(dirPath, path)
+			if err != nil {
+				return err
+			}
+			fileNames = append(fileNames, filepath.ToSlash(fileName))
+		}
+		return nil
+	}); err != nil {
+		return gasstation.PriceImportReport{}, err
+	}
+	sort.Strings(fileNames)
+	importer := gasstation.NewPriceImporter()
+	for _, fileName := range fileNames {
+		if err := importPriceFile(importer, dirPath, fileName); err != nil {
+			return importer.Report, fmt.Errorf("%v: %v", fileName, err)
+		}
+	}
+	return importer.Report, nil
+}
+
+func importPriceFile(importer *gasstation.PriceImporter, dirPath string, fileName string) error {
+	priceImportState := gasstation.FindPriceImportState(fileName)
+	if priceImportState.Done {
+		return nil
+	}
+	file, err := os.Open(filepath.Join(dirPath, filepath.FromSlash(fileName)))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var reader io.Reader = bufio.NewReader(file)
+	if strings.HasSuffix(strings.ToLower(fileName), ".gz") {
+		gzReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	}
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+	header, err := csvReader.Read()
+	if err != nil {
+		return fmt.Errorf("read header failed: %v", err)
+	}
+	columnToIndex, err := readColumns(header)
+	if err != nil {
+		return err
+	}
+	log.Printf("Price import: %v from row: %v\n", fileName, priceImportState.Rows)
+	importer.Report.Files++
+	rowNumber := 0
+	gasPrices := []gsmodel.GasPrice{}
+	saveBatch := func() error {
+		if err := importer.ImportBatch(gasPrices); err != nil {
+			return err
+		}
+		gasPrices = []gsmodel.GasPrice{}
+		priceImportState.Rows = rowNumber
+		return gasstation.SavePriceImportState(&priceImportState)
+	}
+	for {
+		row, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		rowNumber++
+		if rowNumber <= priceImportState.Rows {
+			continue
+		}
+		importer.Report.Rows++
+		myGasPrice, ok := convertCsvToGasPrice(row, columnToIndex)
+		if !ok {
+			importer.Report.Invalid++
+			continue
+		}
+		gasPrices = append(gasPrices, myGasPrice)
+		if len(gasPrices) >= priceBatchRows {
+			if err := saveBatch(); err != nil {
+				return err
+			}
+		}
+	}
+	priceImportState.Done = true
+	return saveBatch()
+}
+
+func readColumns(header []string) (map[string]int, error) {
+	columnToIndex := make(map[string]int)
+	for index, column := range header {
+		columnToIndex[strings.ToLower(strings.TrimSpace(column))] = index
+	}
+	for _, column := range requiredColumns {
+		if _, found := columnToIndex[column]; !found {
+			return columnToIndex, fmt.Errorf("column %v not found", column)
+		}
+	}
+	return columnToIndex, nil
+}
+
+// prices are in euro, the change columns are 0 for no change and 1, 2 or 3 for changed, removed or new
+func convertCsvToGasPrice(row []string, columnToIndex map[string]int) (gsmodel.GasPrice, bool) {
+	column := func(name string) string {
+		if index, found := columnToIndex[name]; found && index < len(row) {
+			return strings.TrimSpace(row[index])
+		}
+		return ""
+	}
+	date, err := time.Parse("2006-01-02 15:04:05-07", column("date"))
+	if err != nil || len(column("station_uuid")) == 0 {
+		return gsmodel.GasPrice{}, false
+	}
+	result := gsmodel.GasPrice{GasStationID: column("station_uuid"), Date: date}
+	for _, fuelColumn := range []struct {
+		name    string
+		price   *int
+		changed int
+	}{{"diesel", &result.Diesel, 1}, {"e5", &result.E5, 4}, {"e10", &result.E10, 16}} {
+		price, err := strconv.ParseFloat(column(fuelColumn.name), 64)
+		if err != nil {
+			return gsmodel.GasPrice{}, false
+		}
+		if price > 0.0 {
+			*fuelColumn.price = int(price*1000 + 0.5)
+		}
+		//files without change columns mark all fuels as changed
+		if column(fuelColumn.name+"change") != "0" {
+			result.Changed = result.Changed + fuelColumn.changed
+		}
+	}
+	return result, true
+}
@@ -0,0 +1,146 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsfile
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"react-and-go/pkd/app"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"testing"
+	"time"
+)
+
+const testStid = "51d4b55e-a095-1aa0-e100-80009459e03a"
+
+const testHeader = "date,station_uuid,diesel,e5,e10,dieselchange,e5change,e10change\n"
+
+func TestReadColumns(t *testing.T) {
+	columnToIndex, err := readColumns([]string{" Date", "station_uuid", "diesel", "E5", "e10", "e10change"})
+	if err != nil {
+		t.Fatalf("Read columns failed: %v", err)
+	}
+	if columnToIndex["date"] != 0 || columnToIndex["e5"] != 3 || columnToIndex["e10change"] != 5 {
+		t.Errorf("Column indexes: %v", columnToIndex)
+	}
+	if _, err := readColumns([]string{"date", "station_uuid", "diesel", "e5"}); err == nil {
+		t.Errorf("Missing column e10 accepted")
+	}
+}
+
+func TestConvertCsvToGasPrice(t *testing.T) {
+	columnToIndex, _ := readColumns([]string{"date", "station_uuid", "diesel", "e5", "e10", "dieselchange", "e5change", "e10change"})
+	date := time.Date(2023, time.March, 6, 7, 10, 0, 0, time.FixedZone("", 3600))
+	testCases := []struct {
+		row      []string
+		valid    bool
+		gasPrice gsmodel.GasPrice
+	}{
+		{[]string{"2023-03-06 07:10:00+01", testStid, "1.689", "1.859", "1.799", "1", "0", "3"}, true,
+			gsmodel.GasPrice{GasStationID: testStid, Date: date, Diesel: 1689, E5: 1859, E10: 1799, Changed: 17}},
+		{[]string{"2023-03-06 07:10:00+01", testStid, "0.000", "1.859", "-0.001", "0", "1", "0"}, true,
+			gsmodel.GasPrice{GasStationID: testStid, Date: date, E5: 1859, Changed: 4}},
+		// files without change columns mark all fuels as changed
+		{[]string{"2023-03-06 07:10:00+01", testStid, "1.689", "1.859", "1.799"}, true,
+			gsmodel.GasPrice{GasStationID: testStid, Date: date, Diesel: 1689, E5: 1859, E10: 1799, Changed: 21}},
+		{[]string{"2023-03-06", testStid, "1.689", "1.859", "1.799", "1", "1", "1"}, false, gsmodel.GasPrice{}},
+		{[]string{"2023-03-06 07:10:00+01", " ", "1.689", "1.859", "1.799", "1", "1", "1"}, false, gsmodel.GasPrice{}},
+		{[]string{"2023-03-06 07:10:00+01", testStid, "1,689", "1.859", "1.799", "1", "1", "1"}, false, gsmodel.GasPrice{}},
+		{[]string{"2023-03-06 07:10:00+01", testStid, "1.689"}, false, gsmodel.GasPrice{}},
+	}
+	for _, testCase := range testCases {
+		gasPrice, ok := convertCsvToGasPrice(testCase.row, columnToIndex)
+		if ok != testCase.valid {
+			t.Errorf("Row %v valid: %v", testCase.row, ok)
+			continue
+		}
+		if ok && (gasPrice.GasStationID != testCase.gasPrice.GasStationID || !gasPrice.Date.Equal(testCase.gasPrice.Date) || gasPrice.Diesel != testCase.gasPrice.Diesel ||
+			gasPrice.E5 != testCase.gasPrice.E5 || gasPrice.E10 != testCase.gasPrice.E10 || gasPrice.Changed != testCase.gasPrice.Changed) {
+			t.Errorf("Row %v: %+v", testCase.row, gasPrice)
+		}
+	}
+}
+
+func TestImportPriceFiles(t *testing.T) {
+	myApp := app.NewInMemoryApp()
+	myApp.Wire()
+	if err := myApp.GasStationRepository.Save([]gsmodel.GasStation{{ID: testStid, StationName: "Test", PostCode: "20095",
+		Latitude: 53.55, Longitude: 10.0}}); err != nil {
+		t.Fatalf("Save gas station failed: %v", err)
+	}
+	dirPath := t.TempDir()
+	writeFile(t, filepath.Join(dirPath, "2023-03-06-prices.csv"), testHeader+
+		"2023-03-06 07:10:00+01,"+testStid+",1.689,1.859,1.799,1,1,1\n"+
+		"2023-03-06 07:10:00+01,"+testStid+",1.689,1.859,1.799,1,1,1\n"+
+		"2023-03-06 07:15:00+01,00000000-0000-0000-0000-000000000001,1.689,1.859,1.799,1,1,1\n"+
+		"invalid,"+testStid+",1.689,1.859,1.799,1,1,1\n")
+	if err := os.MkdirAll(filepath.Join(dirPath, "2023", "03"), 0o755); err != nil {
+		t.Fatalf("Create directory failed: %v", err)
+	}
+	file, err := os.Create(filepath.Join(dirPath, "2023", "03", "2023-03-07-prices.csv.gz"))
+	if err != nil {
+		t.Fatalf("Create file failed: %v", err)
+	}
+	gzWriter := gzip.NewWriter(file)
+	gzWriter.Write([]byte(testHeader + "2023-03-07 08:00:00+01," + testStid + ",1.679,1.849,1.789,1,1,1\n"))
+	gzWriter.Close()
+	file.Close()
+	writeFile(t, filepath.Join(dirPath, "readme.txt"), "not imported")
+
+	report, err := ImportPriceFiles(dirPath)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if report != (gasstation.PriceImportReport{Files: 2, Rows: 5, Imported: 2, Duplicates: 1, UnknownStations: 1, Invalid: 1}) {
+		t.Errorf("Import report: %+v", report)
+	}
+	if gasPrices := myApp.PriceRepository.FindByStid(testStid); len(gasPrices) != 2 {
+		t.Errorf("Imported prices: %v want: 2", len(gasPrices))
+	}
+	// imported files are skipped
+	if report, err := ImportPriceFiles(dirPath); err != nil || report != (gasstation.PriceImportReport{}) {
+		t.Errorf("Second import: %+v, %v", report, err)
+	}
+}
+
+func TestImportPriceFilesResumes(t *testing.T) {
+	myApp := app.NewInMemoryApp()
+	myApp.Wire()
+	if err := myApp.GasStationRepository.Save([]gsmodel.GasStation{{ID: testStid, StationName: "Test", PostCode: "20095",
+		Latitude: 53.55, Longitude: 10.0}}); err != nil {
+		t.Fatalf("Save gas station failed: %v", err)
+	}
+	dirPath := t.TempDir()
+	writeFile(t, filepath.Join(dirPath, "prices.csv"), testHeader+
+		"2023-03-06 07:10:00+01,"+testStid+",1.689,1.859,1.799,1,1,1\n"+
+		"2023-03-06 07:20:00+01,"+testStid+",1.679,1.849,1.789,1,1,1\n")
+	// an interrupted import saved the first row
+	if err := gasstation.SavePriceImportState(&gsmodel.PriceImportState{FileName: "prices.csv", Rows: 1}); err != nil {
+		t.Fatalf("Save import state failed: %v", err)
+	}
+	report, err := ImportPriceFiles(dirPath)
+	if err != nil || report.Rows != 1 || report.Imported != 1 {
+		t.Errorf("Resumed import: %+v, %v", report, err)
+	}
+	if priceImportState := gasstation.FindPriceImportState("prices.csv"); !priceImportState.Done || priceImportState.Rows != 2 {
+		t.Errorf("Import state: %+v", priceImportState)
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Write file failed: %v", err)
+	}
+}
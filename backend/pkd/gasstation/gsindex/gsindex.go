@@ -0,0 +1,193 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsindex
+
+import (
+	"math"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"sort"
+	"sync"
+)
+
+// grid cells of 0.05 degrees, about 5.5 km north-south
+const (
+	cellSize        = 0.05
+	kmPerDegree     = 111.2
+	maxSearchRadius = 20038.0 // half the circumference of the earth
+)
+
+type cellKey struct {
+	LatCell int
+	LngCell int
+}
+
+type entry struct {
+	Latitude  float64
+	Longitude float64
+	Cell      cellKey
+}
+
+type Hit struct {
+	ID        string
+	Latitude  float64
+	Longitude float64
+	Distance  float64
+	Bearing   float64
+}
+
+// in memory grid index of the gas station coordinates, safe for concurrent use
+type Index struct {
+	mutex   sync.RWMutex
+	entries map[string]entry
+	cells   map[cellKey]map[string]bool
+}
+
+func NewIndex() *Index {
+	return &Index{entries: make(map[string]entry), cells: make(map[cellKey]map[string]bool)}
+}
+
+// replaces the content of the index
+func (index *Index) Load(gasStations []gsmodel.GasStation) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	index.entries = make(map[string]entry)
+	index.cells = make(map[cellKey]map[string]bool)
+	for _, myGasStation := range gasStations {
+		index.upsert(myGasStation.ID, myGasStation.Latitude, myGasStation.Longitude)
+	}
+}
+
+func (index *Index) Upsert(gasStations []gsmodel.GasStation) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	for _, myGasStation := range gasStations {
+		index.upsert(myGasStation.ID, myGasStation.Latitude, myGasStation.Longitude)
+	}
+}
+
+func (index *Index) Remove(ids []string) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	for _, id := range ids {
+		index.remove(id)
+	}
+}
+
+func (index *Index) Len() int {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+	return len(index.entries)
+}
+
+// stations within the radius in km, sorted by distance
+func (index *Index) InRadius(latitude float64, longitude float64, radius float64) []Hit {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+	result := []Hit{}
+	if radius < 0.0 {
+		return result
+	}
+	checkHit := func(id string, myEntry entry) {
+		distance, bearing := gsmodel.GasStation{Latitude: myEntry.Latitude, Longitude: myEntry.Longitude}.CalcDistanceBearing(latitude, longitude)
+		if distance <= radius {
+			result = append(result, Hit{ID: id, Latitude: myEntry.Latitude, Longitude: myEntry.Longitude, Distance: distance, Bearing: bearing})
+		}
+	}
+	minCell, maxCell, ok := cellRange(latitude, longitude, radius)
+	cellCount := (maxCell.LatCell - minCell.LatCell + 1) * (maxCell.LngCell - minCell.LngCell + 1)
+	if !ok || cellCount > len(index.cells) {
+		//scanning all entries is cheaper than visiting the cells
+		for id, myEntry := range index.entries {
+			checkHit(id, myEntry)
+		}
+	} else {
+		for latCell := minCell.LatCell; latCell <= maxCell.LatCell; latCell++ {
+			for lngCell := minCell.LngCell; lngCell <= maxCell.LngCell; lngCell++ {
+				for id := range index.cells[cellKey{LatCell: latCell, LngCell: lngCell}] {
+					checkHit(id, index.entries[id])
+				}
+			}
+		}
+	}
+	sortHits(result)
+	return result
+}
+
+// the n nearest stations within maxRadius in km, sorted by distance. maxRadius <= 0 means unlimited.
+func (index *Index) Nearest(latitude float64, longitude float64, n int, maxRadius float64) []Hit {
+	if n <= 0 {
+		return []Hit{}
+	}
+	if maxRadius <= 0.0 || maxRadius > maxSearchRadius {
+		maxRadius = maxSearchRadius
+	}
+	radius := math.Min(cellSize*kmPerDegree, maxRadius)
+	for {
+		hits := index.InRadius(latitude, longitude, radius)
+		if len(hits) >= n || radius >= maxRadius || len(hits) >= index.Len() {
+			if len(hits) > n {
+				hits = hits[:n]
+			}
+			return hits
+		}
+		radius = math.Min(radius*2, maxRadius)
+	}
+}
+
+func (index *Index) upsert(id string, latitude float64, longitude float64) {
+	index.remove(id)
+	myEntry := entry{Latitude: latitude, Longitude: longitude, Cell: toCellKey(latitude, longitude)}
+	index.entries[id] = myEntry
+	if _, found := index.cells[myEntry.Cell]; !found {
+		index.cells[myEntry.Cell] = make(map[string]bool)
+	}
+	index.cells[myEntry.Cell][id] = true
+}
+
+func (index *Index) remove(id string) {
+	myEntry, found := index.entries[id]
+	if !found {
+		return
+	}
+	delete(index.entries, id)
+	delete(index.cells[myEntry.Cell], id)
+	if len(index.cells[myEntry.Cell]) == 0 {
+		delete(index.cells, myEntry.Cell)
+	}
+}
+
+func toCellKey(latitude float64, longitude float64) cellKey {
+	return cellKey{LatCell: int(math.Floor(latitude / cellSize)), LngCell: int(math.Floor(longitude / cellSize))}
+}
+
+// the cells of the bounding box of the circle, not ok if the box covers a pole or the date line
+func cellRange(latitude float64, longitude float64, radius float64) (cellKey, cellKey, bool) {
+	deltaLat := radius / kmPerDegree
+	if math.Abs(latitude)+deltaLat >= 90.0 {
+		return cellKey{}, cellKey{}, false
+	}
+	deltaLng := radius / (kmPerDegree * math.Cos((math.Abs(latitude)+deltaLat)*math.Pi/180.0))
+	if longitude-deltaLng < -180.0 || longitude+deltaLng > 180.0 {
+		return cellKey{}, cellKey{}, false
+	}
+	return toCellKey(latitude-deltaLat, longitude-deltaLng), toCellKey(latitude+deltaLat, longitude+deltaLng), true
+}
+
+func sortHits(hits []Hit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Distance == hits[j].Distance {
+			return hits[i].ID < hits[j].ID
+		}
+		return hits[i].Distance < hits[j].Distance
+	})
+}
@@ -12,7 +12,11 @@
 */
 package gsmodel
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type Tabler interface {
 	TableName() string
@@ -20,14 +24,44 @@ type Tabler interface {
 
 type GasPrice struct {
 	ID           int64  `gorm:"primaryKey"`
-	GasStationID string `gorm:"column:stid;index:idx_stid"`
+	GasStationID string `gorm:"column:stid;index:idx_stid;uniqueIndex:idx_stid_date"`
 	E5           int
 	E10          int
 	Diesel       int
-	Date         time.Time `gorm:"index:idx_date"`
+	Date         time.Time `gorm:"index:idx_date;uniqueIndex:idx_stid_date"` // the source timestamp, unique per station
 	Changed      int
 }
 
 func (GasPrice) TableName() string {
 	return "gas_station_information_history"
 }
+
+type FuelType string
+
+const (
+	FuelE5     FuelType = "e5"
+	FuelE10    FuelType = "e10"
+	FuelDiesel FuelType = "diesel"
+)
+
+var FuelTypes = []FuelType{FuelE5, FuelE10, FuelDiesel}
+
+func ParseFuelType(fuelTypeStr string) (FuelType, error) {
+	myFuelType := FuelType(strings.ToLower(strings.TrimSpace(fuelTypeStr)))
+	for _, fuelType := range FuelTypes {
+		if fuelType == myFuelType {
+			return fuelType, nil
+		}
+	}
+	return FuelE5, fmt.Errorf("unknown fuel type: %v", fuelTypeStr)
+}
+
+func (gasPrice GasPrice) PriceOf(fuelType FuelType) int {
+	switch fuelType {
+	case FuelE10:
+		return gasPrice.E10
+	case FuelDiesel:
+		return gasPrice.Diesel
+	}
+	return gasPrice.E5
+}
@@ -34,11 +34,16 @@ type GasStation struct {
 	PublicHolidayIdentifier string
 	PriceInImport           time.Time `gorm:"index:idx_updated"`
 	PriceChanged            time.Time
-	OpenTs                  int `gorm:"index:idx_open_ts"`
+	OpenTs                  int `gorm:"index:idx_open_ts"` // weekly open minutes from OtJson, 10080 means always open
 	OtJson                  string
 	StationInImport         time.Time
 	FirstActive             time.Time
 	GasPrices               []GasPrice
+	OpeningState            OpeningState      `gorm:"-"`
+	PricePredictions        []PricePrediction `gorm:"-" json:",omitempty"`
+	Distance                float64           `gorm:"-" json:",omitempty"` // km from the search location or route
+	Bearing                 float64           `gorm:"-" json:",omitempty"`
+	RouteKm                 float64           `gorm:"-" json:",omitempty"` // km along the route to the closest route point
 }
 
 type MyGasStation interface {
@@ -0,0 +1,226 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsmodel
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+type OpeningState string
+
+const (
+	Open        OpeningState = "OPEN"
+	ClosingSoon OpeningState = "CLOSING_SOON"
+	Closed      OpeningState = "CLOSED"
+)
+
+const (
+	minutesPerDay     = 24 * 60
+	minutesPerWeek    = 7 * minutesPerDay
+	closingSoonWindow = 30 * time.Minute
+	holidayIndex      = 7
+)
+
+// bits of applicable_days in the Tankerkoenig opening times json: monday = 1 ... sunday = 64, public holiday = 128
+var applicableDayBits = [8]int{1, 2, 4, 8, 16, 32, 64, 128}
+
+var StationLocation = loadStationLocation()
+
+type openingTimesJson struct {
+	OpeningTimes []openingTimeJson `json:"openingTimes"`
+}
+
+type openingTimeJson struct {
+	ApplicableDays int                 `json:"applicable_days"`
+	Periods        []openingPeriodJson `json:"periods"`
+}
+
+type openingPeriodJson struct {
+	Startp string `json:"startp"`
+	Endp   string `json:"endp"`
+}
+
+type minuteRange struct {
+	From int
+	To   int
+}
+
+type OpeningTimes struct {
+	AlwaysOpen bool
+	// index 0 = monday ... 6 = sunday, 7 = public holiday
+	dayRanges [8][]minuteRange
+}
+
+func ParseOpeningTimes(otJson string) (OpeningTimes, error) {
+	result := OpeningTimes{}
+	if len(strings.TrimSpace(otJson)) < 3 {
+		result.AlwaysOpen = true
+		return result, nil
+	}
+	var myOpeningTimesJson openingTimesJson
+	if err := json.Unmarshal([]byte(otJson), &myOpeningTimesJson); err != nil {
+		return result, err
+	}
+	if len(myOpeningTimesJson.OpeningTimes) == 0 {
+		result.AlwaysOpen = true
+		return result, nil
+	}
+	for _, myOpeningTime := range myOpeningTimesJson.OpeningTimes {
+		for _, myPeriod := range myOpeningTime.Periods {
+			from, err := parseMinuteOfDay(myPeriod.Startp)
+			if err != nil {
+				return result, err
+			}
+			to, err := parseMinuteOfDay(myPeriod.Endp)
+			if err != nil {
+				return result, err
+			}
+			for index, dayBit := range applicableDayBits {
+				if myOpeningTime.ApplicableDays&dayBit == 0 {
+					continue
+				}
+				result.addRange(index, from, to)
+			}
+		}
+	}
+	// no separate holiday times means the station opens on public holidays like on sundays
+	if len(result.dayRanges[holidayIndex]) == 0 {
+		result.dayRanges[holidayIndex] = result.dayRanges[6]
+	}
+	result.AlwaysOpen = result.WeeklyOpenMinutes() >= minutesPerWeek
+	return result, nil
+}
+
+func (openingTimes *OpeningTimes) addRange(dayIndex int, from int, to int) {
+	//23:59 is used as end of day
+	if to == minutesPerDay-1 {
+		to = minutesPerDay
+	}
+	if to <= from && !(from == 0 && to == 0) {
+		//period ends after midnight
+		openingTimes.dayRanges[dayIndex] = append(openingTimes.dayRanges[dayIndex], minuteRange{From: from, To: minutesPerDay})
+		if to > 0 {
+			nextDayIndex := (dayIndex + 1) % 7
+			if dayIndex == holidayIndex {
+				nextDayIndex = holidayIndex
+			}
+			openingTimes.dayRanges[nextDayIndex] = append(openingTimes.dayRanges[nextDayIndex], minuteRange{From: 0, To: to})
+		}
+		return
+	}
+	if from == 0 && to == 0 {
+		to = minutesPerDay
+	}
+	openingTimes.dayRanges[dayIndex] = append(openingTimes.dayRanges[dayIndex], minuteRange{From: from, To: to})
+}
+
+func (openingTimes OpeningTimes) WeeklyOpenMinutes() int {
+	if openingTimes.AlwaysOpen {
+		return minutesPerWeek
+	}
+	result := 0
+	for dayIndex := 0; dayIndex < 7; dayIndex++ {
+		openMinutes := [minutesPerDay]bool{}
+		for _, myRange := range openingTimes.dayRanges[dayIndex] {
+			for minute := myRange.From; minute < myRange.To; minute++ {
+				openMinutes[minute] = true
+			}
+		}
+		for _, isOpen := range openMinutes {
+			if isOpen {
+				result++
+			}
+		}
+	}
+	return result
+}
+
+func (openingTimes OpeningTimes) IsOpenAt(at time.Time, publicHolidayIdentifier string) bool {
+	if openingTimes.AlwaysOpen {
+		return true
+	}
+	localAt := at.In(StationLocation)
+	dayIndex := (int(localAt.Weekday()) + 6) % 7
+	if IsPublicHoliday(localAt, publicHolidayIdentifier) {
+		dayIndex = holidayIndex
+	}
+	minuteOfDay := localAt.Hour()*60 + localAt.Minute()
+	for _, myRange := range openingTimes.dayRanges[dayIndex] {
+		if minuteOfDay >= myRange.From && minuteOfDay < myRange.To {
+			return true
+		}
+	}
+	return false
+}
+
+func (openingTimes OpeningTimes) StateAt(at time.Time, publicHolidayIdentifier string) OpeningState {
+	if !openingTimes.IsOpenAt(at, publicHolidayIdentifier) {
+		return Closed
+	}
+	if !openingTimes.IsOpenAt(at.Add(closingSoonWindow), publicHolidayIdentifier) {
+		return ClosingSoon
+	}
+	return Open
+}
+
+func (gasStation GasStation) CalcOpeningState(at time.Time) OpeningState {
+	myOpeningTimes, err := ParseOpeningTimes(gasStation.OtJson)
+	if err != nil {
+		log.Printf("GasStation: %v OtJson parse failed: %v\n", gasStation.ID, err)
+		return Open
+	}
+	return myOpeningTimes.StateAt(at, gasStation.PublicHolidayIdentifier)
+}
+
+func CalcOpenTs(otJson string) int {
+	myOpeningTimes, err := ParseOpeningTimes(otJson)
+	if err != nil {
+		return 0
+	}
+	return myOpeningTimes.WeeklyOpenMinutes()
+}
+
+func parseMinuteOfDay(hourMinute string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(hourMinute), ":")
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("invalid time: %v", hourMinute)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, err
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, err
+	}
+	result := hour*60 + minute
+	if hour < 0 || minute < 0 || minute > 59 || result > minutesPerDay {
+		return 0, fmt.Errorf("invalid time: %v", hourMinute)
+	}
+	return result, nil
+}
+
+func loadStationLocation() *time.Location {
+	result, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		log.Printf("Failed to load location Europe/Berlin: %v\n", err)
+		return time.Local
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsmodel
+
+import (
+	"testing"
+	"time"
+)
+
+const weekdaysAndWeekendJson = `{"openingTimes":[{"applicable_days":31,"periods":[{"startp":"06:00","endp":"22:00"}]},` +
+	`{"applicable_days":96,"periods":[{"startp":"08:00","endp":"20:00"}]}]}`
+
+func stationTime(year int, month time.Month, day int, hour int, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, StationLocation)
+}
+
+func TestParseOpeningTimesStates(t *testing.T) {
+	openingTimes, err := ParseOpeningTimes(weekdaysAndWeekendJson)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if openingTimes.AlwaysOpen {
+		t.Errorf("Station always open")
+	}
+	if minutes := openingTimes.WeeklyOpenMinutes(); minutes != 5*16*60+2*12*60 {
+		t.Errorf("Weekly open minutes: %v", minutes)
+	}
+	// 2023-03-06 is a monday, 2023-03-11 a saturday
+	states := []struct {
+		at    time.Time
+		state OpeningState
+	}{
+		{stationTime(2023, time.March, 6, 5, 59), Closed},
+		{stationTime(2023, time.March, 6, 6, 0), Open},
+		{stationTime(2023, time.March, 6, 21, 45), ClosingSoon},
+		{stationTime(2023, time.March, 6, 22, 0), Closed},
+		{stationTime(2023, time.March, 11, 7, 0), Closed},
+		{stationTime(2023, time.March, 11, 12, 0), Open},
+	}
+	for _, myState := range states {
+		if state := openingTimes.StateAt(myState.at, "HH"); state != myState.state {
+			t.Errorf("State at %v: %v want: %v", myState.at, state, myState.state)
+		}
+	}
+}
+
+func TestParseOpeningTimesHolidays(t *testing.T) {
+	openingTimes, err := ParseOpeningTimes(weekdaysAndWeekendJson)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	// good friday 2023-04-07 uses the sunday times without separate holiday times
+	if openingTimes.IsOpenAt(stationTime(2023, time.April, 7, 7, 0), "HH") {
+		t.Errorf("Open on good friday at 07:00")
+	}
+	if !openingTimes.IsOpenAt(stationTime(2023, time.April, 7, 9, 0), "HH") {
+		t.Errorf("Closed on good friday at 09:00")
+	}
+	holidayJson := `{"openingTimes":[{"applicable_days":127,"periods":[{"startp":"06:00","endp":"22:00"}]},` +
+		`{"applicable_days":128,"periods":[{"startp":"10:00","endp":"14:00"}]}]}`
+	openingTimes, err = ParseOpeningTimes(holidayJson)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	// epiphany is a public holiday in bavaria only
+	if openingTimes.IsOpenAt(stationTime(2023, time.January, 6, 8, 0), "BY") {
+		t.Errorf("Open on epiphany in BY at 08:00")
+	}
+	if !openingTimes.IsOpenAt(stationTime(2023, time.January, 6, 8, 0), "HH") {
+		t.Errorf("Closed on epiphany in HH at 08:00")
+	}
+}
+
+func TestParseOpeningTimesSpecialPeriods(t *testing.T) {
+	for _, otJson := range []string{"", "{}", `{"openingTimes":[]}`,
+		`{"openingTimes":[{"applicable_days":255,"periods":[{"startp":"00:00","endp":"23:59"}]}]}`,
+		`{"openingTimes":[{"applicable_days":127,"periods":[{"startp":"00:00","endp":"00:00"}]}]}`} {
+		openingTimes, err := ParseOpeningTimes(otJson)
+		if err != nil || !openingTimes.AlwaysOpen {
+			t.Errorf("Not always open: %v error: %v", otJson, err)
+		}
+	}
+	// monday 22:00 until tuesday 06:00
+	openingTimes, err := ParseOpeningTimes(`{"openingTimes":[{"applicable_days":1,"periods":[{"startp":"22:00","endp":"06:00"}]}]}`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !openingTimes.IsOpenAt(stationTime(2023, time.March, 7, 5, 0), "HH") || openingTimes.IsOpenAt(stationTime(2023, time.March, 7, 6, 0), "HH") {
+		t.Errorf("Period after midnight not applied to tuesday")
+	}
+	if minutes := openingTimes.WeeklyOpenMinutes(); minutes != 8*60 {
+		t.Errorf("Weekly open minutes: %v", minutes)
+	}
+	for _, otJson := range []string{`{"openingTimes":[{"applicable_days":1,"periods":[{"startp":"25:00","endp":"26:00"}]}]}`,
+		`{"openingTimes":[{"applicable_days":1,"periods":[{"startp":"6","endp":"22:00"}]}]}`, `{"openingTimes":`} {
+		if _, err := ParseOpeningTimes(otJson); err == nil {
+			t.Errorf("Invalid opening times parsed: %v", otJson)
+		}
+	}
+	if openTs := CalcOpenTs(`{"openingTimes":`); openTs != 0 {
+		t.Errorf("Open minutes of invalid opening times: %v", openTs)
+	}
+}
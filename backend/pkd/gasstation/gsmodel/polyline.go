@@ -0,0 +1,58 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsmodel
+
+import "errors"
+
+type GeoPoint struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// decodes the encoded polyline format with a precision of 5 decimals
+func DecodePolyline(encoded string) ([]GeoPoint, error) {
+	result := []GeoPoint{}
+	latitude := 0
+	longitude := 0
+	for index := 0; index < len(encoded); {
+		values := [2]int{}
+		for i := range values {
+			shift := 0
+			value := 0
+			for {
+				if index >= len(encoded) {
+					return []GeoPoint{}, errors.New("invalid polyline")
+				}
+				myByte := int(encoded[index]) - 63
+				index++
+				if myByte < 0 || myByte > 63 {
+					return []GeoPoint{}, errors.New("invalid polyline")
+				}
+				value |= (myByte & 0x1f) << shift
+				shift += 5
+				if myByte < 0x20 {
+					break
+				}
+			}
+			if value&1 == 1 {
+				values[i] = ^(value >> 1)
+			} else {
+				values[i] = value >> 1
+			}
+		}
+		latitude += values[0]
+		longitude += values[1]
+		result = append(result, GeoPoint{Latitude: float64(latitude) / 1e5, Longitude: float64(longitude) / 1e5})
+	}
+	return result, nil
+}
@@ -0,0 +1,45 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsmodel
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecodePolyline(t *testing.T) {
+	geoPoints, err := DecodePolyline("_p~iF~ps|U_ulLnnqC_mqNvxq`@")
+	if err != nil {
+		t.Errorf("Decode failed: %v", err)
+	}
+	expected := []GeoPoint{{Latitude: 38.5, Longitude: -120.2}, {Latitude: 40.7, Longitude: -120.95}, {Latitude: 43.252, Longitude: -126.453}}
+	if len(geoPoints) != len(expected) {
+		t.Fatalf("Decoded points: %v", geoPoints)
+	}
+	for index, myGeoPoint := range geoPoints {
+		if math.Abs(myGeoPoint.Latitude-expected[index].Latitude) > 1e-9 || math.Abs(myGeoPoint.Longitude-expected[index].Longitude) > 1e-9 {
+			t.Errorf("Point %v: %v, expected: %v", index, myGeoPoint, expected[index])
+		}
+	}
+	if geoPoints, err := DecodePolyline(""); err != nil || len(geoPoints) != 0 {
+		t.Errorf("Empty polyline: %v, %v", geoPoints, err)
+	}
+}
+
+func TestDecodePolylineInvalid(t *testing.T) {
+	for _, encoded := range []string{"_p~iF~ps|U_", "_p~iF", "_p~iF~ps|U ", "\x7f?"} {
+		if geoPoints, err := DecodePolyline(encoded); err == nil || len(geoPoints) != 0 {
+			t.Errorf("Invalid polyline %q: %v, %v", encoded, geoPoints, err)
+		}
+	}
+}
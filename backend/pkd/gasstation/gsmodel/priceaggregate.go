@@ -0,0 +1,115 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsmodel
+
+import "time"
+
+// the prices of a fuel in the period, prices <= 10 are not included
+type FuelAggregate struct {
+	Min   int
+	Max   int
+	Avg   int
+	First int
+	Last  int
+	Count int
+	sum   int // while aggregating
+}
+
+type PriceAggregate struct {
+	GasStationID string        `gorm:"column:stid;primaryKey;size:64"`
+	Start        time.Time     `gorm:"primaryKey;index"`
+	E5           FuelAggregate `gorm:"embedded;embeddedPrefix:e5_"`
+	E10          FuelAggregate `gorm:"embedded;embeddedPrefix:e10_"`
+	Diesel       FuelAggregate `gorm:"embedded;embeddedPrefix:diesel_"`
+}
+
+// the raw prices of the hour that started at Start
+type HourlyPrice struct {
+	PriceAggregate
+}
+
+func (HourlyPrice) TableName() string {
+	return "gas_price_hourly"
+}
+
+// the raw prices of the day in StationLocation that started at Start
+type DailyPrice struct {
+	PriceAggregate
+}
+
+func (DailyPrice) TableName() string {
+	return "gas_price_daily"
+}
+
+func (fuelAggregate *FuelAggregate) add(price int) {
+	if price <= 10 {
+		return
+	}
+	if fuelAggregate.Count == 0 {
+		fuelAggregate.Min = price
+		fuelAggregate.Max = price
+		fuelAggregate.First = price
+	}
+	if price < fuelAggregate.Min {
+		fuelAggregate.Min = price
+	}
+	if price > fuelAggregate.Max {
+		fuelAggregate.Max = price
+	}
+	fuelAggregate.Last = price
+	fuelAggregate.sum = fuelAggregate.sum + price
+	fuelAggregate.Count++
+	fuelAggregate.Avg = fuelAggregate.sum / fuelAggregate.Count
+}
+
+// merges the prices of the same period, the prices of other are newer if otherIsNewer
+func (fuelAggregate *FuelAggregate) merge(other FuelAggregate, otherIsNewer bool) {
+	if other.Count == 0 {
+		return
+	}
+	if fuelAggregate.Count == 0 {
+		*fuelAggregate = other
+		return
+	}
+	if other.Min < fuelAggregate.Min {
+		fuelAggregate.Min = other.Min
+	}
+	if other.Max > fuelAggregate.Max {
+		fuelAggregate.Max = other.Max
+	}
+	if otherIsNewer {
+		fuelAggregate.Last = other.Last
+	} else {
+		fuelAggregate.First = other.First
+	}
+	fuelAggregate.Avg = (fuelAggregate.Avg*fuelAggregate.Count + other.Avg*other.Count) / (fuelAggregate.Count + other.Count)
+	fuelAggregate.Count = fuelAggregate.Count + other.Count
+}
+
+// adds the raw prices in the order of their dates
+func (priceAggregate *PriceAggregate) Add(gasPrice GasPrice) {
+	priceAggregate.E5.add(gasPrice.E5)
+	priceAggregate.E10.add(gasPrice.E10)
+	priceAggregate.Diesel.add(gasPrice.Diesel)
+}
+
+func (priceAggregate *PriceAggregate) Merge(other PriceAggregate, otherIsNewer bool) {
+	priceAggregate.E5.merge(other.E5, otherIsNewer)
+	priceAggregate.E10.merge(other.E10, otherIsNewer)
+	priceAggregate.Diesel.merge(other.Diesel, otherIsNewer)
+}
+
+// the average prices at Start
+func (priceAggregate PriceAggregate) ToGasPrice() GasPrice {
+	return GasPrice{GasStationID: priceAggregate.GasStationID, E5: priceAggregate.E5.Avg, E10: priceAggregate.E10.Avg, Diesel: priceAggregate.Diesel.Avg, Date: priceAggregate.Start}
+}
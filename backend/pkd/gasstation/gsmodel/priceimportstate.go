@@ -0,0 +1,23 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsmodel
+
+import "time"
+
+// the progress of a prices csv import, a restart skips the imported rows
+type PriceImportState struct {
+	FileName  string `gorm:"primaryKey;size:255"` // relative to the import directory
+	Rows      int    // data rows that are imported
+	Done      bool
+	UpdatedAt time.Time
+}
@@ -0,0 +1,157 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsmodel
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type publicHoliday struct {
+	Month int
+	Day   int
+	// offset in days to easter sunday, used if Month is 0
+	EasterOffset int
+	// empty for nationwide holidays, otherwise the federal state identifiers
+	States []string
+}
+
+var publicHolidays = []publicHoliday{
+	{Month: 1, Day: 1},
+	{Month: 1, Day: 6, States: []string{"BW", "BY", "ST"}},
+	{Month: 3, Day: 8, States: []string{"BE", "MV"}},
+	{EasterOffset: -2},
+	{EasterOffset: 0, States: []string{"BB"}},
+	{EasterOffset: 1},
+	{Month: 5, Day: 1},
+	{EasterOffset: 39},
+	{EasterOffset: 49, States: []string{"BB"}},
+	{EasterOffset: 50},
+	{EasterOffset: 60, States: []string{"BW", "BY", "HE", "NW", "RP", "SL"}},
+	{Month: 8, Day: 15, States: []string{"SL"}},
+	{Month: 9, Day: 20, States: []string{"TH"}},
+	{Month: 10, Day: 3},
+	{Month: 10, Day: 31, States: []string{"BB", "HB", "HH", "MV", "NI", "SN", "ST", "SH", "TH"}},
+	{Month: 11, Day: 1, States: []string{"BW", "BY", "NW", "RP", "SL"}},
+	{Month: 12, Day: 25},
+	{Month: 12, Day: 26},
+}
+
+func IsPublicHoliday(day time.Time, publicHolidayIdentifier string) bool {
+	state := normalizeStateIdentifier(publicHolidayIdentifier)
+	if state == "SN" && isRepentanceDay(day) {
+		return true
+	}
+	easterSunday := calcEasterSunday(day.Year(), day.Location())
+	for _, myPublicHoliday := range publicHolidays {
+		if len(myPublicHoliday.States) > 0 && !containsState(myPublicHoliday.States, state) {
+			continue
+		}
+		if myPublicHoliday.Month > 0 {
+			if int(day.Month()) == myPublicHoliday.Month && day.Day() == myPublicHoliday.Day {
+				return true
+			}
+			continue
+		}
+		holiday := easterSunday.AddDate(0, 0, myPublicHoliday.EasterOffset)
+		if holiday.Month() == day.Month() && holiday.Day() == day.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// accepts identifiers like 'BY', 'de-by' or 'DE_BY'
+func normalizeStateIdentifier(publicHolidayIdentifier string) string {
+	result := strings.ToUpper(strings.TrimSpace(publicHolidayIdentifier))
+	result = strings.TrimPrefix(result, "DE-")
+	result = strings.TrimPrefix(result, "DE_")
+	return result
+}
+
+func containsState(states []string, state string) bool {
+	for _, myState := range states {
+		if myState == state {
+			return true
+		}
+	}
+	return false
+}
+
+// wednesday before november 23
+func isRepentanceDay(day time.Time) bool {
+	if day.Month() != time.November {
+		return false
+	}
+	referenceDay := time.Date(day.Year(), time.November, 22, 0, 0, 0, 0, day.Location())
+	daysBack := (int(referenceDay.Weekday()) - int(time.Wednesday) + 7) % 7
+	repentanceDay := referenceDay.AddDate(0, 0, -daysBack)
+	return day.Day() == repentanceDay.Day()
+}
+
+// anonymous gregorian algorithm
+func calcEasterSunday(year int, location *time.Location) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := ((h + l - 7*m + 114) % 31) + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, location)
+}
+
+type postCodeState struct {
+	// the first post code of the range, the range ends at the From of the next entry
+	From  int
+	State string
+}
+
+// the federal states of the post code ranges, a few towns at the state borders are assigned to the neighbour state
+var postCodeStates = []postCodeState{
+	{1000, "SN"}, {1940, "BB"}, {2000, "SN"}, {3000, "BB"}, {4000, "SN"}, {4600, "TH"}, {4640, "SN"}, {4890, "BB"},
+	{6000, "ST"}, {6556, "TH"}, {6580, "ST"}, {7000, "TH"}, {8000, "SN"},
+	{10000, "BE"}, {14400, "BB"}, {17000, "MV"}, {17260, "BB"}, {17300, "MV"}, {19300, "BB"}, {19370, "MV"},
+	{20000, "HH"}, {21200, "NI"}, {21450, "SH"}, {21600, "NI"}, {22000, "HH"}, {22800, "SH"}, {23920, "MV"}, {24000, "SH"},
+	{26000, "NI"}, {27568, "HB"}, {27581, "NI"}, {28000, "HB"}, {28780, "NI"},
+	{32000, "NW"}, {34000, "HE"}, {34400, "NW"}, {34450, "HE"}, {36400, "TH"}, {37000, "NI"}, {37200, "HE"}, {37300, "TH"},
+	{37400, "NI"}, {37670, "NW"}, {37700, "NI"}, {38480, "ST"}, {38500, "NI"}, {38820, "ST"},
+	{40000, "NW"}, {48450, "NI"}, {48550, "NW"}, {49000, "NI"}, {49470, "NW"}, {49550, "NI"},
+	{50000, "NW"}, {53400, "RP"}, {53580, "NW"}, {54000, "RP"}, {57000, "NW"}, {57500, "RP"}, {57650, "NW"},
+	{60000, "HE"}, {63700, "BY"}, {64000, "HE"}, {66000, "SL"}, {66480, "RP"}, {66510, "SL"}, {66840, "RP"},
+	{68000, "BW"}, {68600, "HE"}, {68700, "BW"}, {69480, "HE"}, {69520, "BW"},
+	{80000, "BY"}, {88000, "BW"}, {88100, "BY"}, {88180, "BW"}, {89200, "BY"}, {89500, "BW"},
+	{90000, "BY"}, {96500, "TH"}, {96530, "BY"}, {97870, "BW"}, {98000, "TH"}, {100000, ""},
+}
+
+// the public holiday identifier of a german post code, empty if the post code is unknown
+func StateOfPostCode(postCode string) string {
+	myPostCode, err := strconv.Atoi(strings.TrimSpace(postCode))
+	if err != nil || len(strings.TrimSpace(postCode)) != 5 {
+		return ""
+	}
+	index := sort.Search(len(postCodeStates), func(i int) bool { return postCodeStates[i].From > myPostCode })
+	if index == 0 {
+		return ""
+	}
+	return postCodeStates[index-1].State
+}
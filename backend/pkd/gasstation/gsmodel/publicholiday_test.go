@@ -0,0 +1,65 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsmodel
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalcEasterSunday(t *testing.T) {
+	for year, easterSunday := range map[int]time.Time{2019: time.Date(2019, time.April, 21, 0, 0, 0, 0, time.UTC),
+		2023: time.Date(2023, time.April, 9, 0, 0, 0, 0, time.UTC), 2024: time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC),
+		2025: time.Date(2025, time.April, 20, 0, 0, 0, 0, time.UTC)} {
+		if result := calcEasterSunday(year, time.UTC); !result.Equal(easterSunday) {
+			t.Errorf("Easter sunday %v: %v want: %v", year, result, easterSunday)
+		}
+	}
+}
+
+func TestIsPublicHoliday(t *testing.T) {
+	holidays := []struct {
+		day                     time.Time
+		publicHolidayIdentifier string
+		holiday                 bool
+	}{
+		{time.Date(2023, time.October, 3, 12, 0, 0, 0, time.UTC), "", true},
+		{time.Date(2023, time.October, 4, 12, 0, 0, 0, time.UTC), "", false},
+		{time.Date(2023, time.April, 10, 12, 0, 0, 0, time.UTC), "HH", true}, // easter monday
+		{time.Date(2023, time.May, 18, 12, 0, 0, 0, time.UTC), "HH", true},   // ascension day
+		{time.Date(2023, time.June, 8, 12, 0, 0, 0, time.UTC), "NW", true},   // corpus christi
+		{time.Date(2023, time.June, 8, 12, 0, 0, 0, time.UTC), "HH", false},
+		{time.Date(2023, time.January, 6, 12, 0, 0, 0, time.UTC), "de-by", true},
+		{time.Date(2023, time.January, 6, 12, 0, 0, 0, time.UTC), "DE_BY", true},
+		{time.Date(2023, time.January, 6, 12, 0, 0, 0, time.UTC), "NI", false},
+		{time.Date(2023, time.November, 22, 12, 0, 0, 0, time.UTC), "SN", true}, // repentance day
+		{time.Date(2024, time.November, 20, 12, 0, 0, 0, time.UTC), "SN", true},
+		{time.Date(2024, time.November, 20, 12, 0, 0, 0, time.UTC), "BY", false},
+	}
+	for _, myHoliday := range holidays {
+		if holiday := IsPublicHoliday(myHoliday.day, myHoliday.publicHolidayIdentifier); holiday != myHoliday.holiday {
+			t.Errorf("Public holiday %v in %v: %v want: %v", myHoliday.day.Format("2006-01-02"), myHoliday.publicHolidayIdentifier, holiday,
+				myHoliday.holiday)
+		}
+	}
+}
+
+func TestStateOfPostCode(t *testing.T) {
+	for postCode, state := range map[string]string{"01067": "SN", "10115": "BE", "20095": "HH", "28195": "HB", "40210": "NW",
+		"55116": "RP", "60311": "HE", "66111": "SL", "70173": "BW", "80331": "BY", "99084": "TH", " 19053 ": "MV",
+		"00999": "", "1067": "", "123456": "", "abcde": "", "": ""} {
+		if result := StateOfPostCode(postCode); result != state {
+			t.Errorf("State of post code %q: %q want: %q", postCode, result, state)
+		}
+	}
+}
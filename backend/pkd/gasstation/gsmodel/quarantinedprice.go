@@ -0,0 +1,48 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsmodel
+
+import "time"
+
+type QuarantineStatus string
+
+const (
+	QuarantinePending   QuarantineStatus = "PENDING"
+	QuarantineConfirmed QuarantineStatus = "CONFIRMED"
+	QuarantineRejected  QuarantineStatus = "REJECTED"
+)
+
+// a price update that failed the plausibility checks, it is kept out of the history and the notifications until it is confirmed
+type QuarantinedPrice struct {
+	ID           int64  `gorm:"primaryKey"`
+	GasStationID string `gorm:"column:stid;index"`
+	E5           int
+	E10          int
+	Diesel       int
+	Date         time.Time
+	Changed      int
+	Reason       string           `gorm:"size:512"`
+	Status       QuarantineStatus `gorm:"size:16;index"`
+	ResolvedBy   string           `gorm:"size:255"` // the admin or the follow-up update
+	ResolvedAt   time.Time
+	CreatedAt    time.Time
+}
+
+func (QuarantinedPrice) TableName() string {
+	return "gas_price_quarantine"
+}
+
+func (quarantinedPrice QuarantinedPrice) ToGasPrice() GasPrice {
+	return GasPrice{GasStationID: quarantinedPrice.GasStationID, E5: quarantinedPrice.E5, E10: quarantinedPrice.E10, Diesel: quarantinedPrice.Diesel,
+		Date: quarantinedPrice.Date, Changed: quarantinedPrice.Changed}
+}
@@ -0,0 +1,62 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"encoding/json"
+	"react-and-go/pkd/gasstation/gsmodel"
+	unmodel "react-and-go/pkd/notification/model"
+	"time"
+)
+
+// no item without prices to evaluate
+func newEvaluateAlertsItems(gasPrices []gsmodel.GasPrice) ([]unmodel.OutboxItem, error) {
+	if len(gasPrices) == 0 {
+		return []unmodel.OutboxItem{}, nil
+	}
+	payload, err := json.Marshal(gasPrices)
+	if err != nil {
+		return []unmodel.OutboxItem{}, err
+	}
+	return []unmodel.OutboxItem{{Kind: unmodel.EvaluateAlertsKind, Payload: string(payload), Status: unmodel.OutboxPending, NextAttemptAt: time.Now()}}, nil
+}
+
+// the outbox handler for the new prices, the items are evaluated together with the newest price per station.
+// An item that can not be parsed fails alone.
+func EvaluateAlerts(outboxItems []unmodel.OutboxItem) map[int64]error {
+	result := make(map[int64]error)
+	evaluatedIds := []int64{}
+	gasPriceUpdateMap := make(map[string]gsmodel.GasPrice)
+	for _, outboxItem := range outboxItems {
+		gasPrices := []gsmodel.GasPrice{}
+		if err := json.Unmarshal([]byte(outboxItem.Payload), &gasPrices); err != nil {
+			result[outboxItem.ID] = err
+			continue
+		}
+		evaluatedIds = append(evaluatedIds, outboxItem.ID)
+		for _, gasPrice := range gasPrices {
+			if myGasPrice, found := gasPriceUpdateMap[gasPrice.GasStationID]; !found || gasPrice.Date.After(myGasPrice.Date) {
+				gasPriceUpdateMap[gasPrice.GasStationID] = gasPrice
+			}
+		}
+	}
+	if len(gasPriceUpdateMap) == 0 {
+		return result
+	}
+	if err := sendNotifications(&gasPriceUpdateMap); err != nil {
+		for _, id := range evaluatedIds {
+			result[id] = err
+		}
+	}
+	return result
+}
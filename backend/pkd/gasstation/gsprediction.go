@@ -0,0 +1,215 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"fmt"
+	gsbody "react-and-go/pkd/controller/gsmodel"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"sort"
+	"time"
+)
+
+const (
+	hoursPerWeek      = 7 * 24
+	predictionWeeks   = 4
+	predictionHorizon = 24
+)
+
+// average price per hour of the week, index 0 = monday 00:00 - 00:59, 0 means no data
+type weeklyPriceCurve [hoursPerWeek]int
+
+func PredictBestHour(stid string, fuelType gsmodel.FuelType) (gsmodel.PricePrediction, error) {
+	now := time.Now()
+	start := now.AddDate(0, 0, -7*predictionWeeks)
+	myGasPrices := findPricesByStidsAndPeriod(&[]string{stid}, start)
+	if len(myGasPrices) == 0 {
+		return gsmodel.PricePrediction{}, fmt.Errorf("no prices found for gas station: %v", stid)
+	}
+	myCurve := calcWeeklyPriceCurve(myGasPrices, fuelType, start, now)
+	return predictBestHour(stid, fuelType, &myCurve, latestPrice(myGasPrices, fuelType), now), nil
+}
+
+func PredictBestHourByLocation(searchPrediction gsbody.SearchPrediction) (gsmodel.AreaPricePrediction, error) {
+	fuelType, err := gsmodel.ParseFuelType(searchPrediction.FuelType)
+	if err != nil {
+		return gsmodel.AreaPricePrediction{}, err
+	}
+	if err := checkSearchRadius(searchPrediction.Radius); err != nil {
+		return gsmodel.AreaPricePrediction{}, err
+	}
+	now := time.Now()
+	gasStations := findStationsInCircle(searchPrediction.Latitude, searchPrediction.Longitude, searchPrediction.Radius, false)
+	stationCurves := calcStationPriceCurves(gasStations, []gsmodel.FuelType{fuelType}, now)
+	result := gsmodel.AreaPricePrediction{FuelType: fuelType, Stations: []gsmodel.PricePrediction{}}
+	areaCurves := []*weeklyPriceCurve{}
+	for stid, myStationCurves := range stationCurves {
+		myCurve := myStationCurves.curves[fuelType]
+		myPrediction := predictBestHour(stid, fuelType, myCurve, myStationCurves.latestPrices[fuelType], now)
+		if myPrediction.ExpectedPrice <= 10 {
+			continue
+		}
+		areaCurves = append(areaCurves, myCurve)
+		result.Stations = append(result.Stations, myPrediction)
+	}
+	sort.Slice(result.Stations, func(i, j int) bool {
+		return result.Stations[i].ExpectedPrice < result.Stations[j].ExpectedPrice
+	})
+	areaCurve := averagePriceCurves(areaCurves)
+	areaPrediction := predictBestHour("", fuelType, &areaCurve, 0, now)
+	result.BestHour = areaPrediction.BestHour
+	result.ExpectedPrice = areaPrediction.ExpectedPrice
+	return result, nil
+}
+
+func addPricePredictions(gasStations []gsmodel.GasStation, fuelTypeStr string) []gsmodel.GasStation {
+	fuelTypes := gsmodel.FuelTypes
+	if fuelType, err := gsmodel.ParseFuelType(fuelTypeStr); err == nil {
+		fuelTypes = []gsmodel.FuelType{fuelType}
+	}
+	now := time.Now()
+	stationCurves := calcStationPriceCurves(gasStations, fuelTypes, now)
+	for index, myGasStation := range gasStations {
+		myStationCurves, found := stationCurves[myGasStation.ID]
+		if !found {
+			continue
+		}
+		for _, fuelType := range fuelTypes {
+			myPrediction := predictBestHour(myGasStation.ID, fuelType, myStationCurves.curves[fuelType], myStationCurves.latestPrices[fuelType], now)
+			if myPrediction.ExpectedPrice > 10 {
+				gasStations[index].PricePredictions = append(gasStations[index].PricePredictions, myPrediction)
+			}
+		}
+	}
+	return gasStations
+}
+
+type stationPriceCurves struct {
+	curves       map[gsmodel.FuelType]*weeklyPriceCurve
+	latestPrices map[gsmodel.FuelType]int
+}
+
+func calcStationPriceCurves(gasStations []gsmodel.GasStation, fuelTypes []gsmodel.FuelType, now time.Time) map[string]stationPriceCurves {
+	result := make(map[string]stationPriceCurves)
+	if len(gasStations) == 0 {
+		return result
+	}
+	stids := []string{}
+	for _, myGasStation := range gasStations {
+		stids = append(stids, myGasStation.ID)
+	}
+	start := now.AddDate(0, 0, -7*predictionWeeks)
+	stationPricesMap := make(map[string][]gsmodel.GasPrice)
+	for _, myGasPrice := range findPricesByStidsAndPeriod(&stids, start) {
+		stationPricesMap[myGasPrice.GasStationID] = append(stationPricesMap[myGasPrice.GasStationID], myGasPrice)
+	}
+	for stid, myGasPrices := range stationPricesMap {
+		myStationCurves := stationPriceCurves{curves: make(map[gsmodel.FuelType]*weeklyPriceCurve), latestPrices: make(map[gsmodel.FuelType]int)}
+		for _, fuelType := range fuelTypes {
+			myCurve := calcWeeklyPriceCurve(myGasPrices, fuelType, start, now)
+			myStationCurves.curves[fuelType] = &myCurve
+			myStationCurves.latestPrices[fuelType] = latestPrice(myGasPrices, fuelType)
+		}
+		result[stid] = myStationCurves
+	}
+	return result
+}
+
+// the history stores price changes, the price of each hour is the last change before the middle of the hour
+func calcWeeklyPriceCurve(gasPrices []gsmodel.GasPrice, fuelType gsmodel.FuelType, start time.Time, end time.Time) weeklyPriceCurve {
+	sortedGasPrices := append([]gsmodel.GasPrice{}, gasPrices...)
+	sort.Slice(sortedGasPrices, func(i, j int) bool {
+		return sortedGasPrices[i].Date.Before(sortedGasPrices[j].Date)
+	})
+	var sums [hoursPerWeek]int
+	var counts [hoursPerWeek]int
+	priceIndex := -1
+	for hourStart := start.Truncate(time.Hour); hourStart.Before(end); hourStart = hourStart.Add(time.Hour) {
+		sampleTime := hourStart.Add(30 * time.Minute)
+		for priceIndex+1 < len(sortedGasPrices) && !sortedGasPrices[priceIndex+1].Date.After(sampleTime) {
+			priceIndex++
+		}
+		if priceIndex < 0 {
+			continue
+		}
+		price := sortedGasPrices[priceIndex].PriceOf(fuelType)
+		if price <= 10 {
+			continue
+		}
+		slot := hourOfWeek(hourStart)
+		sums[slot] += price
+		counts[slot]++
+	}
+	var result weeklyPriceCurve
+	for slot := range result {
+		if counts[slot] > 0 {
+			result[slot] = sums[slot] / counts[slot]
+		}
+	}
+	return result
+}
+
+func averagePriceCurves(curves []*weeklyPriceCurve) weeklyPriceCurve {
+	var result weeklyPriceCurve
+	for slot := range result {
+		sum := 0
+		count := 0
+		for _, myCurve := range curves {
+			if myCurve[slot] > 10 {
+				sum += myCurve[slot]
+				count++
+			}
+		}
+		if count > 0 {
+			result[slot] = sum / count
+		}
+	}
+	return result
+}
+
+func predictBestHour(stid string, fuelType gsmodel.FuelType, curve *weeklyPriceCurve, currentPrice int, now time.Time) gsmodel.PricePrediction {
+	result := gsmodel.PricePrediction{GasStationID: stid, FuelType: fuelType, CurrentPrice: currentPrice}
+	if curve == nil {
+		return result
+	}
+	nextHour := now.Truncate(time.Hour)
+	for hour := 0; hour < predictionHorizon; hour++ {
+		myHour := nextHour.Add(time.Duration(hour) * time.Hour)
+		expectedPrice := curve[hourOfWeek(myHour)]
+		if expectedPrice <= 10 {
+			continue
+		}
+		if result.ExpectedPrice == 0 || expectedPrice < result.ExpectedPrice {
+			result.ExpectedPrice = expectedPrice
+			result.BestHour = myHour
+		}
+	}
+	return result
+}
+
+func latestPrice(gasPrices []gsmodel.GasPrice, fuelType gsmodel.FuelType) int {
+	result := 0
+	var resultDate time.Time
+	for _, myGasPrice := range gasPrices {
+		if myGasPrice.Date.After(resultDate) {
+			result = myGasPrice.PriceOf(fuelType)
+			resultDate = myGasPrice.Date
+		}
+	}
+	return result
+}
+
+func hourOfWeek(myTime time.Time) int {
+	localTime := myTime.In(gsmodel.StationLocation)
+	return ((int(localTime.Weekday())+6)%7)*24 + localTime.Hour()
+}
@@ -0,0 +1,93 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"react-and-go/pkd/gasstation/gsmodel"
+	"testing"
+	"time"
+)
+
+// 2023-03-06 is a monday
+func mondayAt(hour int, minute int) time.Time {
+	return time.Date(2023, time.March, 6, hour, minute, 0, 0, gsmodel.StationLocation)
+}
+
+func TestHourOfWeek(t *testing.T) {
+	if slot := hourOfWeek(mondayAt(0, 59)); slot != 0 {
+		t.Errorf("Slot of monday 00:59: %v", slot)
+	}
+	if slot := hourOfWeek(mondayAt(0, 0).AddDate(0, 0, 6).Add(23 * time.Hour)); slot != hoursPerWeek-1 {
+		t.Errorf("Slot of sunday 23:00: %v", slot)
+	}
+	// 2023-03-05 23:30 utc is monday 00:30 in germany
+	if slot := hourOfWeek(time.Date(2023, time.March, 5, 23, 30, 0, 0, time.UTC)); slot != 0 {
+		t.Errorf("Slot of utc time: %v", slot)
+	}
+}
+
+func TestCalcWeeklyPriceCurve(t *testing.T) {
+	gasPrices := []gsmodel.GasPrice{
+		{E5: 1700, Date: mondayAt(1, 40)},
+		{E5: 1800, Date: mondayAt(0, 10)},
+		{E5: 1600, Date: mondayAt(0, 10).AddDate(0, 0, 7)},
+		// not sold
+		{E5: 0, Date: mondayAt(2, 10).AddDate(0, 0, 7)},
+	}
+	curve := calcWeeklyPriceCurve(gasPrices, gsmodel.FuelE5, mondayAt(0, 0), mondayAt(3, 0).AddDate(0, 0, 7))
+	// the price of an hour is the last change before its middle, the weeks are averaged
+	if curve[0] != 1700 || curve[1] != 1700 || curve[2] != 1700 {
+		t.Errorf("Curve of monday: %v", curve[:3])
+	}
+	if curve[3] != 1700 || curve[24] != 1700 {
+		t.Errorf("Curve after the changes: %v %v", curve[3], curve[24])
+	}
+	emptyCurve := calcWeeklyPriceCurve([]gsmodel.GasPrice{{E5: 1700, Date: mondayAt(5, 0)}}, gsmodel.FuelE5, mondayAt(0, 0), mondayAt(5, 0))
+	if emptyCurve != (weeklyPriceCurve{}) {
+		t.Errorf("Curve before the first price: %v", emptyCurve[:6])
+	}
+}
+
+func TestPredictBestHour(t *testing.T) {
+	var curve weeklyPriceCurve
+	for slot := range curve {
+		curve[slot] = 1700
+	}
+	curve[5] = 1600
+	curve[30] = 1500 // tuesday 06:00, after the horizon
+	curve[7] = 0
+	prediction := predictBestHour("stid", gsmodel.FuelE5, &curve, 1750, mondayAt(4, 20))
+	if !prediction.BestHour.Equal(mondayAt(5, 0)) || prediction.ExpectedPrice != 1600 || prediction.CurrentPrice != 1750 {
+		t.Errorf("Prediction: %+v", prediction)
+	}
+	if prediction := predictBestHour("stid", gsmodel.FuelE5, nil, 1750, mondayAt(0, 0)); prediction.ExpectedPrice != 0 {
+		t.Errorf("Prediction without curve: %+v", prediction)
+	}
+}
+
+func TestAveragePriceCurves(t *testing.T) {
+	var firstCurve, secondCurve weeklyPriceCurve
+	firstCurve[0], secondCurve[0] = 1700, 1800
+	firstCurve[1], secondCurve[1] = 1700, 0
+	curve := averagePriceCurves([]*weeklyPriceCurve{&firstCurve, &secondCurve})
+	if curve[0] != 1750 || curve[1] != 1700 || curve[2] != 0 {
+		t.Errorf("Average curve: %v", curve[:3])
+	}
+}
+
+func TestLatestPrice(t *testing.T) {
+	gasPrices := []gsmodel.GasPrice{{Diesel: 1600, Date: mondayAt(1, 0)}, {Diesel: 1650, Date: mondayAt(3, 0)}, {Diesel: 1620, Date: mondayAt(2, 0)}}
+	if price := latestPrice(gasPrices, gsmodel.FuelDiesel); price != 1650 {
+		t.Errorf("Latest price: %v", price)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"react-and-go/pkd/gasstation/gsmodel"
+	"time"
+)
+
+const priceImportBatchSize = 1000
+
+type PriceImportReport struct {
+	Files           int
+	Rows            int
+	Imported        int
+	Duplicates      int // (stid, date) is already in the database
+	UnknownStations int
+	Invalid         int
+}
+
+// writes archived prices without the checks and notifications of UpdatePrice
+type PriceImporter struct {
+	stationIds map[string]bool
+	Report     PriceImportReport
+}
+
+func NewPriceImporter() *PriceImporter {
+	stationIds := make(map[string]bool)
+	for _, myGasStation := range gasStationRepository.FindAllLocations() {
+		stationIds[myGasStation.ID] = true
+	}
+	return &PriceImporter{stationIds: stationIds}
+}
+
+// prices of unknown stations and prices with a (stid, date) that is already imported are skipped
+func (importer *PriceImporter) ImportBatch(gasPrices []gsmodel.GasPrice) error {
+	stidSet := make(map[string]bool)
+	var start, end time.Time
+	knownGasPrices := []gsmodel.GasPrice{}
+	for _, myGasPrice := range gasPrices {
+		if !importer.stationIds[myGasPrice.GasStationID] {
+			importer.Report.UnknownStations++
+			continue
+		}
+		stidSet[myGasPrice.GasStationID] = true
+		if start.IsZero() || myGasPrice.Date.Before(start) {
+			start = myGasPrice.Date
+		}
+		if end.IsZero() || myGasPrice.Date.After(end) {
+			end = myGasPrice.Date
+		}
+		knownGasPrices = append(knownGasPrices, myGasPrice)
+	}
+	if len(knownGasPrices) == 0 {
+		return nil
+	}
+	stids := []string{}
+	for stid := range stidSet {
+		stids = append(stids, stid)
+	}
+	importedKeys := make(map[string]bool)
+	for _, myGasPrice := range priceRepository.FindByStidsBetween(stids, start, end) {
+		importedKeys[priceImportKey(myGasPrice)] = true
+	}
+	newGasPrices := []gsmodel.GasPrice{}
+	for _, myGasPrice := range knownGasPrices {
+		if importedKeys[priceImportKey(myGasPrice)] {
+			importer.Report.Duplicates++
+			continue
+		}
+		importedKeys[priceImportKey(myGasPrice)] = true
+		newGasPrices = append(newGasPrices, myGasPrice)
+	}
+	if err := priceRepository.CreateInBatches(newGasPrices, priceImportBatchSize); err != nil {
+		return err
+	}
+	importer.Report.Imported = importer.Report.Imported + len(newGasPrices)
+	return nil
+}
+
+func FindPriceImportState(fileName string) gsmodel.PriceImportState {
+	return priceRepository.FindImportState(fileName)
+}
+
+func SavePriceImportState(priceImportState *gsmodel.PriceImportState) error {
+	return priceRepository.SaveImportState(priceImportState)
+}
+
+func priceImportKey(gasPrice gsmodel.GasPrice) string {
+	return gasPrice.GasStationID + "|" + gasPrice.Date.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,257 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"react-and-go/pkd/config"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/notification"
+	unmodel "react-and-go/pkd/notification/model"
+	"strings"
+	"time"
+)
+
+const (
+	minPlausiblePrice     = 500  // 0.500 €/l
+	maxPlausiblePrice     = 4000 // 4.000 €/l
+	minHistorySamples     = 3
+	confirmTolerance      = 20 // millicents a follow-up update may differ from the quarantined price
+	maxQuarantineListSize = 1000
+)
+
+var ErrQuarantineNotFound = errors.New("quarantined price not found")
+var ErrQuarantineResolved = errors.New("quarantined price is already resolved")
+
+type priceAnomalyCheck struct {
+	referencePrices     *batchReferencePrices
+	stidToHistory       map[string][]gsmodel.GasPrice
+	stidToGasStation    map[string]gsmodel.GasStation
+	maxStationDeviation int
+	maxAreaDeviation    int
+	areaRadius          float64
+}
+
+// moves the implausible updates from the map into the quarantine, returns the quarantined prices that a follow-up update confirmed
+func quarantineAnomalies(gasStationPrices []GasStationPrices, gasPriceUpdateMap map[string]gsmodel.GasPrice, stationPricesDb []gsmodel.GasPrice,
+	report *PriceUpdateReport) []gsmodel.GasPrice {
+	stids := []string{}
+	for _, stationPrices := range gasStationPrices {
+		stids = append(stids, stationPrices.GasStationID)
+	}
+	stidToPending := make(map[string][]gsmodel.QuarantinedPrice)
+	for _, quarantinedPrice := range quarantineRepository.FindByStidsAndStatus(stids, gsmodel.QuarantinePending) {
+		stidToPending[quarantinedPrice.GasStationID] = append(stidToPending[quarantinedPrice.GasStationID], quarantinedPrice)
+	}
+	quarantinedPrices := []gsmodel.QuarantinedPrice{}
+	confirmedPrices := []gsmodel.GasPrice{}
+	skipCheck := make(map[string]bool)
+	for _, stationPrices := range gasStationPrices {
+		pendingPrices := stidToPending[stationPrices.GasStationID]
+		if len(pendingPrices) == 0 {
+			continue
+		}
+		now := time.Now()
+		for _, pendingPrice := range pendingPrices {
+			// a redelivered update is already in the quarantine
+			if !stationPrices.Timestamp.After(pendingPrice.Date) {
+				if stationPrices.Timestamp.Equal(pendingPrice.Date) && similarPrices(stationPrices, pendingPrice) && !skipCheck[stationPrices.GasStationID] {
+					skipCheck[stationPrices.GasStationID] = true
+					if _, found := gasPriceUpdateMap[stationPrices.GasStationID]; found {
+						report.Duplicates++
+						delete(gasPriceUpdateMap, stationPrices.GasStationID)
+					}
+				}
+				continue
+			}
+			if similarPrices(stationPrices, pendingPrice) {
+				pendingPrice.Status = gsmodel.QuarantineConfirmed
+				pendingPrice.ResolvedBy = "follow-up update"
+				confirmedPrices = append(confirmedPrices, pendingPrice.ToGasPrice())
+				skipCheck[stationPrices.GasStationID] = true
+			} else {
+				pendingPrice.Status = gsmodel.QuarantineRejected
+				pendingPrice.ResolvedBy = fmt.Sprintf("superseded by the update of %v", stationPrices.Timestamp.Format(time.RFC3339))
+			}
+			pendingPrice.ResolvedAt = now
+			quarantinedPrices = append(quarantinedPrices, pendingPrice)
+		}
+		delete(stidToPending, stationPrices.GasStationID)
+	}
+	anomalyCheck := newPriceAnomalyCheck(gasPriceUpdateMap, stationPricesDb, skipCheck)
+	for stid, gasPrice := range gasPriceUpdateMap {
+		if skipCheck[stid] {
+			continue
+		}
+		if reasons := anomalyCheck.check(gasPrice); len(reasons) > 0 {
+			log.Printf("Price of GasStation: %v quarantined: %v\n", stid, strings.Join(reasons, "; "))
+			quarantinedPrices = append(quarantinedPrices, gsmodel.QuarantinedPrice{GasStationID: stid, E5: gasPrice.E5, E10: gasPrice.E10,
+				Diesel: gasPrice.Diesel, Date: gasPrice.Date, Changed: gasPrice.Changed, Reason: strings.Join(reasons, "; "), Status: gsmodel.QuarantinePending,
+				CreatedAt: time.Now()})
+			report.Quarantined++
+			delete(gasPriceUpdateMap, stid)
+		}
+	}
+	if err := quarantineRepository.Save(quarantinedPrices); err != nil {
+		log.Printf("Quarantine update failed: %v\n", err)
+	}
+	return confirmedPrices
+}
+
+func newPriceAnomalyCheck(gasPriceUpdateMap map[string]gsmodel.GasPrice, stationPricesDb []gsmodel.GasPrice, skipCheck map[string]bool) *priceAnomalyCheck {
+	anomalyCheck := &priceAnomalyCheck{referencePrices: newBatchReferencePrices(), stidToHistory: make(map[string][]gsmodel.GasPrice),
+		stidToGasStation: make(map[string]gsmodel.GasStation), maxStationDeviation: config.ReadIntEnv("PRICE_MAX_STATION_DEVIATION", 25),
+		maxAreaDeviation: config.ReadIntEnv("PRICE_MAX_AREA_DEVIATION", 30), areaRadius: float64(config.ReadIntEnv("PRICE_ANOMALY_RADIUS", 10))}
+	for _, gasPrice := range stationPricesDb {
+		anomalyCheck.stidToHistory[gasPrice.GasStationID] = append(anomalyCheck.stidToHistory[gasPrice.GasStationID], gasPrice)
+	}
+	// the locations of the area check
+	stationIds := []string{}
+	for stid := range gasPriceUpdateMap {
+		if !skipCheck[stid] {
+			stationIds = append(stationIds, stid)
+		}
+	}
+	if len(stationIds) > 0 {
+		for _, gasStation := range findByIds(&stationIds) {
+			anomalyCheck.stidToGasStation[gasStation.ID] = gasStation
+		}
+	}
+	return anomalyCheck
+}
+
+// the reasons why the price is implausible, empty if it is plausible. The price is compared with the history of the station
+// if it has enough samples and with the median of the area.
+func (anomalyCheck *priceAnomalyCheck) check(gasPrice gsmodel.GasPrice) []string {
+	reasons := []string{}
+	for _, fuelType := range gsmodel.FuelTypes {
+		price := gasPrice.PriceOf(fuelType)
+		// 10 or less means the fuel is not sold
+		if price <= 10 {
+			continue
+		}
+		if price < minPlausiblePrice || price > maxPlausiblePrice {
+			reasons = append(reasons, fmt.Sprintf("%v %v €/l outside %v-%v €/l", fuelType, formatPrice(price), formatPrice(minPlausiblePrice),
+				formatPrice(maxPlausiblePrice)))
+			continue
+		}
+		if historyPrices := anomalyCheck.historyPrices(gasPrice.GasStationID, fuelType); len(historyPrices) >= minHistorySamples {
+			median := calcFuelStatistics(historyPrices).Median
+			if deviation := percentDeviation(price, median); deviation > anomalyCheck.maxStationDeviation {
+				reasons = append(reasons, fmt.Sprintf("%v %v €/l deviates %v%% from the station median %v €/l", fuelType, formatPrice(price),
+					deviation, formatPrice(median)))
+			}
+		}
+		gasStation, found := anomalyCheck.stidToGasStation[gasPrice.GasStationID]
+		if !found {
+			continue
+		}
+		median := anomalyCheck.referencePrices.AreaMedian(gasStation.Latitude, gasStation.Longitude, anomalyCheck.areaRadius, fuelType)
+		if deviation := percentDeviation(price, median); median > 10 && deviation > anomalyCheck.maxAreaDeviation {
+			reasons = append(reasons, fmt.Sprintf("%v %v €/l deviates %v%% from the area median %v €/l", fuelType, formatPrice(price),
+				deviation, formatPrice(median)))
+		}
+	}
+	return reasons
+}
+
+func (anomalyCheck *priceAnomalyCheck) historyPrices(stid string, fuelType gsmodel.FuelType) []int {
+	prices := []int{}
+	for _, gasPrice := range anomalyCheck.stidToHistory[stid] {
+		if price := gasPrice.PriceOf(fuelType); price > 10 {
+			prices = append(prices, price)
+		}
+	}
+	return prices
+}
+
+func similarPrices(stationPrices GasStationPrices, quarantinedPrice gsmodel.QuarantinedPrice) bool {
+	return absInt(stationPrices.E5-quarantinedPrice.E5) <= confirmTolerance && absInt(stationPrices.E10-quarantinedPrice.E10) <= confirmTolerance &&
+		absInt(stationPrices.Diesel-quarantinedPrice.Diesel) <= confirmTolerance
+}
+
+func percentDeviation(price int, reference int) int {
+	if reference <= 0 {
+		return 0
+	}
+	return absInt(price-reference) * 100 / reference
+}
+
+func absInt(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func formatPrice(price int) string {
+	return fmt.Sprintf("%.3f", float64(price)/1000)
+}
+
+func FindQuarantinedPrices(statusStr string) ([]gsmodel.QuarantinedPrice, error) {
+	status := gsmodel.QuarantineStatus(strings.ToUpper(strings.TrimSpace(statusStr)))
+	if len(status) == 0 {
+		status = gsmodel.QuarantinePending
+	}
+	if status != gsmodel.QuarantinePending && status != gsmodel.QuarantineConfirmed && status != gsmodel.QuarantineRejected {
+		return nil, fmt.Errorf("unknown quarantine status: %v", statusStr)
+	}
+	return quarantineRepository.FindByStatus(status, maxQuarantineListSize), nil
+}
+
+// moves the price into the history, the alerts are only evaluated if no newer price exists
+func ConfirmQuarantinedPrice(id int64, username string) (gsmodel.QuarantinedPrice, error) {
+	quarantinedPrice, err := resolveQuarantinedPrice(id, username, gsmodel.QuarantineConfirmed)
+	if err != nil {
+		return quarantinedPrice, err
+	}
+	newerPrices := priceRepository.FindByStidsSince([]string{quarantinedPrice.GasStationID}, quarantinedPrice.Date.Add(time.Second))
+	if err := quarantineRepository.Confirm(quarantinedPrice, func(createdPrices []gsmodel.GasPrice) ([]unmodel.OutboxItem, error) {
+		if len(newerPrices) > 0 {
+			return []unmodel.OutboxItem{}, nil
+		}
+		return newEvaluateAlertsItems(createdPrices)
+	}); err != nil {
+		// a concurrent request resolved it first
+		if storedPrice, findErr := quarantineRepository.FindById(id); findErr == nil && storedPrice.Status != gsmodel.QuarantinePending {
+			return quarantinedPrice, fmt.Errorf("%w: %v", ErrQuarantineResolved, id)
+		}
+		return quarantinedPrice, err
+	}
+	notification.WakeOutboxDispatcher()
+	return quarantinedPrice, nil
+}
+
+func RejectQuarantinedPrice(id int64, username string) (gsmodel.QuarantinedPrice, error) {
+	quarantinedPrice, err := resolveQuarantinedPrice(id, username, gsmodel.QuarantineRejected)
+	if err != nil {
+		return quarantinedPrice, err
+	}
+	return quarantinedPrice, quarantineRepository.Save([]gsmodel.QuarantinedPrice{quarantinedPrice})
+}
+
+func resolveQuarantinedPrice(id int64, username string, status gsmodel.QuarantineStatus) (gsmodel.QuarantinedPrice, error) {
+	quarantinedPrice, err := quarantineRepository.FindById(id)
+	if err != nil {
+		return quarantinedPrice, fmt.Errorf("%w: %v", ErrQuarantineNotFound, id)
+	}
+	if quarantinedPrice.Status != gsmodel.QuarantinePending {
+		return quarantinedPrice, fmt.Errorf("%w: %v", ErrQuarantineResolved, id)
+	}
+	quarantinedPrice.Status = status
+	quarantinedPrice.ResolvedBy = username
+	quarantinedPrice.ResolvedAt = time.Now()
+	return quarantinedPrice, nil
+}
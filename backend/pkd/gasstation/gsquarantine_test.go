@@ -0,0 +1,132 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation_test
+
+import (
+	"errors"
+	"react-and-go/pkd/app"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/gasstation/gsmodel"
+	unmodel "react-and-go/pkd/notification/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testStid = "51d4b55e-a095-1aa0-e100-80009459e03a"
+
+func newTestApp(t *testing.T) *app.App {
+	myApp := app.NewInMemoryApp()
+	myApp.Wire()
+	if err := myApp.GasStationRepository.Save([]gsmodel.GasStation{{ID: testStid, StationName: "Test", PostCode: "20095",
+		Latitude: 53.55, Longitude: 10.0}}); err != nil {
+		t.Fatalf("Save gas station failed: %v", err)
+	}
+	return myApp
+}
+
+func storePrices(gasStationPrices ...gasstation.GasStationPrices) gasstation.PriceUpdateReport {
+	report, _ := gasstation.StorePrices(&gasStationPrices)
+	return report
+}
+
+func TestFollowUpConfirmsQuarantinedPrice(t *testing.T) {
+	myApp := newTestApp(t)
+	timestamp := time.Now().Add(-time.Hour)
+	storePrices(gasstation.GasStationPrices{GasStationID: testStid, E5: 1859, E10: 1799, Diesel: 1689, Timestamp: timestamp})
+	// 5.000 €/l is outside the plausible prices
+	if report := storePrices(gasstation.GasStationPrices{GasStationID: testStid, E5: 1859, E10: 1799, Diesel: 5000,
+		Timestamp: timestamp.Add(time.Minute)}); report.Applied != 0 || report.Quarantined != 1 {
+		t.Fatalf("Implausible price not quarantined: %+v", report)
+	}
+	if gasPrices := myApp.PriceRepository.FindByStid(testStid); len(gasPrices) != 1 {
+		t.Errorf("Quarantined price stored: %+v", gasPrices)
+	}
+	// a redelivered update does not quarantine the price again
+	if report := storePrices(gasstation.GasStationPrices{GasStationID: testStid, E5: 1859, E10: 1799, Diesel: 5000,
+		Timestamp: timestamp.Add(time.Minute)}); report.Quarantined != 0 || report.Duplicates != 1 {
+		t.Errorf("Redelivered quarantined price: %+v", report)
+	}
+	report := storePrices(gasstation.GasStationPrices{GasStationID: testStid, E5: 1859, E10: 1799, Diesel: 5010, Timestamp: timestamp.Add(2 * time.Minute)})
+	if report.Confirmed != 1 || report.Applied != 1 || report.Quarantined != 0 {
+		t.Errorf("Quarantined price not confirmed: %+v", report)
+	}
+	if gasPrices := myApp.PriceRepository.FindByStid(testStid); len(gasPrices) != 3 {
+		t.Errorf("Stored prices: %v want: 3", len(gasPrices))
+	}
+	quarantinedPrices, err := gasstation.FindQuarantinedPrices(string(gsmodel.QuarantineConfirmed))
+	if err != nil || len(quarantinedPrices) != 1 || quarantinedPrices[0].ResolvedBy != "follow-up update" {
+		t.Errorf("Confirmed prices: %+v error: %v", quarantinedPrices, err)
+	}
+}
+
+func TestConfirmQuarantinedPrice(t *testing.T) {
+	myApp := newTestApp(t)
+	timestamp := time.Now().Add(-time.Hour)
+	storePrices(gasstation.GasStationPrices{GasStationID: testStid, E5: 1859, E10: 1799, Diesel: 1689, Timestamp: timestamp})
+	storePrices(gasstation.GasStationPrices{GasStationID: testStid, E5: 1859, E10: 1799, Diesel: 5000, Timestamp: timestamp.Add(time.Minute)})
+	quarantinedPrices, err := gasstation.FindQuarantinedPrices("")
+	if err != nil || len(quarantinedPrices) != 1 {
+		t.Fatalf("Pending prices: %+v error: %v", quarantinedPrices, err)
+	}
+	quarantinedPrice, err := gasstation.ConfirmQuarantinedPrice(quarantinedPrices[0].ID, "admin")
+	if err != nil || quarantinedPrice.Status != gsmodel.QuarantineConfirmed || quarantinedPrice.ResolvedBy != "admin" {
+		t.Fatalf("Confirm failed: %+v error: %v", quarantinedPrice, err)
+	}
+	if gasPrices := myApp.PriceRepository.FindByStid(testStid); len(gasPrices) != 2 || gasPrices[0].Diesel != 5000 {
+		t.Errorf("Confirmed price not stored: %+v", gasPrices)
+	}
+	// the price and its outbox item are stored with the status
+	if pending := myApp.OutboxRepository.CountByStatus()[unmodel.OutboxPending]; pending != 2 {
+		t.Errorf("Outbox items: %v want: 2", pending)
+	}
+	if storedPrice, err := myApp.QuarantineRepository.FindById(quarantinedPrices[0].ID); err != nil || storedPrice.Status != gsmodel.QuarantineConfirmed {
+		t.Errorf("Stored quarantined price: %+v error: %v", storedPrice, err)
+	}
+	if _, err := gasstation.ConfirmQuarantinedPrice(quarantinedPrices[0].ID, "admin"); !errors.Is(err, gasstation.ErrQuarantineResolved) {
+		t.Errorf("Second confirm error: %v", err)
+	}
+	if _, err := gasstation.RejectQuarantinedPrice(quarantinedPrices[0].ID+1, "admin"); !errors.Is(err, gasstation.ErrQuarantineNotFound) {
+		t.Errorf("Reject of unknown price error: %v", err)
+	}
+}
+
+func TestAreaCheckWithStationHistory(t *testing.T) {
+	myApp := newTestApp(t)
+	if err := myApp.GasStationRepository.Save([]gsmodel.GasStation{
+		{ID: "00000000-0000-0000-0000-000000000001", StationName: "Test 1", PostCode: "20095", Latitude: 53.56, Longitude: 10.0},
+		{ID: "00000000-0000-0000-0000-000000000002", StationName: "Test 2", PostCode: "20095", Latitude: 53.55, Longitude: 10.01}}); err != nil {
+		t.Fatalf("Save gas stations failed: %v", err)
+	}
+	timestamp := time.Now().Add(-time.Hour)
+	// the history of the station is far above the prices of the area
+	gasPrices := []gsmodel.GasPrice{
+		{GasStationID: "00000000-0000-0000-0000-000000000001", E5: 1859, E10: 1799, Diesel: 1689, Date: timestamp},
+		{GasStationID: "00000000-0000-0000-0000-000000000002", E5: 1869, E10: 1809, Diesel: 1699, Date: timestamp},
+	}
+	for index := 1; index <= 3; index++ {
+		gasPrices = append(gasPrices, gsmodel.GasPrice{GasStationID: testStid, E5: 1859, E10: 1799, Diesel: 2500, Date: timestamp.Add(time.Duration(index) * time.Minute)})
+	}
+	if err := myApp.PriceRepository.Save(gasPrices); err != nil {
+		t.Fatalf("Save prices failed: %v", err)
+	}
+	report := storePrices(gasstation.GasStationPrices{GasStationID: testStid, E5: 1859, E10: 1799, Diesel: 2510, Timestamp: timestamp.Add(5 * time.Minute)})
+	if report.Applied != 0 || report.Quarantined != 1 {
+		t.Fatalf("Price above the area median not quarantined: %+v", report)
+	}
+	quarantinedPrices, err := gasstation.FindQuarantinedPrices("")
+	if err != nil || len(quarantinedPrices) != 1 || !strings.Contains(quarantinedPrices[0].Reason, "area median") ||
+		strings.Contains(quarantinedPrices[0].Reason, "station median") {
+		t.Errorf("Pending prices: %+v error: %v", quarantinedPrices, err)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"fmt"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"time"
+)
+
+// reference prices for the relative alert rules, cached for one price update batch
+type batchReferencePrices struct {
+	now          time.Time
+	areaMedians  map[string]int
+	trailingAvgs map[string]int
+}
+
+func newBatchReferencePrices() *batchReferencePrices {
+	return &batchReferencePrices{now: time.Now(), areaMedians: make(map[string]int), trailingAvgs: make(map[string]int)}
+}
+
+// median of the latest prices of the stations in the circle, 0 if unknown. The medians of all fuel types are cached with one query.
+func (referencePrices *batchReferencePrices) AreaMedian(latitude float64, longitude float64, radius float64, fuelType gsmodel.FuelType) int {
+	areaKey := fmt.Sprintf("%.4f|%.4f|%.1f", latitude, longitude, radius)
+	if median, found := referencePrices.areaMedians[areaKey+"|"+string(fuelType)]; found {
+		return median
+	}
+	gasStations := findStationsInCircle(latitude, longitude, radius, false)
+	stids := []string{}
+	for _, myGasStation := range gasStations {
+		stids = append(stids, myGasStation.ID)
+	}
+	stidToGasPrices := make(map[string][]gsmodel.GasPrice)
+	if len(stids) > 0 {
+		for _, myGasPrice := range findPricesByStidsAndPeriod(&stids, referencePrices.now.Add(time.Hour*-720)) {
+			stidToGasPrices[myGasPrice.GasStationID] = append(stidToGasPrices[myGasPrice.GasStationID], myGasPrice)
+		}
+	}
+	for _, myFuelType := range gsmodel.FuelTypes {
+		prices := []int{}
+		for _, myGasPrices := range stidToGasPrices {
+			if price := latestPrice(myGasPrices, myFuelType); price > 10 {
+				prices = append(prices, price)
+			}
+		}
+		referencePrices.areaMedians[areaKey+"|"+string(myFuelType)] = calcFuelStatistics(prices).Median
+	}
+	return referencePrices.areaMedians[areaKey+"|"+string(fuelType)]
+}
+
+// average of the prices of the station in the last days, 0 if unknown
+func (referencePrices *batchReferencePrices) TrailingAverage(stid string, fuelType gsmodel.FuelType, days int) int {
+	key := fmt.Sprintf("%v|%v|%v", stid, fuelType, days)
+	if average, found := referencePrices.trailingAvgs[key]; found {
+		return average
+	}
+	stids := []string{stid}
+	prices := []int{}
+	for _, myGasPrice := range findPricesByStidsAndPeriod(&stids, referencePrices.now.AddDate(0, 0, -days)) {
+		if price := myGasPrice.PriceOf(fuelType); price > 10 {
+			prices = append(prices, price)
+		}
+	}
+	average := calcFuelStatistics(prices).Avg
+	referencePrices.trailingAvgs[key] = average
+	return average
+}
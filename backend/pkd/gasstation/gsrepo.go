@@ -13,26 +13,37 @@
 package gasstation
 
 import (
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"math"
 	gsbody "react-and-go/pkd/controller/gsmodel"
-	"react-and-go/pkd/database"
 	"react-and-go/pkd/gasstation/gsmodel"
 	"react-and-go/pkd/notification"
+	unmodel "react-and-go/pkd/notification/model"
+	"react-and-go/pkd/pubsub"
+	"react-and-go/pkd/repository"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+)
 
-	"gorm.io/gorm"
+const (
+	defaultHistoryDays = 30
+	maxHistoryDays     = 3650
 )
 
-const earthRadius = 6371.0
+var gasStationRepository repository.GasStationRepository
+var priceRepository repository.PriceRepository
+var quarantineRepository repository.QuarantineRepository
 
-type minMaxSquare struct {
-	MinLat float64
-	MinLng float64
-	MaxLat float64
-	MaxLng float64
+func SetRepositories(myGasStationRepository repository.GasStationRepository, myPriceRepository repository.PriceRepository,
+	myQuarantineRepository repository.QuarantineRepository) {
+	gasStationRepository = myGasStationRepository
+	priceRepository = myPriceRepository
+	quarantineRepository = myQuarantineRepository
+	resetStationIndex()
 }
 
 type GasStationPrices struct {
@@ -57,43 +68,113 @@ type GasStationImport struct {
 	OpeningTimesJson string
 }
 
-func UpdateGasStations(gasStations *[]GasStationImport) {
+// the differences of a station import to the stations in the database
+type StationImportReport struct {
+	Source     string   `json:"source"`
+	Imported   int      `json:"imported"`
+	New        int      `json:"new"`
+	Changed    int      `json:"changed"`
+	Removed    int      `json:"removed"`
+	Unchanged  int      `json:"unchanged"`
+	NewIds     []string `json:"newIds"`
+	ChangedIds []string `json:"changedIds"`
+	RemovedIds []string `json:"removedIds"` // in the database but not in the import, they are reported and kept
+}
+
+func UpdateGasStations(gasStations *[]GasStationImport) (StationImportReport, error) {
+	report := StationImportReport{NewIds: []string{}, ChangedIds: []string{}, RemovedIds: []string{}}
 	gasStationImportMap := make(map[string]GasStationImport)
 	for _, value := range *gasStations {
 		gasStationImportMap[value.Uuid] = value
 	}
+	report.Imported = len(gasStationImportMap)
 	fmt.Printf("GasStations found: %v\n", len(gasStationImportMap))
-	gasStationsUpdated := 0
-	var values []gsmodel.GasStation
-	database.DB.Transaction(func(tx *gorm.DB) error {
-		tx.FindInBatches(&values, 1000, func(tx *gorm.DB, batch int) error {
-			var newResults []gsmodel.GasStation
-			for _, result := range values {
-				if strings.TrimSpace(result.OtJson) != strings.TrimSpace(gasStationImportMap[result.ID].OpeningTimesJson) {
-					result.OtJson = gasStationImportMap[result.ID].OpeningTimesJson
-					newResults = append(newResults, result)
-				}
-				delete(gasStationImportMap, result.ID)
+	indexUpdates := []gsmodel.GasStation{}
+	if err := gasStationRepository.FindInBatches(1000, func(values []gsmodel.GasStation) error {
+		var newResults []gsmodel.GasStation
+		for _, result := range values {
+			gasStationImport, found := gasStationImportMap[result.ID]
+			if !found {
+				report.RemovedIds = append(report.RemovedIds, result.ID)
+				continue
 			}
-			if len(newResults) > 0 {
-				tx.Save(&newResults)
+			delete(gasStationImportMap, result.ID)
+			if updateGasStation(&result, gasStationImport) {
+				newResults = append(newResults, result)
+				report.ChangedIds = append(report.ChangedIds, result.ID)
+			} else {
+				report.Unchanged++
 			}
-			gasStationsUpdated = gasStationsUpdated + len(newResults)
-			//tx.RowsAffected // number of records in this batch
-
-			//batch // Batch 1, 2, 3
-
-			// returns error will stop future batches
-			return nil
-		})
-		fmt.Printf("GasStations updated: %v\n", gasStationsUpdated)
-		for _, value := range gasStationImportMap {
-			resultGs := createNewGasStation(value)
-			tx.Save(&resultGs)
 		}
-		fmt.Printf("GasStations new: %v\n", len(gasStationImportMap))
+		if err := gasStationRepository.Save(newResults); err != nil {
+			return err
+		}
+		indexUpdates = append(indexUpdates, newResults...)
 		return nil
-	})
+	}); err != nil {
+		log.Printf("GasStations update failed: %v\n", err)
+		return report, err
+	}
+	fmt.Printf("GasStations updated: %v\n", len(report.ChangedIds))
+	newGasStations := []gsmodel.GasStation{}
+	for _, value := range gasStationImportMap {
+		newGasStations = append(newGasStations, createNewGasStation(value))
+		report.NewIds = append(report.NewIds, value.Uuid)
+	}
+	if err := gasStationRepository.Save(newGasStations); err != nil {
+		log.Printf("GasStations create failed: %v\n", err)
+		return report, err
+	}
+	fmt.Printf("GasStations new: %v removed: %v\n", len(newGasStations), len(report.RemovedIds))
+	updateStationIndex(append(indexUpdates, newGasStations...))
+	sort.Strings(report.NewIds)
+	sort.Strings(report.ChangedIds)
+	sort.Strings(report.RemovedIds)
+	report.New = len(report.NewIds)
+	report.Changed = len(report.ChangedIds)
+	report.Removed = len(report.RemovedIds)
+	return report, nil
+}
+
+// copies the changed master data of the import, true if a field has changed
+func updateGasStation(gasStation *gsmodel.GasStation, value GasStationImport) bool {
+	changed := false
+	updateField := func(field *string, importValue string) {
+		if strings.TrimSpace(*field) != strings.TrimSpace(importValue) {
+			*field = importValue
+			changed = true
+		}
+	}
+	updateField(&gasStation.StationName, value.StationName)
+	updateField(&gasStation.Brand, value.Brand)
+	updateField(&gasStation.Street, value.Street)
+	updateField(&gasStation.HouseNumber, value.HouseNumber)
+	updateField(&gasStation.PostCode, value.PostCode)
+	updateField(&gasStation.PublicHolidayIdentifier, gsmodel.StateOfPostCode(value.PostCode))
+	updateField(&gasStation.Place, value.City)
+	if strings.TrimSpace(gasStation.OtJson) != strings.TrimSpace(value.OpeningTimesJson) {
+		gasStation.OtJson = value.OpeningTimesJson
+		gasStation.OpenTs = gsmodel.CalcOpenTs(gasStation.OtJson)
+		changed = true
+	}
+	//the csv has 6 decimals
+	if math.Abs(gasStation.Latitude-value.Latitude) > 0.0000005 || math.Abs(gasStation.Longitude-value.Longitude) > 0.0000005 {
+		gasStation.Latitude = value.Latitude
+		gasStation.Longitude = value.Longitude
+		changed = true
+	}
+	if !value.FirstActive.IsZero() && !gasStation.FirstActive.Equal(value.FirstActive) {
+		gasStation.FirstActive = value.FirstActive
+		changed = true
+	}
+	if changed {
+		gasStation.StationInImport = time.Now()
+		gasStation.VersionTime = time.Now()
+		if version, err := strconv.Atoi(gasStation.Version); err == nil {
+			gasStation.Version = strconv.Itoa(version + 1)
+		}
+	}
+	return changed
 }
 
 func createNewGasStation(value GasStationImport) gsmodel.GasStation {
@@ -104,13 +185,13 @@ func createNewGasStation(value GasStationImport) gsmodel.GasStation {
 	resultGs.HouseNumber = value.HouseNumber
 	resultGs.Latitude = value.Latitude
 	resultGs.Longitude = value.Longitude
-	resultGs.OpenTs = 0
 	resultGs.OtJson = value.OpeningTimesJson
+	resultGs.OpenTs = gsmodel.CalcOpenTs(value.OpeningTimesJson)
 	resultGs.Place = value.City
 	resultGs.PostCode = value.PostCode
 	resultGs.PriceChanged = time.Now()
 	resultGs.PriceInImport = time.Now()
-	//resultGs.PublicHolidayIdentifier = ""
+	resultGs.PublicHolidayIdentifier = gsmodel.StateOfPostCode(value.PostCode)
 	resultGs.StationInImport = time.Now()
 	resultGs.StationName = value.StationName
 	resultGs.Street = value.Street
@@ -119,43 +200,101 @@ func createNewGasStation(value GasStationImport) gsmodel.GasStation {
 	return resultGs
 }
 
-func UpdatePrice(gasStationPrices *[]GasStationPrices) {
+// the counts of one price update batch, every received update is counted once
+type PriceUpdateReport struct {
+	Received    int
+	Applied     int
+	Duplicates  int // the stid and source timestamp are known
+	Stale       int // older than the latest price of the station
+	Unchanged   int
+	Invalid     int
+	Unknown     int // stations that are not imported
+	Quarantined int
+	Confirmed   int // quarantined prices that a follow-up update confirmed
+}
+
+func UpdatePrice(gasStationPrices *[]GasStationPrices) PriceUpdateReport {
+	report, _ := StorePrices(gasStationPrices)
+	notification.WakeOutboxDispatcher()
+	return report
+}
+
+// negative prices and updates older than 720 hours are invalid
+func ValidPrices(value GasStationPrices) bool {
+	return len(strings.TrimSpace(value.GasStationID)) > 0 && !value.Timestamp.Before(time.Now().Add(time.Hour*-720)) &&
+		value.Diesel >= 0 && value.E10 >= 0 && value.E5 >= 0
+}
+
+// stores the prices with the outbox item for the alerts of the new prices in one transaction
+func StorePrices(gasStationPrices *[]GasStationPrices) (PriceUpdateReport, map[string]gsmodel.GasPrice) {
+	report := PriceUpdateReport{Received: len(*gasStationPrices)}
 	stationPricesMap := make(map[string]GasStationPrices)
 	var stationPricesKeys []string
 	for _, value := range *gasStationPrices {
+		// the database stores microseconds
+		value.Timestamp = value.Timestamp.Truncate(time.Microsecond)
+		if !ValidPrices(value) {
+			report.Invalid++
+			continue
+		}
+		// only the newest update of a station in the batch is applied
+		if myStationPrices, found := stationPricesMap[value.GasStationID]; found {
+			if value.Timestamp.Equal(myStationPrices.Timestamp) {
+				report.Duplicates++
+				continue
+			}
+			report.Stale++
+			if value.Timestamp.Before(myStationPrices.Timestamp) {
+				continue
+			}
+		} else {
+			stationPricesKeys = append(stationPricesKeys, value.GasStationID)
+		}
 		stationPricesMap[value.GasStationID] = value
-		stationPricesKeys = append(stationPricesKeys, value.GasStationID)
 	}
 	gasPriceUpdateMap := make(map[string]gsmodel.GasPrice)
 	stationPricesDb := FindPricesByStids(&stationPricesKeys)
 	log.Printf("StationPricesKeys: %v StationPricesDb: %v", len(stationPricesKeys), len(stationPricesDb))
+	latestPricesDb := make(map[string]gsmodel.GasPrice)
 	for _, value := range stationPricesDb {
-		if _, found := gasPriceUpdateMap[value.GasStationID]; !found {
-			var myChanges = 0
-			if stationPricesMap[value.GasStationID].Diesel != value.Diesel {
-				myChanges = myChanges + 1
-			}
-			if stationPricesMap[value.GasStationID].E10 != value.E10 {
-				myChanges = myChanges + 16
-			}
-			if stationPricesMap[value.GasStationID].E5 != value.E5 {
-				myChanges = myChanges + 4
-			}
-			// validation checks
-			if stationPricesMap[value.GasStationID].Timestamp.Before(time.Now().Add(time.Hour*-720)) || stationPricesMap[value.GasStationID].Diesel < 0 ||
-				stationPricesMap[value.GasStationID].E10 < 0 || stationPricesMap[value.GasStationID].E5 < 0 {
-				myChanges = 0
-			}
-			//log.Printf("GasStation: %v Changes: %v", value.GasStationID, myChanges)
-			if myChanges > 0 {
-				gasPriceUpdateMap[value.GasStationID] = gsmodel.GasPrice{GasStationID: value.GasStationID, E5: stationPricesMap[value.GasStationID].E5, E10: stationPricesMap[value.GasStationID].E10,
-					Diesel: stationPricesMap[value.GasStationID].Diesel, Date: stationPricesMap[value.GasStationID].Timestamp, Changed: myChanges}
-				//value, _ := json.Marshal(gasPriceUpdateMap[value.GasStationID])
-				//fmt.Printf("Update: %v\n", string(value))
-			}
+		if _, found := latestPricesDb[value.GasStationID]; !found {
+			latestPricesDb[value.GasStationID] = value
+		}
+		if stationPrices, found := stationPricesMap[value.GasStationID]; found && stationPrices.Timestamp.Equal(value.Date) {
+			report.Duplicates++
 			delete(stationPricesMap, value.GasStationID)
 		}
 	}
+	// the updates that are neither duplicate nor stale
+	currentStationPrices := []GasStationPrices{}
+	for stid, value := range latestPricesDb {
+		stationPrices, found := stationPricesMap[stid]
+		if !found {
+			continue
+		}
+		delete(stationPricesMap, stid)
+		if stationPrices.Timestamp.Before(value.Date) {
+			report.Stale++
+			continue
+		}
+		currentStationPrices = append(currentStationPrices, stationPrices)
+		var myChanges = 0
+		if stationPrices.Diesel != value.Diesel {
+			myChanges = myChanges + 1
+		}
+		if stationPrices.E10 != value.E10 {
+			myChanges = myChanges + 16
+		}
+		if stationPrices.E5 != value.E5 {
+			myChanges = myChanges + 4
+		}
+		if myChanges == 0 {
+			report.Unchanged++
+			continue
+		}
+		gasPriceUpdateMap[stid] = gsmodel.GasPrice{GasStationID: stid, E5: stationPrices.E5, E10: stationPrices.E10,
+			Diesel: stationPrices.Diesel, Date: stationPrices.Timestamp, Changed: myChanges}
+	}
 	if len(stationPricesMap) > 0 {
 		var stationIds []string
 		for _, stationPrice := range stationPricesMap {
@@ -164,175 +303,179 @@ func UpdatePrice(gasStationPrices *[]GasStationPrices) {
 		myGasStations := findByIds(&stationIds)
 		for _, gasStation := range myGasStations {
 			value := stationPricesMap[gasStation.ID]
-			gasPriceUpdateMap[value.GasStationID] = gsmodel.GasPrice{GasStationID: value.GasStationID, E5: stationPricesMap[value.GasStationID].E5, E10: stationPricesMap[value.GasStationID].E10,
-				Diesel: stationPricesMap[value.GasStationID].Diesel, Date: stationPricesMap[value.GasStationID].Timestamp, Changed: 21}
+			gasPriceUpdateMap[value.GasStationID] = gsmodel.GasPrice{GasStationID: value.GasStationID, E5: value.E5, E10: value.E10,
+				Diesel: value.Diesel, Date: value.Timestamp, Changed: 21}
+			currentStationPrices = append(currentStationPrices, value)
 			log.Printf("GasStation with first price: %v\n", gasStation.ID)
 			delete(stationPricesMap, value.GasStationID)
 		}
 		//create new gas stations
 		if len(stationPricesMap) > 0 {
+			report.Unknown = len(stationPricesMap)
 			log.Default().Printf("New GasStations: %v\n", len(stationPricesMap))
 		}
 	}
-	database.DB.Transaction(func(tx *gorm.DB) error {
-		for _, value := range gasPriceUpdateMap {
-			tx.Save(&value)
+	//implausible prices are kept out of the history and the notifications
+	confirmedPrices := quarantineAnomalies(currentStationPrices, gasPriceUpdateMap, stationPricesDb, &report)
+	gasPriceUpdates := []gsmodel.GasPrice{}
+	for _, value := range gasPriceUpdateMap {
+		gasPriceUpdates = append(gasPriceUpdates, value)
+	}
+	// a concurrent update can have stored the same price
+	isUpdate := func(gasPrice gsmodel.GasPrice) bool {
+		myGasPrice, found := gasPriceUpdateMap[gasPrice.GasStationID]
+		return found && myGasPrice.Date.Equal(gasPrice.Date)
+	}
+	createdPrices, err := priceRepository.CreateNew(append(confirmedPrices, gasPriceUpdates...), func(createdPrices []gsmodel.GasPrice) ([]unmodel.OutboxItem, error) {
+		alertPrices := []gsmodel.GasPrice{}
+		for _, value := range createdPrices {
+			if isUpdate(value) {
+				alertPrices = append(alertPrices, value)
+			}
 		}
-		return nil
+		return newEvaluateAlertsItems(alertPrices)
 	})
-	log.Printf("Prices updated: %v\n", len(gasPriceUpdateMap))
-	go sendNotifications(&gasPriceUpdateMap)
+	if err != nil {
+		log.Printf("Prices update failed: %v\n", err)
+	}
+	createdPriceMap := make(map[string]gsmodel.GasPrice)
+	for _, value := range createdPrices {
+		if isUpdate(value) {
+			createdPriceMap[value.GasStationID] = value
+		} else {
+			report.Confirmed++
+		}
+	}
+	if err == nil {
+		report.Duplicates += len(gasPriceUpdateMap) - len(createdPriceMap)
+	}
+	report.Applied = len(createdPriceMap)
+	log.Printf("Prices updated: %+v\n", report)
+	return report, createdPriceMap
 }
 
-func sendNotifications(gasStationIDToGasPriceMap *map[string]gsmodel.GasPrice) {
+func sendNotifications(gasStationIDToGasPriceMap *map[string]gsmodel.GasPrice) error {
 	var gasStationIds []string
 	for key, _ := range *gasStationIDToGasPriceMap {
 		gasStationIds = append(gasStationIds, key)
 	}
 	gasStations := findByIds(&gasStationIds)
-	notification.SendNotifications(gasStationIDToGasPriceMap, gasStations)
+	publishPriceEvents(gasStationIDToGasPriceMap, gasStations)
+	return notification.SendNotifications(gasStationIDToGasPriceMap, gasStations, newBatchReferencePrices())
 }
 
-func createChunks(ids *[]string) [][]string {
-	cunckedSelects := strings.ToLower(strings.TrimSpace(os.Getenv("DB_CHUNKED_SELECTS")))
-	chunkSize := 10000
-	if cunckedSelects == "true" {
-		chunkSize = 999
-	}
-	chuncks := chunkSlice(*ids, chunkSize)
-	if len(chuncks) > 1 {
-		log.Printf("Number of Chunks: %v\n", len(chuncks))
+func publishPriceEvents(gasStationIDToGasPriceMap *map[string]gsmodel.GasPrice, gasStations []gsmodel.GasStation) {
+	for _, gasStation := range gasStations {
+		myGasPrice := (*gasStationIDToGasPriceMap)[gasStation.ID]
+		pubsub.Publish(pubsub.Event{Type: pubsub.PriceEventType, Price: &pubsub.PriceEvent{GasStationID: gasStation.ID, StationName: gasStation.StationName,
+			Brand: gasStation.Brand, Place: gasStation.Place, Latitude: gasStation.Latitude, Longitude: gasStation.Longitude, E5: myGasPrice.E5,
+			E10: myGasPrice.E10, Diesel: myGasPrice.Diesel, Date: myGasPrice.Date, Changed: myGasPrice.Changed}})
 	}
-	return chuncks
 }
 
 func findByIds(ids *[]string) []gsmodel.GasStation {
-	var result []gsmodel.GasStation
-	chuncks := createChunks(ids)
-	database.DB.Transaction(func(tx *gorm.DB) error {
-		for _, chunk := range chuncks {
-			var values []gsmodel.GasStation
-			tx.Where("id in ?", chunk).Find(&values)
-			result = append(result, values...)
-		}
-		return nil
-	})
-	return result
+	return gasStationRepository.FindByIds(*ids)
 }
 
 func FindById(id string) gsmodel.GasStation {
-	var myGasStation gsmodel.GasStation
-	database.DB.Where("id = ?", id).Preload("GasPrices", func(db *gorm.DB) *gorm.DB {
-		return db.Order("date DESC").Limit(20)
-	}).First(&myGasStation)
-	return myGasStation
+	return gasStationRepository.FindById(id, 20)
 }
 
+// the prices since the start of the day one month ago
 func FindPricesByStids(stids *[]string) []gsmodel.GasPrice {
-	var myGasPrice []gsmodel.GasPrice
 	oneMonthAgo := time.Now().Add(time.Hour * -720)
-	dateStr := fmt.Sprintf("%04d-%02d-%02d", oneMonthAgo.Year(), oneMonthAgo.Month(), oneMonthAgo.Day())
-	//log.Printf("Cut off date: %v", dateStr)
-	chuncks := createChunks(stids)
-	database.DB.Transaction(func(tx *gorm.DB) error {
-		for _, chunk := range chuncks {
-			var values []gsmodel.GasPrice
-			//log.Printf("Chunk: %v\n", chunk)
-			tx.Where("stid IN ? and date >= date(?) ", chunk, dateStr).Order("date desc").Find(&values)
-			myGasPrice = append(myGasPrice, values...)
-		}
-		return nil
-	})
-	return myGasPrice
+	return priceRepository.FindByStidsSince(*stids, time.Date(oneMonthAgo.Year(), oneMonthAgo.Month(), oneMonthAgo.Day(), 0, 0, 0, 0, oneMonthAgo.Location()))
 }
 
-func FindPricesByStid(stid string) []gsmodel.GasPrice {
-	var myGasPrice []gsmodel.GasPrice
-	database.DB.Where("stid = ?", stid).Order("date desc").Find(&myGasPrice)
-	return myGasPrice
+// reads the aggregates for the periods before the raw retention window
+func findPricesByStidsAndPeriod(stids *[]string, start time.Time) []gsmodel.GasPrice {
+	return findPriceHistory(*stids, start)
+}
+
+// the price history of the last days, newest first
+func FindPricesByStid(stid string, days int) []gsmodel.GasPrice {
+	if days <= 0 {
+		days = defaultHistoryDays
+	}
+	if days > maxHistoryDays {
+		days = maxHistoryDays
+	}
+	return findPriceHistory([]string{stid}, time.Now().AddDate(0, 0, -days))
 }
 
 func FindBySearchPlace(searchPlace gsbody.SearchPlaceBody) []gsmodel.GasStation {
-	var gasStations []gsmodel.GasStation
-	var query = database.DB
+	place := ""
 	if len(strings.TrimSpace(searchPlace.Place)) >= 2 {
-		query = query.Where("name LIKE ?", "%"+strings.TrimSpace(searchPlace.Place)+"%")
+		place = strings.TrimSpace(searchPlace.Place)
 	}
+	postCode := ""
 	if len(strings.TrimSpace(searchPlace.PostCode)) >= 4 {
-		query = query.Where("post_code LIKE ?", "%"+strings.TrimSpace(searchPlace.PostCode)+"%")
+		postCode = strings.TrimSpace(searchPlace.PostCode)
 	}
+	stationName := ""
 	if len(strings.TrimSpace(searchPlace.StationName)) >= 2 {
-		query = query.Where("name LIKE ?", "%"+strings.TrimSpace(searchPlace.StationName)+"%")
+		stationName = strings.TrimSpace(searchPlace.StationName)
 	}
-	query.Preload("GasPrices", func(db *gorm.DB) *gorm.DB {
-		return db.Order("date DESC").Limit(50)
-	}).Find(&gasStations)
-	return gasStations
+	gasStations := gasStationRepository.FindBySearchPlace(place, postCode, stationName, 50)
+	return filterByOpeningState(gasStations, searchPlace.OpenAt, searchPlace.OnlyOpen)
 }
 
-func FindBySearchLocation(searchLocation gsbody.SearchLocation) []gsmodel.GasStation {
+func FindBySearchLocation(searchLocation gsbody.SearchLocation) ([]gsmodel.GasStation, error) {
+	if !validCoordinates(searchLocation.Latitude, searchLocation.Longitude) {
+		return []gsmodel.GasStation{}, errors.New("invalid coordinates")
+	}
+	if err := checkSearchRadius(searchLocation.Radius); err != nil {
+		return []gsmodel.GasStation{}, err
+	}
+	filteredGasStations := findStationsInCircle(searchLocation.Latitude, searchLocation.Longitude, searchLocation.Radius, true)
+	filteredGasStations = filterByOpeningState(filteredGasStations, searchLocation.OpenAt, searchLocation.OnlyOpen)
+	if searchLocation.WithPrediction {
+		filteredGasStations = addPricePredictions(filteredGasStations, searchLocation.FuelType)
+	}
+	return filteredGasStations, nil
+}
+
+// stations in the circle sorted by distance
+func findStationsInCircle(latitude float64, longitude float64, radius float64, withPrices bool) []gsmodel.GasStation {
+	//add 0.1 for floating point side effects
+	hits := loadedStationIndex().InRadius(latitude, longitude, radius+0.1)
+	if len(hits) == 0 {
+		return []gsmodel.GasStation{}
+	}
+	stids := []string{}
+	for _, hit := range hits {
+		stids = append(stids, hit.ID)
+	}
 	var gasStations []gsmodel.GasStation
-	minMax := minMaxSquare{MinLat: 1000.0, MinLng: 1000.0, MaxLat: 0.0, MaxLng: 0.0}
-	//fmt.Printf("StartLat: %v, StartLng: %v Radius: %v\n", searchLocation.Latitude, searchLocation.Longitude, searchLocation.Radius)
-	//max supported radius 20km and add 0.1 for floation point side effects
-	myRadius := searchLocation.Radius + 0.1
-	if myRadius > 20.0 {
-		myRadius = 20.1
-	}
-	northLat, northLng := gsmodel.CalcLocation(searchLocation.Latitude, searchLocation.Longitude, myRadius, 0.0)
-	minMax = updateMinMaxSquare(northLat, northLng, minMax)
-	//fmt.Printf("NorthLat: %v, NorthLng: %v\n", northLat, northLng)
-	eastLat, eastLng := gsmodel.CalcLocation(searchLocation.Latitude, searchLocation.Longitude, myRadius, 90.0)
-	minMax = updateMinMaxSquare(eastLat, eastLng, minMax)
-	//fmt.Printf("EastLat: %v, EastLng: %v\n", eastLat, eastLng)
-	southLat, southLng := gsmodel.CalcLocation(searchLocation.Latitude, searchLocation.Longitude, myRadius, 180.0)
-	minMax = updateMinMaxSquare(southLat, southLng, minMax)
-	//fmt.Printf("SouthLat: %v, SouthLng: %v\n", southLat, southLng)
-	westLat, westLng := gsmodel.CalcLocation(searchLocation.Latitude, searchLocation.Longitude, myRadius, 270.0)
-	minMax = updateMinMaxSquare(westLat, westLng, minMax)
-	//fmt.Printf("WestLat: %v, WestLng: %v\n", westLat, westLng)
-	//fmt.Printf("MinLat: %v, MinLng: %v, MaxLat: %v, MaxLng: %v\n", minMax.MinLat, minMax.MinLng, minMax.MaxLat, minMax.MaxLng)
-	database.DB.Where("lat >= ? and lat <= ? and lng >= ? and lng <= ?", minMax.MinLat, minMax.MaxLat, minMax.MinLng, minMax.MaxLng).Preload("GasPrices", func(db *gorm.DB) *gorm.DB {
-		return db.Order("date DESC").Limit(50)
-	}).Find(&gasStations)
-	//filter for stations in circle
-	filteredGasStations := []gsmodel.GasStation{}
+	if withPrices {
+		gasStations = gasStationRepository.FindByIdsWithPrices(stids, 50)
+	} else {
+		gasStations = gasStationRepository.FindByIds(stids)
+	}
+	idToGasStation := make(map[string]gsmodel.GasStation)
 	for _, myGasStation := range gasStations {
-		distance, bearing := myGasStation.CalcDistanceBearing(searchLocation.Latitude, searchLocation.Longitude)
-		//fmt.Printf("Distance: %v, Bearing: %v\n", distance, bearing)
-		if distance < myRadius && bearing > -1.0 {
+		idToGasStation[myGasStation.ID] = myGasStation
+	}
+	filteredGasStations := []gsmodel.GasStation{}
+	for _, hit := range hits {
+		if myGasStation, found := idToGasStation[hit.ID]; found {
 			filteredGasStations = append(filteredGasStations, myGasStation)
 		}
 	}
 	return filteredGasStations
 }
 
-func chunkSlice[T any](mySlice []T, chunkSize int) (s [][]T) {
-	numberOfChunks := len(mySlice)/chunkSize + 1
-	var result [][]T
-	for i := 0; i < numberOfChunks; i++ {
-
-		min := (i * len(mySlice) / numberOfChunks)
-		max := ((i + 1) * len(mySlice)) / numberOfChunks
-
-		result = append(result, mySlice[min:max])
-
-	}
-	return result
-}
-
-func updateMinMaxSquare(newLat float64, newLng float64, minMax minMaxSquare) minMaxSquare {
-	if newLat > minMax.MaxLat {
-		minMax.MaxLat = newLat
+func filterByOpeningState(gasStations []gsmodel.GasStation, openAt time.Time, onlyOpen bool) []gsmodel.GasStation {
+	if openAt.IsZero() {
+		openAt = time.Now()
 	}
-	if newLat < minMax.MinLat {
-		minMax.MinLat = newLat
-	}
-	if newLng > minMax.MaxLng {
-		minMax.MaxLng = newLng
-	}
-	if newLng < minMax.MinLng {
-		minMax.MinLng = newLng
+	result := []gsmodel.GasStation{}
+	for _, myGasStation := range gasStations {
+		myGasStation.OpeningState = myGasStation.CalcOpeningState(openAt)
+		if onlyOpen && myGasStation.OpeningState == gsmodel.Closed {
+			continue
+		}
+		result = append(result, myGasStation)
 	}
-	return minMax
+	return result
 }
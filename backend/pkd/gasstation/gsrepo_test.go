@@ -0,0 +1,71 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation_test
+
+import (
+	"react-and-go/pkd/gasstation"
+	unmodel "react-and-go/pkd/notification/model"
+	"testing"
+	"time"
+)
+
+func TestStorePricesSkipsDuplicates(t *testing.T) {
+	myApp := newTestApp(t)
+	timestamp := time.Now().Add(-time.Hour)
+	gasStationPrices := gasstation.GasStationPrices{GasStationID: testStid, E5: 1859, E10: 1799, Diesel: 1689, Timestamp: timestamp}
+	if report := storePrices(gasStationPrices); report.Applied != 1 {
+		t.Fatalf("First price not applied: %+v", report)
+	}
+	// a redelivered message and an update twice in one batch
+	if report := storePrices(gasStationPrices); report.Applied != 0 || report.Duplicates != 1 {
+		t.Errorf("Redelivered price not skipped: %+v", report)
+	}
+	newPrices := gasstation.GasStationPrices{GasStationID: testStid, E5: 1849, E10: 1789, Diesel: 1679, Timestamp: timestamp.Add(time.Minute)}
+	if report := storePrices(newPrices, newPrices); report.Applied != 1 || report.Duplicates != 1 {
+		t.Errorf("Duplicate in batch not skipped: %+v", report)
+	}
+	if gasPrices := myApp.PriceRepository.FindByStid(testStid); len(gasPrices) != 2 {
+		t.Errorf("Stored prices: %v want: 2", len(gasPrices))
+	}
+	// one outbox item per applied batch
+	if pending := myApp.OutboxRepository.CountByStatus()[unmodel.OutboxPending]; pending != 2 {
+		t.Errorf("Outbox items: %v want: 2", pending)
+	}
+}
+
+func TestStorePricesSkipsStaleUpdates(t *testing.T) {
+	myApp := newTestApp(t)
+	timestamp := time.Now().Add(-time.Hour)
+	if report := storePrices(gasstation.GasStationPrices{GasStationID: testStid, E5: 1859, E10: 1799, Diesel: 1689, Timestamp: timestamp}); report.Applied != 1 {
+		t.Fatalf("First price not applied: %+v", report)
+	}
+	// out of order, an older update after a newer one
+	if report := storePrices(gasstation.GasStationPrices{GasStationID: testStid, E5: 1869, E10: 1809, Diesel: 1699,
+		Timestamp: timestamp.Add(-time.Minute)}); report.Applied != 0 || report.Stale != 1 {
+		t.Errorf("Stale price not skipped: %+v", report)
+	}
+	// the older update of a batch is stale
+	report := storePrices(gasstation.GasStationPrices{GasStationID: testStid, E5: 1839, E10: 1779, Diesel: 1669, Timestamp: timestamp.Add(2 * time.Minute)},
+		gasstation.GasStationPrices{GasStationID: testStid, E5: 1849, E10: 1789, Diesel: 1679, Timestamp: timestamp.Add(time.Minute)})
+	if report.Applied != 1 || report.Stale != 1 {
+		t.Errorf("Stale price in batch not skipped: %+v", report)
+	}
+	gasPrices := myApp.PriceRepository.FindByStid(testStid)
+	if len(gasPrices) != 2 || gasPrices[0].E5 != 1839 {
+		t.Errorf("Stored prices: %+v", gasPrices)
+	}
+	if report := storePrices(gasstation.GasStationPrices{GasStationID: testStid, E5: 1839, E10: 1779, Diesel: 1669,
+		Timestamp: timestamp.Add(3 * time.Minute)}); report.Applied != 0 || report.Unchanged != 1 {
+		t.Errorf("Unchanged price stored: %+v", report)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"log"
+	"react-and-go/pkd/config"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRawPriceDays    = 90
+	minRawPriceDays        = 31 // UpdatePrice compares with the raw prices of the last month
+	defaultHourlyPriceDays = 365
+)
+
+type PriceRetentionReport struct {
+	Days                int
+	RawPrices           int
+	HourlyPrices        int
+	DailyPrices         int
+	DeletedHourlyPrices int64
+}
+
+// PRICE_RAW_DAYS
+func rawPriceDays() int {
+	days := config.ReadIntEnv("PRICE_RAW_DAYS", defaultRawPriceDays)
+	if days < minRawPriceDays {
+		days = minRawPriceDays
+	}
+	return days
+}
+
+// PRICE_HOURLY_DAYS, the daily aggregates are kept
+func hourlyPriceDays() int {
+	days := config.ReadIntEnv("PRICE_HOURLY_DAYS", defaultHourlyPriceDays)
+	if days < rawPriceDays() {
+		days = rawPriceDays()
+	}
+	return days
+}
+
+// the start of the day in StationLocation the days before now
+func retentionCutoff(days int, now time.Time) time.Time {
+	return startOfDay(now).AddDate(0, 0, -days)
+}
+
+func startOfDay(date time.Time) time.Time {
+	localDate := date.In(gsmodel.StationLocation)
+	return time.Date(localDate.Year(), localDate.Month(), localDate.Day(), 0, 0, 0, 0, gsmodel.StationLocation)
+}
+
+// rolls the raw prices before the raw retention window day by day into hourly and daily aggregates and deletes them,
+// the hourly aggregates before the hourly retention window are deleted
+func ApplyPriceRetention() (PriceRetentionReport, error) {
+	now := time.Now()
+	report := PriceRetentionReport{}
+	rawCutoff := retentionCutoff(rawPriceDays(), now)
+	if oldestDate := priceRepository.FindOldestDate(); !oldestDate.IsZero() {
+		for dayStart := startOfDay(oldestDate); dayStart.Before(rawCutoff); dayStart = dayStart.AddDate(0, 0, 1) {
+			dayEnd := dayStart.AddDate(0, 0, 1)
+			gasPrices := priceRepository.FindByPeriod(dayStart, dayEnd)
+			if len(gasPrices) == 0 {
+				continue
+			}
+			existingHourlyPrices, existingDailyPrices := priceRepository.FindAggregatesByPeriod(dayStart, dayEnd)
+			hourlyPrices, dailyPrices := aggregatePrices(gasPrices, dayStart, existingHourlyPrices, existingDailyPrices)
+			if err := priceRepository.ReplaceWithAggregates(hourlyPrices, dailyPrices, dayStart, dayEnd); err != nil {
+				return report, err
+			}
+			report.Days++
+			report.RawPrices = report.RawPrices + len(gasPrices)
+			report.HourlyPrices = report.HourlyPrices + len(hourlyPrices)
+			report.DailyPrices = report.DailyPrices + len(dailyPrices)
+		}
+	}
+	deletedHourlyPrices, err := priceRepository.DeleteHourlyPricesBefore(retentionCutoff(hourlyPriceDays(), now))
+	report.DeletedHourlyPrices = deletedHourlyPrices
+	log.Printf("Price retention days: %v raw prices: %v hourly prices: %v daily prices: %v deleted hourly prices: %v\n", report.Days, report.RawPrices,
+		report.HourlyPrices, report.DailyPrices, report.DeletedHourlyPrices)
+	return report, err
+}
+
+// the aggregates of the raw prices of the day, existing aggregates of the day are merged as older prices
+func aggregatePrices(gasPrices []gsmodel.GasPrice, dayStart time.Time, existingHourlyPrices []gsmodel.HourlyPrice, existingDailyPrices []gsmodel.DailyPrice) ([]gsmodel.HourlyPrice, []gsmodel.DailyPrice) {
+	sortedGasPrices := append([]gsmodel.GasPrice{}, gasPrices...)
+	sort.SliceStable(sortedGasPrices, func(i, j int) bool {
+		return sortedGasPrices[i].Date.Before(sortedGasPrices[j].Date)
+	})
+	keyToHourlyPrice := make(map[string]*gsmodel.HourlyPrice)
+	stidToDailyPrice := make(map[string]*gsmodel.DailyPrice)
+	for _, myGasPrice := range sortedGasPrices {
+		hourStart := myGasPrice.Date.Truncate(time.Hour)
+		hourKey := aggregateKey(myGasPrice.GasStationID, hourStart)
+		if _, found := keyToHourlyPrice[hourKey]; !found {
+			keyToHourlyPrice[hourKey] = &gsmodel.HourlyPrice{PriceAggregate: gsmodel.PriceAggregate{GasStationID: myGasPrice.GasStationID, Start: hourStart}}
+		}
+		keyToHourlyPrice[hourKey].Add(myGasPrice)
+		if _, found := stidToDailyPrice[myGasPrice.GasStationID]; !found {
+			stidToDailyPrice[myGasPrice.GasStationID] = &gsmodel.DailyPrice{PriceAggregate: gsmodel.PriceAggregate{GasStationID: myGasPrice.GasStationID, Start: dayStart}}
+		}
+		stidToDailyPrice[myGasPrice.GasStationID].Add(myGasPrice)
+	}
+	hourlyPrices := []gsmodel.HourlyPrice{}
+	for _, existingHourlyPrice := range existingHourlyPrices {
+		if myHourlyPrice, found := keyToHourlyPrice[aggregateKey(existingHourlyPrice.GasStationID, existingHourlyPrice.Start)]; found {
+			myHourlyPrice.Merge(existingHourlyPrice.PriceAggregate, false)
+		} else {
+			hourlyPrices = append(hourlyPrices, existingHourlyPrice)
+		}
+	}
+	for _, myHourlyPrice := range keyToHourlyPrice {
+		hourlyPrices = append(hourlyPrices, *myHourlyPrice)
+	}
+	dailyPrices := []gsmodel.DailyPrice{}
+	for _, existingDailyPrice := range existingDailyPrices {
+		if myDailyPrice, found := stidToDailyPrice[existingDailyPrice.GasStationID]; found {
+			myDailyPrice.Merge(existingDailyPrice.PriceAggregate, false)
+		} else {
+			dailyPrices = append(dailyPrices, existingDailyPrice)
+		}
+	}
+	for _, myDailyPrice := range stidToDailyPrice {
+		dailyPrices = append(dailyPrices, *myDailyPrice)
+	}
+	return hourlyPrices, dailyPrices
+}
+
+// the raw prices since start and before the raw retention window the averages of the hourly or daily aggregates, newest first
+func findPriceHistory(stids []string, start time.Time) []gsmodel.GasPrice {
+	result := priceRepository.FindByStidsSince(stids, start)
+	now := time.Now()
+	rawCutoff := retentionCutoff(rawPriceDays(), now)
+	if !start.Before(rawCutoff) {
+		return result
+	}
+	hourlyCutoff := retentionCutoff(hourlyPriceDays(), now)
+	hourlyStart := start
+	if hourlyStart.Before(hourlyCutoff) {
+		hourlyStart = hourlyCutoff
+		for _, dailyPrice := range priceRepository.FindDailyPrices(stids, start, hourlyCutoff) {
+			result = append(result, dailyPrice.ToGasPrice())
+		}
+	}
+	for _, hourlyPrice := range priceRepository.FindHourlyPrices(stids, hourlyStart, rawCutoff) {
+		result = append(result, hourlyPrice.ToGasPrice())
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Date.After(result[j].Date)
+	})
+	return result
+}
+
+func aggregateKey(stid string, start time.Time) string {
+	return stid + "|" + strconv.FormatInt(start.Unix(), 10)
+}
@@ -0,0 +1,124 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"react-and-go/pkd/gasstation/gsmodel"
+	"testing"
+	"time"
+)
+
+const retentionStid = "51d4b55e-a095-1aa0-e100-80009459e03a"
+
+func TestRetentionDays(t *testing.T) {
+	testCases := []struct {
+		rawDays    string
+		hourlyDays string
+		raw        int
+		hourly     int
+	}{
+		{"", "", defaultRawPriceDays, defaultHourlyPriceDays},
+		{"60", "120", 60, 120},
+		{"7", "", minRawPriceDays, defaultHourlyPriceDays},
+		{"120", "90", 120, 120},
+		{"invalid", "-1", defaultRawPriceDays, defaultHourlyPriceDays},
+	}
+	for _, testCase := range testCases {
+		t.Setenv("PRICE_RAW_DAYS", testCase.rawDays)
+		t.Setenv("PRICE_HOURLY_DAYS", testCase.hourlyDays)
+		if rawPriceDays() != testCase.raw || hourlyPriceDays() != testCase.hourly {
+			t.Errorf("Retention days of %q, %q: %v, %v", testCase.rawDays, testCase.hourlyDays, rawPriceDays(), hourlyPriceDays())
+		}
+	}
+}
+
+func TestRetentionCutoff(t *testing.T) {
+	// 23:30 utc is already the next day in StationLocation
+	now := time.Date(2023, time.March, 6, 23, 30, 0, 0, time.UTC)
+	if cutoff := retentionCutoff(2, now); !cutoff.Equal(time.Date(2023, time.March, 5, 0, 0, 0, 0, gsmodel.StationLocation)) {
+		t.Errorf("Cutoff: %v", cutoff)
+	}
+}
+
+func TestAggregatePrices(t *testing.T) {
+	dayStart := time.Date(2023, time.March, 6, 0, 0, 0, 0, gsmodel.StationLocation)
+	otherStid := "00000000-0000-0000-0000-000000000001"
+	// unsorted, the prices of 10 or less mark fuels the station does not sell
+	gasPrices := []gsmodel.GasPrice{
+		{GasStationID: retentionStid, E5: 1839, E10: 1779, Diesel: 0, Date: dayStart.Add(7*time.Hour + 40*time.Minute)},
+		{GasStationID: retentionStid, E5: 1859, E10: 1799, Diesel: 0, Date: dayStart.Add(7*time.Hour + 10*time.Minute)},
+		{GasStationID: retentionStid, E5: 1819, E10: 1759, Diesel: 0, Date: dayStart.Add(18 * time.Hour)},
+		{GasStationID: otherStid, E5: 1879, E10: 1819, Diesel: 1699, Date: dayStart.Add(9 * time.Hour)},
+	}
+	hourlyPrices, dailyPrices := aggregatePrices(gasPrices, dayStart, []gsmodel.HourlyPrice{}, []gsmodel.DailyPrice{})
+	if len(hourlyPrices) != 3 || len(dailyPrices) != 2 {
+		t.Fatalf("Aggregates hourly: %v daily: %v", len(hourlyPrices), len(dailyPrices))
+	}
+	keyToHourlyPrice := make(map[string]gsmodel.HourlyPrice)
+	for _, myHourlyPrice := range hourlyPrices {
+		keyToHourlyPrice[aggregateKey(myHourlyPrice.GasStationID, myHourlyPrice.Start)] = myHourlyPrice
+	}
+	hourlyPrice := keyToHourlyPrice[aggregateKey(retentionStid, dayStart.Add(7*time.Hour))]
+	if !sameFuelAggregate(hourlyPrice.E5, gsmodel.FuelAggregate{Min: 1839, Max: 1859, Avg: 1849, First: 1859, Last: 1839, Count: 2}) {
+		t.Errorf("Hourly e5 aggregate: %+v", hourlyPrice.E5)
+	}
+	if hourlyPrice.Diesel.Count != 0 {
+		t.Errorf("Hourly diesel aggregate of unsold fuel: %+v", hourlyPrice.Diesel)
+	}
+	for _, myDailyPrice := range dailyPrices {
+		if !myDailyPrice.Start.Equal(dayStart) {
+			t.Errorf("Daily aggregate start: %v", myDailyPrice.Start)
+		}
+		if myDailyPrice.GasStationID == retentionStid && !sameFuelAggregate(myDailyPrice.E10, gsmodel.FuelAggregate{Min: 1759, Max: 1799, Avg: 1779, First: 1799, Last: 1759, Count: 3}) {
+			t.Errorf("Daily e10 aggregate: %+v", myDailyPrice.E10)
+		}
+	}
+}
+
+func TestAggregatePricesMergesExisting(t *testing.T) {
+	dayStart := time.Date(2023, time.March, 6, 0, 0, 0, 0, gsmodel.StationLocation)
+	hourStart := dayStart.Add(7 * time.Hour)
+	existingAggregate := gsmodel.FuelAggregate{Min: 1790, Max: 1810, Avg: 1800, First: 1810, Last: 1790, Count: 2}
+	existingHourlyPrices := []gsmodel.HourlyPrice{
+		{PriceAggregate: gsmodel.PriceAggregate{GasStationID: retentionStid, Start: hourStart, E5: existingAggregate}},
+		{PriceAggregate: gsmodel.PriceAggregate{GasStationID: retentionStid, Start: hourStart.Add(-time.Hour), E5: existingAggregate}},
+	}
+	existingDailyPrices := []gsmodel.DailyPrice{{PriceAggregate: gsmodel.PriceAggregate{GasStationID: retentionStid, Start: dayStart, E5: existingAggregate}}}
+	gasPrices := []gsmodel.GasPrice{
+		{GasStationID: retentionStid, E5: 1850, Date: hourStart.Add(10 * time.Minute)},
+		{GasStationID: retentionStid, E5: 1860, Date: hourStart.Add(20 * time.Minute)},
+	}
+	hourlyPrices, dailyPrices := aggregatePrices(gasPrices, dayStart, existingHourlyPrices, existingDailyPrices)
+	if len(hourlyPrices) != 2 || len(dailyPrices) != 1 {
+		t.Fatalf("Aggregates hourly: %v daily: %v", len(hourlyPrices), len(dailyPrices))
+	}
+	// the existing aggregates are older than the raw prices
+	mergedAggregate := gsmodel.FuelAggregate{Min: 1790, Max: 1860, Avg: 1827, First: 1810, Last: 1860, Count: 4}
+	for _, myHourlyPrice := range hourlyPrices {
+		if myHourlyPrice.Start.Equal(hourStart) && !sameFuelAggregate(myHourlyPrice.E5, mergedAggregate) {
+			t.Errorf("Merged hourly aggregate: %+v", myHourlyPrice.E5)
+		}
+		if myHourlyPrice.Start.Equal(hourStart.Add(-time.Hour)) && !sameFuelAggregate(myHourlyPrice.E5, existingAggregate) {
+			t.Errorf("Existing hourly aggregate changed: %+v", myHourlyPrice.E5)
+		}
+	}
+	if !sameFuelAggregate(dailyPrices[0].E5, mergedAggregate) {
+		t.Errorf("Merged daily aggregate: %+v", dailyPrices[0].E5)
+	}
+}
+
+// the sum is only set while aggregating
+func sameFuelAggregate(fuelAggregate gsmodel.FuelAggregate, expected gsmodel.FuelAggregate) bool {
+	return fuelAggregate.Min == expected.Min && fuelAggregate.Max == expected.Max && fuelAggregate.Avg == expected.Avg && fuelAggregate.First == expected.First &&
+		fuelAggregate.Last == expected.Last && fuelAggregate.Count == expected.Count
+}
@@ -0,0 +1,229 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"react-and-go/pkd/config"
+	gsbody "react-and-go/pkd/controller/gsmodel"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultNearestCount = 10
+	maxNearestCount     = 100
+	maxRoutePoints      = 10000
+	maxRouteDistance    = 25.0
+)
+
+type routeHit struct {
+	distance float64
+	bearing  float64
+	routeKm  float64
+}
+
+// the count nearest stations with a current price for the fuel type
+func FindNearest(searchNearest gsbody.SearchNearest) ([]gsmodel.GasStation, error) {
+	if !validCoordinates(searchNearest.Latitude, searchNearest.Longitude) {
+		return []gsmodel.GasStation{}, errors.New("invalid coordinates")
+	}
+	fuelType, sortBy, err := parseSearchOrder(searchNearest.FuelType, searchNearest.SortBy, "distance")
+	if err != nil {
+		return []gsmodel.GasStation{}, err
+	}
+	count := searchNearest.Count
+	if count <= 0 {
+		count = defaultNearestCount
+	}
+	if count > maxNearestCount {
+		count = maxNearestCount
+	}
+	myStationIndex := loadedStationIndex()
+	result := []gsmodel.GasStation{}
+	//stations without a current price are filtered, more candidates are needed until enough are found
+	for candidateCount := count * 2; ; candidateCount = candidateCount * 4 {
+		hits := myStationIndex.Nearest(searchNearest.Latitude, searchNearest.Longitude, candidateCount, searchNearest.MaxRadius)
+		stids := []string{}
+		stidToRouteHit := make(map[string]routeHit)
+		for _, hit := range hits {
+			stids = append(stids, hit.ID)
+			stidToRouteHit[hit.ID] = routeHit{distance: hit.Distance, bearing: hit.Bearing}
+		}
+		result = findSearchResult(stids, stidToRouteHit, fuelType, searchNearest.OpenAt, searchNearest.OnlyOpen)
+		if len(result) >= count || len(hits) < candidateCount {
+			break
+		}
+	}
+	if len(result) > count {
+		result = result[:count]
+	}
+	sortSearchResult(result, sortBy, fuelType)
+	return result, nil
+}
+
+// the stations within distance km of the route
+func FindAlongRoute(searchRoute gsbody.SearchRoute) ([]gsmodel.GasStation, error) {
+	routePoints := []gsmodel.GeoPoint{}
+	for _, routePoint := range searchRoute.Route {
+		routePoints = append(routePoints, gsmodel.GeoPoint{Latitude: routePoint.Latitude, Longitude: routePoint.Longitude})
+	}
+	if len(routePoints) == 0 && len(strings.TrimSpace(searchRoute.Polyline)) > 0 {
+		var err error
+		if routePoints, err = gsmodel.DecodePolyline(strings.TrimSpace(searchRoute.Polyline)); err != nil {
+			return []gsmodel.GasStation{}, err
+		}
+	}
+	if len(routePoints) == 0 || len(routePoints) > maxRoutePoints {
+		return []gsmodel.GasStation{}, errors.New("invalid number of route points")
+	}
+	for _, routePoint := range routePoints {
+		if !validCoordinates(routePoint.Latitude, routePoint.Longitude) {
+			return []gsmodel.GasStation{}, errors.New("invalid coordinates")
+		}
+	}
+	if searchRoute.Distance <= 0.0 || searchRoute.Distance > maxRouteDistance {
+		return []gsmodel.GasStation{}, errors.New("invalid distance")
+	}
+	fuelType, sortBy, err := parseSearchOrder(searchRoute.FuelType, searchRoute.SortBy, "route")
+	if err != nil {
+		return []gsmodel.GasStation{}, err
+	}
+	if len(routePoints) == 1 {
+		routePoints = append(routePoints, routePoints[0])
+	}
+	myStationIndex := loadedStationIndex()
+	stidToRouteHit := make(map[string]routeHit)
+	routeKm := 0.0
+	for i := 0; i < len(routePoints)-1; i++ {
+		start := routePoints[i]
+		end := routePoints[i+1]
+		segmentKm, _ := gsmodel.GasStation{Latitude: end.Latitude, Longitude: end.Longitude}.CalcDistanceBearing(start.Latitude, start.Longitude)
+		//every station near the segment is in the circle around the segment center
+		for _, hit := range myStationIndex.InRadius((start.Latitude+end.Latitude)/2, (start.Longitude+end.Longitude)/2, segmentKm/2+searchRoute.Distance+0.1) {
+			closestPoint, fraction := closestPointOnSegment(start, end, hit.Latitude, hit.Longitude)
+			distance, bearing := gsmodel.GasStation{Latitude: hit.Latitude, Longitude: hit.Longitude}.CalcDistanceBearing(closestPoint.Latitude, closestPoint.Longitude)
+			if myRouteHit, found := stidToRouteHit[hit.ID]; distance <= searchRoute.Distance && (!found || distance < myRouteHit.distance) {
+				stidToRouteHit[hit.ID] = routeHit{distance: distance, bearing: bearing, routeKm: routeKm + fraction*segmentKm}
+			}
+		}
+		routeKm = routeKm + segmentKm
+	}
+	stids := []string{}
+	for stid := range stidToRouteHit {
+		stids = append(stids, stid)
+	}
+	result := findSearchResult(stids, stidToRouteHit, fuelType, searchRoute.OpenAt, searchRoute.OnlyOpen)
+	sortSearchResult(result, sortBy, fuelType)
+	return result, nil
+}
+
+// loads the stations with the latest price of the last 30 days in the order of the stids
+func findSearchResult(stids []string, stidToRouteHit map[string]routeHit, fuelType gsmodel.FuelType, openAt time.Time, onlyOpen bool) []gsmodel.GasStation {
+	if len(stids) == 0 {
+		return []gsmodel.GasStation{}
+	}
+	idToGasStation := make(map[string]gsmodel.GasStation)
+	for _, myGasStation := range findByIds(&stids) {
+		idToGasStation[myGasStation.ID] = myGasStation
+	}
+	stidToLatestPrice := make(map[string]gsmodel.GasPrice)
+	for _, myGasPrice := range findPricesByStidsAndPeriod(&stids, time.Now().Add(time.Hour*-720)) {
+		if myLatestPrice, found := stidToLatestPrice[myGasPrice.GasStationID]; !found || myGasPrice.Date.After(myLatestPrice.Date) {
+			stidToLatestPrice[myGasPrice.GasStationID] = myGasPrice
+		}
+	}
+	result := []gsmodel.GasStation{}
+	for _, stid := range stids {
+		myGasStation, found := idToGasStation[stid]
+		if !found {
+			continue
+		}
+		myLatestPrice, priceFound := stidToLatestPrice[stid]
+		if len(fuelType) > 0 && (!priceFound || myLatestPrice.PriceOf(fuelType) <= 10) {
+			continue
+		}
+		myGasStation.GasPrices = []gsmodel.GasPrice{}
+		if priceFound {
+			myGasStation.GasPrices = append(myGasStation.GasPrices, myLatestPrice)
+		}
+		myGasStation.Distance = stidToRouteHit[stid].distance
+		myGasStation.Bearing = stidToRouteHit[stid].bearing
+		myGasStation.RouteKm = stidToRouteHit[stid].routeKm
+		result = append(result, myGasStation)
+	}
+	return filterByOpeningState(result, openAt, onlyOpen)
+}
+
+func parseSearchOrder(fuelTypeStr string, sortByStr string, defaultSortBy string) (gsmodel.FuelType, string, error) {
+	var fuelType gsmodel.FuelType
+	if len(strings.TrimSpace(fuelTypeStr)) > 0 {
+		var err error
+		if fuelType, err = gsmodel.ParseFuelType(fuelTypeStr); err != nil {
+			return fuelType, "", err
+		}
+	}
+	sortBy := strings.ToLower(strings.TrimSpace(sortByStr))
+	if len(sortBy) == 0 {
+		sortBy = defaultSortBy
+	}
+	if sortBy != "distance" && sortBy != "price" && sortBy != defaultSortBy {
+		return fuelType, "", errors.New("invalid sort order")
+	}
+	if sortBy == "price" && len(fuelType) == 0 {
+		return fuelType, "", errors.New("sort by price needs a fuel type")
+	}
+	return fuelType, sortBy, nil
+}
+
+func sortSearchResult(gasStations []gsmodel.GasStation, sortBy string, fuelType gsmodel.FuelType) {
+	sort.SliceStable(gasStations, func(i, j int) bool {
+		if sortBy == "price" && gasStations[i].GasPrices[0].PriceOf(fuelType) != gasStations[j].GasPrices[0].PriceOf(fuelType) {
+			return gasStations[i].GasPrices[0].PriceOf(fuelType) < gasStations[j].GasPrices[0].PriceOf(fuelType)
+		}
+		if sortBy == "route" && gasStations[i].RouteKm != gasStations[j].RouteKm {
+			return gasStations[i].RouteKm < gasStations[j].RouteKm
+		}
+		return gasStations[i].Distance < gasStations[j].Distance
+	})
+}
+
+// closest point of the segment with the fraction of the segment length, flat earth approximation near the segment
+func closestPointOnSegment(start gsmodel.GeoPoint, end gsmodel.GeoPoint, latitude float64, longitude float64) (gsmodel.GeoPoint, float64) {
+	cosLat := math.Cos(start.Latitude * math.Pi / 180.0)
+	segmentX := (end.Longitude - start.Longitude) * cosLat
+	segmentY := end.Latitude - start.Latitude
+	pointX := (longitude - start.Longitude) * cosLat
+	pointY := latitude - start.Latitude
+	fraction := 0.0
+	if segmentLength2 := segmentX*segmentX + segmentY*segmentY; segmentLength2 > 0.0 {
+		fraction = math.Max(0.0, math.Min(1.0, (pointX*segmentX+pointY*segmentY)/segmentLength2))
+	}
+	return gsmodel.GeoPoint{Latitude: start.Latitude + fraction*(end.Latitude-start.Latitude), Longitude: start.Longitude + fraction*(end.Longitude-start.Longitude)}, fraction
+}
+
+func validCoordinates(latitude float64, longitude float64) bool {
+	return math.Abs(latitude) <= 90.0 && math.Abs(longitude) <= 180.0
+}
+
+// the radius of a search around a location must be positive and not above SEARCH_MAX_RADIUS_KM
+func checkSearchRadius(radius float64) error {
+	if radius <= 0.0 || radius > config.MaxSearchRadius() {
+		return fmt.Errorf("invalid radius, the maximum is %v km", config.MaxSearchRadius())
+	}
+	return nil
+}
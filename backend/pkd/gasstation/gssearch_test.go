@@ -0,0 +1,81 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"math"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"testing"
+)
+
+func TestParseSearchOrder(t *testing.T) {
+	testCases := []struct {
+		fuelType      string
+		sortBy        string
+		defaultSortBy string
+		fuelTypeOut   gsmodel.FuelType
+		sortByOut     string
+		valid         bool
+	}{
+		{"", "", "distance", "", "distance", true},
+		{"e5", " Price ", "distance", gsmodel.FuelE5, "price", true},
+		{"", "", "route", "", "route", true},
+		{"diesel", "distance", "route", gsmodel.FuelDiesel, "distance", true},
+		{"", "route", "distance", "", "", false},
+		{"", "price", "distance", "", "", false},
+		{"lpg", "distance", "distance", "", "", false},
+	}
+	for _, testCase := range testCases {
+		fuelType, sortBy, err := parseSearchOrder(testCase.fuelType, testCase.sortBy, testCase.defaultSortBy)
+		if (err == nil) != testCase.valid {
+			t.Errorf("Search order %q, %q: %v", testCase.fuelType, testCase.sortBy, err)
+			continue
+		}
+		if testCase.valid && (fuelType != testCase.fuelTypeOut || sortBy != testCase.sortByOut) {
+			t.Errorf("Search order %q, %q: %v, %v", testCase.fuelType, testCase.sortBy, fuelType, sortBy)
+		}
+	}
+}
+
+func TestClosestPointOnSegment(t *testing.T) {
+	start := gsmodel.GeoPoint{Latitude: 0.0, Longitude: 0.0}
+	end := gsmodel.GeoPoint{Latitude: 0.0, Longitude: 2.0}
+	testCases := []struct {
+		latitude  float64
+		longitude float64
+		point     gsmodel.GeoPoint
+		fraction  float64
+	}{
+		{1.0, 1.0, gsmodel.GeoPoint{Latitude: 0.0, Longitude: 1.0}, 0.5},
+		{-1.0, -1.0, start, 0.0},
+		{0.5, 3.0, end, 1.0},
+	}
+	for _, testCase := range testCases {
+		point, fraction := closestPointOnSegment(start, end, testCase.latitude, testCase.longitude)
+		if math.Abs(point.Latitude-testCase.point.Latitude) > 1e-9 || math.Abs(point.Longitude-testCase.point.Longitude) > 1e-9 || math.Abs(fraction-testCase.fraction) > 1e-9 {
+			t.Errorf("Closest point of %v, %v: %v, %v", testCase.latitude, testCase.longitude, point, fraction)
+		}
+	}
+	if point, fraction := closestPointOnSegment(start, start, 1.0, 1.0); point != start || fraction != 0.0 {
+		t.Errorf("Closest point of an empty segment: %v, %v", point, fraction)
+	}
+}
+
+func TestValidCoordinates(t *testing.T) {
+	if !validCoordinates(52.52, 13.405) || !validCoordinates(-90.0, 180.0) {
+		t.Errorf("Valid coordinates rejected")
+	}
+	if validCoordinates(90.1, 13.405) || validCoordinates(52.52, -180.1) {
+		t.Errorf("Invalid coordinates accepted")
+	}
+}
@@ -0,0 +1,54 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"log"
+	"react-and-go/pkd/gasstation/gsindex"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"sync"
+)
+
+var stationIndex = gsindex.NewIndex()
+var stationIndexLoaded bool
+var stationIndexMutex sync.Mutex
+
+// the index is loaded from the database on first use and kept in sync by UpdateGasStations
+func loadedStationIndex() *gsindex.Index {
+	stationIndexMutex.Lock()
+	defer stationIndexMutex.Unlock()
+	if !stationIndexLoaded {
+		stationIndex.Load(gasStationRepository.FindAllLocations())
+		stationIndexLoaded = true
+		log.Printf("Station index loaded: %v\n", stationIndex.Len())
+	}
+	return stationIndex
+}
+
+func resetStationIndex() {
+	stationIndexMutex.Lock()
+	defer stationIndexMutex.Unlock()
+	stationIndexLoaded = false
+}
+
+func updateStationIndex(gasStations []gsmodel.GasStation) {
+	if len(gasStations) == 0 {
+		return
+	}
+	stationIndexMutex.Lock()
+	defer stationIndexMutex.Unlock()
+	//an unloaded index gets the stations with the first load
+	if stationIndexLoaded {
+		stationIndex.Upsert(gasStations)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"fmt"
+	gsbody "react-and-go/pkd/controller/gsmodel"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"sort"
+	"strings"
+	"time"
+)
+
+type BucketType string
+
+const (
+	HourBucket    BucketType = "hour"
+	WeekdayBucket BucketType = "weekday"
+	DayBucket     BucketType = "day"
+)
+
+const (
+	defaultStatisticsDays = 30
+	maxStatisticsDays     = 365
+)
+
+type FuelStatistics struct {
+	Min    int
+	Max    int
+	Avg    int
+	Median int
+	Count  int
+}
+
+type PriceStatistics struct {
+	Bucket string
+	E5     FuelStatistics
+	E10    FuelStatistics
+	Diesel FuelStatistics
+}
+
+type fuelValues struct {
+	E5     []int
+	E10    []int
+	Diesel []int
+}
+
+func ParseBucketType(bucketStr string) (BucketType, error) {
+	switch BucketType(strings.ToLower(strings.TrimSpace(bucketStr))) {
+	case HourBucket, "":
+		return HourBucket, nil
+	case WeekdayBucket:
+		return WeekdayBucket, nil
+	case DayBucket:
+		return DayBucket, nil
+	}
+	return HourBucket, fmt.Errorf("unknown bucket type: %v", bucketStr)
+}
+
+func CalcPriceStatisticsByStid(stid string, bucketType BucketType, days int) []PriceStatistics {
+	myGasPrices := findPricesByStidsAndPeriod(&[]string{stid}, calcStatisticsStart(days))
+	return calcPriceStatistics(myGasPrices, bucketType)
+}
+
+func CalcPriceStatisticsByLocation(searchStatistics gsbody.SearchStatistics) ([]PriceStatistics, error) {
+	bucketType, err := ParseBucketType(searchStatistics.Bucket)
+	if err != nil {
+		return []PriceStatistics{}, err
+	}
+	if err := checkSearchRadius(searchStatistics.Radius); err != nil {
+		return []PriceStatistics{}, err
+	}
+	gasStations := findStationsInCircle(searchStatistics.Latitude, searchStatistics.Longitude, searchStatistics.Radius, false)
+	stids := []string{}
+	for _, myGasStation := range gasStations {
+		stids = append(stids, myGasStation.ID)
+	}
+	if len(stids) == 0 {
+		return []PriceStatistics{}, nil
+	}
+	myGasPrices := findPricesByStidsAndPeriod(&stids, calcStatisticsStart(searchStatistics.Days))
+	return calcPriceStatistics(myGasPrices, bucketType), nil
+}
+
+func calcStatisticsStart(days int) time.Time {
+	if days <= 0 {
+		days = defaultStatisticsDays
+	}
+	if days > maxStatisticsDays {
+		days = maxStatisticsDays
+	}
+	return time.Now().AddDate(0, 0, -days)
+}
+
+func calcPriceStatistics(gasPrices []gsmodel.GasPrice, bucketType BucketType) []PriceStatistics {
+	bucketValues := make(map[string]*fuelValues)
+	for _, myGasPrice := range gasPrices {
+		bucketKey := createBucketKey(myGasPrice.Date, bucketType)
+		myFuelValues, found := bucketValues[bucketKey]
+		if !found {
+			myFuelValues = &fuelValues{}
+			bucketValues[bucketKey] = myFuelValues
+		}
+		//prices <= 10 mark fuel types the station does not sell
+		if myGasPrice.E5 > 10 {
+			myFuelValues.E5 = append(myFuelValues.E5, myGasPrice.E5)
+		}
+		if myGasPrice.E10 > 10 {
+			myFuelValues.E10 = append(myFuelValues.E10, myGasPrice.E10)
+		}
+		if myGasPrice.Diesel > 10 {
+			myFuelValues.Diesel = append(myFuelValues.Diesel, myGasPrice.Diesel)
+		}
+	}
+	result := []PriceStatistics{}
+	for bucketKey, myFuelValues := range bucketValues {
+		result = append(result, PriceStatistics{Bucket: bucketKey, E5: calcFuelStatistics(myFuelValues.E5),
+			E10: calcFuelStatistics(myFuelValues.E10), Diesel: calcFuelStatistics(myFuelValues.Diesel)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Bucket < result[j].Bucket
+	})
+	return result
+}
+
+// the bucket keys sort in chronological order: hours '00'-'23', weekdays '1-Monday'-'7-Sunday', days '2006-01-02'
+func createBucketKey(date time.Time, bucketType BucketType) string {
+	localDate := date.In(gsmodel.StationLocation)
+	switch bucketType {
+	case WeekdayBucket:
+		return fmt.Sprintf("%d-%v", (int(localDate.Weekday())+6)%7+1, localDate.Weekday().String())
+	case DayBucket:
+		return localDate.Format("2006-01-02")
+	}
+	return fmt.Sprintf("%02d", localDate.Hour())
+}
+
+func calcFuelStatistics(values []int) FuelStatistics {
+	result := FuelStatistics{Count: len(values)}
+	if len(values) == 0 {
+		return result
+	}
+	sortedValues := append([]int{}, values...)
+	sort.Ints(sortedValues)
+	sum := 0
+	for _, value := range sortedValues {
+		sum += value
+	}
+	result.Min = sortedValues[0]
+	result.Max = sortedValues[len(sortedValues)-1]
+	result.Avg = sum / len(sortedValues)
+	if len(sortedValues)%2 == 1 {
+		result.Median = sortedValues[len(sortedValues)/2]
+	} else {
+		result.Median = (sortedValues[len(sortedValues)/2-1] + sortedValues[len(sortedValues)/2]) / 2
+	}
+	return result
+}
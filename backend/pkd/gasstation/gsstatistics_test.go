@@ -0,0 +1,71 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"react-and-go/pkd/gasstation/gsmodel"
+	"testing"
+	"time"
+)
+
+func TestCalcFuelStatistics(t *testing.T) {
+	if result := calcFuelStatistics([]int{}); result != (FuelStatistics{}) {
+		t.Errorf("Statistics without values: %+v", result)
+	}
+	if result := calcFuelStatistics([]int{1799, 1759, 1839}); result != (FuelStatistics{Min: 1759, Max: 1839, Avg: 1799, Median: 1799, Count: 3}) {
+		t.Errorf("Statistics of odd count: %+v", result)
+	}
+	values := []int{1899, 1759, 1799, 1839}
+	if result := calcFuelStatistics(values); result != (FuelStatistics{Min: 1759, Max: 1899, Avg: 1824, Median: 1819, Count: 4}) {
+		t.Errorf("Statistics of even count: %+v", result)
+	}
+	if values[0] != 1899 {
+		t.Errorf("Values sorted in place: %v", values)
+	}
+}
+
+func TestCalcPriceStatisticsBuckets(t *testing.T) {
+	// 2023-03-06 is a monday, the prices of 10 or less mark fuels the station does not sell
+	gasPrices := []gsmodel.GasPrice{
+		{E5: 1859, E10: 1799, Diesel: 0, Date: time.Date(2023, time.March, 6, 7, 10, 0, 0, gsmodel.StationLocation)},
+		{E5: 1879, E10: 1819, Diesel: 0, Date: time.Date(2023, time.March, 6, 7, 50, 0, 0, gsmodel.StationLocation)},
+		{E5: 1799, E10: 1739, Diesel: 0, Date: time.Date(2023, time.March, 7, 19, 0, 0, 0, gsmodel.StationLocation)},
+	}
+	hourStatistics := calcPriceStatistics(gasPrices, HourBucket)
+	if len(hourStatistics) != 2 || hourStatistics[0].Bucket != "07" || hourStatistics[1].Bucket != "19" {
+		t.Fatalf("Hour buckets: %+v", hourStatistics)
+	}
+	if hourStatistics[0].E5 != (FuelStatistics{Min: 1859, Max: 1879, Avg: 1869, Median: 1869, Count: 2}) || hourStatistics[0].Diesel.Count != 0 {
+		t.Errorf("Hour statistics: %+v", hourStatistics[0])
+	}
+	weekdayStatistics := calcPriceStatistics(gasPrices, WeekdayBucket)
+	if len(weekdayStatistics) != 2 || weekdayStatistics[0].Bucket != "1-Monday" || weekdayStatistics[1].Bucket != "2-Tuesday" {
+		t.Errorf("Weekday buckets: %+v", weekdayStatistics)
+	}
+	// the day of the station, not of utc
+	dayStatistics := calcPriceStatistics([]gsmodel.GasPrice{{E5: 1859, Date: time.Date(2023, time.March, 6, 23, 30, 0, 0, time.UTC)}}, DayBucket)
+	if len(dayStatistics) != 1 || dayStatistics[0].Bucket != "2023-03-07" {
+		t.Errorf("Day buckets: %+v", dayStatistics)
+	}
+}
+
+func TestParseBucketType(t *testing.T) {
+	for bucketStr, bucketType := range map[string]BucketType{"": HourBucket, "hour": HourBucket, " Weekday ": WeekdayBucket, "DAY": DayBucket} {
+		if result, err := ParseBucketType(bucketStr); err != nil || result != bucketType {
+			t.Errorf("Bucket type of %q: %v error: %v", bucketStr, result, err)
+		}
+	}
+	if _, err := ParseBucketType("month"); err == nil {
+		t.Errorf("Unknown bucket type parsed")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type MailSender interface {
+	Send(mail Mail) error
+}
+
+type SmtpMailSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// writes the mails to a local file instead of sending them, for development and tests
+type FileMailSender struct {
+	FilePath string
+	mutex    sync.Mutex
+}
+
+// MAIL_SINK_FILE has priority over the SMTP_* settings
+func NewMailSender() MailSender {
+	sinkFile := strings.TrimSpace(os.Getenv("MAIL_SINK_FILE"))
+	if len(sinkFile) > 0 {
+		return &FileMailSender{FilePath: sinkFile}
+	}
+	return &SmtpMailSender{Host: strings.TrimSpace(os.Getenv("SMTP_HOST")), Port: strings.TrimSpace(os.Getenv("SMTP_PORT")),
+		Username: strings.TrimSpace(os.Getenv("SMTP_USER")), Password: os.Getenv("SMTP_PWD"), From: strings.TrimSpace(os.Getenv("SMTP_FROM"))}
+}
+
+func (smtpMailSender *SmtpMailSender) Send(mail Mail) error {
+	if len(smtpMailSender.Host) < 2 {
+		return fmt.Errorf("smtp host not configured")
+	}
+	port := smtpMailSender.Port
+	if len(port) == 0 {
+		port = "25"
+	}
+	var auth smtp.Auth
+	if len(smtpMailSender.Username) > 0 {
+		auth = smtp.PlainAuth("", smtpMailSender.Username, smtpMailSender.Password, smtpMailSender.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(smtpMailSender.Host, port), auth, smtpMailSender.From, []string{mail.To}, createMessage(smtpMailSender.From, mail))
+}
+
+func (fileMailSender *FileMailSender) Send(mail Mail) error {
+	fileMailSender.mutex.Lock()
+	defer fileMailSender.mutex.Unlock()
+	file, err := os.OpenFile(fileMailSender.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(createMessage("sink@localhost", mail), []byte("\r\n.\r\n")...))
+	return err
+}
+
+func createMessage(from string, mail Mail) []byte {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("From: %v\r\n", from))
+	builder.WriteString(fmt.Sprintf("To: %v\r\n", mail.To))
+	builder.WriteString(fmt.Sprintf("Subject: %v\r\n", strings.ReplaceAll(strings.ReplaceAll(mail.Subject, "\r", ""), "\n", " ")))
+	builder.WriteString(fmt.Sprintf("Date: %v\r\n", time.Now().Format(time.RFC1123Z)))
+	builder.WriteString("MIME-Version: 1.0\r\n")
+	builder.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	builder.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	return []byte(builder.String())
+}
@@ -20,6 +20,7 @@ import (
 	"os"
 	"react-and-go/pkd/gasstation"
 	"strings"
+	"sync"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -37,15 +38,14 @@ type PriceUpdates struct {
 
 var client mqtt.Client
 
-var randSource = rand.NewSource(time.Now().UnixNano())
+// the decode workers share the source, a rand.Rand is not safe for concurrent use
+var scrambleRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+var scrambleMutex sync.Mutex
 
 var gasPriceMsgHandler mqtt.MessageHandler = func(client mqtt.Client, msg mqtt.Message) {
 	//fmt.Printf("Message: %s received on topic: %s size: %d\n", msg.Payload(), msg.Topic(), len(msg.Payload()))
-	startTime := time.Now()
 	fmt.Printf("Message received on topic: %s size: %d\n", msg.Topic(), len(msg.Payload()))
-	ptr := msg.Payload()
-	HandlePriceUpdate(&ptr, msg.Topic())
-	fmt.Printf("Message processed in: %v on topic: %s size: %d\n", time.Since(startTime), msg.Topic(), len(msg.Payload()))
+	pipeline.offer(msg.Payload(), msg.Topic())
 }
 
 var messagePubHandler mqtt.MessageHandler = func(client mqtt.Client, msg mqtt.Message) {
@@ -64,6 +64,7 @@ var connectionLostHandler mqtt.ConnectionLostHandler = func(client mqtt.Client,
 }
 
 func Start() {
+	startPipeline()
 	msgServerUrl := os.Getenv("MSG_PARAMS")
 	msgClientId := os.Getenv("MSG_CLIENT_ID")
 	msgServerUser := os.Getenv("MSG_SERVER_USER")
@@ -89,8 +90,10 @@ func Start() {
 	subscribeToTopic(msgGasPriceTopic)
 }
 
+// the pipeline is drained after the disconnect
 func Stop() {
 	client.Disconnect(1000)
+	stopPipeline()
 }
 
 func SendMsg(msg string) {
@@ -107,12 +110,21 @@ func ConnectionCheck() {
 	//log.Printf("ConnectionCheck() done.\n")
 }
 
+// processes the message without the pipeline
 func HandlePriceUpdate(msgArr *[]byte, topicName string) {
+	myGasStationPrices, err := decodePriceUpdates(*msgArr, topicName)
+	if err != nil {
+		return
+	}
+	gasstation.UpdatePrice(&myGasStationPrices)
+}
+
+func decodePriceUpdates(msgArr []byte, topicName string) ([]gasstation.GasStationPrices, error) {
 	var priceUpdateRawMap map[string]json.RawMessage
-	if err := json.Unmarshal(*msgArr, &priceUpdateRawMap); err != nil {
-		log.Printf("Message: %s received on topic: %s size: %d\n", msgArr, topicName, len(*msgArr))
+	if err := json.Unmarshal(msgArr, &priceUpdateRawMap); err != nil {
+		log.Printf("Message: %s received on topic: %s size: %d\n", msgArr, topicName, len(msgArr))
 		log.Printf("Unmarshal failed: %v\n", err.Error())
-		return
+		return nil, err
 	}
 	priceUpdateMap := make(map[string]PriceUpdates)
 	for key, value := range priceUpdateRawMap {
@@ -139,7 +151,7 @@ func HandlePriceUpdate(msgArr *[]byte, topicName string) {
 	}
 	//log.Printf("GasStationPrices: %v", myGasStationPrices)
 	log.Printf("Priceupdates received: %v", len(myGasStationPrices))
-	gasstation.UpdatePrice(&myGasStationPrices)
+	return myGasStationPrices, nil
 }
 
 func subscribeToTopic(topicName string) {
@@ -162,8 +174,9 @@ func convertJsonNumberToInt(value json.Number) int64 {
 
 // to have new test prices every time
 func scramblePrices(myGasStationPrices gasstation.GasStationPrices) gasstation.GasStationPrices {
-	r1 := rand.New(randSource)
-	scrambleValue := r1.Intn(20) - 10
+	scrambleMutex.Lock()
+	scrambleValue := scrambleRand.Intn(20) - 10
+	scrambleMutex.Unlock()
 	//log.Printf("ScrambleValue: %v", scrambleValue)
 	myGasStationPrices.E10 = myGasStationPrices.E10 + scrambleValue
 	myGasStationPrices.E5 = myGasStationPrices.E5 + scrambleValue
@@ -0,0 +1,230 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package messaging
+
+import (
+	"log"
+	"react-and-go/pkd/config"
+	"react-and-go/pkd/gasstation"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// counters since the start and the current queue lengths
+type PipelineMetrics struct {
+	ReceivedMessages   int64
+	DroppedMessages    int64 // the decode queue stayed full for MSG_ENQUEUE_TIMEOUT_MS
+	DecodeErrors       int64
+	InvalidUpdates     int64
+	Batches            int64
+	AppliedUpdates     int64
+	DuplicateUpdates   int64
+	StaleUpdates       int64
+	QuarantinedUpdates int64
+	QueueSize          int
+	DecodeQueueDepth   int
+	ValidateQueueDepth int
+	PersistQueueDepth  int
+}
+
+type rawMessage struct {
+	payload []byte
+	topic   string
+}
+
+// decode -> validate and batch -> persist, the stages are connected by bounded queues. The persist stage stores the alert work
+// in the outbox and wakes the dispatcher.
+type pricePipeline struct {
+	messages           chan rawMessage
+	updates            chan []gasstation.GasStationPrices
+	batches            chan []gasstation.GasStationPrices
+	done               chan struct{}
+	queueSize          int
+	enqueueTimeout     time.Duration
+	batchSize          int
+	batchWindow        time.Duration
+	closedMutex        sync.RWMutex
+	closed             bool
+	receivedMessages   int64
+	droppedMessages    int64
+	decodeErrors       int64
+	invalidUpdates     int64
+	batchCount         int64
+	appliedUpdates     int64
+	duplicateUpdates   int64
+	staleUpdates       int64
+	quarantinedUpdates int64
+}
+
+var pipeline *pricePipeline
+var pipelineOnce sync.Once
+
+// Start is called again for reconnects, the pipeline is started once
+func startPipeline() {
+	pipelineOnce.Do(func() {
+		pipeline = newPricePipeline()
+		pipeline.start(config.ReadIntEnv("MSG_DECODE_WORKERS", 2), config.ReadIntEnv("MSG_PERSIST_WORKERS", 1))
+	})
+}
+
+func stopPipeline() {
+	if pipeline == nil {
+		return
+	}
+	if !pipeline.stop(time.Duration(config.ReadIntEnv("MSG_DRAIN_TIMEOUT_SECONDS", 30)) * time.Second) {
+		log.Printf("Price pipeline not drained: %+v\n", pipeline.metrics())
+	}
+}
+
+func Metrics() PipelineMetrics {
+	if pipeline == nil {
+		return PipelineMetrics{}
+	}
+	return pipeline.metrics()
+}
+
+func newPricePipeline() *pricePipeline {
+	queueSize := config.ReadIntEnv("MSG_QUEUE_SIZE", 100)
+	return &pricePipeline{messages: make(chan rawMessage, queueSize), updates: make(chan []gasstation.GasStationPrices, queueSize),
+		batches: make(chan []gasstation.GasStationPrices, queueSize), done: make(chan struct{}), queueSize: queueSize, batchSize: config.ReadIntEnv("MSG_BATCH_SIZE", 500),
+		enqueueTimeout: time.Duration(config.ReadIntEnv("MSG_ENQUEUE_TIMEOUT_MS", 5000)) * time.Millisecond, batchWindow: time.Duration(config.ReadIntEnv("MSG_BATCH_WINDOW_MS", 1000)) * time.Millisecond}
+}
+
+// every stage closes the queue of the next stage after its workers are done
+func (myPipeline *pricePipeline) start(decodeWorkers int, persistWorkers int) {
+	runWorkers(decodeWorkers, myPipeline.decode, func() { close(myPipeline.updates) })
+	runWorkers(1, myPipeline.validateAndBatch, func() { close(myPipeline.batches) })
+	runWorkers(persistWorkers, myPipeline.persist, func() { close(myPipeline.done) })
+	log.Printf("Price pipeline started with queue size: %v workers decode: %v persist: %v\n", myPipeline.queueSize, decodeWorkers, persistWorkers)
+}
+
+func runWorkers(workers int, work func(), done func()) {
+	var waitGroup sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			work()
+		}()
+	}
+	go func() {
+		waitGroup.Wait()
+		done()
+	}()
+}
+
+// blocks the mqtt callback while the decode queue is full, the message is dropped after the enqueue timeout
+func (myPipeline *pricePipeline) offer(payload []byte, topic string) {
+	atomic.AddInt64(&myPipeline.receivedMessages, 1)
+	myPipeline.closedMutex.RLock()
+	defer myPipeline.closedMutex.RUnlock()
+	if myPipeline.closed {
+		atomic.AddInt64(&myPipeline.droppedMessages, 1)
+		log.Printf("Message dropped, pipeline stopped: %v\n", topic)
+		return
+	}
+	timer := time.NewTimer(myPipeline.enqueueTimeout)
+	defer timer.Stop()
+	select {
+	case myPipeline.messages <- rawMessage{payload: payload, topic: topic}:
+	case <-timer.C:
+		atomic.AddInt64(&myPipeline.droppedMessages, 1)
+		log.Printf("Message dropped, decode queue full: %v\n", topic)
+	}
+}
+
+// stops accepting messages and waits until the queued messages are processed
+func (myPipeline *pricePipeline) stop(timeout time.Duration) bool {
+	myPipeline.closedMutex.Lock()
+	if !myPipeline.closed {
+		myPipeline.closed = true
+		close(myPipeline.messages)
+	}
+	myPipeline.closedMutex.Unlock()
+	select {
+	case <-myPipeline.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (myPipeline *pricePipeline) decode() {
+	for myMessage := range myPipeline.messages {
+		gasStationPrices, err := decodePriceUpdates(myMessage.payload, myMessage.topic)
+		if err != nil {
+			atomic.AddInt64(&myPipeline.decodeErrors, 1)
+			continue
+		}
+		myPipeline.updates <- gasStationPrices
+	}
+}
+
+// small messages are collected for the batch window or until the batch size is reached
+func (myPipeline *pricePipeline) validateAndBatch() {
+	batch := []gasstation.GasStationPrices{}
+	var windowEnd <-chan time.Time
+	flush := func() {
+		if len(batch) > 0 {
+			myPipeline.batches <- batch
+			batch = []gasstation.GasStationPrices{}
+		}
+		windowEnd = nil
+	}
+	for {
+		select {
+		case gasStationPrices, ok := <-myPipeline.updates:
+			if !ok {
+				flush()
+				return
+			}
+			for _, value := range gasStationPrices {
+				if gasstation.ValidPrices(value) {
+					batch = append(batch, value)
+				} else {
+					atomic.AddInt64(&myPipeline.invalidUpdates, 1)
+				}
+			}
+			if len(batch) >= myPipeline.batchSize {
+				flush()
+			} else if windowEnd == nil && len(batch) > 0 {
+				windowEnd = time.After(myPipeline.batchWindow)
+			}
+		case <-windowEnd:
+			flush()
+		}
+	}
+}
+
+func (myPipeline *pricePipeline) persist() {
+	for batch := range myPipeline.batches {
+		report, _ := gasstation.StorePrices(&batch)
+		atomic.AddInt64(&myPipeline.batchCount, 1)
+		atomic.AddInt64(&myPipeline.appliedUpdates, int64(report.Applied))
+		atomic.AddInt64(&myPipeline.duplicateUpdates, int64(report.Duplicates))
+		atomic.AddInt64(&myPipeline.staleUpdates, int64(report.Stale))
+		atomic.AddInt64(&myPipeline.quarantinedUpdates, int64(report.Quarantined))
+		atomic.AddInt64(&myPipeline.invalidUpdates, int64(report.Invalid))
+	}
+}
+
+func (myPipeline *pricePipeline) metrics() PipelineMetrics {
+	return PipelineMetrics{ReceivedMessages: atomic.LoadInt64(&myPipeline.receivedMessages), DroppedMessages: atomic.LoadInt64(&myPipeline.droppedMessages),
+		DecodeErrors: atomic.LoadInt64(&myPipeline.decodeErrors), InvalidUpdates: atomic.LoadInt64(&myPipeline.invalidUpdates),
+		Batches: atomic.LoadInt64(&myPipeline.batchCount), AppliedUpdates: atomic.LoadInt64(&myPipeline.appliedUpdates),
+		DuplicateUpdates: atomic.LoadInt64(&myPipeline.duplicateUpdates), StaleUpdates: atomic.LoadInt64(&myPipeline.staleUpdates),
+		QuarantinedUpdates: atomic.LoadInt64(&myPipeline.quarantinedUpdates),
+		QueueSize:          myPipeline.queueSize, DecodeQueueDepth: len(myPipeline.messages), ValidateQueueDepth: len(myPipeline.updates),
+		PersistQueueDepth: len(myPipeline.batches)}
+}
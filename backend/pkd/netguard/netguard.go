@@ -0,0 +1,105 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrBlockedAddress = errors.New("address not allowed")
+
+// ranges that net.IP has no check for: shared address space, benchmarking, IETF protocol assignments and reserved
+var blockedNets = parseCIDRs("0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4", "64:ff9b::/96", "2001:db8::/32")
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	result := []*net.IPNet{}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		result = append(result, ipNet)
+	}
+	return result
+}
+
+// WEBHOOK_ALLOW_PRIVATE_NETWORKS=true allows local receivers for development and tests
+func allowPrivateNetworks() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS")), "true")
+}
+
+// false for loopback, private, link local (like the cloud metadata addresses), multicast and reserved addresses
+func PublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, blockedNet := range blockedNets {
+		if blockedNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// an http(s) url with a host that resolves only to public addresses
+func CheckPublicUrl(rawUrl string) error {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+	if parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https" {
+		return fmt.Errorf("scheme %v: %w", parsedUrl.Scheme, ErrBlockedAddress)
+	}
+	if len(parsedUrl.Hostname()) == 0 {
+		return fmt.Errorf("no host: %w", ErrBlockedAddress)
+	}
+	if allowPrivateNetworks() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ipAddrs, err := net.DefaultResolver.LookupIPAddr(ctx, parsedUrl.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, ipAddr := range ipAddrs {
+		if !PublicIP(ipAddr.IP) {
+			return fmt.Errorf("host %v resolves to %v: %w", parsedUrl.Hostname(), ipAddr.IP, ErrBlockedAddress)
+		}
+	}
+	return nil
+}
+
+// for net.Dialer.Control, it checks the resolved address of every connection and so the redirects and changed dns records too
+func DialControl(network string, address string, c syscall.RawConn) error {
+	if allowPrivateNetworks() {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !PublicIP(net.ParseIP(host)) {
+		return fmt.Errorf("dial %v: %w", host, ErrBlockedAddress)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package notification
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"react-and-go/pkd/appuser"
+	"react-and-go/pkd/appuser/aumodel"
+	unmodel "react-and-go/pkd/notification/model"
+	"sort"
+	"strings"
+	"time"
+)
+
+type NotificationChannel interface {
+	Name() aumodel.NotificationChannel
+	Send(appUser aumodel.AppUser, userNotification unmodel.UserNotification) error
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending DeliveryStatus = "PENDING"
+	DeliverySent    DeliveryStatus = "SENT"
+	DeliveryFailed  DeliveryStatus = "FAILED"
+)
+
+// errors that a retry can not fix, like an expired web push subscription
+type permanentError struct {
+	err error
+}
+
+func (myPermanentError permanentError) Error() string {
+	return myPermanentError.err.Error()
+}
+
+func createNotificationChannels() []NotificationChannel {
+	return []NotificationChannel{createEmailChannel(), createWebhookChannel(), createWebPushChannel()}
+}
+
+type deliveryPayload struct {
+	NotificationID int64
+	Channel        aumodel.NotificationChannel
+}
+
+// the time a delivery can take, a delivery is only started with this time left on the lease of its item
+const deliveryTimeout = 30 * time.Second
+
+// one outbox item per notification and enabled channel, they are stored in the transaction of the notifications
+func newDeliveryItems(appUsersMap map[string]aumodel.AppUser) func(savedNotifications []unmodel.UserNotification) ([]unmodel.OutboxItem, error) {
+	return func(savedNotifications []unmodel.UserNotification) ([]unmodel.OutboxItem, error) {
+		result := []unmodel.OutboxItem{}
+		now := time.Now()
+		for _, userNotification := range savedNotifications {
+			appUser, found := appUsersMap[userNotification.UserUuid]
+			if !found {
+				continue
+			}
+			for _, channel := range aumodel.NotificationChannels {
+				if !appUser.ChannelEnabled(channel) {
+					continue
+				}
+				payload, err := json.Marshal(deliveryPayload{NotificationID: userNotification.ID, Channel: channel})
+				if err != nil {
+					return []unmodel.OutboxItem{}, err
+				}
+				result = append(result, unmodel.OutboxItem{Kind: unmodel.DeliverNotificationKind, Payload: string(payload), Status: unmodel.OutboxPending,
+					NextAttemptAt: now})
+			}
+		}
+		return result, nil
+	}
+}
+
+// the outbox handler of the deliveries, every item is sent once per attempt and the outbox schedules the retries
+func DeliverNotifications(outboxItems []unmodel.OutboxItem) map[int64]error {
+	result := make(map[int64]error)
+	channelsMap := make(map[aumodel.NotificationChannel]NotificationChannel)
+	for _, notificationChannel := range createNotificationChannels() {
+		channelsMap[notificationChannel.Name()] = notificationChannel
+	}
+	for index, outboxItem := range outboxItems {
+		// the first item is always sent, a short lease must not stop the deliveries
+		if index > 0 && time.Now().Add(deliveryTimeout).After(outboxItem.NextAttemptAt) {
+			result[outboxItem.ID] = ErrOutboxNotProcessed
+			continue
+		}
+		if err := deliverNotification(outboxItem, channelsMap); err != nil {
+			result[outboxItem.ID] = err
+		}
+	}
+	return result
+}
+
+func deliverNotification(outboxItem unmodel.OutboxItem, channelsMap map[aumodel.NotificationChannel]NotificationChannel) error {
+	myDeliveryPayload := deliveryPayload{}
+	if err := json.Unmarshal([]byte(outboxItem.Payload), &myDeliveryPayload); err != nil {
+		return err
+	}
+	notificationChannel, found := channelsMap[myDeliveryPayload.Channel]
+	if !found {
+		return fmt.Errorf("unknown notification channel: %v", myDeliveryPayload.Channel)
+	}
+	// the notification or the user can be deleted before the delivery
+	userNotification, err := notificationRepository.FindById(myDeliveryPayload.NotificationID)
+	if err != nil {
+		log.Printf("Notification: %v not delivered: %v\n", myDeliveryPayload.NotificationID, err)
+		return nil
+	}
+	appUser, err := appuser.FindByUuid(userNotification.UserUuid)
+	if err != nil || !appUser.ChannelEnabled(notificationChannel.Name()) {
+		return nil
+	}
+	maxAttempts, _ := outboxRetryPolicy(outboxItem.Kind)
+	err = notificationChannel.Send(appUser, userNotification)
+	status := DeliverySent
+	var myPermanentError permanentError
+	if err != nil {
+		log.Printf("Notification: %v channel: %v attempt: %v failed: %v\n", userNotification.ID, notificationChannel.Name(), outboxItem.Attempts, err)
+		status = DeliveryFailed
+		if !errors.As(err, &myPermanentError) && outboxItem.Attempts < maxAttempts {
+			status = DeliveryPending
+		}
+	}
+	userNotification.DeliveryStatus = updateDeliveryStatus(userNotification.DeliveryStatus, notificationChannel.Name(), status)
+	if updateErr := notificationRepository.UpdateDeliveryStatus(userNotification.ID, userNotification.DeliveryStatus,
+		userNotification.DeliveryAttempts+1); updateErr != nil {
+		log.Printf("Update delivery status failed: %v\n", updateErr)
+	}
+	if errors.As(err, &myPermanentError) {
+		return nil
+	}
+	return err
+}
+
+// replaces the status of the channel in 'email=SENT;webhook=FAILED'
+func updateDeliveryStatus(deliveryStatus string, channel aumodel.NotificationChannel, status DeliveryStatus) string {
+	deliveryResults := []string{}
+	for _, deliveryResult := range strings.Split(deliveryStatus, ";") {
+		if len(deliveryResult) > 0 && !strings.HasPrefix(deliveryResult, string(channel)+"=") {
+			deliveryResults = append(deliveryResults, deliveryResult)
+		}
+	}
+	deliveryResults = append(deliveryResults, fmt.Sprintf("%v=%v", channel, status))
+	sort.Strings(deliveryResults)
+	return strings.Join(deliveryResults, ";")
+}
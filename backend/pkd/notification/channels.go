@@ -0,0 +1,176 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package notification
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"react-and-go/pkd/appuser/aumodel"
+	"react-and-go/pkd/mail"
+	"react-and-go/pkd/netguard"
+	unmodel "react-and-go/pkd/notification/model"
+	"strings"
+	"time"
+
+	webpush "github.com/SherClockHolmes/webpush-go"
+)
+
+const HeaderWebhookSignature = "X-Signature-SHA256"
+
+type emailChannel struct {
+	mailSender mail.MailSender
+}
+
+type webhookChannel struct {
+	client http.Client
+	secret string
+}
+
+type webPushChannel struct {
+	client          *http.Client
+	subscriber      string
+	vapidPublicKey  string
+	vapidPrivateKey string
+}
+
+type channelPayload struct {
+	UserUuid  string
+	Title     string
+	Message   string
+	Timestamp time.Time
+	Data      json.RawMessage
+}
+
+func createEmailChannel() NotificationChannel {
+	return &emailChannel{mailSender: mail.NewMailSender()}
+}
+
+// the urls are checked when they are stored, the dialer checks the resolved addresses again. No proxy is used, it would hide them.
+func newGuardedTransport() *http.Transport {
+	return &http.Transport{DialContext: (&net.Dialer{Timeout: 5 * time.Second, Control: netguard.DialControl}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second}
+}
+
+func createWebhookChannel() NotificationChannel {
+	return &webhookChannel{client: http.Client{Timeout: 5 * time.Second, Transport: newGuardedTransport()}, secret: os.Getenv("WEBHOOK_SECRET")}
+}
+
+// the subscription endpoints are user input like the webhook urls
+func createWebPushChannel() NotificationChannel {
+	return &webPushChannel{client: &http.Client{Timeout: 10 * time.Second, Transport: newGuardedTransport()},
+		subscriber: strings.TrimSpace(os.Getenv("VAPID_SUBSCRIBER")), vapidPublicKey: strings.TrimSpace(os.Getenv("VAPID_PUBLIC_KEY")),
+		vapidPrivateKey: strings.TrimSpace(os.Getenv("VAPID_PRIVATE_KEY"))}
+}
+
+func (myEmailChannel *emailChannel) Name() aumodel.NotificationChannel {
+	return aumodel.EmailChannel
+}
+
+func (myEmailChannel *emailChannel) Send(appUser aumodel.AppUser, userNotification unmodel.UserNotification) error {
+	if len(strings.TrimSpace(appUser.Email)) < 3 {
+		return permanentError{err: fmt.Errorf("no email address for user: %v", appUser.Uuid)}
+	}
+	// the address could belong to someone else until it is verified
+	if !appUser.EmailVerified {
+		return permanentError{err: fmt.Errorf("email address not verified for user: %v", appUser.Uuid)}
+	}
+	return myEmailChannel.mailSender.Send(mail.Mail{To: appUser.Email, Subject: userNotification.Title, Body: userNotification.Message})
+}
+
+func (myWebhookChannel *webhookChannel) Name() aumodel.NotificationChannel {
+	return aumodel.WebhookChannel
+}
+
+func (myWebhookChannel *webhookChannel) Send(appUser aumodel.AppUser, userNotification unmodel.UserNotification) error {
+	if len(strings.TrimSpace(appUser.WebhookUrl)) < 8 {
+		return permanentError{err: fmt.Errorf("no webhook url for user: %v", appUser.Uuid)}
+	}
+	payload, err := createChannelPayload(userNotification)
+	if err != nil {
+		return permanentError{err: err}
+	}
+	request, err := http.NewRequest(http.MethodPost, strings.TrimSpace(appUser.WebhookUrl), bytes.NewReader(payload))
+	if err != nil {
+		return permanentError{err: err}
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if len(myWebhookChannel.secret) > 0 {
+		mac := hmac.New(sha256.New, []byte(myWebhookChannel.secret))
+		mac.Write(payload)
+		request.Header.Set(HeaderWebhookSignature, hex.EncodeToString(mac.Sum(nil)))
+	}
+	response, err := myWebhookChannel.client.Do(request)
+	if errors.Is(err, netguard.ErrBlockedAddress) {
+		return permanentError{err: err}
+	}
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("response status: %v", response.Status)
+	}
+	return nil
+}
+
+func (myWebPushChannel *webPushChannel) Name() aumodel.NotificationChannel {
+	return aumodel.WebPushChannel
+}
+
+func (myWebPushChannel *webPushChannel) Send(appUser aumodel.AppUser, userNotification unmodel.UserNotification) error {
+	if len(myWebPushChannel.vapidPrivateKey) < 10 || len(myWebPushChannel.vapidPublicKey) < 10 {
+		return permanentError{err: fmt.Errorf("vapid keys not configured")}
+	}
+	subscription := webpush.Subscription{}
+	if err := json.Unmarshal([]byte(appUser.WebPushSubscription), &subscription); err != nil || len(subscription.Endpoint) < 8 {
+		return permanentError{err: fmt.Errorf("no web push subscription for user: %v", appUser.Uuid)}
+	}
+	payload, err := createChannelPayload(userNotification)
+	if err != nil {
+		return permanentError{err: err}
+	}
+	response, err := webpush.SendNotification(payload, &subscription, &webpush.Options{Subscriber: myWebPushChannel.subscriber,
+		VAPIDPublicKey: myWebPushChannel.vapidPublicKey, VAPIDPrivateKey: myWebPushChannel.vapidPrivateKey, TTL: 3600,
+		HTTPClient: myWebPushChannel.client})
+	if errors.Is(err, netguard.ErrBlockedAddress) {
+		return permanentError{err: err}
+	}
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusGone {
+		return permanentError{err: fmt.Errorf("web push subscription expired: %v", response.Status)}
+	}
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("response status: %v", response.Status)
+	}
+	return nil
+}
+
+func createChannelPayload(userNotification unmodel.UserNotification) ([]byte, error) {
+	data := json.RawMessage(userNotification.DataJson)
+	if !json.Valid(data) {
+		data = json.RawMessage("[]")
+	}
+	return json.Marshal(channelPayload{UserUuid: userNotification.UserUuid, Title: userNotification.Title, Message: userNotification.Message,
+		Timestamp: userNotification.Timestamp, Data: data})
+}
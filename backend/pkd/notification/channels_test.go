@@ -0,0 +1,59 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package notification
+
+import (
+	"errors"
+	"react-and-go/pkd/appuser/aumodel"
+	"react-and-go/pkd/mail"
+	unmodel "react-and-go/pkd/notification/model"
+	"testing"
+)
+
+type testMailSender struct {
+	mails []mail.Mail
+}
+
+func (mailSender *testMailSender) Send(mail mail.Mail) error {
+	mailSender.mails = append(mailSender.mails, mail)
+	return nil
+}
+
+func TestEmailChannelRequiresVerifiedAddress(t *testing.T) {
+	tests := []struct {
+		email     string
+		verified  bool
+		wantMails int
+	}{
+		{"", true, 0},
+		{"user@example.com", false, 0},
+		{"user@example.com", true, 1},
+	}
+	for _, test := range tests {
+		mailSender := &testMailSender{}
+		myEmailChannel := &emailChannel{mailSender: mailSender}
+		appUser := aumodel.AppUser{Uuid: "uuid1", Email: test.email, EmailVerified: test.verified}
+		err := myEmailChannel.Send(appUser, unmodel.UserNotification{Title: "title", Message: "message"})
+		if len(mailSender.mails) != test.wantMails {
+			t.Errorf("Send(%q, %v) mails: %v", test.email, test.verified, len(mailSender.mails))
+		}
+		// the missing or unverified addresses are not retried
+		var myPermanentError permanentError
+		if test.wantMails == 0 && !errors.As(err, &myPermanentError) {
+			t.Errorf("Send(%q, %v) error: %v", test.email, test.verified, err)
+		}
+		if test.wantMails > 0 && err != nil {
+			t.Errorf("Send(%q, %v) failed: %v", test.email, test.verified, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package notification
+
+import (
+	"encoding/json"
+	"log"
+	"react-and-go/pkd/appuser"
+	"react-and-go/pkd/appuser/aumodel"
+	unmodel "react-and-go/pkd/notification/model"
+	"react-and-go/pkd/pubsub"
+	"time"
+)
+
+// merges the deferred matches of the users into one notification, called by a cron job
+func SendDueDigests() {
+	digestEntries := notificationRepository.FindDigestEntries()
+	if len(digestEntries) == 0 {
+		return
+	}
+	userUuidToDigestEntries := make(map[string][]unmodel.DigestEntry)
+	for _, digestEntry := range digestEntries {
+		userUuidToDigestEntries[digestEntry.UserUuid] = append(userUuidToDigestEntries[digestEntry.UserUuid], digestEntry)
+	}
+	appUsersMap := make(map[string]aumodel.AppUser)
+	for _, appUser := range appuser.FindAllUsers() {
+		appUsersMap[appUser.Uuid] = appUser
+	}
+	now := time.Now()
+	for userUuid, myDigestEntries := range userUuidToDigestEntries {
+		appUser, found := appUsersMap[userUuid]
+		if !found || !digestDue(appUser, myDigestEntries[0].Timestamp, now) {
+			continue
+		}
+		ids := []int64{}
+		for _, digestEntry := range myDigestEntries {
+			ids = append(ids, digestEntry.ID)
+		}
+		userNotification, err := notificationRepository.SaveDigest(createDigestNotification(userUuid, myDigestEntries, now), ids,
+			newDeliveryItems(appUsersMap))
+		if err != nil {
+			log.Printf("Store digest failed: %v\n", err)
+			continue
+		}
+		pubsub.Publish(pubsub.Event{Type: pubsub.NotificationEventType, Notification: &userNotification})
+	}
+	WakeOutboxDispatcher()
+}
+
+// the digest is sent after the quiet hours, hourly or daily after its oldest entry
+func digestDue(appUser aumodel.AppUser, oldestEntry time.Time, now time.Time) bool {
+	if appUser.InQuietHours(now) {
+		return false
+	}
+	switch appUser.Digest() {
+	case aumodel.HourlyDigest:
+		return now.Sub(oldestEntry) >= time.Hour
+	case aumodel.DailyDigest:
+		return now.Sub(oldestEntry) >= 24*time.Hour
+	}
+	return true
+}
+
+// the newest entry per station
+func createDigestNotification(userUuid string, digestEntries []unmodel.DigestEntry, now time.Time) unmodel.UserNotification {
+	stidToNotificationData := make(map[string]NotificationData)
+	stids := []string{}
+	for _, digestEntry := range digestEntries {
+		var myNotificationData NotificationData
+		if err := json.Unmarshal([]byte(digestEntry.DataJson), &myNotificationData); err != nil {
+			log.Printf("Json unmarshal failed: %v", err)
+			continue
+		}
+		if _, found := stidToNotificationData[digestEntry.GasStationID]; !found {
+			stids = append(stids, digestEntry.GasStationID)
+		}
+		stidToNotificationData[digestEntry.GasStationID] = myNotificationData
+	}
+	myDatas := []NotificationData{}
+	myMessage := ""
+	for _, stid := range stids {
+		myNotificationData := stidToNotificationData[stid]
+		myDatas = append(myDatas, myNotificationData)
+		myMessage = myMessage + notificationLine(myNotificationData)
+	}
+	myDataJson, err := json.Marshal(myDatas)
+	if err != nil {
+		log.Printf("Json marshal failed: %v", err)
+		myDataJson = []byte("[]")
+	}
+	return unmodel.UserNotification{Timestamp: now, UserUuid: userUuid, Title: "Gas price digest.", Message: myMessage, DataJson: string(myDataJson)}
+}
@@ -0,0 +1,55 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package unmodel
+
+import (
+	"time"
+)
+
+type SuppressionReason string
+
+const (
+	CooldownReason      SuppressionReason = "COOLDOWN"        // the station was notified less than the cooldown ago
+	NoFurtherDropReason SuppressionReason = "NO_FURTHER_DROP" // the price is not below the last notified price
+	QuietHoursReason    SuppressionReason = "QUIET_HOURS"     // deferred to the digest after the quiet hours
+	DigestReason        SuppressionReason = "DIGEST"          // deferred to the hourly or daily digest
+)
+
+// the last notified price of a station and fuel for a user
+type NotificationState struct {
+	UserUuid     string `gorm:"primaryKey;size:64"`
+	GasStationID string `gorm:"primaryKey;size:64;column:stid;index:idx_ns_stid"`
+	FuelType     string `gorm:"primaryKey;size:8"`
+	Price        int
+	NotifiedAt   time.Time
+}
+
+// a match that did not create a notification, kept for debugging
+type NotificationSuppression struct {
+	ID           int64     `gorm:"primaryKey"`
+	Timestamp    time.Time `gorm:"index:idx_nsu_timestamp"`
+	UserUuid     string    `gorm:"size:64;not null;index:idx_nsu_user_uuid"`
+	GasStationID string    `gorm:"size:64;column:stid"`
+	FuelType     string    `gorm:"size:8"`
+	Price        int
+	Reason       SuppressionReason `gorm:"size:16"`
+}
+
+// a match that waits for the digest of the user
+type DigestEntry struct {
+	ID           int64     `gorm:"primaryKey"`
+	Timestamp    time.Time `gorm:"index:idx_de_timestamp"`
+	UserUuid     string    `gorm:"size:64;not null;index:idx_de_user_uuid"`
+	GasStationID string    `gorm:"size:64;column:stid"`
+	DataJson     string    // the NotificationData of the match
+}
@@ -0,0 +1,47 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package unmodel
+
+import (
+	"time"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "PENDING"
+	OutboxDone    OutboxStatus = "DONE"
+	OutboxFailed  OutboxStatus = "FAILED"
+)
+
+const (
+	EvaluateAlertsKind      = "evaluate-alerts"
+	DeliverNotificationKind = "deliver-notification" // one item per notification and channel
+)
+
+// work that is stored in the transaction of its cause and processed at least once by the outbox dispatcher
+type OutboxItem struct {
+	ID            int64        `gorm:"primaryKey"`
+	Kind          string       `gorm:"size:32;not null"`
+	Payload       string       // json
+	Status        OutboxStatus `gorm:"size:16;index:idx_outbox_status_next"`
+	Attempts      int
+	LastError     string    `gorm:"size:1024"`
+	NextAttemptAt time.Time `gorm:"index:idx_outbox_status_next"` // a claimed item is retried after its lease expired
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (OutboxItem) TableName() string {
+	return "notification_outbox"
+}
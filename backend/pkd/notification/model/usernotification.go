@@ -24,4 +24,6 @@ type UserNotification struct {
 	Message          string    `gorm:"size:4096"`
 	DataJson         string
 	NotificationSend bool
+	DeliveryStatus   string `gorm:"size:1024"` // per channel like 'email=SENT;webhook=FAILED'
+	DeliveryAttempts int
 }
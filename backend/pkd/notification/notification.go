@@ -17,13 +17,18 @@ import (
 	"fmt"
 	"log"
 	"react-and-go/pkd/appuser"
+	"react-and-go/pkd/appuser/aumodel"
+	"react-and-go/pkd/gasstation/gsindex"
 	"react-and-go/pkd/gasstation/gsmodel"
 	"time"
 )
 
 type gasStationWithPrice struct {
-	gasStation gsmodel.GasStation
-	gasPrice   gsmodel.GasPrice
+	gasStation     gsmodel.GasStation
+	gasPrice       gsmodel.GasPrice
+	alertRuleNames []string
+	// the matched price per fuel type for the cooldown and price drop checks
+	matchedPrices map[gsmodel.FuelType]int
 }
 
 type NotificationData struct {
@@ -40,9 +45,25 @@ type NotificationData struct {
 	E10          int
 	Diesel       int
 	Timestamp    time.Time
+	OpeningState gsmodel.OpeningState
+	AlertRules   []string
 }
 
-func SendNotifications(gasStationIDToGasPriceMapPtr *map[string]gsmodel.GasPrice, gasStations []gsmodel.GasStation) {
+// reference values for the relative alert rules, 0 means unknown
+type ReferencePriceProvider interface {
+	AreaMedian(latitude float64, longitude float64, radius float64, fuelType gsmodel.FuelType) int
+	TrailingAverage(stid string, fuelType gsmodel.FuelType, days int) int
+}
+
+const trailingAverageDays = 7
+
+type lastSeenKey struct {
+	alertRuleId uint
+	stid        string
+}
+
+// an error means that no notifications are stored and the evaluation can be retried
+func SendNotifications(gasStationIDToGasPriceMapPtr *map[string]gsmodel.GasPrice, gasStations []gsmodel.GasStation, referencePrices ReferencePriceProvider) error {
 	gasStationWithPricesMap := make(map[string]gasStationWithPrice)
 	for _, gasStation := range gasStations {
 		myGasStationWithPrice := gasStationWithPrice{}
@@ -51,34 +72,79 @@ func SendNotifications(gasStationIDToGasPriceMapPtr *map[string]gsmodel.GasPrice
 		myGasStationWithPrice.gasStation = gasStation
 		gasStationWithPricesMap[gasStation.ID] = myGasStationWithPrice
 	}
+	now := time.Now()
+	for key, myGasStationWithPrice := range gasStationWithPricesMap {
+		//no alerts for stations that are closed right now
+		myGasStationWithPrice.gasStation.OpeningState = myGasStationWithPrice.gasStation.CalcOpeningState(now)
+		if myGasStationWithPrice.gasStation.OpeningState == gsmodel.Closed {
+			delete(gasStationWithPricesMap, key)
+			continue
+		}
+		gasStationWithPricesMap[key] = myGasStationWithPrice
+	}
+	//index of the open stations of the batch for the radius lookups of the alert rules
+	openGasStations := []gsmodel.GasStation{}
+	for _, myGasStationWithPrice := range gasStationWithPricesMap {
+		openGasStations = append(openGasStations, myGasStationWithPrice.gasStation)
+	}
+	batchIndex := gsindex.NewIndex()
+	batchIndex.Load(openGasStations)
+	openStids := []string{}
+	for _, gasStation := range openGasStations {
+		openStids = append(openStids, gasStation.ID)
+	}
+	filter := newNotificationFilter(openStids, now)
+	lastSeenPrices := make(map[lastSeenKey]int)
+	for _, lastSeenPrice := range appuser.FindLastSeenPrices(openStids) {
+		lastSeenPrices[lastSeenKey{lastSeenPrice.AlertRuleID, lastSeenPrice.GasStationID}] = lastSeenPrice.Price
+	}
 	allAppUsers := appuser.FindAllUsers()
+	allAlertRules := appuser.FindAllActiveAlertRules()
+	alertRuleUsers := appuser.FindAlertRuleUsers()
+	appUsersMap := make(map[string]aumodel.AppUser)
 	myNotificationMsgs := []NotificationMsg{}
+	newLastSeenPrices := []aumodel.AlertRuleLastSeen{}
 	for _, appUser := range allAppUsers {
-		gsMatches := []gasStationWithPrice{}
-		for _, myGasStationWithPrice := range gasStationWithPricesMap {
-			//Type available and target price reached?
-			if (myGasStationWithPrice.gasPrice.Diesel > 10 && appUser.TargetDiesel >= myGasStationWithPrice.gasPrice.Diesel) ||
-				(myGasStationWithPrice.gasPrice.E10 > 10 && appUser.TargetE10 >= myGasStationWithPrice.gasPrice.E10) ||
-				(myGasStationWithPrice.gasPrice.E5 > 10 && appUser.TargetE5 >= myGasStationWithPrice.gasPrice.E5) {
-				//Distance match?
-				distance, _ := myGasStationWithPrice.gasStation.CalcDistanceBearing(appUser.Latitude, appUser.Longitude)
-				if appUser.SearchRadius >= distance {
-					//log.Printf("Match found: %v %v %v %v %v %v\n", distance, myGasStationWithPrice.gasStation.Brand, myGasStationWithPrice.gasStation.Place,
-					//	myGasStationWithPrice.gasPrice.Diesel, myGasStationWithPrice.gasPrice.E10, myGasStationWithPrice.gasPrice.E5)
-					gsMatches = append(gsMatches, myGasStationWithPrice)
+		appUsersMap[appUser.Uuid] = appUser
+		myAlertRules := allAlertRules[appUser.Uuid]
+		if !alertRuleUsers[appUser.Uuid] {
+			myAlertRules = appUser.LegacyAlertRules()
+		}
+		gsMatchesMap := make(map[string]gasStationWithPrice)
+		for _, alertRule := range myAlertRules {
+			for _, hit := range batchIndex.InRadius(alertRule.Latitude, alertRule.Longitude, alertRule.SearchRadius) {
+				myGasStationWithPrice, found := gsMatchesMap[hit.ID]
+				if !found {
+					myGasStationWithPrice = gasStationWithPricesMap[hit.ID]
+					myGasStationWithPrice.alertRuleNames = []string{}
+					myGasStationWithPrice.matchedPrices = make(map[gsmodel.FuelType]int)
+				}
+				myLastSeenKey := lastSeenKey{alertRule.ID, hit.ID}
+				price, matches := matchesAlertRule(alertRule, myGasStationWithPrice, now, referencePrices, lastSeenPrices[myLastSeenKey])
+				//every evaluated price is the reference of the next one, the first one only sets it
+				if price > 10 && alertRule.ID > 0 && alertRule.Reference() == aumodel.LastSeenReference {
+					newLastSeenPrices = append(newLastSeenPrices, aumodel.AlertRuleLastSeen{AlertRuleID: alertRule.ID, GasStationID: hit.ID, Price: price, SeenAt: now})
+				}
+				if matches {
+					myGasStationWithPrice.alertRuleNames = append(myGasStationWithPrice.alertRuleNames, alertRule.Name)
+					fuelType, _ := gsmodel.ParseFuelType(alertRule.FuelType)
+					myGasStationWithPrice.matchedPrices[fuelType] = price
+					gsMatchesMap[hit.ID] = myGasStationWithPrice
 				}
 			}
 		}
+		gsMatches := []gasStationWithPrice{}
+		for _, myGasStationWithPrice := range gsMatchesMap {
+			gsMatches = append(gsMatches, myGasStationWithPrice)
+		}
+		gsMatches = filter.apply(appUser, gsMatches)
 		if len(gsMatches) > 0 {
 			myTitle := "Gas price matches found."
 			myMessage := ""
 			myDatas := []NotificationData{}
 			for _, gsMatch := range gsMatches {
-				myMessage = myMessage + fmt.Sprintf("Location: %v, E5: %v, E10: %v, Diesel: %v\n", gsMatch.gasStation.Place, (float64(gsMatch.gasPrice.E5)/1000), (float64(gsMatch.gasPrice.E10)/1000), (float64(gsMatch.gasPrice.Diesel)/1000))
-				myNotificationData := NotificationData{GasStationID: gsMatch.gasStation.ID, StationName: gsMatch.gasStation.StationName, Brand: gsMatch.gasStation.Brand,
-					Street: gsMatch.gasStation.Street, Place: gsMatch.gasStation.Place, HouseNumber: gsMatch.gasStation.HouseNumber, PostCode: gsMatch.gasStation.PostCode,
-					Latitude: gsMatch.gasStation.Latitude, Longitude: gsMatch.gasStation.Longitude, Timestamp: time.Now(), E5: gsMatch.gasPrice.E5, E10: gsMatch.gasPrice.E10,
-					Diesel: gsMatch.gasPrice.Diesel}
+				myNotificationData := newNotificationData(gsMatch, now)
+				myMessage = myMessage + notificationLine(myNotificationData)
 				myDatas = append(myDatas, myNotificationData)
 			}
 			myDataJson, err := json.Marshal(myDatas)
@@ -93,5 +159,71 @@ func SendNotifications(gasStationIDToGasPriceMapPtr *map[string]gsmodel.GasPrice
 			myNotificationMsgs = append(myNotificationMsgs, myNotificationMsg)
 		}
 	}
-	StoreNotifications(&myNotificationMsgs)
+	if _, err := storeNotifications(&myNotificationMsgs, filter, newDeliveryItems(appUsersMap)); err != nil {
+		return err
+	}
+	appuser.StoreLastSeenPrices(newLastSeenPrices)
+	WakeOutboxDispatcher()
+	return nil
+}
+
+func newNotificationData(gsMatch gasStationWithPrice, now time.Time) NotificationData {
+	return NotificationData{GasStationID: gsMatch.gasStation.ID, StationName: gsMatch.gasStation.StationName, Brand: gsMatch.gasStation.Brand,
+		Street: gsMatch.gasStation.Street, Place: gsMatch.gasStation.Place, HouseNumber: gsMatch.gasStation.HouseNumber, PostCode: gsMatch.gasStation.PostCode,
+		Latitude: gsMatch.gasStation.Latitude, Longitude: gsMatch.gasStation.Longitude, Timestamp: now, E5: gsMatch.gasPrice.E5, E10: gsMatch.gasPrice.E10,
+		Diesel: gsMatch.gasPrice.Diesel, OpeningState: gsMatch.gasStation.OpeningState, AlertRules: gsMatch.alertRuleNames}
+}
+
+func notificationLine(myNotificationData NotificationData) string {
+	return fmt.Sprintf("Location: %v, E5: %v, E10: %v, Diesel: %v\n", myNotificationData.Place, (float64(myNotificationData.E5) / 1000),
+		(float64(myNotificationData.E10) / 1000), (float64(myNotificationData.Diesel) / 1000))
+}
+
+// returns the price of the station that was evaluated for the rule, 0 if the rule does not apply to the station
+func matchesAlertRule(alertRule aumodel.AlertRule, myGasStationWithPrice gasStationWithPrice, now time.Time, referencePrices ReferencePriceProvider,
+	lastSeenPrice int) (int, bool) {
+	if !alertRule.IsActiveAt(now) || !alertRule.MatchesBrand(myGasStationWithPrice.gasStation.Brand) {
+		return 0, false
+	}
+	fuelType, err := gsmodel.ParseFuelType(alertRule.FuelType)
+	if err != nil {
+		return 0, false
+	}
+	//Type available?
+	price := myGasStationWithPrice.gasPrice.PriceOf(fuelType)
+	if price <= 10 {
+		return 0, false
+	}
+	//Distance match?
+	distance, _ := myGasStationWithPrice.gasStation.CalcDistanceBearing(alertRule.Latitude, alertRule.Longitude)
+	if alertRule.SearchRadius < distance {
+		return 0, false
+	}
+	//Target price reached? For relative rules the target price is an optional upper limit.
+	if alertRule.TargetPrice > 0 && alertRule.TargetPrice < price {
+		return price, false
+	}
+	referencePrice := 0
+	switch alertRule.Reference() {
+	case aumodel.AbsoluteReference:
+		return price, alertRule.TargetPrice > 0
+	case aumodel.AreaMedianReference:
+		referenceRadius := alertRule.ReferenceRadius
+		if referenceRadius <= 0.0 {
+			referenceRadius = alertRule.SearchRadius
+		}
+		referencePrice = referencePrices.AreaMedian(alertRule.Latitude, alertRule.Longitude, referenceRadius, fuelType)
+	case aumodel.TrailingAverageReference:
+		referencePrice = referencePrices.TrailingAverage(myGasStationWithPrice.gasStation.ID, fuelType, trailingAverageDays)
+	case aumodel.LastSeenReference:
+		//the first price of the station has no reference, an unchanged price is no drop
+		if price >= lastSeenPrice {
+			return price, false
+		}
+		referencePrice = lastSeenPrice
+	}
+	if referencePrice <= 10 {
+		return price, false
+	}
+	return price, price <= referencePrice-alertRule.MinDifference
 }
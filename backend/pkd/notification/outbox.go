@@ -0,0 +1,179 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"react-and-go/pkd/config"
+	unmodel "react-and-go/pkd/notification/model"
+	"sync"
+	"time"
+)
+
+const (
+	outboxBatchSize    = 100
+	outboxErrorLength  = 1000
+	outboxCleanupEvery = time.Hour
+)
+
+// processes the claimed items of one kind and returns the errors of the failed items by id, the other items are done
+type OutboxHandler func(outboxItems []unmodel.OutboxItem) map[int64]error
+
+// a handler returns it for the items it did not start before their lease expired, they are due again without a used attempt
+var ErrOutboxNotProcessed = errors.New("outbox item not processed before its lease expired")
+
+var outboxHandlers = make(map[string]OutboxHandler)
+var outboxHandlersMutex sync.RWMutex
+var outboxWakeup = make(chan struct{}, 1)
+var outboxStop chan struct{}
+var outboxDone chan struct{}
+var outboxMutex sync.Mutex
+
+func SetOutboxHandler(kind string, handler OutboxHandler) {
+	outboxHandlersMutex.Lock()
+	defer outboxHandlersMutex.Unlock()
+	outboxHandlers[kind] = handler
+}
+
+// never blocks, the dispatcher polls the outbox anyway
+func WakeOutboxDispatcher() {
+	select {
+	case outboxWakeup <- struct{}{}:
+	default:
+	}
+}
+
+func OutboxStatusCounts() map[unmodel.OutboxStatus]int64 {
+	return outboxRepository.CountByStatus()
+}
+
+func StartOutboxDispatcher() {
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+	if outboxStop != nil {
+		return
+	}
+	outboxStop = make(chan struct{})
+	outboxDone = make(chan struct{})
+	go runOutboxDispatcher(outboxStop, outboxDone)
+}
+
+// processes the due items before it returns
+func StopOutboxDispatcher() {
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+	if outboxStop == nil {
+		return
+	}
+	close(outboxStop)
+	select {
+	case <-outboxDone:
+	case <-time.After(time.Duration(config.ReadIntEnv("NOTIFICATION_OUTBOX_DRAIN_SECONDS", 30)+5) * time.Second):
+		log.Printf("Outbox dispatcher not stopped: %v\n", OutboxStatusCounts())
+	}
+	outboxStop = nil
+}
+
+func runOutboxDispatcher(stop chan struct{}, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Duration(config.ReadIntEnv("NOTIFICATION_OUTBOX_POLL_SECONDS", 5)) * time.Second)
+	defer ticker.Stop()
+	lastCleanup := time.Now()
+	for {
+		select {
+		case <-stop:
+			deadline := time.Now().Add(time.Duration(config.ReadIntEnv("NOTIFICATION_OUTBOX_DRAIN_SECONDS", 30)) * time.Second)
+			for time.Now().Before(deadline) && dispatchOutbox() > 0 {
+			}
+			log.Printf("Outbox drained: %v\n", OutboxStatusCounts())
+			return
+		case <-ticker.C:
+		case <-outboxWakeup:
+		}
+		for dispatchOutbox() > 0 {
+		}
+		if time.Since(lastCleanup) > outboxCleanupEvery {
+			lastCleanup = time.Now()
+			keepHours := config.ReadIntEnv("NOTIFICATION_OUTBOX_KEEP_HOURS", 24)
+			if _, err := outboxRepository.DeleteDoneBefore(time.Now().Add(time.Duration(-keepHours) * time.Hour)); err != nil {
+				log.Printf("Outbox cleanup failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// one round of the due items, returns the number of claimed items
+func dispatchOutbox() int {
+	now := time.Now()
+	leaseUntil := now.Add(time.Duration(config.ReadIntEnv("NOTIFICATION_OUTBOX_LEASE_SECONDS", 120)) * time.Second)
+	outboxItems := outboxRepository.ClaimDue(now, leaseUntil, outboxBatchSize)
+	kindToOutboxItems := make(map[string][]unmodel.OutboxItem)
+	for _, outboxItem := range outboxItems {
+		kindToOutboxItems[outboxItem.Kind] = append(kindToOutboxItems[outboxItem.Kind], outboxItem)
+	}
+	for kind, myOutboxItems := range kindToOutboxItems {
+		outboxHandlersMutex.RLock()
+		handler, found := outboxHandlers[kind]
+		outboxHandlersMutex.RUnlock()
+		idToError := make(map[int64]error)
+		if found {
+			idToError = handler(myOutboxItems)
+		} else {
+			for _, outboxItem := range myOutboxItems {
+				idToError[outboxItem.ID] = fmt.Errorf("no outbox handler for kind: %v", kind)
+			}
+		}
+		for _, outboxItem := range myOutboxItems {
+			finishOutboxItem(outboxItem, idToError[outboxItem.ID])
+		}
+	}
+	return len(outboxItems)
+}
+
+// the deliveries have their own retry settings
+func outboxRetryPolicy(kind string) (int, time.Duration) {
+	if kind == unmodel.DeliverNotificationKind {
+		return config.ReadIntEnv("NOTIFICATION_MAX_ATTEMPTS", 3), time.Duration(config.ReadIntEnv("NOTIFICATION_BACKOFF_SECONDS", 10)) * time.Second
+	}
+	return config.ReadIntEnv("NOTIFICATION_OUTBOX_MAX_ATTEMPTS", 5), time.Duration(config.ReadIntEnv("NOTIFICATION_OUTBOX_BACKOFF_SECONDS", 10)) * time.Second
+}
+
+func finishOutboxItem(outboxItem unmodel.OutboxItem, err error) {
+	claimedAttempts := outboxItem.Attempts
+	maxAttempts, backoff := outboxRetryPolicy(outboxItem.Kind)
+	if errors.Is(err, ErrOutboxNotProcessed) {
+		outboxItem.Attempts--
+		outboxItem.NextAttemptAt = time.Now()
+	} else if err == nil {
+		outboxItem.Status = unmodel.OutboxDone
+		outboxItem.LastError = ""
+	} else {
+		log.Printf("Outbox item: %v attempt: %v failed: %v\n", outboxItem.ID, outboxItem.Attempts, err)
+		outboxItem.LastError = err.Error()
+		if len(outboxItem.LastError) > outboxErrorLength {
+			outboxItem.LastError = outboxItem.LastError[:outboxErrorLength]
+		}
+		if outboxItem.Attempts >= maxAttempts {
+			outboxItem.Status = unmodel.OutboxFailed
+		} else {
+			outboxItem.NextAttemptAt = time.Now().Add(backoff * time.Duration(1<<(outboxItem.Attempts-1)))
+		}
+	}
+	if finished, err := outboxRepository.Finish(outboxItem, claimedAttempts); err != nil {
+		log.Printf("Outbox item: %v update failed: %v\n", outboxItem.ID, err)
+	} else if !finished {
+		log.Printf("Outbox item: %v was claimed again after the lease of attempt: %v\n", outboxItem.ID, outboxItem.Attempts)
+	}
+}
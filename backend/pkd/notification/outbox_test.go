@@ -0,0 +1,108 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package notification_test
+
+import (
+	"errors"
+	"os"
+	"react-and-go/pkd/app"
+	"react-and-go/pkd/notification"
+	unmodel "react-and-go/pkd/notification/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testOutboxKind = "test"
+
+// the stop drains the due items
+func runOutboxDispatcher() {
+	notification.StartOutboxDispatcher()
+	notification.StopOutboxDispatcher()
+}
+
+func TestOutboxRetriesFailedItems(t *testing.T) {
+	os.Setenv("NOTIFICATION_OUTBOX_BACKOFF_SECONDS", "1")
+	os.Setenv("NOTIFICATION_OUTBOX_MAX_ATTEMPTS", "2")
+	defer os.Unsetenv("NOTIFICATION_OUTBOX_BACKOFF_SECONDS")
+	defer os.Unsetenv("NOTIFICATION_OUTBOX_MAX_ATTEMPTS")
+	myApp := app.NewInMemoryApp()
+	myApp.Wire()
+	var mutex sync.Mutex
+	idToCalls := make(map[int64]int)
+	notification.SetOutboxHandler(testOutboxKind, func(outboxItems []unmodel.OutboxItem) map[int64]error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		idToError := make(map[int64]error)
+		for _, outboxItem := range outboxItems {
+			idToCalls[outboxItem.ID]++
+			// the payload tells the handler when to succeed
+			if outboxItem.Payload == "fail" || idToCalls[outboxItem.ID] == 1 {
+				idToError[outboxItem.ID] = errors.New("receiver not available")
+			}
+		}
+		return idToError
+	})
+	outboxItems := []unmodel.OutboxItem{{Kind: testOutboxKind, Payload: "fail", Status: unmodel.OutboxPending, NextAttemptAt: time.Now()},
+		{Kind: testOutboxKind, Payload: "retry", Status: unmodel.OutboxPending, NextAttemptAt: time.Now()}}
+	for index := range outboxItems {
+		if err := myApp.OutboxRepository.Save(&outboxItems[index]); err != nil {
+			t.Fatalf("Save outbox item failed: %v", err)
+		}
+	}
+	runOutboxDispatcher()
+	if counts := notification.OutboxStatusCounts(); counts[unmodel.OutboxPending] != 2 {
+		t.Fatalf("Failed items not pending: %v", counts)
+	}
+	// the items are due again after the backoff
+	runOutboxDispatcher()
+	if counts := notification.OutboxStatusCounts(); counts[unmodel.OutboxPending] != 2 {
+		t.Fatalf("Items retried before the backoff: %v", counts)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	runOutboxDispatcher()
+	counts := notification.OutboxStatusCounts()
+	if counts[unmodel.OutboxDone] != 1 || counts[unmodel.OutboxFailed] != 1 {
+		t.Errorf("Status counts: %v", counts)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, outboxItem := range outboxItems {
+		if idToCalls[outboxItem.ID] != 2 {
+			t.Errorf("Outbox item: %v calls: %v want: 2", outboxItem.Payload, idToCalls[outboxItem.ID])
+		}
+	}
+}
+
+func TestOutboxRetriesNotProcessedItemsAtOnce(t *testing.T) {
+	myApp := app.NewInMemoryApp()
+	myApp.Wire()
+	calls := 0
+	notification.SetOutboxHandler(testOutboxKind, func(outboxItems []unmodel.OutboxItem) map[int64]error {
+		idToError := make(map[int64]error)
+		for _, outboxItem := range outboxItems {
+			calls++
+			if calls == 1 {
+				idToError[outboxItem.ID] = notification.ErrOutboxNotProcessed
+			}
+		}
+		return idToError
+	})
+	if err := myApp.OutboxRepository.Save(&unmodel.OutboxItem{Kind: testOutboxKind, Status: unmodel.OutboxPending, NextAttemptAt: time.Now()}); err != nil {
+		t.Fatalf("Save outbox item failed: %v", err)
+	}
+	runOutboxDispatcher()
+	if counts := notification.OutboxStatusCounts(); counts[unmodel.OutboxDone] != 1 || calls != 2 {
+		t.Errorf("Status counts: %v calls: %v", counts, calls)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"react-and-go/pkd/appuser/aumodel"
+	"react-and-go/pkd/config"
+	"react-and-go/pkd/gasstation/gsmodel"
+	unmodel "react-and-go/pkd/notification/model"
+	"time"
+)
+
+// decides per user which matches of a price batch are notified now, deferred to a digest or suppressed
+type notificationFilter struct {
+	now                time.Time
+	defaultCooldown    time.Duration
+	dropResetAfter     time.Duration
+	notificationStates map[string]unmodel.NotificationState
+	changedStates      []unmodel.NotificationState
+	suppressions       []unmodel.NotificationSuppression
+	digestEntries      []unmodel.DigestEntry
+}
+
+func newNotificationFilter(stids []string, now time.Time) *notificationFilter {
+	filter := &notificationFilter{now: now, defaultCooldown: time.Duration(config.ReadIntEnv("NOTIFICATION_COOLDOWN_MINUTES", 60)) * time.Minute,
+		dropResetAfter: time.Duration(config.ReadIntEnv("NOTIFICATION_DROP_RESET_HOURS", 24)) * time.Hour, notificationStates: make(map[string]unmodel.NotificationState)}
+	if len(stids) > 0 {
+		for _, notificationState := range notificationRepository.FindStates(stids) {
+			filter.notificationStates[stateKey(notificationState.UserUuid, notificationState.GasStationID, notificationState.FuelType)] = notificationState
+		}
+	}
+	return filter
+}
+
+func stateKey(userUuid string, stid string, fuelType string) string {
+	return fmt.Sprintf("%v|%v|%v", userUuid, stid, fuelType)
+}
+
+// returns the matches to notify now
+func (filter *notificationFilter) apply(appUser aumodel.AppUser, gsMatches []gasStationWithPrice) []gasStationWithPrice {
+	cooldown := filter.defaultCooldown
+	if appUser.NotificationCooldown > 0 {
+		cooldown = time.Duration(appUser.NotificationCooldown) * time.Minute
+	}
+	result := []gasStationWithPrice{}
+	for _, gsMatch := range gsMatches {
+		stid := gsMatch.gasStation.ID
+		// the cooldown is per station, the price drop per fuel
+		lastNotifiedAt := time.Time{}
+		for _, fuelType := range gsmodel.FuelTypes {
+			if notificationState, found := filter.notificationStates[stateKey(appUser.Uuid, stid, string(fuelType))]; found && notificationState.NotifiedAt.After(lastNotifiedAt) {
+				lastNotifiedAt = notificationState.NotifiedAt
+			}
+		}
+		notify := false
+		for fuelType, price := range gsMatch.matchedPrices {
+			key := stateKey(appUser.Uuid, stid, string(fuelType))
+			notificationState, found := filter.notificationStates[key]
+			if filter.now.Sub(lastNotifiedAt) < cooldown {
+				filter.suppress(appUser, stid, fuelType, price, unmodel.CooldownReason)
+				continue
+			}
+			if found && !appUser.RepeatUnchanged && filter.now.Sub(notificationState.NotifiedAt) < filter.dropResetAfter && price >= notificationState.Price {
+				filter.suppress(appUser, stid, fuelType, price, unmodel.NoFurtherDropReason)
+				continue
+			}
+			notify = true
+			notificationState = unmodel.NotificationState{UserUuid: appUser.Uuid, GasStationID: stid, FuelType: string(fuelType), Price: price, NotifiedAt: filter.now}
+			filter.notificationStates[key] = notificationState
+			filter.changedStates = append(filter.changedStates, notificationState)
+		}
+		if notify {
+			result = append(result, gsMatch)
+		}
+	}
+	if len(result) == 0 {
+		return result
+	}
+	var reason unmodel.SuppressionReason
+	if appUser.InQuietHours(filter.now) {
+		reason = unmodel.QuietHoursReason
+	} else if appUser.Digest() != aumodel.NoDigest {
+		reason = unmodel.DigestReason
+	} else {
+		return result
+	}
+	for _, gsMatch := range result {
+		dataJson, err := json.Marshal(newNotificationData(gsMatch, filter.now))
+		if err != nil {
+			log.Printf("Json marshal failed: %v", err)
+			continue
+		}
+		filter.digestEntries = append(filter.digestEntries, unmodel.DigestEntry{Timestamp: filter.now, UserUuid: appUser.Uuid,
+			GasStationID: gsMatch.gasStation.ID, DataJson: string(dataJson)})
+		for fuelType, price := range gsMatch.matchedPrices {
+			filter.suppress(appUser, gsMatch.gasStation.ID, fuelType, price, reason)
+		}
+	}
+	return []gasStationWithPrice{}
+}
+
+func (filter *notificationFilter) suppress(appUser aumodel.AppUser, stid string, fuelType gsmodel.FuelType, price int, reason unmodel.SuppressionReason) {
+	filter.suppressions = append(filter.suppressions, unmodel.NotificationSuppression{Timestamp: filter.now, UserUuid: appUser.Uuid, GasStationID: stid,
+		FuelType: string(fuelType), Price: price, Reason: reason})
+}
+
+func FindSuppressions(userUuid string) []unmodel.NotificationSuppression {
+	return notificationRepository.FindSuppressions(userUuid, 100)
+}
+
+func DeleteOldSuppressions() {
+	before := time.Now().AddDate(0, 0, -config.ReadIntEnv("NOTIFICATION_SUPPRESSION_DAYS", 7))
+	if deleted, err := notificationRepository.DeleteSuppressionsBefore(before); err != nil {
+		log.Printf("Delete suppressions failed: %v\n", err)
+	} else {
+		log.Printf("Suppressions deleted: %v\n", deleted)
+	}
+}
@@ -14,11 +14,11 @@ package notification
 
 import (
 	"log"
-	"react-and-go/pkd/database"
+	"math"
 	unmodel "react-and-go/pkd/notification/model"
+	"react-and-go/pkd/pubsub"
+	"react-and-go/pkd/repository"
 	"time"
-
-	"gorm.io/gorm"
 )
 
 type NotificationMsg struct {
@@ -28,43 +28,69 @@ type NotificationMsg struct {
 	DataJson string
 }
 
-func StoreNotifications(notificationMsgs *[]NotificationMsg) {
-	database.DB.Transaction(func(tx *gorm.DB) error {
-		for _, notificationMsg := range *notificationMsgs {
-			log.Printf("%v\n", notificationMsg.Title)
-			myUserNotification := unmodel.UserNotification{Timestamp: time.Now(), UserUuid: notificationMsg.UserUuid,
-				Title: notificationMsg.Title, Message: notificationMsg.Message, DataJson: notificationMsg.DataJson, NotificationSend: false}
-			tx.Save(&myUserNotification)
-		}
-		return nil
-	})
+const maxNotificationListSize = 10
+
+var notificationRepository repository.NotificationRepository
+var outboxRepository repository.OutboxRepository
+
+func SetRepositories(myNotificationRepository repository.NotificationRepository, myOutboxRepository repository.OutboxRepository) {
+	notificationRepository = myNotificationRepository
+	outboxRepository = myOutboxRepository
 }
 
+func StoreNotifications(notificationMsgs *[]NotificationMsg) []unmodel.UserNotification {
+	result, err := storeNotifications(notificationMsgs, &notificationFilter{}, nil)
+	if err != nil {
+		log.Printf("Store notifications failed: %v\n", err)
+	}
+	return result
+}
+
+// the notifications are stored with the notification states, suppressions and digest entries of the filter and the delivery items
+func storeNotifications(notificationMsgs *[]NotificationMsg, filter *notificationFilter,
+	newOutboxItems func(savedNotifications []unmodel.UserNotification) ([]unmodel.OutboxItem, error)) ([]unmodel.UserNotification, error) {
+	myUserNotifications := []unmodel.UserNotification{}
+	for _, notificationMsg := range *notificationMsgs {
+		log.Printf("%v\n", notificationMsg.Title)
+		myUserNotifications = append(myUserNotifications, unmodel.UserNotification{Timestamp: time.Now(), UserUuid: notificationMsg.UserUuid,
+			Title: notificationMsg.Title, Message: notificationMsg.Message, DataJson: notificationMsg.DataJson, NotificationSend: false})
+	}
+	result, err := notificationRepository.SaveEvaluation(myUserNotifications, filter.changedStates, filter.suppressions, filter.digestEntries,
+		newOutboxItems)
+	if err != nil {
+		return []unmodel.UserNotification{}, err
+	}
+	for index := range result {
+		pubsub.Publish(pubsub.Event{Type: pubsub.NotificationEventType, Notification: &result[index]})
+	}
+	return result, nil
+}
+
+// the new notifications are marked sent, the list of all notifications shows the newest
 func LoadNotifications(userUuid string, newNotifications bool) []unmodel.UserNotification {
-	var userNotifications []unmodel.UserNotification
-	if newNotifications {
-		database.DB.Transaction(func(tx *gorm.DB) error {
-			tx.Where("user_uuid = ? and notification_send = ?", userUuid, !newNotifications).Order("timestamp desc").Find(&userNotifications)
-			for _, userNotification := range userNotifications {
-				userNotification.NotificationSend = true
-				tx.Save(&userNotification)
-			}
-			return nil
-		})
-	} else {
-		database.DB.Transaction(func(tx *gorm.DB) error {
-			tx.Where("user_uuid = ?", userUuid).Order("timestamp desc").Find(&userNotifications)
-			var myUserNotifications []unmodel.UserNotification
-			for index, userNotification := range userNotifications {
-				if index < 10 {
-					myUserNotifications = append(myUserNotifications, userNotification)
-					continue
-				}
-				tx.Delete(&userNotification)
-			}
-			userNotifications = myUserNotifications
-			return nil
-		})
+	if !newNotifications {
+		return notificationRepository.FindByUserUuid(userUuid, false, maxNotificationListSize)
+	}
+	userNotifications := notificationRepository.FindByUserUuid(userUuid, true, math.MaxInt32)
+	ids := []int64{}
+	for _, userNotification := range userNotifications {
+		ids = append(ids, userNotification.ID)
+	}
+	if err := notificationRepository.MarkSent(ids); err != nil {
+		log.Printf("Mark notifications sent failed: %v\n", err)
 	}
 	return userNotifications
 }
+
+type UserDataExport struct {
+	Notifications []unmodel.UserNotification
+	Suppressions  []unmodel.NotificationSuppression
+	DigestEntries []unmodel.DigestEntry
+}
+
+// registered with appuser.AddUserDataHandler for the export before an account is deleted
+func ExportUserData(userUuid string) (interface{}, error) {
+	return UserDataExport{Notifications: notificationRepository.FindByUserUuid(userUuid, false, math.MaxInt32),
+		Suppressions:  notificationRepository.FindSuppressions(userUuid, math.MaxInt32),
+		DigestEntries: notificationRepository.FindDigestEntriesByUserUuid(userUuid)}, nil
+}
@@ -0,0 +1,43 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package notification_test
+
+import (
+	"fmt"
+	"math"
+	"react-and-go/pkd/app"
+	"react-and-go/pkd/notification"
+	"testing"
+)
+
+func TestLoadNotificationsKeepsOlderNotifications(t *testing.T) {
+	myApp := app.NewInMemoryApp()
+	myApp.Wire()
+	notificationMsgs := []notification.NotificationMsg{}
+	for index := 0; index < 12; index++ {
+		notificationMsgs = append(notificationMsgs, notification.NotificationMsg{UserUuid: "user1", Title: fmt.Sprintf("Title %v", index), DataJson: "[]"})
+	}
+	notification.StoreNotifications(&notificationMsgs)
+	if userNotifications := notification.LoadNotifications("user1", true); len(userNotifications) != 12 {
+		t.Errorf("New notifications: %v want: 12", len(userNotifications))
+	}
+	if userNotifications := notification.LoadNotifications("user1", true); len(userNotifications) != 0 {
+		t.Errorf("New notifications after mark sent: %v want: 0", len(userNotifications))
+	}
+	if userNotifications := notification.LoadNotifications("user1", false); len(userNotifications) != 10 {
+		t.Errorf("Listed notifications: %v want: 10", len(userNotifications))
+	}
+	if userNotifications := myApp.NotificationRepository.FindByUserUuid("user1", false, math.MaxInt32); len(userNotifications) != 12 {
+		t.Errorf("Stored notifications: %v want: 12", len(userNotifications))
+	}
+}
@@ -0,0 +1,120 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package poller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"react-and-go/pkd/poller/pomodel"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var apiKeyEnvRegex = regexp.MustCompile(`^APIKEY(\d+)$`)
+
+type keyLimits struct {
+	minInterval time.Duration // between 2 requests of a key
+	dailyLimit  int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+}
+
+type apiKey struct {
+	key   string
+	state pomodel.PollKeyState
+}
+
+// the values of APIKEY1, APIKEY2, ... in the order of the numbers, duplicates are removed
+func readApiKeys() []string {
+	numberToKey := make(map[int]string)
+	numbers := []int{}
+	for _, envEntry := range os.Environ() {
+		envName, envValue, _ := strings.Cut(envEntry, "=")
+		matches := apiKeyEnvRegex.FindStringSubmatch(envName)
+		if matches == nil || len(strings.TrimSpace(envValue)) == 0 {
+			continue
+		}
+		number, _ := strconv.Atoi(matches[1])
+		numberToKey[number] = strings.TrimSpace(envValue)
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	result := []string{}
+	keys := make(map[string]bool)
+	for _, number := range numbers {
+		if !keys[numberToKey[number]] {
+			keys[numberToKey[number]] = true
+			result = append(result, numberToKey[number])
+		}
+	}
+	return result
+}
+
+// the key is not stored, the state is found with the hash
+func keyId(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func (myApiKey *apiKey) availableAt(limits keyLimits, now time.Time) time.Time {
+	result := myApiKey.state.LastRequest.Add(limits.minInterval)
+	if myApiKey.state.BackoffUntil.After(result) {
+		result = myApiKey.state.BackoffUntil
+	}
+	if myApiKey.state.Day == utcDay(now) && myApiKey.state.RequestCount >= limits.dailyLimit {
+		nextDay := time.Date(now.UTC().Year(), now.UTC().Month(), now.UTC().Day()+1, 0, 0, 0, 0, time.UTC)
+		if nextDay.After(result) {
+			result = nextDay
+		}
+	}
+	return result
+}
+
+func (myApiKey *apiKey) recordRequest(now time.Time) {
+	if myApiKey.state.Day != utcDay(now) {
+		myApiKey.state.Day = utcDay(now)
+		myApiKey.state.RequestCount = 0
+	}
+	myApiKey.state.RequestCount++
+	myApiKey.state.LastRequest = now
+}
+
+func (myApiKey *apiKey) recordSuccess() {
+	myApiKey.state.BackoffSeconds = 0
+}
+
+// the backoff doubles with every 429 or 503 until a request succeeds
+func (myApiKey *apiKey) recordBackoff(limits keyLimits, retryAfter time.Duration, now time.Time) {
+	backoff := time.Duration(myApiKey.state.BackoffSeconds) * time.Second * 2
+	if backoff < limits.minBackoff {
+		backoff = limits.minBackoff
+	}
+	if backoff > limits.maxBackoff {
+		backoff = limits.maxBackoff
+	}
+	if retryAfter > backoff {
+		backoff = retryAfter
+	}
+	myApiKey.state.BackoffSeconds = int(backoff / time.Second)
+	if backoffUntil := now.Add(backoff); backoffUntil.After(myApiKey.state.BackoffUntil) {
+		myApiKey.state.BackoffUntil = backoffUntil
+	}
+}
+
+func utcDay(at time.Time) string {
+	return at.UTC().Format("2006-01-02")
+}
@@ -0,0 +1,96 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package poller
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"react-and-go/pkd/gasstation/gsindex"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const fakeListUsage = "usage: fakelist [address] [tooManyRequestsEvery]"
+
+// serves list.php with random prices for the stations in the radius, every tooManyRequestsEvery request is answered with 429
+func NewFakeListHandler(gasStations []gsmodel.GasStation, tooManyRequestsEvery int) http.Handler {
+	stationIndex := gsindex.NewIndex()
+	stationIndex.Load(gasStations)
+	var requestCounter int64
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc("/json/list.php", func(writer http.ResponseWriter, request *http.Request) {
+		requestNumber := atomic.AddInt64(&requestCounter, 1)
+		if tooManyRequestsEvery > 0 && requestNumber%int64(tooManyRequestsEvery) == 0 {
+			writer.Header().Set("Retry-After", "60")
+			writer.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		latitude, latErr := strconv.ParseFloat(request.URL.Query().Get("lat"), 64)
+		longitude, lngErr := strconv.ParseFloat(request.URL.Query().Get("lng"), 64)
+		radiusKm, radErr := strconv.ParseFloat(request.URL.Query().Get("rad"), 64)
+		myListResponse := listResponse{Ok: true, License: "CC BY 4.0 - https://creativecommons.tankerkoenig.de", Data: "FAKE", Status: "ok", Stations: []listStation{}}
+		if latErr != nil || lngErr != nil || radErr != nil || radiusKm <= 0.0 || radiusKm > maxRadiusKm {
+			myListResponse = listResponse{Ok: false, Status: "error", Message: "parameter error"}
+		} else if len(strings.TrimSpace(request.URL.Query().Get("apikey"))) != 36 {
+			myListResponse = listResponse{Ok: false, Status: "error", Message: "apikey nicht angegeben, falsch, oder im falschen Format"}
+		}
+		if myListResponse.Ok {
+			for _, hit := range stationIndex.InRadius(latitude, longitude, radiusKm) {
+				myListResponse.Stations = append(myListResponse.Stations, listStation{Id: hit.ID, Lat: hit.Latitude, Lng: hit.Longitude, Dist: hit.Distance,
+					Diesel: fakePrice(1.65), E5: fakePrice(1.80), E10: fakePrice(1.74), IsOpen: true})
+			}
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(writer).Encode(&myListResponse); err != nil {
+			log.Printf("Fake list response failed: %v\n", err)
+		}
+	})
+	return serveMux
+}
+
+// the price in euro with 3 decimals
+func fakePrice(basePrice float64) listPrice {
+	return listPrice(float64(int((basePrice+rand.Float64()*0.2)*1000)) / 1000)
+}
+
+// serves the fake list.php until the process is stopped, returns the exit code
+func RunFakeList(args []string, gasStations []gsmodel.GasStation) int {
+	address := ":3001"
+	tooManyRequestsEvery := 0
+	if len(args) > 2 {
+		fmt.Fprintln(os.Stderr, fakeListUsage)
+		return 2
+	}
+	if len(args) > 0 {
+		address = args[0]
+	}
+	if len(args) > 1 {
+		var err error
+		if tooManyRequestsEvery, err = strconv.Atoi(args[1]); err != nil || tooManyRequestsEvery < 0 {
+			fmt.Fprintln(os.Stderr, fakeListUsage)
+			return 2
+		}
+	}
+	log.Printf("Fake list.php with %v stations at: http://%v/json/list.php\n", len(gasStations), address)
+	if err := http.ListenAndServe(address, NewFakeListHandler(gasStations, tooManyRequestsEvery)); err != nil {
+		fmt.Fprintf(os.Stderr, "fakelist failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
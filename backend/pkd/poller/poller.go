@@ -0,0 +1,254 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package poller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"react-and-go/pkd/config"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/poller/pomodel"
+	"react-and-go/pkd/repository"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// a poll run ends before the next run of the cron job
+const pollDuration = 55 * time.Second
+
+var pollStateRepository repository.PollStateRepository
+var defaultPoller *Poller
+
+func SetRepository(myPollStateRepository repository.PollStateRepository) {
+	pollStateRepository = myPollStateRepository
+}
+
+type Poller struct {
+	regions  []Region
+	apiKeys  []*apiKey
+	limits   keyLimits
+	client   *http.Client
+	listUrl  string
+	stop     chan struct{}
+	stopOnce sync.Once
+	mutex    sync.Mutex
+}
+
+func newPoller(regions []Region, apikeys []string, limits keyLimits, listUrl string) *Poller {
+	keyIdToState := make(map[string]pomodel.PollKeyState)
+	for _, keyState := range pollStateRepository.FindKeyStates() {
+		keyIdToState[keyState.KeyId] = keyState
+	}
+	myApiKeys := []*apiKey{}
+	for _, key := range apikeys {
+		keyState, found := keyIdToState[keyId(key)]
+		if !found {
+			keyState = pomodel.PollKeyState{KeyId: keyId(key)}
+		}
+		myApiKeys = append(myApiKeys, &apiKey{key: key, state: keyState})
+	}
+	return &Poller{regions: regions, apiKeys: myApiKeys, limits: limits, client: &http.Client{Timeout: 5 * time.Second}, listUrl: listUrl, stop: make(chan struct{})}
+}
+
+// reads the regions of POLL_REGIONS_FILE and the APIKEYn values, false if the polling is not configured
+func Start() bool {
+	regionsFile := strings.TrimSpace(os.Getenv("POLL_REGIONS_FILE"))
+	apikeys := readApiKeys()
+	if len(regionsFile) == 0 || len(apikeys) == 0 {
+		log.Printf("Price polling disabled, regions file: '%v' api keys: %v\n", regionsFile, len(apikeys))
+		return false
+	}
+	regions, err := LoadRegions(regionsFile)
+	if err != nil {
+		log.Printf("Price polling disabled: %v\n", err)
+		return false
+	}
+	limits := keyLimits{minInterval: time.Duration(config.ReadIntEnv("POLL_KEY_MIN_INTERVAL_SECONDS", 15)) * time.Second, dailyLimit: config.ReadIntEnv("POLL_KEY_DAILY_LIMIT", 1000),
+		minBackoff: 60 * time.Second, maxBackoff: time.Duration(config.ReadIntEnv("POLL_MAX_BACKOFF_SECONDS", 3600)) * time.Second}
+	defaultPoller = newPoller(regions, apikeys, limits, listUrl())
+	log.Printf("Price polling of %v regions with %v api keys from: %v\n", len(regions), len(apikeys), defaultPoller.listUrl)
+	return true
+}
+
+func Poll() {
+	if defaultPoller != nil {
+		defaultPoller.Poll()
+	}
+}
+
+func Stop() {
+	if defaultPoller != nil {
+		defaultPoller.Stop()
+	}
+}
+
+// ends a running poll without waiting for the next api key
+func (poller *Poller) Stop() {
+	poller.stopOnce.Do(func() {
+		close(poller.stop)
+	})
+}
+
+// polls the circles of the due regions until no api key is available, the next run continues with the next circle
+func (poller *Poller) Poll() {
+	if !poller.mutex.TryLock() {
+		return
+	}
+	defer poller.mutex.Unlock()
+	select {
+	case <-poller.stop:
+		return
+	default:
+	}
+	deadline := time.Now().Add(pollDuration)
+	for _, regionState := range poller.dueRegionStates(time.Now()) {
+		region := poller.findRegion(regionState.Name)
+		if regionState.NextCircle == 0 {
+			regionState.RoundStarted = time.Now()
+			regionState.FailedRequests = 0
+		}
+		for regionState.NextCircle < len(region.Circles) {
+			myApiKey := poller.waitForApiKey(deadline)
+			if myApiKey == nil {
+				poller.saveRegionState(&regionState)
+				return
+			}
+			circle := region.Circles[regionState.NextCircle]
+			err := poller.requestPrices(myApiKey, circle, region.RadiusKm)
+			var statusError *StatusError
+			if errors.As(err, &statusError) && statusError.TooManyRequests() {
+				log.Printf("Region %v circle %v: %v, backoff of the api key\n", region.Name, regionState.NextCircle, err)
+				continue
+			}
+			if err != nil {
+				log.Printf("Region %v circle %v failed: %v\n", region.Name, regionState.NextCircle, err)
+				regionState.FailedRequests++
+			}
+			regionState.NextCircle++
+			poller.saveRegionState(&regionState)
+		}
+		regionState.NextCircle = 0
+		regionState.RoundFinished = time.Now()
+		poller.saveRegionState(&regionState)
+		log.Printf("Region %v polled in %v, failed requests: %v\n", region.Name, regionState.RoundFinished.Sub(regionState.RoundStarted).Round(time.Second), regionState.FailedRequests)
+	}
+}
+
+// the started rounds first, then the regions that waited longest
+func (poller *Poller) dueRegionStates(now time.Time) []pomodel.PollRegionState {
+	nameToRegionState := make(map[string]pomodel.PollRegionState)
+	for _, regionState := range pollStateRepository.FindRegionStates() {
+		nameToRegionState[regionState.Name] = regionState
+	}
+	result := []pomodel.PollRegionState{}
+	for _, region := range poller.regions {
+		regionState, found := nameToRegionState[region.Name]
+		if !found {
+			regionState = pomodel.PollRegionState{Name: region.Name}
+		}
+		//the circles of the region have changed
+		if regionState.NextCircle >= len(region.Circles) {
+			regionState.NextCircle = 0
+		}
+		if regionState.NextCircle > 0 || regionState.RoundFinished.Add(time.Duration(region.IntervalMinutes)*time.Minute).Before(now) {
+			result = append(result, regionState)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if (result[i].NextCircle > 0) != (result[j].NextCircle > 0) {
+			return result[i].NextCircle > 0
+		}
+		return result[i].RoundFinished.Before(result[j].RoundFinished)
+	})
+	return result
+}
+
+func (poller *Poller) findRegion(name string) Region {
+	for _, region := range poller.regions {
+		if region.Name == name {
+			return region
+		}
+	}
+	return Region{}
+}
+
+// the api key that is available first, nil if none is available before the deadline or the poller is stopped
+func (poller *Poller) waitForApiKey(deadline time.Time) *apiKey {
+	var result *apiKey
+	var resultAvailableAt time.Time
+	for _, myApiKey := range poller.apiKeys {
+		if availableAt := myApiKey.availableAt(poller.limits, time.Now()); result == nil || availableAt.Before(resultAvailableAt) {
+			result = myApiKey
+			resultAvailableAt = availableAt
+		}
+	}
+	if result == nil || resultAvailableAt.After(deadline) {
+		return nil
+	}
+	select {
+	case <-poller.stop:
+		return nil
+	case <-time.After(time.Until(resultAvailableAt)):
+		return result
+	}
+}
+
+func (poller *Poller) requestPrices(myApiKey *apiKey, circle CircleCenter, radiusKm float64) error {
+	myApiKey.recordRequest(time.Now())
+	myListResponse, err := requestList(poller.client, poller.listUrl, circle.Latitude, circle.Longitude, radiusKm, myApiKey.key)
+	var statusError *StatusError
+	if errors.As(err, &statusError) && statusError.TooManyRequests() {
+		backoffKeys := []*apiKey{myApiKey}
+		//the service is unavailable for all keys
+		if statusError.StatusCode == http.StatusServiceUnavailable {
+			backoffKeys = poller.apiKeys
+		}
+		for _, backoffKey := range backoffKeys {
+			backoffKey.recordBackoff(poller.limits, statusError.RetryAfter, time.Now())
+			poller.saveKeyState(backoffKey)
+		}
+		return err
+	}
+	if err == nil {
+		myApiKey.recordSuccess()
+	}
+	poller.saveKeyState(myApiKey)
+	if err != nil {
+		return err
+	}
+	gasStationPrices := []gasstation.GasStationPrices{}
+	for _, value := range myListResponse.Stations {
+		gasStationPrices = append(gasStationPrices, gasstation.GasStationPrices{GasStationID: value.Id, E5: value.E5.millicents(), E10: value.E10.millicents(),
+			Diesel: value.Diesel.millicents(), Timestamp: time.Now()})
+	}
+	if len(gasStationPrices) > 0 {
+		gasstation.UpdatePrice(&gasStationPrices)
+	}
+	return nil
+}
+
+func (poller *Poller) saveKeyState(myApiKey *apiKey) {
+	if err := pollStateRepository.SaveKeyState(&myApiKey.state); err != nil {
+		log.Printf("Save poll key state failed: %v\n", err)
+	}
+}
+
+func (poller *Poller) saveRegionState(regionState *pomodel.PollRegionState) {
+	if err := pollStateRepository.SaveRegionState(regionState); err != nil {
+		log.Printf("Save poll region state failed: %v\n", err)
+	}
+}
@@ -0,0 +1,34 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package pomodel
+
+import "time"
+
+// the request quota of an api key, the key itself is not stored
+type PollKeyState struct {
+	KeyId          string `gorm:"primaryKey;size:64"` // sha256 of the api key
+	Day            string `gorm:"size:10"`            // 'YYYY-MM-DD' in UTC of the RequestCount
+	RequestCount   int
+	LastRequest    time.Time
+	BackoffUntil   time.Time
+	BackoffSeconds int
+}
+
+// the progress of a region round, a restart continues with the next circle
+type PollRegionState struct {
+	Name           string `gorm:"primaryKey;size:64"`
+	NextCircle     int
+	RoundStarted   time.Time
+	RoundFinished  time.Time
+	FailedRequests int
+}
@@ -0,0 +1,73 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package poller
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+const (
+	maxRadiusKm            = 25.0
+	defaultIntervalMinutes = 30
+)
+
+type CircleCenter struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// the prices of a region are requested circle by circle every IntervalMinutes
+type Region struct {
+	Name            string         `json:"name"`
+	RadiusKm        float64        `json:"radiusKm"`
+	IntervalMinutes int            `json:"intervalMinutes"`
+	Circles         []CircleCenter `json:"circles"`
+}
+
+func LoadRegions(filePath string) ([]Region, error) {
+	jsonFile, err := os.ReadFile(filePath)
+	if err != nil {
+		return []Region{}, err
+	}
+	var regions []Region
+	if err := json.Unmarshal(jsonFile, &regions); err != nil {
+		return []Region{}, fmt.Errorf("regions file %v: %v", filePath, err)
+	}
+	regionNames := make(map[string]bool)
+	for index := range regions {
+		regions[index].Name = strings.TrimSpace(regions[index].Name)
+		if len(regions[index].Name) == 0 || regionNames[regions[index].Name] {
+			return []Region{}, fmt.Errorf("regions file %v: missing or duplicate name: '%v'", filePath, regions[index].Name)
+		}
+		regionNames[regions[index].Name] = true
+		if regions[index].RadiusKm <= 0.0 || regions[index].RadiusKm > maxRadiusKm {
+			regions[index].RadiusKm = maxRadiusKm
+		}
+		if regions[index].IntervalMinutes <= 0 {
+			regions[index].IntervalMinutes = defaultIntervalMinutes
+		}
+		if len(regions[index].Circles) == 0 {
+			return []Region{}, fmt.Errorf("regions file %v: region %v has no circles", filePath, regions[index].Name)
+		}
+		for _, circle := range regions[index].Circles {
+			if math.Abs(circle.Latitude) > 90.0 || math.Abs(circle.Longitude) > 180.0 {
+				return []Region{}, fmt.Errorf("regions file %v: region %v has invalid coordinates", filePath, regions[index].Name)
+			}
+		}
+	}
+	return regions, nil
+}
@@ -0,0 +1,129 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package poller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultListUrl = "https://creativecommons.tankerkoenig.de/json"
+
+type listResponse struct {
+	Ok       bool          `json:"ok"`
+	License  string        `json:"license"`
+	Data     string        `json:"data"`
+	Status   string        `json:"status"`
+	Message  string        `json:"message"`
+	Stations []listStation `json:"stations"`
+}
+
+type listStation struct {
+	Id          string      `json:"id"`
+	Name        string      `json:"name"`
+	Brand       string      `json:"brand"`
+	Street      string      `json:"street"`
+	Place       string      `json:"place"`
+	Lat         float64     `json:"lat"`
+	Lng         float64     `json:"lng"`
+	Dist        float64     `json:"dist"`
+	Diesel      listPrice   `json:"diesel"`
+	E5          listPrice   `json:"e5"`
+	E10         listPrice   `json:"e10"`
+	IsOpen      bool        `json:"isOpen"`
+	HouseNumber string      `json:"houseNumber"`
+	PostCode    json.Number `json:"postCode"`
+}
+
+// the api sends false or null for fuels that are not sold
+type listPrice float64
+
+func (myListPrice *listPrice) UnmarshalJSON(data []byte) error {
+	dataStr := strings.TrimSpace(string(data))
+	if dataStr == "false" || dataStr == "null" {
+		*myListPrice = 0
+		return nil
+	}
+	value, err := strconv.ParseFloat(dataStr, 64)
+	if err != nil {
+		return err
+	}
+	*myListPrice = listPrice(value)
+	return nil
+}
+
+// the price in millicents
+func (myListPrice listPrice) millicents() int {
+	return int(float64(myListPrice)*1000 + 0.5)
+}
+
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // from the Retry-After header, 0 if missing
+}
+
+func (statusError *StatusError) Error() string {
+	return fmt.Sprintf("response status: %v", statusError.StatusCode)
+}
+
+// 429 and 503 are answered with a backoff of the api key
+func (statusError *StatusError) TooManyRequests() bool {
+	return statusError.StatusCode == http.StatusTooManyRequests || statusError.StatusCode == http.StatusServiceUnavailable
+}
+
+// TANKERKOENIG_URL can point to a fake of list.php for tests
+func listUrl() string {
+	result := strings.TrimSpace(os.Getenv("TANKERKOENIG_URL"))
+	if len(result) == 0 {
+		result = defaultListUrl
+	}
+	return strings.TrimSuffix(result, "/")
+}
+
+func requestList(client *http.Client, baseUrl string, latitude float64, longitude float64, radiusKm float64, apikey string) (listResponse, error) {
+	var result listResponse
+	queryUrl := fmt.Sprintf("%v/list.php?lat=%f&lng=%f&rad=%f&sort=dist&type=all&apikey=%v", baseUrl, latitude, longitude, radiusKm, url.QueryEscape(strings.TrimSpace(apikey)))
+	response, err := client.Get(queryUrl)
+	if err != nil {
+		return result, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return result, &StatusError{StatusCode: response.StatusCode, RetryAfter: parseRetryAfter(response.Header.Get("Retry-After"))}
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return result, fmt.Errorf("json decode failed: %v", err)
+	}
+	if !result.Ok {
+		return result, fmt.Errorf("request failed: %v", result.Message)
+	}
+	return result, nil
+}
+
+// Retry-After is in seconds or a http date
+func parseRetryAfter(retryAfter string) time.Duration {
+	retryAfter = strings.TrimSpace(retryAfter)
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if retryAt, err := http.ParseTime(retryAfter); err == nil && retryAt.After(time.Now()) {
+		return time.Until(retryAt)
+	}
+	return 0
+}
@@ -0,0 +1,93 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package pubsub
+
+import (
+	"log"
+	unmodel "react-and-go/pkd/notification/model"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type EventType string
+
+const (
+	PriceEventType        EventType = "price"
+	NotificationEventType EventType = "notification"
+)
+
+type PriceEvent struct {
+	GasStationID string
+	StationName  string
+	Brand        string
+	Place        string
+	Latitude     float64
+	Longitude    float64
+	E5           int
+	E10          int
+	Diesel       int
+	Date         time.Time
+	Changed      int
+}
+
+type Event struct {
+	Type         EventType
+	Price        *PriceEvent
+	Notification *unmodel.UserNotification
+}
+
+type Subscriber struct {
+	Events  chan Event
+	filter  func(event Event) bool
+	dropped int64
+}
+
+var subscribers = make(map[*Subscriber]bool)
+var subscribersMutex sync.RWMutex
+
+func Subscribe(bufferSize int, filter func(event Event) bool) *Subscriber {
+	mySubscriber := &Subscriber{Events: make(chan Event, bufferSize), filter: filter}
+	subscribersMutex.Lock()
+	defer subscribersMutex.Unlock()
+	subscribers[mySubscriber] = true
+	return mySubscriber
+}
+
+func Unsubscribe(subscriber *Subscriber) {
+	subscribersMutex.Lock()
+	defer subscribersMutex.Unlock()
+	if _, found := subscribers[subscriber]; found {
+		delete(subscribers, subscriber)
+		close(subscriber.Events)
+		if dropped := atomic.LoadInt64(&subscriber.dropped); dropped > 0 {
+			log.Printf("Subscriber dropped events: %v\n", dropped)
+		}
+	}
+}
+
+// never blocks, events for subscribers with a full buffer are dropped
+func Publish(event Event) {
+	subscribersMutex.RLock()
+	defer subscribersMutex.RUnlock()
+	for mySubscriber := range subscribers {
+		if mySubscriber.filter != nil && !mySubscriber.filter(event) {
+			continue
+		}
+		select {
+		case mySubscriber.Events <- event:
+		default:
+			atomic.AddInt64(&mySubscriber.dropped, 1)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gormrepo
+
+import (
+	"log"
+	"react-and-go/pkd/appuser/aumodel"
+
+	"gorm.io/gorm"
+)
+
+type AuditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) *AuditLogRepository {
+	return &AuditLogRepository{db: db}
+}
+
+func (repository *AuditLogRepository) Save(auditLogEntry *aumodel.AuditLogEntry) error {
+	return repository.db.Save(auditLogEntry).Error
+}
+
+func (repository *AuditLogRepository) FindLatest(limit int) []aumodel.AuditLogEntry {
+	result := []aumodel.AuditLogEntry{}
+	if err := repository.db.Order("timestamp desc, id desc").Limit(limit).Find(&result).Error; err != nil {
+		log.Printf("FindLatest failed: %v\n", err)
+	}
+	return result
+}
@@ -0,0 +1,187 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gormrepo
+
+import (
+	"fmt"
+	"log"
+	"react-and-go/pkd/appuser/aumodel"
+	database "react-and-go/pkd/database"
+	unmodel "react-and-go/pkd/notification/model"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type AppUserRepository struct {
+	db *gorm.DB
+}
+
+func NewAppUserRepository(db *gorm.DB) *AppUserRepository {
+	return &AppUserRepository{db: db}
+}
+
+func (repository *AppUserRepository) FindAll() []aumodel.AppUser {
+	var result []aumodel.AppUser
+	repository.db.Find(&result)
+	return result
+}
+
+func (repository *AppUserRepository) FindByUsername(username string) (aumodel.AppUser, error) {
+	var appUser aumodel.AppUser
+	err := repository.db.Where("username = ?", username).First(&appUser).Error
+	return appUser, err
+}
+
+func (repository *AppUserRepository) FindByUuid(userUuid string) (aumodel.AppUser, error) {
+	var appUser aumodel.AppUser
+	err := repository.db.Where("uuid = ?", userUuid).First(&appUser).Error
+	return appUser, err
+}
+
+func (repository *AppUserRepository) Save(appUser *aumodel.AppUser) error {
+	return repository.db.Save(appUser).Error
+}
+
+func (repository *AppUserRepository) FindPostCodeLocations(label string, limit int) []aumodel.PostCodeLocation {
+	result := []aumodel.PostCodeLocation{}
+	lowerFunction := "lower"
+	if repository.db.Dialector.Name() == database.SqliteDriver {
+		lowerFunction = database.SqliteLowerFunction
+	}
+	repository.db.Where(fmt.Sprintf("%v(label) like ?", lowerFunction), fmt.Sprintf("%%%v%%", strings.ToLower(label))).Limit(limit).Find(&result)
+	return result
+}
+
+func (repository *AppUserRepository) FindAllPostCodeLocations() []aumodel.PostCodeLocation {
+	var result []aumodel.PostCodeLocation
+	repository.db.Find(&result)
+	return result
+}
+
+func (repository *AppUserRepository) SavePostCodeLocations(postCodeLocations []aumodel.PostCodeLocation) error {
+	return repository.db.Transaction(func(tx *gorm.DB) error {
+		for index := range postCodeLocations {
+			if err := tx.Save(&postCodeLocations[index]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (repository *AppUserRepository) FindAlertRules(userUuid string) []aumodel.AlertRule {
+	result := []aumodel.AlertRule{}
+	repository.db.Where("user_uuid = ?", userUuid).Order("id").Find(&result)
+	return result
+}
+
+func (repository *AppUserRepository) FindActiveAlertRules() []aumodel.AlertRule {
+	var result []aumodel.AlertRule
+	repository.db.Where("active = ?", true).Find(&result)
+	return result
+}
+
+func (repository *AppUserRepository) FindAlertRuleUserUuids() []string {
+	result := []string{}
+	if err := repository.db.Model(&aumodel.AlertRule{}).Distinct("user_uuid").Pluck("user_uuid", &result).Error; err != nil {
+		log.Printf("FindAlertRuleUserUuids failed: %v\n", err)
+	}
+	return result
+}
+
+func (repository *AppUserRepository) FindAlertRule(id uint, userUuid string) (aumodel.AlertRule, error) {
+	var alertRule aumodel.AlertRule
+	err := repository.db.Where("id = ? and user_uuid = ?", id, userUuid).First(&alertRule).Error
+	return alertRule, err
+}
+
+func (repository *AppUserRepository) CountAlertRules(userUuid string) int {
+	var ruleCount int64
+	repository.db.Model(&aumodel.AlertRule{}).Where("user_uuid = ?", userUuid).Count(&ruleCount)
+	return int(ruleCount)
+}
+
+func (repository *AppUserRepository) SaveAlertRule(alertRule *aumodel.AlertRule) error {
+	return repository.db.Save(alertRule).Error
+}
+
+func (repository *AppUserRepository) DeleteAlertRule(id uint, userUuid string) bool {
+	deleted := false
+	if err := repository.db.Transaction(func(tx *gorm.DB) error {
+		deleteResult := tx.Where("id = ? and user_uuid = ?", id, userUuid).Delete(&aumodel.AlertRule{})
+		if deleteResult.Error != nil {
+			return deleteResult.Error
+		}
+		deleted = deleteResult.RowsAffected > 0
+		if !deleted {
+			return nil
+		}
+		return tx.Where("alert_rule_id = ?", id).Delete(&aumodel.AlertRuleLastSeen{}).Error
+	}); err != nil {
+		log.Printf("DeleteAlertRule failed: %v\n", err)
+		return false
+	}
+	return deleted
+}
+
+func (repository *AppUserRepository) FindLastSeenPrices(stids []string) []aumodel.AlertRuleLastSeen {
+	result := []aumodel.AlertRuleLastSeen{}
+	for _, chunk := range createChunks(repository.db, stids) {
+		var chunkResult []aumodel.AlertRuleLastSeen
+		if err := repository.db.Where("stid in ?", chunk).Find(&chunkResult).Error; err != nil {
+			log.Printf("FindLastSeenPrices failed: %v\n", err)
+		}
+		result = append(result, chunkResult...)
+	}
+	return result
+}
+
+func (repository *AppUserRepository) SaveLastSeenPrices(lastSeenPrices []aumodel.AlertRuleLastSeen) error {
+	return repository.db.Transaction(func(tx *gorm.DB) error {
+		for index := range lastSeenPrices {
+			if err := tx.Save(&lastSeenPrices[index]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// the user is deleted unscoped, a soft delete would keep the personal data
+func (repository *AppUserRepository) Delete(appUser aumodel.AppUser) error {
+	return repository.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("alert_rule_id in (?)", tx.Unscoped().Model(&aumodel.AlertRule{}).Select("id").Where("user_uuid = ?", appUser.Uuid)).
+			Delete(&aumodel.AlertRuleLastSeen{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_uuid = ?", appUser.Uuid).Delete(&aumodel.AlertRule{}).Error; err != nil {
+			return err
+		}
+		sessionIds := tx.Model(&aumodel.UserSession{}).Select("id").Where("username = ?", appUser.Username)
+		if err := tx.Where("session_id in (?)", sessionIds).Delete(&aumodel.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&aumodel.UserSession{}, &aumodel.UserToken{}} {
+			if err := tx.Where("username = ?", appUser.Username).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		for _, model := range []interface{}{&unmodel.UserNotification{}, &unmodel.NotificationState{}, &unmodel.NotificationSuppression{}, &unmodel.DigestEntry{}} {
+			if err := tx.Where("user_uuid = ?", appUser.Uuid).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(&appUser).Error
+	})
+}
@@ -0,0 +1,142 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gormrepo
+
+import (
+	"log"
+	"os"
+	database "react-and-go/pkd/database"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type GasStationRepository struct {
+	db *gorm.DB
+}
+
+func NewGasStationRepository(db *gorm.DB) *GasStationRepository {
+	return &GasStationRepository{db: db}
+}
+
+func (repository *GasStationRepository) FindAllLocations() []gsmodel.GasStation {
+	var result []gsmodel.GasStation
+	if err := repository.db.Select("id", "lat", "lng").Find(&result).Error; err != nil {
+		log.Printf("FindAllLocations failed: %v\n", err)
+	}
+	return result
+}
+
+func (repository *GasStationRepository) FindInBatches(batchSize int, process func(gasStations []gsmodel.GasStation) error) error {
+	var values []gsmodel.GasStation
+	return repository.db.FindInBatches(&values, batchSize, func(tx *gorm.DB, batch int) error {
+		return process(values)
+	}).Error
+}
+
+func (repository *GasStationRepository) FindByIds(ids []string) []gsmodel.GasStation {
+	return repository.findByIds(ids, 0)
+}
+
+func (repository *GasStationRepository) FindByIdsWithPrices(ids []string, priceLimit int) []gsmodel.GasStation {
+	return repository.findByIds(ids, priceLimit)
+}
+
+func (repository *GasStationRepository) findByIds(ids []string, priceLimit int) []gsmodel.GasStation {
+	var result []gsmodel.GasStation
+	chuncks := createChunks(repository.db, ids)
+	repository.db.Transaction(func(tx *gorm.DB) error {
+		for _, chunk := range chuncks {
+			var values []gsmodel.GasStation
+			query := tx.Where("id in ?", chunk)
+			if priceLimit > 0 {
+				query = query.Preload("GasPrices", func(db *gorm.DB) *gorm.DB {
+					return db.Order("date DESC").Limit(priceLimit)
+				})
+			}
+			query.Find(&values)
+			result = append(result, values...)
+		}
+		return nil
+	})
+	return result
+}
+
+func (repository *GasStationRepository) FindById(id string, priceLimit int) gsmodel.GasStation {
+	var myGasStation gsmodel.GasStation
+	repository.db.Where("id = ?", id).Preload("GasPrices", func(db *gorm.DB) *gorm.DB {
+		return db.Order("date DESC").Limit(priceLimit)
+	}).First(&myGasStation)
+	return myGasStation
+}
+
+func (repository *GasStationRepository) FindBySearchPlace(place string, postCode string, stationName string, priceLimit int) []gsmodel.GasStation {
+	var gasStations []gsmodel.GasStation
+	var query = repository.db
+	if len(place) > 0 {
+		query = query.Where("name LIKE ?", "%"+place+"%")
+	}
+	if len(postCode) > 0 {
+		query = query.Where("post_code LIKE ?", "%"+postCode+"%")
+	}
+	if len(stationName) > 0 {
+		query = query.Where("name LIKE ?", "%"+stationName+"%")
+	}
+	query.Preload("GasPrices", func(db *gorm.DB) *gorm.DB {
+		return db.Order("date DESC").Limit(priceLimit)
+	}).Find(&gasStations)
+	return gasStations
+}
+
+func (repository *GasStationRepository) Save(gasStations []gsmodel.GasStation) error {
+	if len(gasStations) == 0 {
+		return nil
+	}
+	return repository.db.Transaction(func(tx *gorm.DB) error {
+		for index := range gasStations {
+			if err := tx.Save(&gasStations[index]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// sqlite limits the number of bind parameters, chunked selects are the default there
+func createChunks(db *gorm.DB, ids []string) [][]string {
+	cunckedSelects := strings.ToLower(strings.TrimSpace(os.Getenv("DB_CHUNKED_SELECTS")))
+	chunkSize := 10000
+	if cunckedSelects == "true" || (len(cunckedSelects) == 0 && db.Dialector.Name() == database.SqliteDriver) {
+		chunkSize = 999
+	}
+	chuncks := chunkSlice(ids, chunkSize)
+	if len(chuncks) > 1 {
+		log.Printf("Number of Chunks: %v\n", len(chuncks))
+	}
+	return chuncks
+}
+
+func chunkSlice[T any](mySlice []T, chunkSize int) (s [][]T) {
+	numberOfChunks := len(mySlice)/chunkSize + 1
+	var result [][]T
+	for i := 0; i < numberOfChunks; i++ {
+
+		min := (i * len(mySlice) / numberOfChunks)
+		max := ((i + 1) * len(mySlice)) / numberOfChunks
+
+		result = append(result, mySlice[min:max])
+
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gormrepo
+
+import (
+	"log"
+	unmodel "react-and-go/pkd/notification/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// the attempts are the optimistic lock of the claim
+func (repository *OutboxRepository) ClaimDue(now time.Time, leaseUntil time.Time, limit int) []unmodel.OutboxItem {
+	candidates := []unmodel.OutboxItem{}
+	if err := repository.db.Where("status = ? and next_attempt_at <= ?", unmodel.OutboxPending, now).Order("id").Limit(limit).Find(&candidates).Error; err != nil {
+		log.Printf("ClaimDue failed: %v\n", err)
+		return []unmodel.OutboxItem{}
+	}
+	result := []unmodel.OutboxItem{}
+	for _, outboxItem := range candidates {
+		myResult := repository.db.Model(&unmodel.OutboxItem{}).Where("id = ? and status = ? and attempts = ?", outboxItem.ID, unmodel.OutboxPending,
+			outboxItem.Attempts).Updates(map[string]interface{}{"next_attempt_at": leaseUntil, "attempts": outboxItem.Attempts + 1})
+		if myResult.Error != nil {
+			log.Printf("ClaimDue failed: %v\n", myResult.Error)
+			continue
+		}
+		if myResult.RowsAffected == 1 {
+			outboxItem.NextAttemptAt = leaseUntil
+			outboxItem.Attempts++
+			result = append(result, outboxItem)
+		}
+	}
+	return result
+}
+
+func (repository *OutboxRepository) Save(outboxItem *unmodel.OutboxItem) error {
+	return repository.db.Save(outboxItem).Error
+}
+
+// the attempts of the claim are checked like in ClaimDue
+func (repository *OutboxRepository) Finish(outboxItem unmodel.OutboxItem, claimedAttempts int) (bool, error) {
+	myResult := repository.db.Model(&unmodel.OutboxItem{}).Where("id = ? and status = ? and attempts = ?", outboxItem.ID, unmodel.OutboxPending,
+		claimedAttempts).Updates(map[string]interface{}{"status": outboxItem.Status, "attempts": outboxItem.Attempts, "last_error": outboxItem.LastError,
+		"next_attempt_at": outboxItem.NextAttemptAt, "updated_at": time.Now()})
+	return myResult.RowsAffected == 1, myResult.Error
+}
+
+func (repository *OutboxRepository) CountByStatus() map[unmodel.OutboxStatus]int64 {
+	type statusCount struct {
+		Status unmodel.OutboxStatus
+		Count  int64
+	}
+	statusCounts := []statusCount{}
+	if err := repository.db.Model(&unmodel.OutboxItem{}).Select("status, count(*) as count").Group("status").Scan(&statusCounts).Error; err != nil {
+		log.Printf("CountByStatus failed: %v\n", err)
+	}
+	result := make(map[unmodel.OutboxStatus]int64)
+	for _, myStatusCount := range statusCounts {
+		result[myStatusCount.Status] = myStatusCount.Count
+	}
+	return result
+}
+
+func (repository *OutboxRepository) DeleteDoneBefore(before time.Time) (int64, error) {
+	myResult := repository.db.Where("status = ? and updated_at < ?", unmodel.OutboxDone, before).Delete(&unmodel.OutboxItem{})
+	return myResult.RowsAffected, myResult.Error
+}
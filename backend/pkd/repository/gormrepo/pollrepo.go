@@ -0,0 +1,52 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gormrepo
+
+import (
+	"log"
+	"react-and-go/pkd/poller/pomodel"
+
+	"gorm.io/gorm"
+)
+
+type PollStateRepository struct {
+	db *gorm.DB
+}
+
+func NewPollStateRepository(db *gorm.DB) *PollStateRepository {
+	return &PollStateRepository{db: db}
+}
+
+func (repository *PollStateRepository) FindKeyStates() []pomodel.PollKeyState {
+	result := []pomodel.PollKeyState{}
+	if err := repository.db.Find(&result).Error; err != nil {
+		log.Printf("FindKeyStates failed: %v\n", err)
+	}
+	return result
+}
+
+func (repository *PollStateRepository) SaveKeyState(pollKeyState *pomodel.PollKeyState) error {
+	return repository.db.Save(pollKeyState).Error
+}
+
+func (repository *PollStateRepository) FindRegionStates() []pomodel.PollRegionState {
+	result := []pomodel.PollRegionState{}
+	if err := repository.db.Find(&result).Error; err != nil {
+		log.Printf("FindRegionStates failed: %v\n", err)
+	}
+	return result
+}
+
+func (repository *PollStateRepository) SaveRegionState(pollRegionState *pomodel.PollRegionState) error {
+	return repository.db.Save(pollRegionState).Error
+}
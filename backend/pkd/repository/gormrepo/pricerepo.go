@@ -0,0 +1,204 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gormrepo
+
+import (
+	"react-and-go/pkd/gasstation/gsmodel"
+	unmodel "react-and-go/pkd/notification/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PriceRepository struct {
+	db *gorm.DB
+}
+
+func NewPriceRepository(db *gorm.DB) *PriceRepository {
+	return &PriceRepository{db: db}
+}
+
+func (repository *PriceRepository) FindByStidsSince(stids []string, start time.Time) []gsmodel.GasPrice {
+	var myGasPrices []gsmodel.GasPrice
+	chuncks := createChunks(repository.db, stids)
+	repository.db.Transaction(func(tx *gorm.DB) error {
+		for _, chunk := range chuncks {
+			var values []gsmodel.GasPrice
+			tx.Where("stid IN ? and date >= ?", chunk, start).Order("date desc").Find(&values)
+			myGasPrices = append(myGasPrices, values...)
+		}
+		return nil
+	})
+	return myGasPrices
+}
+
+func (repository *PriceRepository) FindByStid(stid string) []gsmodel.GasPrice {
+	var myGasPrice []gsmodel.GasPrice
+	repository.db.Where("stid = ?", stid).Order("date desc").Find(&myGasPrice)
+	return myGasPrice
+}
+
+func (repository *PriceRepository) FindByStidsBetween(stids []string, start time.Time, end time.Time) []gsmodel.GasPrice {
+	var myGasPrices []gsmodel.GasPrice
+	chuncks := createChunks(repository.db, stids)
+	repository.db.Transaction(func(tx *gorm.DB) error {
+		for _, chunk := range chuncks {
+			var values []gsmodel.GasPrice
+			tx.Where("stid IN ? and date >= ? and date <= ?", chunk, start, end).Find(&values)
+			myGasPrices = append(myGasPrices, values...)
+		}
+		return nil
+	})
+	return myGasPrices
+}
+
+func (repository *PriceRepository) Save(gasPrices []gsmodel.GasPrice) error {
+	if len(gasPrices) == 0 {
+		return nil
+	}
+	return repository.db.Transaction(func(tx *gorm.DB) error {
+		for index := range gasPrices {
+			if err := tx.Save(&gasPrices[index]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (repository *PriceRepository) CreateNew(gasPrices []gsmodel.GasPrice, newOutboxItems func(createdPrices []gsmodel.GasPrice) ([]unmodel.OutboxItem, error)) ([]gsmodel.GasPrice, error) {
+	result := []gsmodel.GasPrice{}
+	if len(gasPrices) == 0 {
+		return result, nil
+	}
+	err := repository.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = createNewPrices(tx, gasPrices, newOutboxItems)
+		return err
+	})
+	if err != nil {
+		return []gsmodel.GasPrice{}, err
+	}
+	return result, nil
+}
+
+// the unique index on stid and date decides, a concurrent insert of the same price is skipped too
+func createNewPrices(tx *gorm.DB, gasPrices []gsmodel.GasPrice, newOutboxItems func(createdPrices []gsmodel.GasPrice) ([]unmodel.OutboxItem, error)) ([]gsmodel.GasPrice, error) {
+	result := []gsmodel.GasPrice{}
+	for index := range gasPrices {
+		myResult := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&gasPrices[index])
+		if myResult.Error != nil {
+			return result, myResult.Error
+		}
+		if myResult.RowsAffected > 0 {
+			result = append(result, gasPrices[index])
+		}
+	}
+	if newOutboxItems == nil || len(result) == 0 {
+		return result, nil
+	}
+	outboxItems, err := newOutboxItems(result)
+	if err != nil || len(outboxItems) == 0 {
+		return result, err
+	}
+	return result, tx.Create(&outboxItems).Error
+}
+
+func (repository *PriceRepository) CreateInBatches(gasPrices []gsmodel.GasPrice, batchSize int) error {
+	if len(gasPrices) == 0 {
+		return nil
+	}
+	return repository.db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(gasPrices, batchSize).Error
+	})
+}
+
+func (repository *PriceRepository) FindImportState(fileName string) gsmodel.PriceImportState {
+	result := gsmodel.PriceImportState{FileName: fileName}
+	repository.db.Where("file_name = ?", fileName).Limit(1).Find(&result)
+	return result
+}
+
+func (repository *PriceRepository) SaveImportState(priceImportState *gsmodel.PriceImportState) error {
+	return repository.db.Save(priceImportState).Error
+}
+
+func (repository *PriceRepository) FindOldestDate() time.Time {
+	var myGasPrice gsmodel.GasPrice
+	if result := repository.db.Order("date").Limit(1).Find(&myGasPrice); result.Error != nil || result.RowsAffected == 0 {
+		return time.Time{}
+	}
+	return myGasPrice.Date
+}
+
+func (repository *PriceRepository) FindByPeriod(start time.Time, end time.Time) []gsmodel.GasPrice {
+	var myGasPrices []gsmodel.GasPrice
+	repository.db.Where("date >= ? and date < ?", start, end).Find(&myGasPrices)
+	return myGasPrices
+}
+
+func (repository *PriceRepository) FindAggregatesByPeriod(start time.Time, end time.Time) ([]gsmodel.HourlyPrice, []gsmodel.DailyPrice) {
+	var hourlyPrices []gsmodel.HourlyPrice
+	var dailyPrices []gsmodel.DailyPrice
+	repository.db.Where("start >= ? and start < ?", start, end).Find(&hourlyPrices)
+	repository.db.Where("start >= ? and start < ?", start, end).Find(&dailyPrices)
+	return hourlyPrices, dailyPrices
+}
+
+// the raw prices and the aggregates of the period are replaced in one transaction
+func (repository *PriceRepository) ReplaceWithAggregates(hourlyPrices []gsmodel.HourlyPrice, dailyPrices []gsmodel.DailyPrice, start time.Time, end time.Time) error {
+	return repository.db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&gsmodel.HourlyPrice{}, &gsmodel.DailyPrice{}} {
+			if err := tx.Where("start >= ? and start < ?", start, end).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if len(hourlyPrices) > 0 {
+			if err := tx.CreateInBatches(hourlyPrices, 1000).Error; err != nil {
+				return err
+			}
+		}
+		if len(dailyPrices) > 0 {
+			if err := tx.CreateInBatches(dailyPrices, 1000).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("date >= ? and date < ?", start, end).Delete(&gsmodel.GasPrice{}).Error
+	})
+}
+
+func (repository *PriceRepository) FindHourlyPrices(stids []string, start time.Time, end time.Time) []gsmodel.HourlyPrice {
+	var result []gsmodel.HourlyPrice
+	for _, chunk := range createChunks(repository.db, stids) {
+		var values []gsmodel.HourlyPrice
+		repository.db.Where("stid IN ? and start >= ? and start < ?", chunk, start, end).Find(&values)
+		result = append(result, values...)
+	}
+	return result
+}
+
+func (repository *PriceRepository) FindDailyPrices(stids []string, start time.Time, end time.Time) []gsmodel.DailyPrice {
+	var result []gsmodel.DailyPrice
+	for _, chunk := range createChunks(repository.db, stids) {
+		var values []gsmodel.DailyPrice
+		repository.db.Where("stid IN ? and start >= ? and start < ?", chunk, start, end).Find(&values)
+		result = append(result, values...)
+	}
+	return result
+}
+
+func (repository *PriceRepository) DeleteHourlyPricesBefore(before time.Time) (int64, error) {
+	result := repository.db.Where("start < ?", before).Delete(&gsmodel.HourlyPrice{})
+	return result.RowsAffected, result.Error
+}
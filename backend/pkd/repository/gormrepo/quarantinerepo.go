@@ -0,0 +1,78 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gormrepo
+
+import (
+	"log"
+	"react-and-go/pkd/gasstation/gsmodel"
+	unmodel "react-and-go/pkd/notification/model"
+
+	"gorm.io/gorm"
+)
+
+type QuarantineRepository struct {
+	db *gorm.DB
+}
+
+func NewQuarantineRepository(db *gorm.DB) *QuarantineRepository {
+	return &QuarantineRepository{db: db}
+}
+
+func (repository *QuarantineRepository) Save(quarantinedPrices []gsmodel.QuarantinedPrice) error {
+	if len(quarantinedPrices) == 0 {
+		return nil
+	}
+	return repository.db.Save(&quarantinedPrices).Error
+}
+
+func (repository *QuarantineRepository) Confirm(quarantinedPrice gsmodel.QuarantinedPrice, newOutboxItems func(createdPrices []gsmodel.GasPrice) ([]unmodel.OutboxItem, error)) error {
+	return repository.db.Transaction(func(tx *gorm.DB) error {
+		// the condition on the status lets only one of concurrent requests resolve the price
+		myResult := tx.Model(&gsmodel.QuarantinedPrice{}).Where("id = ? and status = ?", quarantinedPrice.ID, gsmodel.QuarantinePending).
+			Updates(map[string]interface{}{"status": quarantinedPrice.Status, "resolved_by": quarantinedPrice.ResolvedBy, "resolved_at": quarantinedPrice.ResolvedAt})
+		if myResult.Error != nil {
+			return myResult.Error
+		}
+		if myResult.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		_, err := createNewPrices(tx, []gsmodel.GasPrice{quarantinedPrice.ToGasPrice()}, newOutboxItems)
+		return err
+	})
+}
+
+func (repository *QuarantineRepository) FindById(id int64) (gsmodel.QuarantinedPrice, error) {
+	var quarantinedPrice gsmodel.QuarantinedPrice
+	err := repository.db.Where("id = ?", id).First(&quarantinedPrice).Error
+	return quarantinedPrice, err
+}
+
+func (repository *QuarantineRepository) FindByStatus(status gsmodel.QuarantineStatus, limit int) []gsmodel.QuarantinedPrice {
+	result := []gsmodel.QuarantinedPrice{}
+	if err := repository.db.Where("status = ?", status).Order("date desc, id desc").Limit(limit).Find(&result).Error; err != nil {
+		log.Printf("FindByStatus failed: %v\n", err)
+	}
+	return result
+}
+
+func (repository *QuarantineRepository) FindByStidsAndStatus(stids []string, status gsmodel.QuarantineStatus) []gsmodel.QuarantinedPrice {
+	result := []gsmodel.QuarantinedPrice{}
+	for _, chunk := range createChunks(repository.db, stids) {
+		var chunkResult []gsmodel.QuarantinedPrice
+		if err := repository.db.Where("stid in ? and status = ?", chunk, status).Order("date desc, id desc").Find(&chunkResult).Error; err != nil {
+			log.Printf("FindByStidsAndStatus failed: %v\n", err)
+		}
+		result = append(result, chunkResult...)
+	}
+	return result
+}
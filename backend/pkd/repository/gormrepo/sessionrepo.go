@@ -0,0 +1,102 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gormrepo
+
+import (
+	"log"
+	"react-and-go/pkd/appuser/aumodel"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (repository *SessionRepository) CreateSession(userSession *aumodel.UserSession, refreshToken aumodel.RefreshToken) error {
+	return repository.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(userSession).Error; err != nil {
+			return err
+		}
+		return tx.Create(&refreshToken).Error
+	})
+}
+
+func (repository *SessionRepository) FindSession(id string) (aumodel.UserSession, error) {
+	var userSession aumodel.UserSession
+	err := repository.db.Where("id = ?", id).First(&userSession).Error
+	return userSession, err
+}
+
+func (repository *SessionRepository) FindSessions(username string) []aumodel.UserSession {
+	result := []aumodel.UserSession{}
+	if err := repository.db.Where("username = ?", username).Order("created_at desc").Find(&result).Error; err != nil {
+		log.Printf("FindSessions failed: %v\n", err)
+	}
+	return result
+}
+
+func (repository *SessionRepository) FindRefreshToken(tokenHash string) (aumodel.RefreshToken, error) {
+	var refreshToken aumodel.RefreshToken
+	err := repository.db.Where("token_hash = ?", tokenHash).First(&refreshToken).Error
+	return refreshToken, err
+}
+
+func (repository *SessionRepository) RotateRefreshToken(tokenHash string, newRefreshToken aumodel.RefreshToken, now time.Time) (bool, error) {
+	rotated := false
+	err := repository.db.Transaction(func(tx *gorm.DB) error {
+		// the condition on used lets only one of concurrent requests with the same token rotate it
+		myResult := tx.Model(&aumodel.RefreshToken{}).Where("token_hash = ? and used = ?", tokenHash, false).Update("used", true)
+		if myResult.Error != nil || myResult.RowsAffected == 0 {
+			return myResult.Error
+		}
+		if err := tx.Create(&newRefreshToken).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&aumodel.UserSession{}).Where("id = ?", newRefreshToken.SessionID).
+			Updates(map[string]interface{}{"last_used_at": now, "expires_at": newRefreshToken.ExpiresAt}).Error; err != nil {
+			return err
+		}
+		rotated = true
+		return nil
+	})
+	return rotated && err == nil, err
+}
+
+func (repository *SessionRepository) RevokeSessions(ids []string, reason string, now time.Time) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	myResult := repository.db.Model(&aumodel.UserSession{}).Where("id in ? and revoked = ?", ids, false).
+		Updates(map[string]interface{}{"revoked": true, "revoked_at": now, "revoke_reason": reason})
+	return myResult.RowsAffected, myResult.Error
+}
+
+func (repository *SessionRepository) DeleteSessionsBefore(before time.Time) (int64, error) {
+	var deleted int64
+	err := repository.db.Transaction(func(tx *gorm.DB) error {
+		sessionIds := tx.Model(&aumodel.UserSession{}).Select("id").Where("expires_at < ? or (revoked = ? and revoked_at < ?)", before, true, before)
+		if err := tx.Where("session_id in (?)", sessionIds).Delete(&aumodel.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		myResult := tx.Where("expires_at < ? or (revoked = ? and revoked_at < ?)", before, true, before).Delete(&aumodel.UserSession{})
+		deleted = myResult.RowsAffected
+		return myResult.Error
+	})
+	return deleted, err
+}
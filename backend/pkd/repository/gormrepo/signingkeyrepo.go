@@ -0,0 +1,47 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gormrepo
+
+import (
+	"log"
+	"react-and-go/pkd/token/tkmodel"
+
+	"gorm.io/gorm"
+)
+
+type SigningKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewSigningKeyRepository(db *gorm.DB) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+func (repository *SigningKeyRepository) FindAll() []tkmodel.SigningKey {
+	result := []tkmodel.SigningKey{}
+	if err := repository.db.Order("activates_at, created_at").Find(&result).Error; err != nil {
+		log.Printf("FindAll signing keys failed: %v\n", err)
+	}
+	return result
+}
+
+func (repository *SigningKeyRepository) Save(signingKey *tkmodel.SigningKey) error {
+	return repository.db.Save(signingKey).Error
+}
+
+func (repository *SigningKeyRepository) Delete(kids []string) error {
+	if len(kids) == 0 {
+		return nil
+	}
+	return repository.db.Where("kid in ?", kids).Delete(&tkmodel.SigningKey{}).Error
+}
@@ -0,0 +1,169 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gormrepo
+
+import (
+	"log"
+	unmodel "react-and-go/pkd/notification/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type NotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+func (repository *NotificationRepository) Save(userNotifications []unmodel.UserNotification) ([]unmodel.UserNotification, error) {
+	err := repository.db.Transaction(func(tx *gorm.DB) error {
+		for index := range userNotifications {
+			if err := tx.Save(&userNotifications[index]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return userNotifications, err
+}
+
+func (repository *NotificationRepository) FindByUserUuid(userUuid string, onlyNew bool, limit int) []unmodel.UserNotification {
+	var userNotifications []unmodel.UserNotification
+	query := repository.db.Where("user_uuid = ?", userUuid)
+	if onlyNew {
+		query = query.Where("notification_send = ?", false)
+	}
+	query.Order("timestamp desc, id desc").Limit(limit).Find(&userNotifications)
+	return userNotifications
+}
+
+func (repository *NotificationRepository) FindById(id int64) (unmodel.UserNotification, error) {
+	var userNotification unmodel.UserNotification
+	err := repository.db.Where("id = ?", id).First(&userNotification).Error
+	return userNotification, err
+}
+
+func (repository *NotificationRepository) MarkSent(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return repository.db.Model(&unmodel.UserNotification{}).Where("id in ?", ids).Update("notification_send", true).Error
+}
+
+func (repository *NotificationRepository) UpdateDeliveryStatus(id int64, deliveryStatus string, deliveryAttempts int) error {
+	return repository.db.Model(&unmodel.UserNotification{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"delivery_status": deliveryStatus, "delivery_attempts": deliveryAttempts}).Error
+}
+
+func (repository *NotificationRepository) SaveEvaluation(userNotifications []unmodel.UserNotification, notificationStates []unmodel.NotificationState,
+	suppressions []unmodel.NotificationSuppression, digestEntries []unmodel.DigestEntry,
+	newOutboxItems func(savedNotifications []unmodel.UserNotification) ([]unmodel.OutboxItem, error)) ([]unmodel.UserNotification, error) {
+	err := repository.db.Transaction(func(tx *gorm.DB) error {
+		for index := range userNotifications {
+			if err := tx.Save(&userNotifications[index]).Error; err != nil {
+				return err
+			}
+		}
+		if err := createOutboxItems(tx, userNotifications, newOutboxItems); err != nil {
+			return err
+		}
+		for index := range notificationStates {
+			if err := tx.Save(&notificationStates[index]).Error; err != nil {
+				return err
+			}
+		}
+		if len(suppressions) > 0 {
+			if err := tx.CreateInBatches(suppressions, 500).Error; err != nil {
+				return err
+			}
+		}
+		if len(digestEntries) > 0 {
+			return tx.CreateInBatches(digestEntries, 500).Error
+		}
+		return nil
+	})
+	return userNotifications, err
+}
+
+func createOutboxItems(tx *gorm.DB, savedNotifications []unmodel.UserNotification,
+	newOutboxItems func(savedNotifications []unmodel.UserNotification) ([]unmodel.OutboxItem, error)) error {
+	if newOutboxItems == nil || len(savedNotifications) == 0 {
+		return nil
+	}
+	outboxItems, err := newOutboxItems(savedNotifications)
+	if err != nil || len(outboxItems) == 0 {
+		return err
+	}
+	return tx.Create(&outboxItems).Error
+}
+
+func (repository *NotificationRepository) FindStates(stids []string) []unmodel.NotificationState {
+	result := []unmodel.NotificationState{}
+	for _, chunk := range createChunks(repository.db, stids) {
+		var chunkResult []unmodel.NotificationState
+		if err := repository.db.Where("stid in ?", chunk).Find(&chunkResult).Error; err != nil {
+			log.Printf("FindStates failed: %v\n", err)
+		}
+		result = append(result, chunkResult...)
+	}
+	return result
+}
+
+func (repository *NotificationRepository) FindSuppressions(userUuid string, limit int) []unmodel.NotificationSuppression {
+	result := []unmodel.NotificationSuppression{}
+	if err := repository.db.Where("user_uuid = ?", userUuid).Order("timestamp desc, id desc").Limit(limit).Find(&result).Error; err != nil {
+		log.Printf("FindSuppressions failed: %v\n", err)
+	}
+	return result
+}
+
+func (repository *NotificationRepository) DeleteSuppressionsBefore(before time.Time) (int64, error) {
+	myResult := repository.db.Where("timestamp < ?", before).Delete(&unmodel.NotificationSuppression{})
+	return myResult.RowsAffected, myResult.Error
+}
+
+func (repository *NotificationRepository) FindDigestEntries() []unmodel.DigestEntry {
+	result := []unmodel.DigestEntry{}
+	if err := repository.db.Order("timestamp, id").Find(&result).Error; err != nil {
+		log.Printf("FindDigestEntries failed: %v\n", err)
+	}
+	return result
+}
+
+func (repository *NotificationRepository) FindDigestEntriesByUserUuid(userUuid string) []unmodel.DigestEntry {
+	result := []unmodel.DigestEntry{}
+	if err := repository.db.Where("user_uuid = ?", userUuid).Order("timestamp, id").Find(&result).Error; err != nil {
+		log.Printf("FindDigestEntriesByUserUuid failed: %v\n", err)
+	}
+	return result
+}
+
+func (repository *NotificationRepository) SaveDigest(userNotification unmodel.UserNotification, digestEntryIds []int64,
+	newOutboxItems func(savedNotifications []unmodel.UserNotification) ([]unmodel.OutboxItem, error)) (unmodel.UserNotification, error) {
+	err := repository.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&userNotification).Error; err != nil {
+			return err
+		}
+		if err := createOutboxItems(tx, []unmodel.UserNotification{userNotification}, newOutboxItems); err != nil {
+			return err
+		}
+		if len(digestEntryIds) == 0 {
+			return nil
+		}
+		return tx.Where("id in ?", digestEntryIds).Delete(&unmodel.DigestEntry{}).Error
+	})
+	return userNotification, err
+}
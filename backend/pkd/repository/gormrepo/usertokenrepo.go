@@ -0,0 +1,54 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gormrepo
+
+import (
+	"react-and-go/pkd/appuser/aumodel"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UserTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) *UserTokenRepository {
+	return &UserTokenRepository{db: db}
+}
+
+func (repository *UserTokenRepository) Save(userToken *aumodel.UserToken) error {
+	return repository.db.Save(userToken).Error
+}
+
+func (repository *UserTokenRepository) Use(tokenHash string, purpose aumodel.TokenPurpose, now time.Time) (aumodel.UserToken, error) {
+	var userToken aumodel.UserToken
+	err := repository.db.Transaction(func(tx *gorm.DB) error {
+		// the condition on used lets only one of concurrent requests use the token
+		myResult := tx.Model(&aumodel.UserToken{}).Where("token_hash = ? and purpose = ? and used = ? and expires_at > ?", tokenHash, purpose, false, now).
+			Update("used", true)
+		if myResult.Error != nil {
+			return myResult.Error
+		}
+		if myResult.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("token_hash = ?", tokenHash).First(&userToken).Error
+	})
+	return userToken, err
+}
+
+func (repository *UserTokenRepository) DeleteExpiredBefore(before time.Time) (int64, error) {
+	myResult := repository.db.Where("expires_at < ?", before).Delete(&aumodel.UserToken{})
+	return myResult.RowsAffected, myResult.Error
+}
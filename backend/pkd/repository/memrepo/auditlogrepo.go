@@ -0,0 +1,56 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package memrepo
+
+import (
+	"react-and-go/pkd/appuser/aumodel"
+	"sync"
+)
+
+type AuditLogRepository struct {
+	mutex           sync.RWMutex
+	auditLogEntries []aumodel.AuditLogEntry
+	nextId          int64
+}
+
+func NewAuditLogRepository() *AuditLogRepository {
+	return &AuditLogRepository{}
+}
+
+func (repository *AuditLogRepository) Save(auditLogEntry *aumodel.AuditLogEntry) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	if auditLogEntry.ID == 0 {
+		repository.nextId++
+		auditLogEntry.ID = repository.nextId
+		repository.auditLogEntries = append(repository.auditLogEntries, *auditLogEntry)
+		return nil
+	}
+	for index := range repository.auditLogEntries {
+		if repository.auditLogEntries[index].ID == auditLogEntry.ID {
+			repository.auditLogEntries[index] = *auditLogEntry
+		}
+	}
+	return nil
+}
+
+// the entries are appended in the order of their timestamps
+func (repository *AuditLogRepository) FindLatest(limit int) []aumodel.AuditLogEntry {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []aumodel.AuditLogEntry{}
+	for index := len(repository.auditLogEntries) - 1; index >= 0 && len(result) < limit; index-- {
+		result = append(result, repository.auditLogEntries[index])
+	}
+	return result
+}
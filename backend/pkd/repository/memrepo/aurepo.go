@@ -0,0 +1,251 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package memrepo
+
+import (
+	"fmt"
+	"react-and-go/pkd/appuser/aumodel"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AppUserRepository struct {
+	mutex             sync.RWMutex
+	appUsers          map[uint]aumodel.AppUser
+	postCodeLocations map[uint]aumodel.PostCodeLocation
+	alertRules        map[uint]aumodel.AlertRule
+	lastSeenPrices    map[string]aumodel.AlertRuleLastSeen
+	nextId            uint
+	// the data of the user that Delete erases
+	sessionRepository      *SessionRepository
+	userTokenRepository    *UserTokenRepository
+	notificationRepository *NotificationRepository
+}
+
+func NewAppUserRepository(sessionRepository *SessionRepository, userTokenRepository *UserTokenRepository,
+	notificationRepository *NotificationRepository) *AppUserRepository {
+	return &AppUserRepository{appUsers: make(map[uint]aumodel.AppUser),
+		postCodeLocations: make(map[uint]aumodel.PostCodeLocation), alertRules: make(map[uint]aumodel.AlertRule),
+		lastSeenPrices: make(map[string]aumodel.AlertRuleLastSeen), sessionRepository: sessionRepository,
+		userTokenRepository: userTokenRepository, notificationRepository: notificationRepository}
+}
+
+func (repository *AppUserRepository) FindAll() []aumodel.AppUser {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []aumodel.AppUser{}
+	for _, appUser := range repository.appUsers {
+		result = append(result, appUser)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+func (repository *AppUserRepository) FindByUsername(username string) (aumodel.AppUser, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	for _, appUser := range repository.appUsers {
+		if appUser.Username == username {
+			return appUser, nil
+		}
+	}
+	return aumodel.AppUser{}, gorm.ErrRecordNotFound
+}
+
+func (repository *AppUserRepository) FindByUuid(userUuid string) (aumodel.AppUser, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	for _, appUser := range repository.appUsers {
+		if appUser.Uuid == userUuid {
+			return appUser, nil
+		}
+	}
+	return aumodel.AppUser{}, gorm.ErrRecordNotFound
+}
+
+func (repository *AppUserRepository) Save(appUser *aumodel.AppUser) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	repository.updateModel(&appUser.Model)
+	repository.appUsers[appUser.ID] = *appUser
+	return nil
+}
+
+func (repository *AppUserRepository) FindPostCodeLocations(label string, limit int) []aumodel.PostCodeLocation {
+	result := []aumodel.PostCodeLocation{}
+	for _, postCodeLocation := range repository.FindAllPostCodeLocations() {
+		if len(result) < limit && strings.Contains(strings.ToLower(postCodeLocation.Label), strings.ToLower(label)) {
+			result = append(result, postCodeLocation)
+		}
+	}
+	return result
+}
+
+func (repository *AppUserRepository) FindAllPostCodeLocations() []aumodel.PostCodeLocation {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []aumodel.PostCodeLocation{}
+	for _, postCodeLocation := range repository.postCodeLocations {
+		result = append(result, postCodeLocation)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+func (repository *AppUserRepository) SavePostCodeLocations(postCodeLocations []aumodel.PostCodeLocation) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	for index := range postCodeLocations {
+		repository.updateModel(&postCodeLocations[index].Model)
+		repository.postCodeLocations[postCodeLocations[index].ID] = postCodeLocations[index]
+	}
+	return nil
+}
+
+func (repository *AppUserRepository) FindAlertRules(userUuid string) []aumodel.AlertRule {
+	return repository.findAlertRules(func(alertRule aumodel.AlertRule) bool {
+		return alertRule.UserUuid == userUuid
+	})
+}
+
+func (repository *AppUserRepository) FindActiveAlertRules() []aumodel.AlertRule {
+	return repository.findAlertRules(func(alertRule aumodel.AlertRule) bool {
+		return alertRule.Active
+	})
+}
+
+func (repository *AppUserRepository) FindAlertRuleUserUuids() []string {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	userUuidSet := make(map[string]bool)
+	for _, alertRule := range repository.alertRules {
+		userUuidSet[alertRule.UserUuid] = true
+	}
+	result := []string{}
+	for userUuid := range userUuidSet {
+		result = append(result, userUuid)
+	}
+	return result
+}
+
+func (repository *AppUserRepository) FindAlertRule(id uint, userUuid string) (aumodel.AlertRule, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	if alertRule, found := repository.alertRules[id]; found && alertRule.UserUuid == userUuid {
+		return alertRule, nil
+	}
+	return aumodel.AlertRule{}, gorm.ErrRecordNotFound
+}
+
+func (repository *AppUserRepository) CountAlertRules(userUuid string) int {
+	return len(repository.FindAlertRules(userUuid))
+}
+
+func (repository *AppUserRepository) SaveAlertRule(alertRule *aumodel.AlertRule) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	repository.updateModel(&alertRule.Model)
+	repository.alertRules[alertRule.ID] = *alertRule
+	return nil
+}
+
+func (repository *AppUserRepository) DeleteAlertRule(id uint, userUuid string) bool {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	if alertRule, found := repository.alertRules[id]; found && alertRule.UserUuid == userUuid {
+		delete(repository.alertRules, id)
+		repository.deleteLastSeenPrices(id)
+		return true
+	}
+	return false
+}
+
+func (repository *AppUserRepository) FindLastSeenPrices(stids []string) []aumodel.AlertRuleLastSeen {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	stidSet := make(map[string]bool)
+	for _, stid := range stids {
+		stidSet[stid] = true
+	}
+	result := []aumodel.AlertRuleLastSeen{}
+	for _, lastSeenPrice := range repository.lastSeenPrices {
+		if stidSet[lastSeenPrice.GasStationID] {
+			result = append(result, lastSeenPrice)
+		}
+	}
+	return result
+}
+
+func (repository *AppUserRepository) SaveLastSeenPrices(lastSeenPrices []aumodel.AlertRuleLastSeen) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	for _, lastSeenPrice := range lastSeenPrices {
+		repository.lastSeenPrices[lastSeenPriceKey(lastSeenPrice)] = lastSeenPrice
+	}
+	return nil
+}
+
+func lastSeenPriceKey(lastSeenPrice aumodel.AlertRuleLastSeen) string {
+	return fmt.Sprintf("%v|%v", lastSeenPrice.AlertRuleID, lastSeenPrice.GasStationID)
+}
+
+func (repository *AppUserRepository) deleteLastSeenPrices(alertRuleId uint) {
+	for key, lastSeenPrice := range repository.lastSeenPrices {
+		if lastSeenPrice.AlertRuleID == alertRuleId {
+			delete(repository.lastSeenPrices, key)
+		}
+	}
+}
+
+func (repository *AppUserRepository) findAlertRules(matches func(alertRule aumodel.AlertRule) bool) []aumodel.AlertRule {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []aumodel.AlertRule{}
+	for _, alertRule := range repository.alertRules {
+		if matches(alertRule) {
+			result = append(result, alertRule)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// sets the id of new entities and the timestamps like gorm
+func (repository *AppUserRepository) updateModel(model *gorm.Model) {
+	if model.ID == 0 {
+		repository.nextId++
+		model.ID = repository.nextId
+		model.CreatedAt = time.Now()
+	}
+	model.UpdatedAt = time.Now()
+}
+
+func (repository *AppUserRepository) Delete(appUser aumodel.AppUser) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	for id, alertRule := range repository.alertRules {
+		if alertRule.UserUuid == appUser.Uuid {
+			delete(repository.alertRules, id)
+			repository.deleteLastSeenPrices(id)
+		}
+	}
+	repository.sessionRepository.deleteByUsername(appUser.Username)
+	repository.userTokenRepository.deleteByUsername(appUser.Username)
+	repository.notificationRepository.deleteByUserUuid(appUser.Uuid)
+	delete(repository.appUsers, appUser.ID)
+	return nil
+}
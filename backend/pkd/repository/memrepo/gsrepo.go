@@ -0,0 +1,121 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package memrepo
+
+import (
+	"react-and-go/pkd/gasstation/gsmodel"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type GasStationRepository struct {
+	mutex           sync.RWMutex
+	gasStations     map[string]gsmodel.GasStation
+	priceRepository *PriceRepository
+}
+
+// the price repository provides the preloaded GasPrices
+func NewGasStationRepository(priceRepository *PriceRepository) *GasStationRepository {
+	return &GasStationRepository{gasStations: make(map[string]gsmodel.GasStation), priceRepository: priceRepository}
+}
+
+func (repository *GasStationRepository) FindAllLocations() []gsmodel.GasStation {
+	result := []gsmodel.GasStation{}
+	for _, myGasStation := range repository.sortedGasStations() {
+		result = append(result, gsmodel.GasStation{ID: myGasStation.ID, Latitude: myGasStation.Latitude, Longitude: myGasStation.Longitude})
+	}
+	return result
+}
+
+func (repository *GasStationRepository) FindInBatches(batchSize int, process func(gasStations []gsmodel.GasStation) error) error {
+	gasStations := repository.sortedGasStations()
+	for start := 0; start < len(gasStations); start = start + batchSize {
+		end := start + batchSize
+		if end > len(gasStations) {
+			end = len(gasStations)
+		}
+		if err := process(gasStations[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repository *GasStationRepository) FindByIds(ids []string) []gsmodel.GasStation {
+	return repository.FindByIdsWithPrices(ids, 0)
+}
+
+func (repository *GasStationRepository) FindByIdsWithPrices(ids []string, priceLimit int) []gsmodel.GasStation {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []gsmodel.GasStation{}
+	for _, id := range ids {
+		if myGasStation, found := repository.gasStations[id]; found {
+			result = append(result, repository.withPrices(myGasStation, priceLimit))
+		}
+	}
+	return result
+}
+
+func (repository *GasStationRepository) FindById(id string, priceLimit int) gsmodel.GasStation {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	if myGasStation, found := repository.gasStations[id]; found {
+		return repository.withPrices(myGasStation, priceLimit)
+	}
+	return gsmodel.GasStation{}
+}
+
+func (repository *GasStationRepository) FindBySearchPlace(place string, postCode string, stationName string, priceLimit int) []gsmodel.GasStation {
+	result := []gsmodel.GasStation{}
+	for _, myGasStation := range repository.sortedGasStations() {
+		if !strings.Contains(myGasStation.StationName, place) || !strings.Contains(myGasStation.PostCode, postCode) || !strings.Contains(myGasStation.StationName, stationName) {
+			continue
+		}
+		result = append(result, repository.withPrices(myGasStation, priceLimit))
+	}
+	return result
+}
+
+func (repository *GasStationRepository) Save(gasStations []gsmodel.GasStation) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	for _, myGasStation := range gasStations {
+		myGasStation.GasPrices = nil
+		repository.gasStations[myGasStation.ID] = myGasStation
+	}
+	return nil
+}
+
+func (repository *GasStationRepository) sortedGasStations() []gsmodel.GasStation {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []gsmodel.GasStation{}
+	for _, myGasStation := range repository.gasStations {
+		result = append(result, myGasStation)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+func (repository *GasStationRepository) withPrices(gasStation gsmodel.GasStation, priceLimit int) gsmodel.GasStation {
+	if priceLimit <= 0 || repository.priceRepository == nil {
+		return gasStation
+	}
+	gasStation.GasPrices = repository.priceRepository.FindByStid(gasStation.ID)
+	if len(gasStation.GasPrices) > priceLimit {
+		gasStation.GasPrices = gasStation.GasPrices[:priceLimit]
+	}
+	return gasStation
+}
@@ -0,0 +1,102 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package memrepo
+
+import (
+	unmodel "react-and-go/pkd/notification/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+type OutboxRepository struct {
+	mutex       sync.RWMutex
+	outboxItems map[int64]unmodel.OutboxItem
+	nextId      int64
+}
+
+func NewOutboxRepository() *OutboxRepository {
+	return &OutboxRepository{outboxItems: make(map[int64]unmodel.OutboxItem)}
+}
+
+func (repository *OutboxRepository) ClaimDue(now time.Time, leaseUntil time.Time, limit int) []unmodel.OutboxItem {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	result := []unmodel.OutboxItem{}
+	for _, outboxItem := range repository.outboxItems {
+		if outboxItem.Status == unmodel.OutboxPending && !outboxItem.NextAttemptAt.After(now) {
+			result = append(result, outboxItem)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	for index := range result {
+		result[index].NextAttemptAt = leaseUntil
+		result[index].Attempts++
+		result[index].UpdatedAt = now
+		repository.outboxItems[result[index].ID] = result[index]
+	}
+	return result
+}
+
+func (repository *OutboxRepository) Save(outboxItem *unmodel.OutboxItem) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	if outboxItem.ID == 0 {
+		repository.nextId++
+		outboxItem.ID = repository.nextId
+		outboxItem.CreatedAt = time.Now()
+	}
+	outboxItem.UpdatedAt = time.Now()
+	repository.outboxItems[outboxItem.ID] = *outboxItem
+	return nil
+}
+
+func (repository *OutboxRepository) Finish(outboxItem unmodel.OutboxItem, claimedAttempts int) (bool, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	myOutboxItem, found := repository.outboxItems[outboxItem.ID]
+	if !found || myOutboxItem.Status != unmodel.OutboxPending || myOutboxItem.Attempts != claimedAttempts {
+		return false, nil
+	}
+	outboxItem.UpdatedAt = time.Now()
+	repository.outboxItems[outboxItem.ID] = outboxItem
+	return true, nil
+}
+
+func (repository *OutboxRepository) CountByStatus() map[unmodel.OutboxStatus]int64 {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := make(map[unmodel.OutboxStatus]int64)
+	for _, outboxItem := range repository.outboxItems {
+		result[outboxItem.Status]++
+	}
+	return result
+}
+
+func (repository *OutboxRepository) DeleteDoneBefore(before time.Time) (int64, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	var deleted int64
+	for id, outboxItem := range repository.outboxItems {
+		if outboxItem.Status == unmodel.OutboxDone && outboxItem.UpdatedAt.Before(before) {
+			delete(repository.outboxItems, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,62 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package memrepo
+
+import (
+	"react-and-go/pkd/poller/pomodel"
+	"sync"
+)
+
+type PollStateRepository struct {
+	mutex        sync.RWMutex
+	keyStates    map[string]pomodel.PollKeyState
+	regionStates map[string]pomodel.PollRegionState
+}
+
+func NewPollStateRepository() *PollStateRepository {
+	return &PollStateRepository{keyStates: make(map[string]pomodel.PollKeyState), regionStates: make(map[string]pomodel.PollRegionState)}
+}
+
+func (repository *PollStateRepository) FindKeyStates() []pomodel.PollKeyState {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []pomodel.PollKeyState{}
+	for _, keyState := range repository.keyStates {
+		result = append(result, keyState)
+	}
+	return result
+}
+
+func (repository *PollStateRepository) SaveKeyState(pollKeyState *pomodel.PollKeyState) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	repository.keyStates[pollKeyState.KeyId] = *pollKeyState
+	return nil
+}
+
+func (repository *PollStateRepository) FindRegionStates() []pomodel.PollRegionState {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []pomodel.PollRegionState{}
+	for _, regionState := range repository.regionStates {
+		result = append(result, regionState)
+	}
+	return result
+}
+
+func (repository *PollStateRepository) SaveRegionState(pollRegionState *pomodel.PollRegionState) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	repository.regionStates[pollRegionState.Name] = *pollRegionState
+	return nil
+}
@@ -0,0 +1,253 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package memrepo
+
+import (
+	"fmt"
+	"react-and-go/pkd/gasstation/gsmodel"
+	unmodel "react-and-go/pkd/notification/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+type PriceRepository struct {
+	mutex        sync.RWMutex
+	gasPrices    map[int64]gsmodel.GasPrice
+	nextId       int64
+	importStates map[string]gsmodel.PriceImportState
+	hourlyPrices []gsmodel.HourlyPrice
+	dailyPrices  []gsmodel.DailyPrice
+	// the outbox items of CreateNew
+	outboxRepository *OutboxRepository
+}
+
+func NewPriceRepository(outboxRepository *OutboxRepository) *PriceRepository {
+	return &PriceRepository{gasPrices: make(map[int64]gsmodel.GasPrice), importStates: make(map[string]gsmodel.PriceImportState),
+		outboxRepository: outboxRepository}
+}
+
+func (repository *PriceRepository) FindByStidsSince(stids []string, start time.Time) []gsmodel.GasPrice {
+	stidSet := make(map[string]bool)
+	for _, stid := range stids {
+		stidSet[stid] = true
+	}
+	return repository.find(func(gasPrice gsmodel.GasPrice) bool {
+		return stidSet[gasPrice.GasStationID] && !gasPrice.Date.Before(start)
+	})
+}
+
+func (repository *PriceRepository) FindByStid(stid string) []gsmodel.GasPrice {
+	return repository.find(func(gasPrice gsmodel.GasPrice) bool {
+		return gasPrice.GasStationID == stid
+	})
+}
+
+func (repository *PriceRepository) FindByStidsBetween(stids []string, start time.Time, end time.Time) []gsmodel.GasPrice {
+	stidSet := make(map[string]bool)
+	for _, stid := range stids {
+		stidSet[stid] = true
+	}
+	return repository.find(func(gasPrice gsmodel.GasPrice) bool {
+		return stidSet[gasPrice.GasStationID] && !gasPrice.Date.Before(start) && !gasPrice.Date.After(end)
+	})
+}
+
+func (repository *PriceRepository) Save(gasPrices []gsmodel.GasPrice) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	for index := range gasPrices {
+		if gasPrices[index].ID == 0 {
+			repository.nextId++
+			gasPrices[index].ID = repository.nextId
+		}
+		repository.gasPrices[gasPrices[index].ID] = gasPrices[index]
+	}
+	return nil
+}
+
+func (repository *PriceRepository) CreateNew(gasPrices []gsmodel.GasPrice, newOutboxItems func(createdPrices []gsmodel.GasPrice) ([]unmodel.OutboxItem, error)) ([]gsmodel.GasPrice, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	existingKeys := make(map[string]bool)
+	for _, gasPrice := range repository.gasPrices {
+		existingKeys[priceKey(gasPrice)] = true
+	}
+	newIndexes := []int{}
+	result := []gsmodel.GasPrice{}
+	for index := range gasPrices {
+		if existingKeys[priceKey(gasPrices[index])] {
+			continue
+		}
+		existingKeys[priceKey(gasPrices[index])] = true
+		newIndexes = append(newIndexes, index)
+		result = append(result, gasPrices[index])
+	}
+	// the prices are only stored with their outbox item
+	if newOutboxItems != nil && len(result) > 0 {
+		outboxItems, err := newOutboxItems(result)
+		if err != nil {
+			return []gsmodel.GasPrice{}, err
+		}
+		for index := range outboxItems {
+			if err := repository.outboxRepository.Save(&outboxItems[index]); err != nil {
+				return []gsmodel.GasPrice{}, err
+			}
+		}
+	}
+	for resultIndex, index := range newIndexes {
+		repository.nextId++
+		gasPrices[index].ID = repository.nextId
+		result[resultIndex].ID = repository.nextId
+		repository.gasPrices[gasPrices[index].ID] = gasPrices[index]
+	}
+	return result, nil
+}
+
+func priceKey(gasPrice gsmodel.GasPrice) string {
+	return fmt.Sprintf("%v|%v", gasPrice.GasStationID, gasPrice.Date.UnixNano())
+}
+
+func (repository *PriceRepository) CreateInBatches(gasPrices []gsmodel.GasPrice, batchSize int) error {
+	return repository.Save(gasPrices)
+}
+
+func (repository *PriceRepository) FindImportState(fileName string) gsmodel.PriceImportState {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	if priceImportState, found := repository.importStates[fileName]; found {
+		return priceImportState
+	}
+	return gsmodel.PriceImportState{FileName: fileName}
+}
+
+func (repository *PriceRepository) SaveImportState(priceImportState *gsmodel.PriceImportState) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	priceImportState.UpdatedAt = time.Now()
+	repository.importStates[priceImportState.FileName] = *priceImportState
+	return nil
+}
+
+func (repository *PriceRepository) FindOldestDate() time.Time {
+	result := repository.find(func(gasPrice gsmodel.GasPrice) bool {
+		return true
+	})
+	if len(result) == 0 {
+		return time.Time{}
+	}
+	return result[len(result)-1].Date
+}
+
+func (repository *PriceRepository) FindByPeriod(start time.Time, end time.Time) []gsmodel.GasPrice {
+	return repository.find(func(gasPrice gsmodel.GasPrice) bool {
+		return !gasPrice.Date.Before(start) && gasPrice.Date.Before(end)
+	})
+}
+
+func (repository *PriceRepository) FindAggregatesByPeriod(start time.Time, end time.Time) ([]gsmodel.HourlyPrice, []gsmodel.DailyPrice) {
+	return repository.FindHourlyPrices(nil, start, end), repository.FindDailyPrices(nil, start, end)
+}
+
+func (repository *PriceRepository) ReplaceWithAggregates(hourlyPrices []gsmodel.HourlyPrice, dailyPrices []gsmodel.DailyPrice, start time.Time, end time.Time) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	inPeriod := func(date time.Time) bool {
+		return !date.Before(start) && date.Before(end)
+	}
+	myHourlyPrices := append([]gsmodel.HourlyPrice{}, hourlyPrices...)
+	for _, hourlyPrice := range repository.hourlyPrices {
+		if !inPeriod(hourlyPrice.Start) {
+			myHourlyPrices = append(myHourlyPrices, hourlyPrice)
+		}
+	}
+	myDailyPrices := append([]gsmodel.DailyPrice{}, dailyPrices...)
+	for _, dailyPrice := range repository.dailyPrices {
+		if !inPeriod(dailyPrice.Start) {
+			myDailyPrices = append(myDailyPrices, dailyPrice)
+		}
+	}
+	repository.hourlyPrices = myHourlyPrices
+	repository.dailyPrices = myDailyPrices
+	for id, gasPrice := range repository.gasPrices {
+		if inPeriod(gasPrice.Date) {
+			delete(repository.gasPrices, id)
+		}
+	}
+	return nil
+}
+
+// nil stids find the prices of all stations
+func (repository *PriceRepository) FindHourlyPrices(stids []string, start time.Time, end time.Time) []gsmodel.HourlyPrice {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	stidSet := make(map[string]bool)
+	for _, stid := range stids {
+		stidSet[stid] = true
+	}
+	result := []gsmodel.HourlyPrice{}
+	for _, hourlyPrice := range repository.hourlyPrices {
+		if (stids == nil || stidSet[hourlyPrice.GasStationID]) && !hourlyPrice.Start.Before(start) && hourlyPrice.Start.Before(end) {
+			result = append(result, hourlyPrice)
+		}
+	}
+	return result
+}
+
+func (repository *PriceRepository) FindDailyPrices(stids []string, start time.Time, end time.Time) []gsmodel.DailyPrice {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	stidSet := make(map[string]bool)
+	for _, stid := range stids {
+		stidSet[stid] = true
+	}
+	result := []gsmodel.DailyPrice{}
+	for _, dailyPrice := range repository.dailyPrices {
+		if (stids == nil || stidSet[dailyPrice.GasStationID]) && !dailyPrice.Start.Before(start) && dailyPrice.Start.Before(end) {
+			result = append(result, dailyPrice)
+		}
+	}
+	return result
+}
+
+func (repository *PriceRepository) DeleteHourlyPricesBefore(before time.Time) (int64, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	myHourlyPrices := []gsmodel.HourlyPrice{}
+	for _, hourlyPrice := range repository.hourlyPrices {
+		if !hourlyPrice.Start.Before(before) {
+			myHourlyPrices = append(myHourlyPrices, hourlyPrice)
+		}
+	}
+	deleted := int64(len(repository.hourlyPrices) - len(myHourlyPrices))
+	repository.hourlyPrices = myHourlyPrices
+	return deleted, nil
+}
+
+func (repository *PriceRepository) find(matches func(gasPrice gsmodel.GasPrice) bool) []gsmodel.GasPrice {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []gsmodel.GasPrice{}
+	for _, myGasPrice := range repository.gasPrices {
+		if matches(myGasPrice) {
+			result = append(result, myGasPrice)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Date.Equal(result[j].Date) {
+			return result[i].ID > result[j].ID
+		}
+		return result[i].Date.After(result[j].Date)
+	})
+	return result
+}
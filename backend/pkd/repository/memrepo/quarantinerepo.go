@@ -0,0 +1,108 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package memrepo
+
+import (
+	"react-and-go/pkd/gasstation/gsmodel"
+	unmodel "react-and-go/pkd/notification/model"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type QuarantineRepository struct {
+	mutex             sync.RWMutex
+	quarantinedPrices map[int64]gsmodel.QuarantinedPrice
+	nextId            int64
+	// the confirmed prices of Confirm
+	priceRepository *PriceRepository
+}
+
+func NewQuarantineRepository(priceRepository *PriceRepository) *QuarantineRepository {
+	return &QuarantineRepository{quarantinedPrices: make(map[int64]gsmodel.QuarantinedPrice), priceRepository: priceRepository}
+}
+
+func (repository *QuarantineRepository) Save(quarantinedPrices []gsmodel.QuarantinedPrice) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	for index := range quarantinedPrices {
+		if quarantinedPrices[index].ID == 0 {
+			repository.nextId++
+			quarantinedPrices[index].ID = repository.nextId
+		}
+		repository.quarantinedPrices[quarantinedPrices[index].ID] = quarantinedPrices[index]
+	}
+	return nil
+}
+
+func (repository *QuarantineRepository) Confirm(quarantinedPrice gsmodel.QuarantinedPrice, newOutboxItems func(createdPrices []gsmodel.GasPrice) ([]unmodel.OutboxItem, error)) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	if storedPrice, found := repository.quarantinedPrices[quarantinedPrice.ID]; !found || storedPrice.Status != gsmodel.QuarantinePending {
+		return gorm.ErrRecordNotFound
+	}
+	if _, err := repository.priceRepository.CreateNew([]gsmodel.GasPrice{quarantinedPrice.ToGasPrice()}, newOutboxItems); err != nil {
+		return err
+	}
+	repository.quarantinedPrices[quarantinedPrice.ID] = quarantinedPrice
+	return nil
+}
+
+func (repository *QuarantineRepository) FindById(id int64) (gsmodel.QuarantinedPrice, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	if quarantinedPrice, found := repository.quarantinedPrices[id]; found {
+		return quarantinedPrice, nil
+	}
+	return gsmodel.QuarantinedPrice{}, gorm.ErrRecordNotFound
+}
+
+func (repository *QuarantineRepository) FindByStatus(status gsmodel.QuarantineStatus, limit int) []gsmodel.QuarantinedPrice {
+	result := repository.find(func(quarantinedPrice gsmodel.QuarantinedPrice) bool {
+		return quarantinedPrice.Status == status
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func (repository *QuarantineRepository) FindByStidsAndStatus(stids []string, status gsmodel.QuarantineStatus) []gsmodel.QuarantinedPrice {
+	stidSet := make(map[string]bool)
+	for _, stid := range stids {
+		stidSet[stid] = true
+	}
+	return repository.find(func(quarantinedPrice gsmodel.QuarantinedPrice) bool {
+		return quarantinedPrice.Status == status && stidSet[quarantinedPrice.GasStationID]
+	})
+}
+
+// newest first
+func (repository *QuarantineRepository) find(matches func(quarantinedPrice gsmodel.QuarantinedPrice) bool) []gsmodel.QuarantinedPrice {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []gsmodel.QuarantinedPrice{}
+	for _, quarantinedPrice := range repository.quarantinedPrices {
+		if matches(quarantinedPrice) {
+			result = append(result, quarantinedPrice)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Date.Equal(result[j].Date) {
+			return result[i].ID > result[j].ID
+		}
+		return result[i].Date.After(result[j].Date)
+	})
+	return result
+}
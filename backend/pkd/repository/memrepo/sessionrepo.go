@@ -0,0 +1,138 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package memrepo
+
+import (
+	"react-and-go/pkd/appuser/aumodel"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SessionRepository struct {
+	mutex         sync.RWMutex
+	userSessions  map[string]aumodel.UserSession
+	refreshTokens map[string]aumodel.RefreshToken
+}
+
+func NewSessionRepository() *SessionRepository {
+	return &SessionRepository{userSessions: make(map[string]aumodel.UserSession), refreshTokens: make(map[string]aumodel.RefreshToken)}
+}
+
+func (repository *SessionRepository) CreateSession(userSession *aumodel.UserSession, refreshToken aumodel.RefreshToken) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	repository.userSessions[userSession.ID] = *userSession
+	repository.refreshTokens[refreshToken.TokenHash] = refreshToken
+	return nil
+}
+
+func (repository *SessionRepository) FindSession(id string) (aumodel.UserSession, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	if userSession, found := repository.userSessions[id]; found {
+		return userSession, nil
+	}
+	return aumodel.UserSession{}, gorm.ErrRecordNotFound
+}
+
+func (repository *SessionRepository) FindSessions(username string) []aumodel.UserSession {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []aumodel.UserSession{}
+	for _, userSession := range repository.userSessions {
+		if userSession.Username == username {
+			result = append(result, userSession)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
+	return result
+}
+
+func (repository *SessionRepository) FindRefreshToken(tokenHash string) (aumodel.RefreshToken, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	if refreshToken, found := repository.refreshTokens[tokenHash]; found {
+		return refreshToken, nil
+	}
+	return aumodel.RefreshToken{}, gorm.ErrRecordNotFound
+}
+
+func (repository *SessionRepository) RotateRefreshToken(tokenHash string, newRefreshToken aumodel.RefreshToken, now time.Time) (bool, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	refreshToken, found := repository.refreshTokens[tokenHash]
+	if !found || refreshToken.Used {
+		return false, nil
+	}
+	refreshToken.Used = true
+	repository.refreshTokens[tokenHash] = refreshToken
+	repository.refreshTokens[newRefreshToken.TokenHash] = newRefreshToken
+	if userSession, found := repository.userSessions[newRefreshToken.SessionID]; found {
+		userSession.LastUsedAt = now
+		userSession.ExpiresAt = newRefreshToken.ExpiresAt
+		repository.userSessions[userSession.ID] = userSession
+	}
+	return true, nil
+}
+
+func (repository *SessionRepository) RevokeSessions(ids []string, reason string, now time.Time) (int64, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	var revoked int64
+	for _, id := range ids {
+		if userSession, found := repository.userSessions[id]; found && !userSession.Revoked {
+			userSession.Revoked = true
+			userSession.RevokedAt = now
+			userSession.RevokeReason = reason
+			repository.userSessions[id] = userSession
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (repository *SessionRepository) DeleteSessionsBefore(before time.Time) (int64, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	var deleted int64
+	for id, userSession := range repository.userSessions {
+		if userSession.ExpiresAt.Before(before) || (userSession.Revoked && userSession.RevokedAt.Before(before)) {
+			delete(repository.userSessions, id)
+			deleted++
+		}
+	}
+	for tokenHash, refreshToken := range repository.refreshTokens {
+		if _, found := repository.userSessions[refreshToken.SessionID]; !found {
+			delete(repository.refreshTokens, tokenHash)
+		}
+	}
+	return deleted, nil
+}
+
+func (repository *SessionRepository) deleteByUsername(username string) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	for id, userSession := range repository.userSessions {
+		if userSession.Username == username {
+			delete(repository.userSessions, id)
+		}
+	}
+	for tokenHash, refreshToken := range repository.refreshTokens {
+		if _, found := repository.userSessions[refreshToken.SessionID]; !found {
+			delete(repository.refreshTokens, tokenHash)
+		}
+	}
+}
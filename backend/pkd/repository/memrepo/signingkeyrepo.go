@@ -0,0 +1,60 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package memrepo
+
+import (
+	"react-and-go/pkd/token/tkmodel"
+	"sort"
+	"sync"
+)
+
+type SigningKeyRepository struct {
+	mutex       sync.RWMutex
+	signingKeys map[string]tkmodel.SigningKey
+}
+
+func NewSigningKeyRepository() *SigningKeyRepository {
+	return &SigningKeyRepository{signingKeys: make(map[string]tkmodel.SigningKey)}
+}
+
+func (repository *SigningKeyRepository) FindAll() []tkmodel.SigningKey {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []tkmodel.SigningKey{}
+	for _, signingKey := range repository.signingKeys {
+		result = append(result, signingKey)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ActivatesAt.Equal(result[j].ActivatesAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].ActivatesAt.Before(result[j].ActivatesAt)
+	})
+	return result
+}
+
+func (repository *SigningKeyRepository) Save(signingKey *tkmodel.SigningKey) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	repository.signingKeys[signingKey.Kid] = *signingKey
+	return nil
+}
+
+func (repository *SigningKeyRepository) Delete(kids []string) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	for _, kid := range kids {
+		delete(repository.signingKeys, kid)
+	}
+	return nil
+}
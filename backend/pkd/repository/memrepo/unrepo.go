@@ -0,0 +1,265 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package memrepo
+
+import (
+	"fmt"
+	unmodel "react-and-go/pkd/notification/model"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type NotificationRepository struct {
+	mutex              sync.RWMutex
+	userNotifications  map[int64]unmodel.UserNotification
+	nextId             int64
+	notificationStates map[string]unmodel.NotificationState
+	suppressions       []unmodel.NotificationSuppression
+	digestEntries      map[int64]unmodel.DigestEntry
+	outboxRepository   *OutboxRepository
+}
+
+func NewNotificationRepository(outboxRepository *OutboxRepository) *NotificationRepository {
+	return &NotificationRepository{userNotifications: make(map[int64]unmodel.UserNotification),
+		notificationStates: make(map[string]unmodel.NotificationState), digestEntries: make(map[int64]unmodel.DigestEntry),
+		outboxRepository: outboxRepository}
+}
+
+func (repository *NotificationRepository) Save(userNotifications []unmodel.UserNotification) ([]unmodel.UserNotification, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	return repository.save(userNotifications), nil
+}
+
+func (repository *NotificationRepository) save(userNotifications []unmodel.UserNotification) []unmodel.UserNotification {
+	for index := range userNotifications {
+		if userNotifications[index].ID == 0 {
+			repository.nextId++
+			userNotifications[index].ID = repository.nextId
+		}
+		repository.userNotifications[userNotifications[index].ID] = userNotifications[index]
+	}
+	return userNotifications
+}
+
+func (repository *NotificationRepository) FindByUserUuid(userUuid string, onlyNew bool, limit int) []unmodel.UserNotification {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []unmodel.UserNotification{}
+	for _, userNotification := range repository.userNotifications {
+		if userNotification.UserUuid == userUuid && (!onlyNew || !userNotification.NotificationSend) {
+			result = append(result, userNotification)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Timestamp.Equal(result[j].Timestamp) {
+			return result[i].ID > result[j].ID
+		}
+		return result[i].Timestamp.After(result[j].Timestamp)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func (repository *NotificationRepository) FindById(id int64) (unmodel.UserNotification, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	if userNotification, found := repository.userNotifications[id]; found {
+		return userNotification, nil
+	}
+	return unmodel.UserNotification{}, gorm.ErrRecordNotFound
+}
+
+func (repository *NotificationRepository) MarkSent(ids []int64) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	for _, id := range ids {
+		if userNotification, found := repository.userNotifications[id]; found {
+			userNotification.NotificationSend = true
+			repository.userNotifications[id] = userNotification
+		}
+	}
+	return nil
+}
+
+func (repository *NotificationRepository) UpdateDeliveryStatus(id int64, deliveryStatus string, deliveryAttempts int) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	if userNotification, found := repository.userNotifications[id]; found {
+		userNotification.DeliveryStatus = deliveryStatus
+		userNotification.DeliveryAttempts = deliveryAttempts
+		repository.userNotifications[id] = userNotification
+	}
+	return nil
+}
+
+func (repository *NotificationRepository) SaveEvaluation(userNotifications []unmodel.UserNotification, notificationStates []unmodel.NotificationState,
+	suppressions []unmodel.NotificationSuppression, digestEntries []unmodel.DigestEntry,
+	newOutboxItems func(savedNotifications []unmodel.UserNotification) ([]unmodel.OutboxItem, error)) ([]unmodel.UserNotification, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	result := repository.save(userNotifications)
+	if err := repository.saveOutboxItems(result, newOutboxItems); err != nil {
+		return []unmodel.UserNotification{}, err
+	}
+	for _, notificationState := range notificationStates {
+		repository.notificationStates[notificationStateKey(notificationState)] = notificationState
+	}
+	for _, suppression := range suppressions {
+		repository.nextId++
+		suppression.ID = repository.nextId
+		repository.suppressions = append(repository.suppressions, suppression)
+	}
+	for _, digestEntry := range digestEntries {
+		repository.nextId++
+		digestEntry.ID = repository.nextId
+		repository.digestEntries[digestEntry.ID] = digestEntry
+	}
+	return result, nil
+}
+
+// the notifications are kept after a failure, the evaluation is retried anyway
+func (repository *NotificationRepository) saveOutboxItems(savedNotifications []unmodel.UserNotification,
+	newOutboxItems func(savedNotifications []unmodel.UserNotification) ([]unmodel.OutboxItem, error)) error {
+	if newOutboxItems == nil || len(savedNotifications) == 0 {
+		return nil
+	}
+	outboxItems, err := newOutboxItems(savedNotifications)
+	if err != nil {
+		return err
+	}
+	for index := range outboxItems {
+		if err := repository.outboxRepository.Save(&outboxItems[index]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func notificationStateKey(notificationState unmodel.NotificationState) string {
+	return fmt.Sprintf("%v|%v|%v", notificationState.UserUuid, notificationState.GasStationID, notificationState.FuelType)
+}
+
+func (repository *NotificationRepository) FindStates(stids []string) []unmodel.NotificationState {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	stidSet := make(map[string]bool)
+	for _, stid := range stids {
+		stidSet[stid] = true
+	}
+	result := []unmodel.NotificationState{}
+	for _, notificationState := range repository.notificationStates {
+		if stidSet[notificationState.GasStationID] {
+			result = append(result, notificationState)
+		}
+	}
+	return result
+}
+
+func (repository *NotificationRepository) FindSuppressions(userUuid string, limit int) []unmodel.NotificationSuppression {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []unmodel.NotificationSuppression{}
+	for index := len(repository.suppressions) - 1; index >= 0 && (limit <= 0 || len(result) < limit); index-- {
+		if repository.suppressions[index].UserUuid == userUuid {
+			result = append(result, repository.suppressions[index])
+		}
+	}
+	return result
+}
+
+func (repository *NotificationRepository) DeleteSuppressionsBefore(before time.Time) (int64, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	suppressions := []unmodel.NotificationSuppression{}
+	for _, suppression := range repository.suppressions {
+		if !suppression.Timestamp.Before(before) {
+			suppressions = append(suppressions, suppression)
+		}
+	}
+	deleted := int64(len(repository.suppressions) - len(suppressions))
+	repository.suppressions = suppressions
+	return deleted, nil
+}
+
+func (repository *NotificationRepository) FindDigestEntries() []unmodel.DigestEntry {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []unmodel.DigestEntry{}
+	for _, digestEntry := range repository.digestEntries {
+		result = append(result, digestEntry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Timestamp.Equal(result[j].Timestamp) {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	return result
+}
+
+func (repository *NotificationRepository) FindDigestEntriesByUserUuid(userUuid string) []unmodel.DigestEntry {
+	result := []unmodel.DigestEntry{}
+	for _, digestEntry := range repository.FindDigestEntries() {
+		if digestEntry.UserUuid == userUuid {
+			result = append(result, digestEntry)
+		}
+	}
+	return result
+}
+
+func (repository *NotificationRepository) SaveDigest(userNotification unmodel.UserNotification, digestEntryIds []int64,
+	newOutboxItems func(savedNotifications []unmodel.UserNotification) ([]unmodel.OutboxItem, error)) (unmodel.UserNotification, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	result := repository.save([]unmodel.UserNotification{userNotification})
+	if err := repository.saveOutboxItems(result, newOutboxItems); err != nil {
+		return unmodel.UserNotification{}, err
+	}
+	for _, id := range digestEntryIds {
+		delete(repository.digestEntries, id)
+	}
+	return result[0], nil
+}
+
+func (repository *NotificationRepository) deleteByUserUuid(userUuid string) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	for id, userNotification := range repository.userNotifications {
+		if userNotification.UserUuid == userUuid {
+			delete(repository.userNotifications, id)
+		}
+	}
+	for key, notificationState := range repository.notificationStates {
+		if notificationState.UserUuid == userUuid {
+			delete(repository.notificationStates, key)
+		}
+	}
+	suppressions := []unmodel.NotificationSuppression{}
+	for _, suppression := range repository.suppressions {
+		if suppression.UserUuid != userUuid {
+			suppressions = append(suppressions, suppression)
+		}
+	}
+	repository.suppressions = suppressions
+	for id, digestEntry := range repository.digestEntries {
+		if digestEntry.UserUuid == userUuid {
+			delete(repository.digestEntries, id)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package memrepo
+
+import (
+	"react-and-go/pkd/appuser/aumodel"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UserTokenRepository struct {
+	mutex      sync.Mutex
+	userTokens map[string]aumodel.UserToken
+}
+
+func NewUserTokenRepository() *UserTokenRepository {
+	return &UserTokenRepository{userTokens: make(map[string]aumodel.UserToken)}
+}
+
+func (repository *UserTokenRepository) Save(userToken *aumodel.UserToken) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	repository.userTokens[userToken.TokenHash] = *userToken
+	return nil
+}
+
+func (repository *UserTokenRepository) Use(tokenHash string, purpose aumodel.TokenPurpose, now time.Time) (aumodel.UserToken, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	userToken, found := repository.userTokens[tokenHash]
+	if !found || userToken.Purpose != purpose || userToken.Used || !now.Before(userToken.ExpiresAt) {
+		return aumodel.UserToken{}, gorm.ErrRecordNotFound
+	}
+	userToken.Used = true
+	repository.userTokens[tokenHash] = userToken
+	return userToken, nil
+}
+
+func (repository *UserTokenRepository) deleteByUsername(username string) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	for tokenHash, userToken := range repository.userTokens {
+		if userToken.Username == username {
+			delete(repository.userTokens, tokenHash)
+		}
+	}
+}
+
+func (repository *UserTokenRepository) DeleteExpiredBefore(before time.Time) (int64, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	var deleted int64
+	for tokenHash, userToken := range repository.userTokens {
+		if userToken.ExpiresAt.Before(before) {
+			delete(repository.userTokens, tokenHash)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,148 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package repository
+
+import (
+	"react-and-go/pkd/appuser/aumodel"
+	"react-and-go/pkd/gasstation/gsmodel"
+	unmodel "react-and-go/pkd/notification/model"
+	"react-and-go/pkd/poller/pomodel"
+	"react-and-go/pkd/token/tkmodel"
+	"time"
+)
+
+type GasStationRepository interface {
+	FindAllLocations() []gsmodel.GasStation // only ID, Latitude and Longitude
+	FindInBatches(batchSize int, process func(gasStations []gsmodel.GasStation) error) error
+	FindByIds(ids []string) []gsmodel.GasStation
+	FindByIdsWithPrices(ids []string, priceLimit int) []gsmodel.GasStation
+	FindById(id string, priceLimit int) gsmodel.GasStation
+	FindBySearchPlace(place string, postCode string, stationName string, priceLimit int) []gsmodel.GasStation
+	Save(gasStations []gsmodel.GasStation) error
+}
+
+type PriceRepository interface {
+	FindByStidsSince(stids []string, start time.Time) []gsmodel.GasPrice // newest first
+	FindByStid(stid string) []gsmodel.GasPrice                           // newest first
+	FindByStidsBetween(stids []string, start time.Time, end time.Time) []gsmodel.GasPrice
+	Save(gasPrices []gsmodel.GasPrice) error
+	// skips the prices of an existing stid and date, the outbox item of the created prices is stored in the same transaction
+	CreateNew(gasPrices []gsmodel.GasPrice, newOutboxItems func(createdPrices []gsmodel.GasPrice) ([]unmodel.OutboxItem, error)) ([]gsmodel.GasPrice, error)
+	CreateInBatches(gasPrices []gsmodel.GasPrice, batchSize int) error // one transaction
+	FindImportState(fileName string) gsmodel.PriceImportState
+	SaveImportState(priceImportState *gsmodel.PriceImportState) error
+	FindOldestDate() time.Time                                                                           // zero without prices
+	FindByPeriod(start time.Time, end time.Time) []gsmodel.GasPrice                                      // end is exclusive
+	FindAggregatesByPeriod(start time.Time, end time.Time) ([]gsmodel.HourlyPrice, []gsmodel.DailyPrice) // end is exclusive
+	ReplaceWithAggregates(hourlyPrices []gsmodel.HourlyPrice, dailyPrices []gsmodel.DailyPrice, start time.Time, end time.Time) error
+	FindHourlyPrices(stids []string, start time.Time, end time.Time) []gsmodel.HourlyPrice
+	FindDailyPrices(stids []string, start time.Time, end time.Time) []gsmodel.DailyPrice
+	DeleteHourlyPricesBefore(before time.Time) (int64, error)
+}
+
+type QuarantineRepository interface {
+	Save(quarantinedPrices []gsmodel.QuarantinedPrice) error
+	// resolves the pending price and stores its price with the outbox items in one transaction, gorm.ErrRecordNotFound if it is not pending
+	Confirm(quarantinedPrice gsmodel.QuarantinedPrice, newOutboxItems func(createdPrices []gsmodel.GasPrice) ([]unmodel.OutboxItem, error)) error
+	FindById(id int64) (gsmodel.QuarantinedPrice, error)
+	FindByStatus(status gsmodel.QuarantineStatus, limit int) []gsmodel.QuarantinedPrice // newest first
+	FindByStidsAndStatus(stids []string, status gsmodel.QuarantineStatus) []gsmodel.QuarantinedPrice
+}
+
+type AppUserRepository interface {
+	FindAll() []aumodel.AppUser
+	FindByUsername(username string) (aumodel.AppUser, error)
+	FindByUuid(userUuid string) (aumodel.AppUser, error)
+	Save(appUser *aumodel.AppUser) error
+	FindPostCodeLocations(label string, limit int) []aumodel.PostCodeLocation
+	FindAllPostCodeLocations() []aumodel.PostCodeLocation
+	SavePostCodeLocations(postCodeLocations []aumodel.PostCodeLocation) error
+	FindAlertRules(userUuid string) []aumodel.AlertRule
+	FindActiveAlertRules() []aumodel.AlertRule
+	FindAlertRuleUserUuids() []string // the users with alert rules, active or not
+	FindAlertRule(id uint, userUuid string) (aumodel.AlertRule, error)
+	CountAlertRules(userUuid string) int
+	SaveAlertRule(alertRule *aumodel.AlertRule) error
+	DeleteAlertRule(id uint, userUuid string) bool // with the last seen prices of the rule
+	FindLastSeenPrices(stids []string) []aumodel.AlertRuleLastSeen
+	SaveLastSeenPrices(lastSeenPrices []aumodel.AlertRuleLastSeen) error
+	// erases the user with the alert rules, last seen prices, sessions, user tokens, notifications, notification states,
+	// suppressions and digest entries in one transaction
+	Delete(appUser aumodel.AppUser) error
+}
+
+type NotificationRepository interface {
+	Save(userNotifications []unmodel.UserNotification) ([]unmodel.UserNotification, error)
+	FindByUserUuid(userUuid string, onlyNew bool, limit int) []unmodel.UserNotification // newest first
+	FindById(id int64) (unmodel.UserNotification, error)
+	MarkSent(ids []int64) error
+	UpdateDeliveryStatus(id int64, deliveryStatus string, deliveryAttempts int) error
+	// stores the results of an alert evaluation with the outbox items of the saved notifications in one transaction
+	SaveEvaluation(userNotifications []unmodel.UserNotification, notificationStates []unmodel.NotificationState,
+		suppressions []unmodel.NotificationSuppression, digestEntries []unmodel.DigestEntry,
+		newOutboxItems func(savedNotifications []unmodel.UserNotification) ([]unmodel.OutboxItem, error)) ([]unmodel.UserNotification, error)
+	FindStates(stids []string) []unmodel.NotificationState
+	FindSuppressions(userUuid string, limit int) []unmodel.NotificationSuppression // newest first
+	DeleteSuppressionsBefore(before time.Time) (int64, error)
+	FindDigestEntries() []unmodel.DigestEntry                          // oldest first
+	FindDigestEntriesByUserUuid(userUuid string) []unmodel.DigestEntry // oldest first
+	// stores the digest notification with its outbox items and deletes its entries in one transaction
+	SaveDigest(userNotification unmodel.UserNotification, digestEntryIds []int64,
+		newOutboxItems func(savedNotifications []unmodel.UserNotification) ([]unmodel.OutboxItem, error)) (unmodel.UserNotification, error)
+}
+
+type SessionRepository interface {
+	// stores the session with its first refresh token in one transaction
+	CreateSession(userSession *aumodel.UserSession, refreshToken aumodel.RefreshToken) error
+	FindSession(id string) (aumodel.UserSession, error)
+	FindSessions(username string) []aumodel.UserSession // newest first
+	FindRefreshToken(tokenHash string) (aumodel.RefreshToken, error)
+	// marks the token used, stores its successor and extends the session in one transaction, false if the token was used before
+	RotateRefreshToken(tokenHash string, newRefreshToken aumodel.RefreshToken, now time.Time) (bool, error)
+	RevokeSessions(ids []string, reason string, now time.Time) (int64, error)
+	DeleteSessionsBefore(before time.Time) (int64, error) // the expired and revoked sessions with their refresh tokens
+}
+
+type UserTokenRepository interface {
+	Save(userToken *aumodel.UserToken) error
+	// marks the unused and unexpired token used, gorm.ErrRecordNotFound otherwise
+	Use(tokenHash string, purpose aumodel.TokenPurpose, now time.Time) (aumodel.UserToken, error)
+	DeleteExpiredBefore(before time.Time) (int64, error)
+}
+
+type SigningKeyRepository interface {
+	FindAll() []tkmodel.SigningKey // ordered by ActivatesAt
+	Save(signingKey *tkmodel.SigningKey) error
+	Delete(kids []string) error
+}
+
+type AuditLogRepository interface {
+	Save(auditLogEntry *aumodel.AuditLogEntry) error
+	FindLatest(limit int) []aumodel.AuditLogEntry // newest first
+}
+
+type OutboxRepository interface {
+	ClaimDue(now time.Time, leaseUntil time.Time, limit int) []unmodel.OutboxItem // moves the next attempt of the due items to leaseUntil, oldest first
+	Save(outboxItem *unmodel.OutboxItem) error
+	// stores the result of a claim, false if the item was claimed again after its lease expired
+	Finish(outboxItem unmodel.OutboxItem, claimedAttempts int) (bool, error)
+	CountByStatus() map[unmodel.OutboxStatus]int64
+	DeleteDoneBefore(before time.Time) (int64, error)
+}
+
+type PollStateRepository interface {
+	FindKeyStates() []pomodel.PollKeyState
+	SaveKeyState(pollKeyState *pomodel.PollKeyState) error
+	FindRegionStates() []pomodel.PollRegionState
+	SaveRegionState(pollRegionState *pomodel.PollRegionState) error
+}
@@ -0,0 +1,387 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package token
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"react-and-go/pkd/config"
+	"react-and-go/pkd/repository"
+	"react-and-go/pkd/token/tkmodel"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+type signingKey struct {
+	kid          string
+	algorithm    tkmodel.SigningAlgorithm
+	privateKey   crypto.Signer
+	createdAt    time.Time
+	activatesAt  time.Time
+	supersededAt time.Time // the activation of the next key, zero while the key signs
+}
+
+// a json web key of RFC 7517 with the public part of a signing key
+type Jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JwkSet struct {
+	Keys []Jwk `json:"keys"`
+}
+
+var ErrNoSigningKey = errors.New("no active signing key")
+var ErrNoKeySecret = errors.New("JWT_KEY_SECRET is not set")
+var ErrShortKeySecret = fmt.Errorf("JWT_KEY_SECRET is shorter than %v characters", minKeySecretLength)
+
+const minKeySecretLength = 32
+
+const (
+	encryptedKeyPemType = "ENCRYPTED SIGNING KEY" // nonce and AES-256-GCM sealed PKCS8 der, the kid is the additional data
+	plainKeyPemType     = "PRIVATE KEY"           // keys that were stored before the encryption
+)
+
+var signingKeyRepository repository.SigningKeyRepository
+
+// the keys are shared by the backend instances in the database, every instance reloads them
+var signingKeys []signingKey
+var keysMutex sync.RWMutex
+var keysLoadedAt time.Time
+
+func SetRepository(mySigningKeyRepository repository.SigningKeyRepository) {
+	signingKeyRepository = mySigningKeyRepository
+}
+
+func signingAlgorithm() tkmodel.SigningAlgorithm {
+	myAlgorithm := tkmodel.SigningAlgorithm(strings.ToUpper(strings.TrimSpace(os.Getenv("JWT_SIGNING_ALGORITHM"))))
+	if myAlgorithm != tkmodel.RS256 && myAlgorithm != tkmodel.ES256 {
+		return tkmodel.ES256
+	}
+	return myAlgorithm
+}
+
+// creates the first key, pre-publishes the next key after JWT_KEY_ROTATION_DAYS and deletes the keys
+// that were superseded JWT_KEY_RETIRE_HOURS ago. Called at startup and by a cron job.
+func RotateKeys() {
+	if err := reloadKeys(); err != nil {
+		log.Printf("Load signing keys failed: %v\n", err)
+		return
+	}
+	now := time.Now()
+	keysMutex.RLock()
+	myKeys := append([]signingKey{}, signingKeys...)
+	keysMutex.RUnlock()
+	// the new key is published before it signs, so the verifiers can fetch it in time
+	activatesAt := now.Add(time.Duration(config.ReadIntEnv("JWT_KEY_PUBLISH_MINUTES", 60)) * time.Minute)
+	if _, found := currentKey(myKeys, now); !found {
+		activatesAt = now
+	}
+	// a pending key is the newest key and delays the next rotation
+	rotationDue := len(myKeys) == 0 || now.Sub(myKeys[len(myKeys)-1].createdAt) > time.Duration(config.ReadIntEnv("JWT_KEY_ROTATION_DAYS", 30))*24*time.Hour
+	if rotationDue {
+		// concurrent instances can create a key each, the newest one signs
+		newKey, err := generateSigningKey(signingAlgorithm(), now, activatesAt)
+		if err != nil {
+			log.Printf("Create signing key failed: %v\n", err)
+			return
+		}
+		if err := signingKeyRepository.Save(&newKey); err != nil {
+			log.Printf("Store signing key failed: %v\n", err)
+			return
+		}
+		log.Printf("Signing key created: %v activates at: %v\n", newKey.Kid, newKey.ActivatesAt)
+	}
+	retireAfter := time.Duration(config.ReadIntEnv("JWT_KEY_RETIRE_HOURS", 24)) * time.Hour
+	retiredKids := []string{}
+	for _, myKey := range myKeys {
+		if !myKey.supersededAt.IsZero() && now.Sub(myKey.supersededAt) > retireAfter {
+			retiredKids = append(retiredKids, myKey.kid)
+		}
+	}
+	if err := signingKeyRepository.Delete(retiredKids); err != nil {
+		log.Printf("Delete signing keys failed: %v\n", err)
+	}
+	if rotationDue || len(retiredKids) > 0 {
+		if err := reloadKeys(); err != nil {
+			log.Printf("Load signing keys failed: %v\n", err)
+		}
+	}
+}
+
+func generateSigningKey(algorithm tkmodel.SigningAlgorithm, now time.Time, activatesAt time.Time) (tkmodel.SigningKey, error) {
+	var privateKey crypto.Signer
+	var err error
+	if algorithm == tkmodel.RS256 {
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	} else {
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		return tkmodel.SigningKey{}, err
+	}
+	derBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return tkmodel.SigningKey{}, err
+	}
+	myKid, err := uuid.NewRandom()
+	if err != nil {
+		return tkmodel.SigningKey{}, err
+	}
+	pemBytes, err := encryptPrivateKey(derBytes, myKid.String())
+	if err != nil {
+		return tkmodel.SigningKey{}, err
+	}
+	return tkmodel.SigningKey{Kid: myKid.String(), Algorithm: algorithm, PrivateKey: string(pemBytes), CreatedAt: now, ActivatesAt: activatesAt}, nil
+}
+
+// checked at startup, without the secret no signing key can be created or loaded and the logins fail
+func CheckKeySecret() error {
+	mySecret := strings.TrimSpace(os.Getenv("JWT_KEY_SECRET"))
+	if len(mySecret) == 0 {
+		return ErrNoKeySecret
+	}
+	if len(mySecret) < minKeySecretLength {
+		return ErrShortKeySecret
+	}
+	return nil
+}
+
+// the aead of the sha256 of JWT_KEY_SECRET, the secret is shared by the backend instances
+func keyCipher() (cipher.AEAD, error) {
+	if err := CheckKeySecret(); err != nil {
+		return nil, err
+	}
+	mySecret := strings.TrimSpace(os.Getenv("JWT_KEY_SECRET"))
+	myKey := sha256.Sum256([]byte(mySecret))
+	block, err := aes.NewCipher(myKey[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptPrivateKey(derBytes []byte, kid string) ([]byte, error) {
+	aead, err := keyCipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, derBytes, []byte(kid))
+	return pem.EncodeToMemory(&pem.Block{Type: encryptedKeyPemType, Bytes: sealed}), nil
+}
+
+// returns the PKCS8 der and true if the key was stored unencrypted
+func decryptPrivateKey(pemBlock *pem.Block, kid string) ([]byte, bool, error) {
+	if pemBlock.Type == plainKeyPemType {
+		return pemBlock.Bytes, true, nil
+	}
+	if pemBlock.Type != encryptedKeyPemType {
+		return nil, false, fmt.Errorf("unknown pem type: %v", pemBlock.Type)
+	}
+	aead, err := keyCipher()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(pemBlock.Bytes) < aead.NonceSize() {
+		return nil, false, errors.New("encrypted key too short")
+	}
+	derBytes, err := aead.Open(nil, pemBlock.Bytes[:aead.NonceSize()], pemBlock.Bytes[aead.NonceSize():], []byte(kid))
+	return derBytes, false, err
+}
+
+func reloadKeys() error {
+	if signingKeyRepository == nil {
+		return errors.New("no signing key repository")
+	}
+	now := time.Now()
+	myKeys := []signingKey{}
+	for _, myModel := range signingKeyRepository.FindAll() {
+		pemBlock, _ := pem.Decode([]byte(myModel.PrivateKey))
+		if pemBlock == nil {
+			log.Printf("Signing key: %v pem decode failed\n", myModel.Kid)
+			continue
+		}
+		derBytes, plain, err := decryptPrivateKey(pemBlock, myModel.Kid)
+		if err != nil {
+			log.Printf("Signing key: %v decrypt failed: %v\n", myModel.Kid, err)
+			continue
+		}
+		parsedKey, err := x509.ParsePKCS8PrivateKey(derBytes)
+		if err != nil {
+			log.Printf("Signing key: %v parse failed: %v\n", myModel.Kid, err)
+			continue
+		}
+		if plain {
+			encryptStoredKey(myModel, derBytes)
+		}
+		privateKey, ok := parsedKey.(crypto.Signer)
+		if !ok {
+			continue
+		}
+		myKeys = append(myKeys, signingKey{kid: myModel.Kid, algorithm: myModel.Algorithm, privateKey: privateKey, createdAt: myModel.CreatedAt,
+			activatesAt: myModel.ActivatesAt})
+	}
+	for index := range myKeys {
+		for _, nextKey := range myKeys[index+1:] {
+			if !nextKey.activatesAt.After(now) {
+				myKeys[index].supersededAt = nextKey.activatesAt
+				break
+			}
+		}
+	}
+	keysMutex.Lock()
+	defer keysMutex.Unlock()
+	signingKeys = myKeys
+	keysLoadedAt = now
+	return nil
+}
+
+// replaces a key that was stored unencrypted, it stays usable if that fails
+func encryptStoredKey(myModel tkmodel.SigningKey, derBytes []byte) {
+	pemBytes, err := encryptPrivateKey(derBytes, myModel.Kid)
+	if err == nil {
+		myModel.PrivateKey = string(pemBytes)
+		err = signingKeyRepository.Save(&myModel)
+	}
+	if err != nil {
+		log.Printf("Signing key: %v encrypt failed: %v\n", myModel.Kid, err)
+		return
+	}
+	log.Printf("Signing key: %v encrypted\n", myModel.Kid)
+}
+
+// the newest activated key, the keys are ordered by activation
+func currentKey(myKeys []signingKey, now time.Time) (signingKey, bool) {
+	for index := len(myKeys) - 1; index >= 0; index-- {
+		if !myKeys[index].activatesAt.After(now) {
+			return myKeys[index], true
+		}
+	}
+	return signingKey{}, false
+}
+
+func signingMethod(algorithm tkmodel.SigningAlgorithm) jwt.SigningMethod {
+	if algorithm == tkmodel.RS256 {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodES256
+}
+
+func signToken(claims jwt.MapClaims) (string, error) {
+	keysMutex.RLock()
+	myKey, found := currentKey(signingKeys, time.Now())
+	keysMutex.RUnlock()
+	if !found {
+		return "", ErrNoSigningKey
+	}
+	myToken := jwt.NewWithClaims(signingMethod(myKey.algorithm), claims)
+	myToken.Header["kid"] = myKey.kid
+	return myToken.SignedString(myKey.privateKey)
+}
+
+// the public key of the kid for the algorithm of the token, unknown kids reload the keys at most every 10 seconds
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	myKey, found := findVerificationKey(kid)
+	keysMutex.RLock()
+	reloadAllowed := time.Since(keysLoadedAt) > 10*time.Second
+	keysMutex.RUnlock()
+	if !found && reloadAllowed {
+		if err := reloadKeys(); err != nil {
+			return nil, err
+		}
+		myKey, found = findVerificationKey(kid)
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown kid: %v", kid)
+	}
+	if token.Method.Alg() != string(myKey.algorithm) {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return myKey.privateKey.Public(), nil
+}
+
+func findVerificationKey(kid string) (signingKey, bool) {
+	now := time.Now()
+	retireAfter := time.Duration(config.ReadIntEnv("JWT_KEY_RETIRE_HOURS", 24)) * time.Hour
+	keysMutex.RLock()
+	defer keysMutex.RUnlock()
+	for _, myKey := range signingKeys {
+		if myKey.kid == kid && (myKey.supersededAt.IsZero() || now.Sub(myKey.supersededAt) <= retireAfter) {
+			return myKey, true
+		}
+	}
+	return signingKey{}, false
+}
+
+// the pending, active and superseded keys that are not retired
+func Jwks() JwkSet {
+	result := JwkSet{Keys: []Jwk{}}
+	keysMutex.RLock()
+	kids := []string{}
+	for _, myKey := range signingKeys {
+		kids = append(kids, myKey.kid)
+	}
+	keysMutex.RUnlock()
+	for _, kid := range kids {
+		myKey, found := findVerificationKey(kid)
+		if !found {
+			continue
+		}
+		myJwk := Jwk{Kid: myKey.kid, Use: "sig", Alg: string(myKey.algorithm)}
+		switch publicKey := myKey.privateKey.Public().(type) {
+		case *rsa.PublicKey:
+			myJwk.Kty = "RSA"
+			myJwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			myJwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case *ecdsa.PublicKey:
+			myJwk.Kty = "EC"
+			myJwk.Crv = publicKey.Curve.Params().Name
+			myJwk.X = base64.RawURLEncoding.EncodeToString(publicKey.X.FillBytes(make([]byte, 32)))
+			myJwk.Y = base64.RawURLEncoding.EncodeToString(publicKey.Y.FillBytes(make([]byte, 32)))
+		default:
+			continue
+		}
+		result.Keys = append(result.Keys, myJwk)
+	}
+	return result
+}
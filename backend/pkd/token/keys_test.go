@@ -0,0 +1,52 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package token
+
+import (
+	"encoding/pem"
+	"testing"
+)
+
+func TestCheckKeySecret(t *testing.T) {
+	tests := []struct {
+		secret string
+		want   error
+	}{
+		{"", ErrNoKeySecret},
+		{"   ", ErrNoKeySecret},
+		{"test-key-secret", ErrShortKeySecret},
+		{"test-key-secret-of-32-characters", nil},
+	}
+	for _, test := range tests {
+		t.Setenv("JWT_KEY_SECRET", test.secret)
+		if err := CheckKeySecret(); err != test.want {
+			t.Errorf("CheckKeySecret(%q) = %v, want %v", test.secret, err, test.want)
+		}
+	}
+}
+
+func TestEncryptPrivateKeyRoundTrip(t *testing.T) {
+	t.Setenv("JWT_KEY_SECRET", "test-key-secret-of-32-characters")
+	pemBytes, err := encryptPrivateKey([]byte("der bytes"), "kid1")
+	if err != nil {
+		t.Fatalf("encryptPrivateKey failed: %v", err)
+	}
+	pemBlock, _ := pem.Decode(pemBytes)
+	if derBytes, plain, err := decryptPrivateKey(pemBlock, "kid1"); err != nil || plain || string(derBytes) != "der bytes" {
+		t.Errorf("decryptPrivateKey: %q %v %v", derBytes, plain, err)
+	}
+	t.Setenv("JWT_KEY_SECRET", "short")
+	if _, err := encryptPrivateKey([]byte("der bytes"), "kid1"); err != ErrShortKeySecret {
+		t.Errorf("encrypted with a short secret: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package tkmodel
+
+import "time"
+
+type SigningAlgorithm string
+
+const (
+	RS256 SigningAlgorithm = "RS256"
+	ES256 SigningAlgorithm = "ES256"
+)
+
+// a key pair for the access tokens, a key signs from ActivatesAt until the next key activates and is published longer to verify its tokens
+type SigningKey struct {
+	Kid         string           `gorm:"primaryKey;size:64"`
+	Algorithm   SigningAlgorithm `gorm:"size:8;not null"`
+	PrivateKey  string           `gorm:"size:4096;not null"` // pem of the PKCS8 key encrypted with JWT_KEY_SECRET
+	CreatedAt   time.Time        `gorm:"not null"`
+	ActivatesAt time.Time        `gorm:"not null"`
+}
+
+func (SigningKey) TableName() string {
+	return "jwt_signing_key"
+}
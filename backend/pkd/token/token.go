@@ -13,7 +13,6 @@
 package token
 
 import (
-	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -30,54 +29,63 @@ type Role string
 const (
 	UserRole  Role = "USER"
 	GuestRole Role = "GUEST"
+	AdminRole Role = "ADMIN"
 )
 
 const (
-	HeaderAuth     = "Authorization"
-	HeaderBearer   = "Bearer"
-	JwtTokenSecret = "JWT_TOKEN_SECRET"
-	TokenAuth      = "auth"
-	TokenSub       = "sub"
-	TokenUuid      = "uuid"
-	TokenExp       = "exp"
-	TokenLastMsg   = "lastmsg"
+	HeaderAuth   = "Authorization"
+	HeaderBearer = "Bearer"
+	TokenAuth    = "auth"
+	TokenSub     = "sub"
+	TokenUuid    = "uuid"
+	TokenExp     = "exp"
+	TokenLastMsg = "lastmsg"
 )
 
 type TokenUser struct {
-	Username string
-	Roles    []string
+	Username  string
+	Roles     []string
+	SessionId string // the uuid claim, a random uuid if empty
 }
 
-type LoggedOutUserOut struct {
-	Username   string
-	Uuid       string
-	LastLogout time.Time
-}
+// checks that the session of a token is not revoked, set at startup
+var sessionCheck func(username string, sessionId string) bool
 
-var LoggedOutUsers []LoggedOutUserOut
+func SetSessionCheck(mySessionCheck func(username string, sessionId string) bool) {
+	sessionCheck = mySessionCheck
+}
 
+// the token has the GUEST role and the roles of the user, USER if none are given
 func CreateToken(tokenUser TokenUser) (string, error) {
 	roles := []string{string(GuestRole)}
-	var myUuid uuid.UUID
-	if myUuid1, err := uuid.NewRandom(); err != nil {
-		log.Printf("Uuid creation failed: %v\n", err.Error())
-		return "", err
-	} else {
-		myUuid = myUuid1
+	myUuid := tokenUser.SessionId
+	if len(myUuid) == 0 {
+		myUuid1, err := uuid.NewRandom()
+		if err != nil {
+			log.Printf("Uuid creation failed: %v\n", err.Error())
+			return "", err
+		}
+		myUuid = myUuid1.String()
+	}
+	for _, role := range tokenUser.Roles {
+		if role != string(GuestRole) {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) < 2 {
 		roles = append(roles, string(UserRole))
 	}
 	tokenTtl := 60
 	if len(strings.TrimSpace(os.Getenv("MSG_MESSAGES"))) >= 3 {
 		tokenTtl = tokenTtl * 10
 	}
-	myToken := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+	result, err := signToken(jwt.MapClaims{
 		TokenSub:     tokenUser.Username,
-		TokenUuid:    myUuid.String(),
+		TokenUuid:    myUuid,
 		TokenAuth:    strings.Join(roles[:], ","),
 		TokenLastMsg: time.Now().Unix(),
 		TokenExp:     time.Now().Add(time.Second * time.Duration(tokenTtl)).Unix(),
 	})
-	result, err := myToken.SignedString([]byte(os.Getenv(JwtTokenSecret)))
 	return result, err
 }
 
@@ -90,12 +98,7 @@ func CheckToken(c *gin.Context) {
 		c.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
-	token, err := jwt.Parse(tokenStrs[1], func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv(JwtTokenSecret)), nil
-	})
+	token, err := jwt.Parse(tokenStrs[1], verificationKey)
 
 	if err != nil {
 		log.Printf("Token error: %v\n", err.Error())
@@ -118,20 +121,38 @@ func CheckToken(c *gin.Context) {
 		c.AbortWithStatus(http.StatusUnauthorized)
 	}
 
-	if len(username) > 3 && len(uuid) > 10 && LoggedOutUser(username, uuid) {
-		log.Printf("User logged out.\n")
+	if len(username) > 3 && len(uuid) > 10 && sessionCheck != nil && !sessionCheck(username, uuid) {
+		log.Printf("Session revoked.\n")
 		c.AbortWithStatus(http.StatusUnauthorized)
 	}
 
 	c.Next()
 }
 
-func LoggedOutUser(username string, uuid string) bool {
-	result := false
-	for _, myLoggedOutUser := range LoggedOutUsers {
-		if myLoggedOutUser.Username == username && myLoggedOutUser.Uuid == uuid {
-			result = true
+// has to be added after CheckToken
+func RequireRole(role Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasRole(c, role) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+func HasRole(c *gin.Context, role Role) bool {
+	roles, exists := c.Get("roles")
+	if !exists {
+		return false
+	}
+	rolesStr, ok := roles.(string)
+	if !ok {
+		return false
+	}
+	for _, roleStr := range strings.Split(rolesStr, ",") {
+		if Role(strings.TrimSpace(roleStr)) == role {
+			return true
 		}
 	}
-	return result
+	return false
 }
@@ -31,6 +31,7 @@ export interface UserRequest {
 
 export interface UserResponse {
   Token?: string
+  RefreshToken?: string
   Message?: string
   Uuid?: string
   Longitude?: number
@@ -43,6 +44,7 @@ export interface UserResponse {
 
 interface MsgData {
   jwtToken?: string;
+  refreshToken?: string;
   newNotificationUrl?: string;
 }
 
@@ -141,12 +143,12 @@ const LoginModal = () => {
             GlobalState.jwtToken = event.data.Token;
           }
         });
-        worker.postMessage({ jwtToken: userResponse.Token, newNotificationUrl: `/usernotification/new/${userResponse.Uuid}` } as MsgData);
+        worker.postMessage({ jwtToken: userResponse.Token, refreshToken: userResponse.RefreshToken, newNotificationUrl: `/usernotification/new/${userResponse.Uuid}` } as MsgData);
         setGlobalWebWorkerRefState(worker);
         result = worker;
       }
     } else {
-      globalWebWorkerRefState.postMessage({ jwtToken: userResponse.Token, newNotificationUrl: `/usernotification/new/${userResponse.Uuid}` } as MsgData);
+      globalWebWorkerRefState.postMessage({ jwtToken: userResponse.Token, refreshToken: userResponse.RefreshToken, newNotificationUrl: `/usernotification/new/${userResponse.Uuid}` } as MsgData);
       result = globalWebWorkerRefState;
     }
     return result;
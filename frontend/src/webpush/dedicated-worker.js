@@ -1,25 +1,30 @@
 let jwtToken = '';
+let nextRefreshToken = '';
 let tokenIntervalRef;
-const refreshToken = (myToken) => {
+const refreshToken = (myToken, myRefreshToken) => {
     if (!!tokenIntervalRef) {
         clearInterval(tokenIntervalRef);
     }
     jwtToken = myToken;
-    if (!!jwtToken && jwtToken.length > 10) {
+    nextRefreshToken = myRefreshToken;
+    if (!!jwtToken && jwtToken.length > 10 && !!nextRefreshToken) {
         tokenIntervalRef = setInterval(() => {
             const requestOptions = {
-                method: 'GET',
-                headers: { 'Content-Type': 'application/json', 'Authorization': `Bearer ${jwtToken}` },
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ RefreshToken: nextRefreshToken }),
             };
-            fetch('/appuser/refreshtoken', requestOptions).then(response => response.json()).then(result => {
-                if ((!result.Message && !!result.Token && result.Token.length > 10)) {
+            fetch('/appuser/token/refresh', requestOptions).then(response => response.json()).then(result => {
+                if ((!result.Message && !!result.Token && result.Token.length > 10 && !!result.RefreshToken)) {
                     //console.log('Token refreshed.');
                     jwtToken = result.Token;
+                    nextRefreshToken = result.RefreshToken;
                     /* eslint-disable-next-line no-restricted-globals */
                     self.postMessage(result);
                 }
                 else {
                     jwtToken = '';
+                    nextRefreshToken = '';
                     clearInterval(tokenIntervalRef);
                 }
             });
@@ -31,7 +36,7 @@ let notificationIntervalRef;
 /* eslint-disable-next-line no-restricted-globals */
 self.addEventListener('message', (event) => {
     const msgData = event.data;
-    refreshToken(msgData.jwtToken);
+    refreshToken(msgData.jwtToken, msgData.refreshToken);
     if (!!notificationIntervalRef) {
         clearInterval(notificationIntervalRef);
     }
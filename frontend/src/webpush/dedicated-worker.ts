@@ -17,35 +17,42 @@ export { };
 
 interface MsgData {
   jwtToken: string;
+  refreshToken: string;
   newNotificationUrl: string;
 }
 
 interface UserResponse {
   Token?: string
+  RefreshToken?: string
   Message?: string
 }
 
 let jwtToken = '';
+let nextRefreshToken = '';
 let tokenIntervalRef: ReturnType<typeof setInterval>;
-const refreshToken = (myToken: string) => {
+const refreshToken = (myToken: string, myRefreshToken: string) => {
   if (!!tokenIntervalRef) {
     clearInterval(tokenIntervalRef);
   }
   jwtToken = myToken;
-  if (!!jwtToken && jwtToken.length > 10) {
+  nextRefreshToken = myRefreshToken;
+  if (!!jwtToken && jwtToken.length > 10 && !!nextRefreshToken) {
     tokenIntervalRef = setInterval(() => {
       const requestOptions = {
-        method: 'GET',
-        headers: { 'Content-Type': 'application/json', 'Authorization': `Bearer ${jwtToken}` },
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ RefreshToken: nextRefreshToken }),
       };
-      fetch('/appuser/refreshtoken', requestOptions).then(response => response.json() as UserResponse).then(result => {
-        if ((!result.Message && !!result.Token && result.Token.length > 10)) {
+      fetch('/appuser/token/refresh', requestOptions).then(response => response.json() as UserResponse).then(result => {
+        if ((!result.Message && !!result.Token && result.Token.length > 10 && !!result.RefreshToken)) {
           //console.log('Token refreshed.');
           jwtToken = result.Token;
+          nextRefreshToken = result.RefreshToken;
           /* eslint-disable-next-line no-restricted-globals */
           self.postMessage(result);
         } else {
           jwtToken = '';
+          nextRefreshToken = '';
           clearInterval(tokenIntervalRef);
         }
       });
@@ -58,7 +65,7 @@ let notificationIntervalRef: ReturnType<typeof setInterval>;
 /* eslint-disable-next-line no-restricted-globals */
 self.addEventListener('message', (event: MessageEvent) => {
   const msgData = event.data as MsgData;
-  refreshToken(msgData.jwtToken);  
+  refreshToken(msgData.jwtToken, msgData.refreshToken);  
   if (!!notificationIntervalRef) {
     clearInterval(notificationIntervalRef);
   }